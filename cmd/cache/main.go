@@ -71,6 +71,9 @@ func main() {
 	// Custom error handler - centralized error logging and response formatting
 	e.HTTPErrorHandler = api.CustomErrorHandler
 
+	// Keep integers as int64 when decoding request bodies
+	e.JSONSerializer = api.JSONSerializer{}
+
 	// Recover middleware - MUST be first to catch panics from all other middleware
 	e.Use(middleware.Recover())
 
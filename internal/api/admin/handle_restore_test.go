@@ -336,7 +336,7 @@ func TestHandleRestore_WithTriggers(t *testing.T) {
 	val, err := cache.Get(ctx, "counter")
 	cache.Release("test")
 	assert.NoError(t, err)
-	assert.Equal(t, float64(0), val)
+	assert.Equal(t, int64(0), val)
 
 	// Cleanup
 	caches.DeleteCache("default")
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// JSONSerializer decodes request bodies with caches.DecodeJSON, so integers
+// stay int64 instead of becoming float64, and {"$decimal": "..."} values
+// become decimals. Encoding is echo's default.
+type JSONSerializer struct {
+	echo.DefaultJSONSerializer
+}
+
+// Deserialize reads a JSON request body into i, reporting bad JSON as 400
+// like echo's default serializer.
+func (JSONSerializer) Deserialize(c echo.Context, i any) error {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	err = caches.DecodeJSON(body, i)
+	if ute, ok := err.(*json.UnmarshalTypeError); ok {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Unmarshal type error: expected=%v, got=%v, field=%v, offset=%v", ute.Type, ute.Value, ute.Field, ute.Offset)).SetInternal(err)
+	} else if se, ok := err.(*json.SyntaxError); ok {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Syntax error: offset=%v, error=%v", se.Offset, se.Error())).SetInternal(err)
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	return nil
+}
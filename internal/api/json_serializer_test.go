@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSerializer(t *testing.T) {
+	e := echo.New()
+	e.JSONSerializer = JSONSerializer{}
+
+	bind := func(body string) (map[string]any, error) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c := e.NewContext(req, httptest.NewRecorder())
+		var v map[string]any
+		err := c.Bind(&v)
+		return v, err
+	}
+
+	v, err := bind(`{"count": 9007199254740993, "ratio": 0.5, "price": {"$decimal": "4.20"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), v["count"])
+	assert.Equal(t, 0.5, v["ratio"])
+	assert.Equal(t, "4.20", v["price"].(caches.Decimal).String())
+
+	var he *echo.HTTPError
+	_, err = bind(`{"count": `)
+	require.ErrorAs(t, err, &he)
+	assert.Equal(t, http.StatusBadRequest, he.Code)
+
+	_, err = bind(`{"price": {"$decimal": "four"}}`)
+	require.ErrorAs(t, err, &he)
+	assert.Equal(t, http.StatusBadRequest, he.Code)
+}
@@ -13,6 +13,9 @@ import (
 type createCacheRequest struct {
 	Name string `json:"name,required"`
 	TTL  *int64 `json:"ttl,omitempty"` // millisecond
+
+	// Limits bounds the work each command execution on the cache may do.
+	Limits *caches.ExecutionLimits `json:"limits,omitempty"`
 }
 
 func (req createCacheRequest) Validate() error {
@@ -20,6 +23,10 @@ func (req createCacheRequest) Validate() error {
 		return errors.New("cache name is required")
 	}
 
+	if req.Limits != nil {
+		return req.Limits.Validate()
+	}
+
 	return nil
 }
 
@@ -47,6 +54,12 @@ func handleCreateCache() echo.HandlerFunc {
 			}
 		}
 
+		if req.Limits != nil {
+			if err := caches.SetCacheLimits(req.Name, *req.Limits); err != nil {
+				log.FromContext(c.Request().Context()).WithError(err).With("cache", req.Name).Error("could not set cache limits")
+			}
+		}
+
 		return c.NoContent(http.StatusCreated)
 	}
 }
@@ -0,0 +1,46 @@
+package caches
+
+import (
+	"net/http"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// handleGetLimits returns the execution limits of a cache.
+func handleGetLimits() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limits, err := caches.CacheLimits(c.Param("name"))
+		if err != nil {
+			return limitsError(err)
+		}
+		return c.JSON(http.StatusOK, limits)
+	}
+}
+
+// handleSetLimits replaces the execution limits of a cache. Omitted limits
+// are removed.
+func handleSetLimits() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input caches.ExecutionLimits
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid json payload").SetInternal(err)
+		}
+
+		if err := caches.SetCacheLimits(c.Param("name"), input); err != nil {
+			return limitsError(err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func limitsError(err error) error {
+	switch {
+	case errors.Is(err, caches.ErrCacheNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "cache not found").SetInternal(err)
+	case errors.Is(err, caches.ErrInvalidExecutionLimits):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "failed to update cache limits").SetInternal(err)
+}
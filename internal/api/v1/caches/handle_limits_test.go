@@ -0,0 +1,74 @@
+package caches
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleLimits(t *testing.T) {
+	e := echo.New()
+
+	require.NoError(t, caches.AddCache("test-limits-cache"))
+	defer caches.DeleteCache("test-limits-cache")
+
+	// Set limits
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/caches/test-limits-cache/limits", strings.NewReader(`{"max_steps": 100, "max_writes": 10}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("name")
+	c.SetParamValues("test-limits-cache")
+
+	require.NoError(t, handleSetLimits()(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	// Get them back
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/caches/test-limits-cache/limits", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames("name")
+	c.SetParamValues("test-limits-cache")
+
+	require.NoError(t, handleGetLimits()(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"max_steps": 100, "max_writes": 10}`, rec.Body.String())
+}
+
+func TestHandleLimits_Errors(t *testing.T) {
+	e := echo.New()
+
+	require.NoError(t, caches.AddCache("test-limits-cache-2"))
+	defer caches.DeleteCache("test-limits-cache-2")
+
+	tests := []struct {
+		name  string
+		cache string
+		body  string
+		code  int
+	}{
+		{"negative limit", "test-limits-cache-2", `{"max_depth": -1}`, http.StatusBadRequest},
+		{"missing cache", "no-such-cache", `{"max_depth": 5}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/caches/"+tt.cache+"/limits", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("name")
+			c.SetParamValues(tt.cache)
+
+			err := handleSetLimits()(c)
+			var httpErr *echo.HTTPError
+			require.ErrorAs(t, err, &httpErr)
+			assert.Equal(t, tt.code, httpErr.Code)
+		})
+	}
+}
@@ -15,6 +15,7 @@ type LongOperationHistory struct {
 	Operation  string    `json:"operation"` // e.g., "POST /keys", "POST /commands"
 	Success    bool      `json:"success"`
 	TimedOut   bool      `json:"timed_out"`
+	JobId      string    `json:"job_id,omitempty"` // set for background command jobs
 }
 
 // CacheStats represents statistics for a single cache
@@ -70,6 +71,7 @@ func handleGetStats() echo.HandlerFunc {
 						Operation:  exec.Operation,
 						Success:    exec.Success,
 						TimedOut:   exec.TimedOut,
+						JobId:      exec.JobId,
 					}
 				}
 				stats.LongOperations = history
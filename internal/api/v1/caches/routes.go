@@ -17,6 +17,10 @@ func SetupRoutes(group *echo.Group) {
 	// Update cache expiration
 	gCaches.PUT("/:name", handleUpdateCache())
 
+	// Get and set cache execution limits
+	gCaches.GET("/:name/limits", handleGetLimits())
+	gCaches.PUT("/:name/limits", handleSetLimits())
+
 	// Delete a cache
 	gCaches.DELETE("/:name", handleDeleteCache())
 }
@@ -1,6 +1,9 @@
 package commands
 
 import (
+	"context"
+	"net/http"
+
 	"github.com/goodblaster/map-cache/pkg/caches"
 	"github.com/labstack/echo/v4"
 )
@@ -18,3 +21,34 @@ func Cache(c echo.Context) *caches.Cache {
 
 	return cache
 }
+
+// linkCaches locks the other caches the commands use with @name: keys, so
+// that they execute atomically across all of them, and returns a context
+// carrying them and a function releasing them.
+//
+// The request's own cache is already held by cacheMW. It is released and
+// re-acquired together with the others in name order, so that requests
+// locking overlapping caches cannot deadlock.
+func linkCaches(c echo.Context, ctx context.Context, names []string) (context.Context, func(), error) {
+	cache := Cache(c)
+	cacheName, _ := c.Get("cache_name").(string)
+	tag, _ := c.Get("request_id").(string)
+
+	cache.Release(tag)
+	locked := caches.AcquireCaches(tag, append(names, cacheName)...)
+	if locked[cacheName] != cache {
+		// The request's cache was deleted or replaced meanwhile
+		caches.ReleaseCaches(tag, locked)
+		cache.Acquire(tag)
+		return nil, nil, echo.NewHTTPError(http.StatusConflict, "cache was replaced during the request")
+	}
+
+	release := func() {
+		for name, other := range locked {
+			if name != cacheName {
+				other.Release(tag)
+			}
+		}
+	}
+	return caches.WithCaches(ctx, locked), release, nil
+}
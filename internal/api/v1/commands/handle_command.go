@@ -2,7 +2,9 @@ package commands
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/goodblaster/errors"
@@ -13,19 +15,60 @@ import (
 
 type commandRequest struct {
 	Commands []caches.RawCommand `json:"commands"`
+
+	// Script is the same commands in text form, instead of Commands.
+	Script string `json:"script"`
+
+	// DryRun executes against a throwaway view of the cache and returns a
+	// caches.DryRunResult instead of the result; the cache is not changed.
+	DryRun bool `json:"dry_run"`
+
+	// Limits lowers the cache's execution limits for this request.
+	Limits *caches.ExecutionLimits `json:"limits"`
 }
 
 func (req commandRequest) Validate() error {
-	if len(req.Commands) == 0 {
+	if len(req.Commands) == 0 && strings.TrimSpace(req.Script) == "" {
 		return errors.New("at least one command is required")
 	}
+	if len(req.Commands) > 0 && req.Script != "" {
+		return errors.New("commands and script cannot both be set")
+	}
+	if req.Limits != nil {
+		return req.Limits.Validate()
+	}
 	return nil
 }
 
+// bindCommandRequest reads a JSON request, or a text/plain body as a script.
+func bindCommandRequest(c echo.Context, input *commandRequest) error {
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMETextPlain) {
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		input.Script = string(body)
+		return nil
+	}
+	return c.Bind(input)
+}
+
+// commands returns the request's commands, parsing the script if there is one.
+func (req commandRequest) commands() ([]caches.Command, error) {
+	if req.Script != "" {
+		return caches.ParseScript(req.Script)
+	}
+	var cmds []caches.Command
+	for _, rawCommand := range req.Commands {
+		cmds = append(cmds, rawCommand.Command)
+	}
+	return cmds, nil
+}
+
 func handleCommand() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		var input commandRequest
-		if err := c.Bind(&input); err != nil {
+		if err := bindCommandRequest(c, &input); err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, "invalid json payload").SetInternal(err)
 		}
 
@@ -34,16 +77,36 @@ func handleCommand() echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusBadRequest, "validation failed").SetInternal(err)
 		}
 
-		var cmds []caches.Command
-		for _, rawCommand := range input.Commands {
-			cmds = append(cmds, rawCommand.Command)
+		cmds, err := input.commands()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
 		}
 
 		// Create context with timeout
 		ctx, cancel := context.WithTimeout(c.Request().Context(), time.Duration(config.CommandTimeoutMs)*time.Millisecond)
 		defer cancel()
+		if input.Limits != nil {
+			ctx = caches.WithExecutionLimits(ctx, *input.Limits)
+		}
 
 		cache := Cache(c)
+		if names := cache.ReferencedCaches(cmds...); len(names) > 0 {
+			linkedCtx, release, err := linkCaches(c, ctx, names)
+			if err != nil {
+				return err
+			}
+			defer release()
+			ctx = linkedCtx
+		}
+
+		if input.DryRun {
+			report := cache.DryRun(ctx, cmds...)
+			if ctx.Err() == context.DeadlineExceeded {
+				return echo.NewHTTPError(http.StatusRequestTimeout, "command execution timed out")
+			}
+			return c.JSON(http.StatusOK, report)
+		}
+
 		result := cache.Execute(ctx, cmds...)
 
 		// Check if execution timed out
@@ -51,6 +114,11 @@ func handleCommand() echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusRequestTimeout, "command execution timed out")
 		}
 
+		var limitErr *caches.LimitError
+		if errors.As(result.Error, &limitErr) {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, limitErr.Error()).SetInternal(result.Error)
+		}
+
 		if result.Error != nil {
 			// Generic message to user, full error preserved for logging
 			return echo.NewHTTPError(http.StatusInternalServerError, "command execution failed").SetInternal(result.Error)
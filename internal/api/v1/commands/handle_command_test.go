@@ -176,4 +176,203 @@ func TestHandleCommand(t *testing.T) {
 		assert.Equal(t, float64(7), x)
 		assert.Equal(t, float64(4), y)
 	})
+
+	t.Run("dry run", func(t *testing.T) {
+		cache := caches.New()
+		ctx := context.Background()
+
+		cache.Acquire("test")
+		err := cache.Create(ctx, map[string]any{"counter": float64(1)})
+		cache.Release("test")
+		require.NoError(t, err)
+
+		reqBody := map[string]any{
+			"dry_run": true,
+			"commands": []map[string]any{
+				{"type": "INC", "key": "counter", "value": 2},
+			},
+		}
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/commands/execute", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("cache", cache)
+
+		h := handleCommand()
+		if assert.NoError(t, h(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var report caches.DryRunResult
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+			assert.Equal(t, []any{float64(3)}, report.Value)
+			if assert.Len(t, report.Writes, 1) {
+				assert.Equal(t, "counter", report.Writes[0].Key)
+				assert.Equal(t, float64(1), report.Writes[0].OldValue)
+				assert.Equal(t, float64(3), report.Writes[0].NewValue)
+			}
+			if assert.Len(t, report.Trace, 1) {
+				assert.Equal(t, caches.CommandTypeInc, report.Trace[0].Type)
+			}
+		}
+
+		// The cache was not changed
+		cache.Acquire("test")
+		val, err := cache.Get(ctx, "counter")
+		cache.Release("test")
+		assert.NoError(t, err)
+		assert.Equal(t, float64(1), val)
+	})
+
+	t.Run("script", func(t *testing.T) {
+		cache := caches.New()
+		ctx := context.Background()
+
+		cache.Acquire("test")
+		err := cache.Create(ctx, map[string]any{"counter": float64(1)})
+		cache.Release("test")
+		require.NoError(t, err)
+
+		body, _ := json.Marshal(map[string]any{"script": "inc counter 2\nreturn = ${{counter}} * 10"})
+		req := httptest.NewRequest(http.MethodPost, "/commands/execute", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("cache", cache)
+
+		h := handleCommand()
+		if assert.NoError(t, h(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, `[3, 30]`, rec.Body.String())
+		}
+	})
+
+	t.Run("text/plain script", func(t *testing.T) {
+		cache := caches.New()
+		ctx := context.Background()
+
+		cache.Acquire("test")
+		err := cache.Create(ctx, map[string]any{"counter": float64(1)})
+		cache.Release("test")
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/commands/execute", bytes.NewReader([]byte("inc counter 4")))
+		req.Header.Set(echo.HeaderContentType, echo.MIMETextPlain)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("cache", cache)
+
+		h := handleCommand()
+		if assert.NoError(t, h(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+
+		cache.Acquire("test")
+		val, err := cache.Get(ctx, "counter")
+		cache.Release("test")
+		assert.NoError(t, err)
+		assert.Equal(t, float64(5), val)
+	})
+
+	t.Run("script syntax error", func(t *testing.T) {
+		cache := caches.New()
+
+		req := httptest.NewRequest(http.MethodPost, "/commands/execute", bytes.NewReader([]byte("noop\nbogus key")))
+		req.Header.Set(echo.HeaderContentType, echo.MIMETextPlain)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("cache", cache)
+
+		h := handleCommand()
+		err := h(c)
+		var httpErr *echo.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+		assert.Contains(t, httpErr.Message, "line 2, column 1")
+	})
+
+	t.Run("request limits", func(t *testing.T) {
+		cache := caches.New()
+		ctx := context.Background()
+
+		cache.Acquire("test")
+		err := cache.Create(ctx, map[string]any{"counter": float64(0)})
+		cache.Release("test")
+		require.NoError(t, err)
+
+		body, _ := json.Marshal(map[string]any{
+			"script": "for range 0 10 { inc counter }",
+			"limits": map[string]any{"max_writes": 3},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/commands/execute", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("cache", cache)
+
+		h := handleCommand()
+		err = h(c)
+		var httpErr *echo.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusUnprocessableEntity, httpErr.Code)
+		assert.Equal(t, "execution limit exceeded: max_writes is 3", httpErr.Message)
+	})
+}
+
+func TestHandleCommand_CrossCache(t *testing.T) {
+	e := echo.New()
+	ctx := context.Background()
+
+	require.NoError(t, caches.AddCache("test-xcache-jobs"))
+	require.NoError(t, caches.AddCache("test-xcache-workers"))
+	defer caches.DeleteCache("test-xcache-jobs")
+	defer caches.DeleteCache("test-xcache-workers")
+
+	jobs, _ := caches.FetchCache("test-xcache-jobs")
+	workers, _ := caches.FetchCache("test-xcache-workers")
+	require.NoError(t, jobs.Create(ctx, map[string]any{"pending": float64(1)}))
+	require.NoError(t, workers.Create(ctx, map[string]any{"busy": float64(0)}))
+
+	body, _ := json.Marshal(map[string]any{
+		"script": "inc pending -1\ninc @test-xcache-workers:busy\nreturn = ${{pending}} + ${{@test-xcache-workers:busy}}",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/commands/execute", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-Cache-Name", "test-xcache-jobs")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	require.NoError(t, cacheMW(handleCommand())(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[0, 1, 1]`, rec.Body.String())
+
+	// Both caches were released
+	jobs.Acquire("test")
+	jobs.Release("test")
+	workers.Acquire("test")
+	busy, err := workers.Get(ctx, "busy")
+	workers.Release("test")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), busy)
+}
+
+func TestHandleFormat(t *testing.T) {
+	e := echo.New()
+
+	body, _ := json.Marshal(map[string]any{
+		"commands": []map[string]any{
+			{"type": "INC", "key": "counter", "value": 2},
+			{"type": "IF", "condition": "${{counter}} > 2", "if_true": map[string]any{"type": "DELETE", "key": "counter"}, "if_false": map[string]any{"type": "NOOP"}},
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/commands/format", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	h := handleFormat()
+	if assert.NoError(t, h(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "inc counter 2\nif ${{counter}} > 2 {\n  delete counter\n}\n", rec.Body.String())
+	}
 }
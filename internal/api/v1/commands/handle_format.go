@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"net/http"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// handleFormat returns the request's commands pretty-printed as a script.
+// It accepts the same JSON or text/plain bodies as /commands/execute.
+func handleFormat() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input commandRequest
+		if err := bindCommandRequest(c, &input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid json payload").SetInternal(err)
+		}
+
+		if err := input.Validate(); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "validation failed").SetInternal(err)
+		}
+
+		cmds, err := input.commands()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+		}
+
+		return c.String(http.StatusOK, caches.FormatScript(cmds...))
+	}
+}
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"net/http"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// handleSubmitJob queues commands for background execution. It does not wait
+// for the cache lock, so it is not routed through cacheMW.
+func handleSubmitJob() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input commandRequest
+		if err := bindCommandRequest(c, &input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid json payload").SetInternal(err)
+		}
+
+		if err := input.Validate(); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "validation failed").SetInternal(err)
+		}
+		if input.DryRun {
+			return echo.NewHTTPError(http.StatusBadRequest, "dry_run is not supported for jobs")
+		}
+
+		cmds, err := input.commands()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+		}
+
+		ctx := c.Request().Context()
+		if input.Limits != nil {
+			ctx = caches.WithExecutionLimits(ctx, *input.Limits)
+		}
+
+		job, err := caches.SubmitJob(ctx, jobCacheName(c), cmds...)
+		if errors.Is(err, caches.ErrCacheNotFound) {
+			return echo.NewHTTPError(http.StatusFailedDependency, "cache not found").SetInternal(err)
+		}
+		if errors.Is(err, caches.ErrTooManyJobs) {
+			return echo.NewHTTPError(http.StatusTooManyRequests, err.Error()).SetInternal(err)
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to submit job").SetInternal(err)
+		}
+
+		c.Response().Header().Set(echo.HeaderLocation, c.Request().URL.Path+"/"+job.Id)
+		return c.JSON(http.StatusAccepted, job)
+	}
+}
+
+func handleGetJob() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		job, err := caches.GetJob(jobCacheName(c), c.Param("id"))
+		if err != nil {
+			return jobError(err)
+		}
+		return c.JSON(http.StatusOK, job)
+	}
+}
+
+func handleCancelJob() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		job, err := caches.CancelJob(jobCacheName(c), c.Param("id"))
+		if err != nil {
+			return jobError(err)
+		}
+		return c.JSON(http.StatusOK, job)
+	}
+}
+
+// jobCacheName returns the cache a job request is for. Jobs belong to their
+// cache, so polling and canceling take the same X-Cache-Name as submitting.
+func jobCacheName(c echo.Context) string {
+	if name := c.Request().Header.Get("X-Cache-Name"); name != "" {
+		return name
+	}
+	return caches.DefaultName
+}
+
+func jobError(err error) error {
+	switch {
+	case errors.Is(err, caches.ErrJobNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "job not found").SetInternal(err)
+	case errors.Is(err, caches.ErrCacheNotFound):
+		return echo.NewHTTPError(http.StatusFailedDependency, "cache not found").SetInternal(err)
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "job lookup failed").SetInternal(err)
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleJobs(t *testing.T) {
+	e := echo.New()
+	ctx := context.Background()
+
+	require.NoError(t, caches.AddCache("test-jobs"))
+	defer caches.DeleteCache("test-jobs")
+	cache, _ := caches.FetchCache("test-jobs")
+	require.NoError(t, cache.Create(ctx, map[string]any{"counter": float64(0)}))
+
+	getJob := func(t *testing.T, method, id string) (*httptest.ResponseRecorder, caches.Job) {
+		req := httptest.NewRequest(method, "/commands/jobs/"+id, nil)
+		req.Header.Set("X-Cache-Name", "test-jobs")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+
+		h := handleGetJob()
+		if method == http.MethodDelete {
+			h = handleCancelJob()
+		}
+		require.NoError(t, h(c))
+
+		var job caches.Job
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+		return rec, job
+	}
+
+	t.Run("submit and poll", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/commands/jobs", bytes.NewReader([]byte("inc counter 5\nget counter")))
+		req.Header.Set(echo.HeaderContentType, echo.MIMETextPlain)
+		req.Header.Set("X-Cache-Name", "test-jobs")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		require.NoError(t, handleSubmitJob()(c))
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+
+		var job caches.Job
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+		assert.Equal(t, "test-jobs", job.Cache)
+		assert.Equal(t, "/commands/jobs/"+job.Id, rec.Header().Get(echo.HeaderLocation))
+
+		require.Eventually(t, func() bool {
+			_, job = getJob(t, http.MethodGet, job.Id)
+			return job.Finished()
+		}, 5*time.Second, time.Millisecond)
+		assert.Equal(t, caches.JobSucceeded, job.Status)
+		assert.Equal(t, []any{float64(5), float64(5)}, job.Result)
+	})
+
+	t.Run("cancel queued", func(t *testing.T) {
+		cache.Acquire("test")
+		defer cache.Release("test")
+
+		// Submitting doesn't wait for the lock
+		body, _ := json.Marshal(map[string]any{"script": "inc counter"})
+		req := httptest.NewRequest(http.MethodPost, "/commands/jobs", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-Cache-Name", "test-jobs")
+		rec := httptest.NewRecorder()
+		require.NoError(t, handleSubmitJob()(e.NewContext(req, rec)))
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+
+		var job caches.Job
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+		assert.Equal(t, caches.JobQueued, job.Status)
+
+		rec, job = getJob(t, http.MethodDelete, job.Id)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, caches.JobCanceled, job.Status)
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/commands/jobs/nope", nil)
+		req.Header.Set("X-Cache-Name", "test-jobs")
+		c := e.NewContext(req, httptest.NewRecorder())
+		c.SetParamNames("id")
+		c.SetParamValues("nope")
+
+		err := handleGetJob()(c)
+		var he *echo.HTTPError
+		require.ErrorAs(t, err, &he)
+		assert.Equal(t, http.StatusNotFound, he.Code)
+	})
+
+	t.Run("unknown cache", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]any{"script": "inc counter"})
+		req := httptest.NewRequest(http.MethodPost, "/commands/jobs", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-Cache-Name", "test-jobs-missing")
+
+		err := handleSubmitJob()(e.NewContext(req, httptest.NewRecorder()))
+		var he *echo.HTTPError
+		require.ErrorAs(t, err, &he)
+		assert.Equal(t, http.StatusFailedDependency, he.Code)
+	})
+
+	t.Run("too many jobs", func(t *testing.T) {
+		defer func(n int) { config.JobMaxActive = n }(config.JobMaxActive)
+		config.JobMaxActive = 1
+
+		cache.Acquire("test")
+		defer cache.Release("test")
+
+		submit := func() error {
+			req := httptest.NewRequest(http.MethodPost, "/commands/jobs", bytes.NewReader([]byte("inc counter")))
+			req.Header.Set(echo.HeaderContentType, echo.MIMETextPlain)
+			req.Header.Set("X-Cache-Name", "test-jobs")
+			rec := httptest.NewRecorder()
+			return handleSubmitJob()(e.NewContext(req, rec))
+		}
+		require.NoError(t, submit())
+
+		var he *echo.HTTPError
+		require.ErrorAs(t, submit(), &he)
+		assert.Equal(t, http.StatusTooManyRequests, he.Code)
+	})
+
+	t.Run("dry run rejected", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]any{"script": "inc counter", "dry_run": true})
+		req := httptest.NewRequest(http.MethodPost, "/commands/jobs", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-Cache-Name", "test-jobs")
+
+		err := handleSubmitJob()(e.NewContext(req, httptest.NewRecorder()))
+		var he *echo.HTTPError
+		require.ErrorAs(t, err, &he)
+		assert.Equal(t, http.StatusBadRequest, he.Code)
+	})
+}
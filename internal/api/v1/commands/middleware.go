@@ -32,6 +32,7 @@ func cacheMW(next echo.HandlerFunc) echo.HandlerFunc {
 
 		// Set the cache in the context
 		c.Set("cache", cache)
+		c.Set("cache_name", cacheName)
 		return next(c)
 	}
 }
@@ -1,10 +1,22 @@
 package commands
 
-import "github.com/labstack/echo/v4"
+import (
+	"github.com/goodblaster/map-cache/internal/api/v1/idempotency"
+	"github.com/labstack/echo/v4"
+)
 
 func SetupRoutes(group *echo.Group) {
 	gCaches := group.Group("/commands", cacheMW)
 
 	// Execute command(s).
-	gCaches.POST("/execute", handleCommand())
+	gCaches.POST("/execute", handleCommand(), idempotency.Middleware)
+
+	// Background jobs. These don't hold the cache lock, so they are not
+	// routed through cacheMW.
+	group.POST("/commands/jobs", handleSubmitJob())
+	group.GET("/commands/jobs/:id", handleGetJob())
+	group.DELETE("/commands/jobs/:id", handleCancelJob())
+
+	// Pretty-print command(s) as a script.
+	group.POST("/commands/format", handleFormat())
 }
@@ -0,0 +1,103 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderIdempotencyKey names the request header holding an idempotency key.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// HeaderIdempotentReplayed is set on a response replayed for a retry.
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+// MaxKeyLength is the longest idempotency key accepted.
+const MaxKeyLength = 255
+
+// Middleware makes a route idempotent for requests with an Idempotency-Key
+// header: the first successful response is kept by the request's cache, and
+// retries with the same key and request get it again without running the
+// handler. It must run after a middleware that acquires the cache and sets it
+// as "cache".
+//
+// Only 2xx responses are kept, so a failed request can be retried with the
+// same key.
+func Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(HeaderIdempotencyKey)
+		if key == "" {
+			return next(c)
+		}
+		if len(key) > MaxKeyLength {
+			return echo.NewHTTPError(http.StatusBadRequest, "idempotency key is too long")
+		}
+
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "could not read request body").SetInternal(err)
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		cache, ok := c.Get("cache").(*caches.Cache)
+		if !ok {
+			panic("cache value is not set")
+		}
+
+		saved, err := cache.BeginIdempotent(key, fingerprint(c, body))
+		switch {
+		case errors.Is(err, caches.ErrIdempotencyKeyReused):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error()).SetInternal(err)
+		case errors.Is(err, caches.ErrIdempotencyKeyInProgress):
+			return echo.NewHTTPError(http.StatusConflict, err.Error()).SetInternal(err)
+		case err != nil:
+			return echo.NewHTTPError(http.StatusInternalServerError, "idempotency check failed").SetInternal(err)
+		case saved != nil:
+			c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+			return c.Blob(saved.Status, saved.ContentType, saved.Body)
+		}
+
+		rec := &recorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = rec
+		err = next(c)
+
+		res := c.Response()
+		if err != nil || !res.Committed || res.Status < 200 || res.Status > 299 {
+			cache.AbortIdempotent(key)
+			return err
+		}
+		cache.FinishIdempotent(key, caches.IdempotentResponse{
+			Status:      res.Status,
+			ContentType: res.Header().Get(echo.HeaderContentType),
+			Body:        rec.body.Bytes(),
+		})
+		return nil
+	}
+}
+
+// fingerprint identifies a request by its method, path, content type and body,
+// so that a key reused for a different request can be rejected.
+func fingerprint(c echo.Context, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(c.Request().Method + " " + c.Request().URL.Path + "\n"))
+	h.Write([]byte(c.Request().Header.Get(echo.HeaderContentType) + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder copies a response body as it is written.
+type recorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
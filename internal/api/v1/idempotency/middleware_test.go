@@ -0,0 +1,107 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+	defer cache.Close()
+
+	calls := 0
+	status := http.StatusCreated
+	contentType := echo.MIMEApplicationJSON
+	h := Middleware(func(c echo.Context) error {
+		calls++
+		if status != http.StatusCreated {
+			return echo.NewHTTPError(status, "failed")
+		}
+		return c.JSON(status, map[string]any{"calls": calls})
+	})
+
+	call := func(key, body string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodPost, "/keys", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		if key != "" {
+			req.Header.Set(HeaderIdempotencyKey, key)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("cache", cache)
+		return rec, h(c)
+	}
+
+	// First request runs the handler
+	rec, err := call("k1", `{"a":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"calls":1}`, rec.Body.String())
+	assert.Empty(t, rec.Header().Get(HeaderIdempotentReplayed))
+
+	// Retry gets the same response
+	rec, err = call("k1", `{"a":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"calls":1}`, rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, 1, calls)
+
+	// Same key, different body
+	var he *echo.HTTPError
+	_, err = call("k1", `{"a":2}`)
+	require.ErrorAs(t, err, &he)
+	assert.Equal(t, http.StatusUnprocessableEntity, he.Code)
+
+	// Same key and body, different content type
+	contentType = echo.MIMETextPlain
+	_, err = call("k1", `{"a":1}`)
+	require.ErrorAs(t, err, &he)
+	assert.Equal(t, http.StatusUnprocessableEntity, he.Code)
+	contentType = echo.MIMEApplicationJSON
+
+	// No key: not idempotent
+	_, err = call("", `{"a":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// Failures are not kept
+	status = http.StatusBadRequest
+	_, err = call("k2", `{}`)
+	require.ErrorAs(t, err, &he)
+	status = http.StatusCreated
+	rec, err = call("k2", `{}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"calls":4}`, rec.Body.String())
+
+	// Key too long
+	_, err = call(strings.Repeat("k", MaxKeyLength+1), `{}`)
+	require.ErrorAs(t, err, &he)
+	assert.Equal(t, http.StatusBadRequest, he.Code)
+}
+
+func TestMiddleware_InProgress(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+	defer cache.Close()
+
+	_, err := cache.BeginIdempotent("k", fingerprint(e.NewContext(httptest.NewRequest(http.MethodPost, "/keys", nil), nil), []byte("{}")))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/keys", strings.NewReader("{}"))
+	req.Header.Set(HeaderIdempotencyKey, "k")
+	c := e.NewContext(req, httptest.NewRecorder())
+	c.Set("cache", cache)
+
+	var he *echo.HTTPError
+	require.ErrorAs(t, Middleware(func(c echo.Context) error { return nil })(c), &he)
+	assert.Equal(t, http.StatusConflict, he.Code)
+}
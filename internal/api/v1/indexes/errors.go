@@ -0,0 +1,24 @@
+package indexes
+
+import (
+	"net/http"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// indexError maps index errors to HTTP errors, using msg for anything
+// unexpected.
+func indexError(err error, msg string) error {
+	switch {
+	case errors.Is(err, caches.ErrIndexNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "index not found").SetInternal(err)
+	case errors.Is(err, caches.ErrIndexExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error()).SetInternal(err)
+	case errors.Is(err, caches.ErrInvalidIndexName),
+		errors.Is(err, caches.ErrInvalidIndex):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, msg).SetInternal(err)
+}
@@ -0,0 +1,39 @@
+package indexes
+
+import (
+	"net/http"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// createIndexRequest defines an index, e.g.
+// {"source": "sessions/*", "field": "user_id"}.
+type createIndexRequest struct {
+	Source string `json:"source"`
+	Field  string `json:"field"`
+}
+
+// handleCreateIndex creates an index and builds it from the current data.
+func handleCreateIndex() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		var input createIndexRequest
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid JSON payload").SetInternal(err)
+		}
+
+		cache := Cache(c)
+		info, err := cache.CreateIndex(ctx, caches.Index{
+			Name:   c.Param("name"),
+			Source: input.Source,
+			Field:  input.Field,
+		})
+		if err != nil {
+			return indexError(err, "failed to create index")
+		}
+
+		return c.JSON(http.StatusCreated, info)
+	}
+}
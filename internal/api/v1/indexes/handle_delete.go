@@ -0,0 +1,21 @@
+package indexes
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handleDeleteIndex deletes an index.
+func handleDeleteIndex() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		cache := Cache(c)
+		if err := cache.DeleteIndex(ctx, c.Param("name")); err != nil {
+			return indexError(err, "could not delete index")
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
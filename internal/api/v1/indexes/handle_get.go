@@ -0,0 +1,30 @@
+package indexes
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handleListIndexes returns every index, by name.
+func handleListIndexes() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cache := Cache(c)
+		return c.JSON(http.StatusOK, cache.Indexes(c.Request().Context()))
+	}
+}
+
+// handleGetIndex returns an index and its size.
+func handleGetIndex() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		cache := Cache(c)
+		info, err := cache.IndexByName(ctx, c.Param("name"))
+		if err != nil {
+			return indexError(err, "failed to get index")
+		}
+
+		return c.JSON(http.StatusOK, info)
+	}
+}
@@ -0,0 +1,120 @@
+package indexes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newContext(e *echo.Echo, cache *caches.Cache, method, path string, body any, name string) (echo.Context, *httptest.ResponseRecorder) {
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("name")
+	c.SetParamValues(name)
+	c.Set("cache", cache)
+	return c, rec
+}
+
+func TestHandleIndexes(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+	ctx := context.Background()
+
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"sessions": map[string]any{
+			"a": map[string]any{"user_id": 42},
+			"b": map[string]any{"user_id": 7},
+		},
+	}))
+
+	// Create
+	c, rec := newContext(e, cache, http.MethodPut, "/indexes/by_user", map[string]any{
+		"source": "sessions/*",
+		"field":  "user_id",
+	}, "by_user")
+	if assert.NoError(t, handleCreateIndex()(c)) {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var info map[string]any
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		assert.Equal(t, "by_user", info["name"])
+		assert.Equal(t, float64(2), info["items"])
+	}
+
+	keys, err := cache.Lookup(ctx, "sessions/*/user_id", 42)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sessions/a"}, keys)
+
+	// List
+	c, rec = newContext(e, cache, http.MethodGet, "/indexes", nil, "")
+	if assert.NoError(t, handleListIndexes()(c)) {
+		var infos []map[string]any
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &infos))
+		assert.Len(t, infos, 1)
+	}
+
+	// Get
+	c, rec = newContext(e, cache, http.MethodGet, "/indexes/by_user", nil, "by_user")
+	if assert.NoError(t, handleGetIndex()(c)) {
+		var info map[string]any
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		assert.Equal(t, "sessions/*", info["source"])
+		assert.Equal(t, float64(2), info["values"])
+	}
+
+	// Delete
+	c, rec = newContext(e, cache, http.MethodDelete, "/indexes/by_user", nil, "by_user")
+	if assert.NoError(t, handleDeleteIndex()(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	assert.Empty(t, cache.Indexes(ctx))
+}
+
+func TestHandleIndexErrors(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+
+	_, err := cache.CreateIndex(context.Background(), caches.Index{Name: "by_user", Source: "sessions/*", Field: "user_id"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		handler echo.HandlerFunc
+		method  string
+		index   string
+		body    any
+		code    int
+	}{
+		{"exists", handleCreateIndex(), http.MethodPut, "by_user", map[string]any{"source": "sessions/*", "field": "user_id"}, http.StatusConflict},
+		{"no wildcard", handleCreateIndex(), http.MethodPut, "x", map[string]any{"source": "sessions", "field": "user_id"}, http.StatusBadRequest},
+		{"no field", handleCreateIndex(), http.MethodPut, "x", map[string]any{"source": "sessions/*"}, http.StatusBadRequest},
+		{"get missing", handleGetIndex(), http.MethodGet, "missing", nil, http.StatusNotFound},
+		{"delete missing", handleDeleteIndex(), http.MethodDelete, "missing", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newContext(e, cache, tt.method, "/indexes/"+tt.index, tt.body, tt.index)
+			err := tt.handler(c)
+			var httpErr *echo.HTTPError
+			if assert.ErrorAs(t, err, &httpErr) {
+				assert.Equal(t, tt.code, httpErr.Code)
+			}
+		})
+	}
+}
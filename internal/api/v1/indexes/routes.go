@@ -0,0 +1,19 @@
+package indexes
+
+import "github.com/labstack/echo/v4"
+
+func SetupRoutes(group *echo.Group) {
+	indexes := group.Group("/indexes", cacheMW)
+
+	// List indexes
+	indexes.GET("", handleListIndexes())
+
+	// Create an index
+	indexes.PUT("/:name", handleCreateIndex())
+
+	// Get an index
+	indexes.GET("/:name", handleGetIndex())
+
+	// Delete an index
+	indexes.DELETE("/:name", handleDeleteIndex())
+}
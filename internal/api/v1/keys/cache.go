@@ -1,6 +1,8 @@
 package keys
 
 import (
+	"net/url"
+
 	"github.com/goodblaster/map-cache/pkg/caches"
 	"github.com/labstack/echo/v4"
 )
@@ -18,3 +20,16 @@ func Cache(c echo.Context) *caches.Cache {
 
 	return cache
 }
+
+// Key returns the :key path parameter. A key holding a "/" or a path escape
+// ("\") is percent-encoded in the URL, e.g. /keys/users%2F42%2Fname, and is
+// decoded here before it is read with the usual path syntax.
+func Key(c echo.Context) (string, error) {
+	key := c.Param("key")
+	// Echo routes on the raw path when it has encoded characters, leaving
+	// them encoded in the parameter
+	if c.Request().URL.RawPath == "" {
+		return key, nil
+	}
+	return url.PathUnescape(key)
+}
@@ -31,7 +31,10 @@ func (req deleteBatchRequest) Validate() error {
 func handleDelete() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		cache := Cache(c)
-		key := c.Param("key")
+		key, err := Key(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid key").SetInternal(err)
+		}
 
 		if err := cache.Delete(c.Request().Context(), key); err != nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, "could not delete key").SetInternal(err)
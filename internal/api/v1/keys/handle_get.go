@@ -28,7 +28,10 @@ func (req getBatchRequest) Validate() error {
 func handleGetValue() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		cache := Cache(c)
-		key := c.Param("key")
+		key, err := Key(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid key").SetInternal(err)
+		}
 
 		value, err := cache.Get(c.Request().Context(), key)
 		if err != nil {
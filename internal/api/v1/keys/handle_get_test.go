@@ -225,3 +225,34 @@ func TestGetBatchRequest_Validate_EmptyKeyInArray(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "key cannot be empty")
 }
+
+func TestHandleGetValue_EncodedKey(t *testing.T) {
+	// Setup
+	e := echo.New()
+	cache := caches.New()
+	cache.Acquire("test")
+	defer cache.Release("test")
+
+	err := cache.Create(context.Background(), map[string]any{
+		`files/report\/2024.pdf`: "contents",
+	})
+	assert.NoError(t, err)
+
+	e.GET("/keys/:key", handleGetValue(), func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("cache", cache)
+			return next(c)
+		}
+	})
+
+	// "files/report\/2024.pdf", percent-encoded
+	req := httptest.NewRequest(http.MethodGet, "/keys/files%2Freport%5C%2F2024.pdf", nil)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	e.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "contents")
+}
@@ -125,9 +125,17 @@ func (req handlePatchRequest) ValidateOperations(ctx context.Context, cache *cac
 	return nil
 }
 
-// handlePatch applies a series of patch operations to the cache.
+// handlePatch applies a series of patch operations to the cache. A JSON Patch
+// or merge patch body, by its content type, patches the value at :key instead.
 func handlePatch() echo.HandlerFunc {
 	return func(c echo.Context) error {
+		switch patchFormat(c) {
+		case MIMEJSONPatch:
+			return handleJSONPatch(c)
+		case MIMEMergePatch:
+			return handleMergePatch(c)
+		}
+
 		ctx := c.Request().Context()
 		cache := Cache(c)
 
@@ -169,12 +177,12 @@ func handlePatch() echo.HandlerFunc {
 				}
 
 			case PatchIncrement:
-				f64, err := cache.Increment(ctx, op.Key, op.Value)
+				sum, err := cache.IncrementNumber(ctx, op.Key, op.Value)
 				if err != nil {
 					result.Error = errors.Wrap(err, "failed to increment key")
 					break
 				}
-				result.Result = f64
+				result.Result = sum
 
 			case PatchAppend:
 				if op.Value == nil {
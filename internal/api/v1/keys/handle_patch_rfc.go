@@ -0,0 +1,88 @@
+package keys
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// Content types of the standard patch formats PATCH /keys/:key accepts
+// alongside its own operations list.
+const (
+	MIMEJSONPatch  = "application/json-patch+json"  // RFC 6902
+	MIMEMergePatch = "application/merge-patch+json" // RFC 7396
+)
+
+// patchFormat returns the media type of the request body, without parameters.
+func patchFormat(c echo.Context) string {
+	mediaType, _, err := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// handleJSONPatch applies an RFC 6902 JSON Patch, an array of operations, to
+// the value at :key and returns the new value.
+func handleJSONPatch(c echo.Context) error {
+	key, err := Key(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid key").SetInternal(err)
+	}
+
+	var ops []caches.JSONPatchOp
+	if err := c.Echo().JSONSerializer.Deserialize(c, &ops); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid json patch").SetInternal(err)
+	}
+
+	cache := Cache(c)
+	value, err := cache.JSONPatch(c.Request().Context(), key, ops)
+	if err != nil {
+		return patchError(err)
+	}
+
+	return c.JSON(http.StatusOK, value)
+}
+
+// handleMergePatch applies an RFC 7396 merge patch to the value at :key and
+// returns the new value.
+func handleMergePatch(c echo.Context) error {
+	key, err := Key(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid key").SetInternal(err)
+	}
+
+	var patch any
+	if err := c.Echo().JSONSerializer.Deserialize(c, &patch); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid merge patch").SetInternal(err)
+	}
+
+	cache := Cache(c)
+	value, err := cache.MergePatch(c.Request().Context(), key, patch)
+	if err != nil {
+		return patchError(err)
+	}
+
+	return c.JSON(http.StatusOK, value)
+}
+
+// patchError maps the errors of a standard patch to HTTP errors. A patch that
+// does not fit the current value, including a failed test, is a conflict.
+func patchError(err error) error {
+	var limitErr *caches.LimitError
+	switch {
+	case errors.As(err, &limitErr):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, limitErr.Error()).SetInternal(err)
+	case errors.Is(err, caches.ErrKeyNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "key not found").SetInternal(err)
+	case errors.Is(err, caches.ErrInvalidPatch):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	case errors.Is(err, caches.ErrPatchConflict),
+		errors.Is(err, caches.ErrPatchTestFailed):
+		return echo.NewHTTPError(http.StatusConflict, err.Error()).SetInternal(err)
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "could not patch value").SetInternal(err)
+}
@@ -0,0 +1,112 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPatchContext(e *echo.Echo, cache *caches.Cache, key, contentType, body string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPatch, "/keys/"+key, bytes.NewReader([]byte(body)))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("key")
+	c.SetParamValues(key)
+	c.Set("cache", cache)
+	return c, rec
+}
+
+func TestHandlePatch_JSONPatch(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+	ctx := context.Background()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"user": map[string]any{"name": "alice", "tags": []any{"a"}},
+	}))
+
+	c, rec := newPatchContext(e, cache, "user", MIMEJSONPatch, `[
+		{"op": "test", "path": "/name", "value": "alice"},
+		{"op": "replace", "path": "/name", "value": "bob"},
+		{"op": "add", "path": "/tags/-", "value": "b"}
+	]`)
+	if assert.NoError(t, handlePatch()(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var value map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &value))
+		assert.Equal(t, map[string]any{"name": "bob", "tags": []any{"a", "b"}}, value)
+	}
+
+	// A failed test changes nothing
+	c, _ = newPatchContext(e, cache, "user", MIMEJSONPatch, `[
+		{"op": "remove", "path": "/tags"},
+		{"op": "test", "path": "/name", "value": "alice"}
+	]`)
+	err := handlePatch()(c)
+	var httpErr *echo.HTTPError
+	if assert.ErrorAs(t, err, &httpErr) {
+		assert.Equal(t, http.StatusConflict, httpErr.Code)
+	}
+	tags, err := cache.Get(ctx, "user/tags")
+	require.NoError(t, err)
+	assert.Equal(t, []any{"a", "b"}, tags)
+}
+
+func TestHandlePatch_MergePatch(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+	ctx := context.Background()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"user": map[string]any{"name": "alice", "address": map[string]any{"city": "Paris", "zip": "75001"}},
+	}))
+
+	c, rec := newPatchContext(e, cache, "user", MIMEMergePatch+"; charset=utf-8", `{"address": {"zip": null, "street": "Main"}}`)
+	if assert.NoError(t, handlePatch()(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	value, err := cache.Get(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"name":    "alice",
+		"address": map[string]any{"city": "Paris", "street": "Main"},
+	}, value)
+}
+
+func TestHandlePatch_StandardErrors(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+	require.NoError(t, cache.Create(context.Background(), map[string]any{"user": map[string]any{"name": "alice"}}))
+
+	tests := []struct {
+		name        string
+		key         string
+		contentType string
+		body        string
+		code        int
+	}{
+		{"missing key", "nobody", MIMEMergePatch, `{"name": "bob"}`, http.StatusNotFound},
+		{"invalid json", "user", MIMEJSONPatch, `[{`, http.StatusBadRequest},
+		{"unknown op", "user", MIMEJSONPatch, `[{"op": "frobnicate", "path": "/name"}]`, http.StatusBadRequest},
+		{"missing value", "user", MIMEJSONPatch, `[{"op": "replace", "path": "/name"}]`, http.StatusBadRequest},
+		{"missing path", "user", MIMEJSONPatch, `[{"op": "remove", "path": "/age"}]`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newPatchContext(e, cache, tt.key, tt.contentType, tt.body)
+			err := handlePatch()(c)
+			var httpErr *echo.HTTPError
+			if assert.ErrorAs(t, err, &httpErr) {
+				assert.Equal(t, tt.code, httpErr.Code)
+			}
+		})
+	}
+}
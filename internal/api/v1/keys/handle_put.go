@@ -22,7 +22,10 @@ func (req handlePutRequest) Validate() error {
 func handlePut() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		cache := Cache(c)
-		key := c.Param("key")
+		key, err := Key(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid key").SetInternal(err)
+		}
 
 		var req handlePutRequest
 		if err := c.Bind(&req); err != nil {
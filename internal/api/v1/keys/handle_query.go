@@ -0,0 +1,32 @@
+package keys
+
+import (
+	"net/http"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// handleQuery runs a query (see caches.Query) against the values matching a
+// wildcard source.
+func handleQuery() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cache := Cache(c)
+		var query caches.Query
+		if err := c.Bind(&query); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid json payload").SetInternal(err)
+		}
+
+		result, err := cache.Query(c.Request().Context(), query)
+		if err != nil {
+			var limitErr *caches.LimitError
+			if errors.As(err, &limitErr) {
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, limitErr.Error()).SetInternal(err)
+			}
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+		}
+
+		return c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,56 @@
+package keys
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func queryRequest(cache *caches.Cache, body string) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/keys/query", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("cache", cache)
+	return rec, handleQuery()(c)
+}
+
+func TestHandleQuery_Success(t *testing.T) {
+	cache := caches.New()
+	require.NoError(t, cache.Create(context.Background(), map[string]any{
+		"sessions/a": map[string]any{"status": "active", "last_seen": 2},
+		"sessions/b": map[string]any{"status": "idle", "last_seen": 3},
+		"sessions/c": map[string]any{"status": "active", "last_seen": 1},
+	}))
+
+	rec, err := queryRequest(cache, `{
+		"source": "sessions/*",
+		"where": "${{$item/status}} == \"active\"",
+		"select": ["last_seen"],
+		"order_by": [{"field": "last_seen"}]
+	}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"total": 2, "items": [
+		{"key": "sessions/c", "value": {"last_seen": 1}},
+		{"key": "sessions/a", "value": {"last_seen": 2}}
+	]}`, rec.Body.String())
+}
+
+func TestHandleQuery_Invalid(t *testing.T) {
+	cache := caches.New()
+	for _, body := range []string{`{"source": "sessions/a"}`, `{"source": `, `{"source": "s/*", "where": "1 +"}`} {
+		_, err := queryRequest(cache, body)
+		var httpErr *echo.HTTPError
+		require.ErrorAs(t, err, &httpErr, body)
+		assert.Equal(t, http.StatusBadRequest, httpErr.Code, body)
+	}
+}
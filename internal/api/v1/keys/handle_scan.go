@@ -0,0 +1,79 @@
+package keys
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// maxScanLimit caps the page size of a scan.
+const maxScanLimit = 1000
+
+// scanResponse is one page of a scan. The cursor is a string because it can
+// exceed the integers JSON numbers hold exactly; "0" means the scan is done.
+type scanResponse struct {
+	Keys   []string `json:"keys"`
+	Cursor string   `json:"cursor"`
+}
+
+// handleScan pages through the keys matching the "pattern" query param ("*"
+// by default), starting from "cursor" ("0" to begin). "limit" sets the page
+// size and "type" keeps only keys whose values have that JSON type.
+func handleScan() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		pattern := c.QueryParam("pattern")
+		if pattern == "" {
+			pattern = "*"
+		}
+
+		var cursor uint64
+		if val := c.QueryParam("cursor"); val != "" {
+			parsed, err := strconv.ParseUint(val, 10, 64)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid cursor").SetInternal(err)
+			}
+			cursor = parsed
+		}
+
+		limit := caches.DefaultScanCount
+		if val := c.QueryParam("limit"); val != "" {
+			parsed, err := strconv.Atoi(val)
+			if err != nil || parsed < 1 || parsed > maxScanLimit {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid limit").SetInternal(err)
+			}
+			limit = parsed
+		}
+
+		var filter func(key string, value any) bool
+		if valueType := c.QueryParam("type"); valueType != "" {
+			switch valueType {
+			case "null", "bool", "number", "string", "array", "object":
+			default:
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid type")
+			}
+			filter = func(key string, value any) bool {
+				return caches.TypeName(value) == valueType
+			}
+		}
+
+		cache := Cache(c)
+		keys, next, err := cache.Scan(c.Request().Context(), pattern, cursor, limit, filter)
+		if errors.Is(err, caches.ErrInvalidScanCursor) {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid cursor").SetInternal(err)
+		}
+		if err != nil {
+			return err
+		}
+		if keys == nil {
+			keys = []string{}
+		}
+
+		return c.JSON(http.StatusOK, scanResponse{
+			Keys:   keys,
+			Cursor: strconv.FormatUint(next, 10),
+		})
+	}
+}
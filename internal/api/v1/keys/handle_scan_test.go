@@ -0,0 +1,70 @@
+package keys
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scanRequest(t *testing.T, cache *caches.Cache, query string) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/keys?"+query, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("cache", cache)
+	return rec, handleScan()(c)
+}
+
+func TestHandleScan_Pages(t *testing.T) {
+	cache := caches.New()
+	for i := 0; i < 25; i++ {
+		require.NoError(t, cache.Create(context.Background(), map[string]any{fmt.Sprintf("users/%d/name", i): "x"}))
+	}
+	require.NoError(t, cache.Create(context.Background(), map[string]any{"other": 1}))
+
+	var seen []string
+	cursor := "0"
+	for {
+		rec, err := scanRequest(t, cache, "pattern=users/*&limit=10&cursor="+cursor)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var resp scanResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		seen = append(seen, resp.Keys...)
+		if resp.Cursor == "0" {
+			break
+		}
+		cursor = resp.Cursor
+	}
+
+	assert.Len(t, seen, 25)
+	assert.NotContains(t, seen, "other")
+}
+
+func TestHandleScan_Type(t *testing.T) {
+	cache := caches.New()
+	require.NoError(t, cache.Create(context.Background(), map[string]any{"a": "x", "b": 2, "c": []any{1}}))
+
+	rec, err := scanRequest(t, cache, "type=number")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"keys":["b"],"cursor":"0"}`, rec.Body.String())
+}
+
+func TestHandleScan_BadParams(t *testing.T) {
+	cache := caches.New()
+	for _, query := range []string{"cursor=abc", "cursor=-1", "cursor=12345", "limit=0", "limit=5000", "type=hash"} {
+		_, err := scanRequest(t, cache, query)
+		var httpErr *echo.HTTPError
+		require.ErrorAs(t, err, &httpErr, query)
+		assert.Equal(t, http.StatusBadRequest, httpErr.Code, query)
+	}
+}
@@ -1,6 +1,7 @@
 package keys
 
 import (
+	"github.com/goodblaster/map-cache/internal/api/v1/idempotency"
 	"github.com/labstack/echo/v4"
 )
 
@@ -9,20 +10,22 @@ func SetupRoutes(group *echo.Group) {
 	group = group.Group("/keys", cacheMW)
 
 	// --- Create keys ---
-	group.POST("", handleCreate())
+	group.POST("", handleCreate(), idempotency.Middleware)
 
 	// --- Read keys ---
+	group.GET("", handleScan())          // Scan keys, a page at a time
 	group.GET("/:key", handleGetValue()) // Get single key
 	group.POST("/get", handleGetBatch()) // Get multiple keys (batch)
+	group.POST("/query", handleQuery())  // Filter, sort and group values
 
 	// --- Update keys ---
-	group.PUT("/:key", handlePut())     // Full replace single
-	group.PUT("", handleReplaceBatch()) // Full replace batch
+	group.PUT("/:key", handlePut(), idempotency.Middleware)     // Full replace single
+	group.PUT("", handleReplaceBatch(), idempotency.Middleware) // Full replace batch
 
 	// ---
-	group.PATCH("/:key", handlePatch()) // Partial update single
+	group.PATCH("/:key", handlePatch(), idempotency.Middleware) // Partial update single
 
 	// --- Delete keys ---
-	group.DELETE("/:key", handleDelete())      // Delete single key
-	group.POST("/delete", handleDeleteBatch()) // Delete batch (POST because DELETE doesn't accept bodies cleanly)
+	group.DELETE("/:key", handleDelete(), idempotency.Middleware)      // Delete single key
+	group.POST("/delete", handleDeleteBatch(), idempotency.Middleware) // Delete batch (POST because DELETE doesn't accept bodies cleanly)
 }
@@ -80,10 +80,12 @@ func UpdateCacheMetrics() {
 		cacheSizeBytes.WithLabelValues(name).Set(float64(cache.SizeBytes(ctx)))
 		cacheActivityCount.WithLabelValues(name).Set(float64(cache.ActivityCount()))
 
-		// Count keys (assuming we can get this from the cache)
-		// Note: This might be expensive for large caches
-		keys := cache.WildKeys(ctx, "*")
-		cacheKeyCount.WithLabelValues(name).Set(float64(len(keys)))
+		// Count top-level keys without collecting them
+		keyCount := 0
+		for range cache.MatchKeys(ctx, "*") {
+			keyCount++
+		}
+		cacheKeyCount.WithLabelValues(name).Set(float64(keyCount))
 
 		cache.Release("metrics")
 
@@ -0,0 +1,30 @@
+package procedures
+
+import (
+	"net/http"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// procedureError maps procedure errors to HTTP errors, using msg for
+// anything unexpected.
+func procedureError(err error, msg string) error {
+	var limitErr *caches.LimitError
+	switch {
+	case errors.As(err, &limitErr):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, limitErr.Error()).SetInternal(err)
+	case errors.Is(err, caches.ErrProcedureNotFound),
+		errors.Is(err, caches.ErrProcedureVersionNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "procedure not found").SetInternal(err)
+	case errors.Is(err, caches.ErrInvalidProcedureName),
+		errors.Is(err, caches.ErrProcedureCommandRequired),
+		errors.Is(err, caches.ErrDuplicateProcedureParam),
+		errors.Is(err, caches.ErrInvalidVariableName),
+		errors.Is(err, caches.ErrMissingProcedureArg),
+		errors.Is(err, caches.ErrUnknownProcedureArg):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, msg).SetInternal(err)
+}
@@ -0,0 +1,45 @@
+package procedures
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/labstack/echo/v4"
+)
+
+// callProcedureRequest holds the arguments of a procedure call, by parameter
+// name. Version 0 (or omitted) calls the latest version.
+type callProcedureRequest struct {
+	Args    map[string]any `json:"args"`
+	Version int            `json:"version"`
+}
+
+// handleCallProcedure runs a procedure and returns its result.
+func handleCallProcedure() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input callProcedureRequest
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid json payload").SetInternal(err)
+		}
+
+		// Create context with timeout
+		ctx, cancel := context.WithTimeout(c.Request().Context(), time.Duration(config.CommandTimeoutMs)*time.Millisecond)
+		defer cancel()
+
+		cache := Cache(c)
+		result := cache.CallProcedure(ctx, c.Param("name"), input.Version, input.Args)
+
+		// Check if execution timed out
+		if ctx.Err() == context.DeadlineExceeded {
+			return echo.NewHTTPError(http.StatusRequestTimeout, "procedure execution timed out")
+		}
+
+		if result.Error != nil {
+			return procedureError(result.Error, "procedure execution failed")
+		}
+
+		return c.JSON(http.StatusOK, result.Value)
+	}
+}
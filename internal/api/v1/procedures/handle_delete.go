@@ -0,0 +1,21 @@
+package procedures
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handleDeleteProcedure deletes a procedure and all of its versions.
+func handleDeleteProcedure() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		cache := Cache(c)
+		if err := cache.DeleteProcedure(ctx, c.Param("name")); err != nil {
+			return procedureError(err, "could not delete procedure")
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
@@ -0,0 +1,56 @@
+package procedures
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handleListProcedures returns the latest version of every procedure.
+func handleListProcedures() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cache := Cache(c)
+		return c.JSON(http.StatusOK, cache.Procedures(c.Request().Context()))
+	}
+}
+
+// handleGetProcedure returns the latest version of a procedure, or the
+// version given by the "version" query param.
+func handleGetProcedure() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		version := 0
+		if val := c.QueryParam("version"); val != "" {
+			parsed, err := strconv.Atoi(val)
+			if err != nil || parsed < 1 {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid version").SetInternal(err)
+			}
+			version = parsed
+		}
+
+		cache := Cache(c)
+		proc, err := cache.Procedure(ctx, c.Param("name"), version)
+		if err != nil {
+			return procedureError(err, "failed to get procedure")
+		}
+
+		return c.JSON(http.StatusOK, proc)
+	}
+}
+
+// handleGetProcedureVersions returns every version of a procedure, oldest first.
+func handleGetProcedureVersions() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		cache := Cache(c)
+		versions, err := cache.ProcedureVersions(ctx, c.Param("name"))
+		if err != nil {
+			return procedureError(err, "failed to get procedure versions")
+		}
+
+		return c.JSON(http.StatusOK, versions)
+	}
+}
@@ -0,0 +1,173 @@
+package procedures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newContext(e *echo.Echo, cache *caches.Cache, method, path string, body any, name string) (echo.Context, *httptest.ResponseRecorder) {
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("name")
+	c.SetParamValues(name)
+	c.Set("cache", cache)
+	return c, rec
+}
+
+func TestHandleSaveAndCallProcedure(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+	ctx := context.Background()
+
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"jobs": map[string]any{"42": map[string]any{"apple": "busy"}},
+	}))
+
+	// Save
+	c, rec := newContext(e, cache, http.MethodPut, "/procedures/complete_domain", map[string]any{
+		"params": []string{"job", "domain"},
+		"command": map[string]any{
+			"type":  "REPLACE",
+			"key":   "jobs/${{$job}}/${{$domain}}",
+			"value": "complete",
+		},
+	}, "complete_domain")
+	if assert.NoError(t, handleSaveProcedure()(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var proc map[string]any
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &proc))
+		assert.Equal(t, float64(1), proc["version"])
+		assert.Equal(t, "REPLACE", proc["command"].(map[string]any)["type"])
+	}
+
+	// Call
+	c, rec = newContext(e, cache, http.MethodPost, "/procedures/complete_domain/call", map[string]any{
+		"args": map[string]any{"job": "42", "domain": "apple"},
+	}, "complete_domain")
+	if assert.NoError(t, handleCallProcedure()(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	val, err := cache.Get(ctx, "jobs/42/apple")
+	assert.NoError(t, err)
+	assert.Equal(t, "complete", val)
+
+	// List
+	c, rec = newContext(e, cache, http.MethodGet, "/procedures", nil, "")
+	if assert.NoError(t, handleListProcedures()(c)) {
+		var procs []map[string]any
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &procs))
+		assert.Len(t, procs, 1)
+	}
+}
+
+func TestHandleCallProcedureErrors(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+
+	_, err := cache.SaveProcedure(context.Background(), "echo", []string{"x"}, caches.RETURN("${{$x}}"))
+	require.NoError(t, err)
+
+	t.Run("not found", func(t *testing.T) {
+		c, _ := newContext(e, cache, http.MethodPost, "/procedures/missing/call", map[string]any{}, "missing")
+		err := handleCallProcedure()(c)
+		var httpErr *echo.HTTPError
+		if assert.ErrorAs(t, err, &httpErr) {
+			assert.Equal(t, http.StatusNotFound, httpErr.Code)
+		}
+	})
+
+	t.Run("missing argument", func(t *testing.T) {
+		c, _ := newContext(e, cache, http.MethodPost, "/procedures/echo/call", map[string]any{}, "echo")
+		err := handleCallProcedure()(c)
+		var httpErr *echo.HTTPError
+		if assert.ErrorAs(t, err, &httpErr) {
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+		}
+	})
+}
+
+func TestHandleProcedureVersions(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+	ctx := context.Background()
+
+	_, err := cache.SaveProcedure(ctx, "p", nil, caches.RETURN(1))
+	require.NoError(t, err)
+	_, err = cache.SaveProcedure(ctx, "p", nil, caches.RETURN(2))
+	require.NoError(t, err)
+
+	c, rec := newContext(e, cache, http.MethodGet, "/procedures/p/versions", nil, "p")
+	if assert.NoError(t, handleGetProcedureVersions()(c)) {
+		var versions []map[string]any
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &versions))
+		assert.Len(t, versions, 2)
+	}
+
+	c, rec = newContext(e, cache, http.MethodGet, "/procedures/p?version=1", nil, "p")
+	if assert.NoError(t, handleGetProcedure()(c)) {
+		var proc map[string]any
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &proc))
+		assert.Equal(t, float64(1), proc["version"])
+	}
+
+	c, rec = newContext(e, cache, http.MethodDelete, "/procedures/p", nil, "p")
+	if assert.NoError(t, handleDeleteProcedure()(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	c, _ = newContext(e, cache, http.MethodGet, "/procedures/p", nil, "p")
+	err = handleGetProcedure()(c)
+	var httpErr *echo.HTTPError
+	if assert.ErrorAs(t, err, &httpErr) {
+		assert.Equal(t, http.StatusNotFound, httpErr.Code)
+	}
+}
+
+func TestHandleSaveProcedureScript(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+	ctx := context.Background()
+
+	require.NoError(t, cache.Create(ctx, map[string]any{"count": 1.0}))
+
+	c, rec := newContext(e, cache, http.MethodPut, "/procedures/bump", map[string]any{
+		"params": []string{"by"},
+		"script": "inc count 1\nreturn = ${{count}} + ${{$by}}",
+	}, "bump")
+	if assert.NoError(t, handleSaveProcedure()(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	res := cache.CallProcedure(ctx, "bump", 0, map[string]any{"by": 10.0})
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{2.0, 12.0}, res.Value)
+
+	// Syntax errors are reported with their position
+	c, _ = newContext(e, cache, http.MethodPut, "/procedures/bad", map[string]any{
+		"script": "inc count\nif {",
+	}, "bad")
+	err := handleSaveProcedure()(c)
+	var httpErr *echo.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+	assert.Contains(t, httpErr.Message, "line 2")
+}
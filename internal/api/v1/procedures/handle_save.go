@@ -0,0 +1,45 @@
+package procedures
+
+import (
+	"net/http"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// saveProcedureRequest defines a procedure, e.g.
+// {"params": ["job", "domain"], "command": {...}}, or with "script" holding
+// the command in text form.
+type saveProcedureRequest struct {
+	Params []string          `json:"params"`
+	Raw    caches.RawCommand `json:"command"`
+	Script string            `json:"script"`
+}
+
+// handleSaveProcedure creates a procedure, or adds a new version of it.
+func handleSaveProcedure() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		var input saveProcedureRequest
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid JSON payload").SetInternal(err)
+		}
+
+		cmd := input.Raw.Command
+		if input.Script != "" {
+			var err error
+			if cmd, err = caches.ParseScriptCommand(input.Script); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+			}
+		}
+
+		cache := Cache(c)
+		proc, err := cache.SaveProcedure(ctx, c.Param("name"), input.Params, cmd)
+		if err != nil {
+			return procedureError(err, "failed to save procedure")
+		}
+
+		return c.JSON(http.StatusOK, proc)
+	}
+}
@@ -0,0 +1,25 @@
+package procedures
+
+import "github.com/labstack/echo/v4"
+
+func SetupRoutes(group *echo.Group) {
+	procedures := group.Group("/procedures", cacheMW)
+
+	// List procedures (latest versions)
+	procedures.GET("", handleListProcedures())
+
+	// Create or update a procedure (adds a version)
+	procedures.PUT("/:name", handleSaveProcedure())
+
+	// Get a procedure (latest, or ?version=N)
+	procedures.GET("/:name", handleGetProcedure())
+
+	// List all versions of a procedure
+	procedures.GET("/:name/versions", handleGetProcedureVersions())
+
+	// Delete a procedure and all of its versions
+	procedures.DELETE("/:name", handleDeleteProcedure())
+
+	// Call a procedure
+	procedures.POST("/:name/call", handleCallProcedure())
+}
@@ -4,7 +4,10 @@ import (
 	"github.com/goodblaster/map-cache/internal/api/v1/caches"
 	"github.com/goodblaster/map-cache/internal/api/v1/commands"
 	"github.com/goodblaster/map-cache/internal/api/v1/docs"
+	"github.com/goodblaster/map-cache/internal/api/v1/indexes"
 	"github.com/goodblaster/map-cache/internal/api/v1/keys"
+	"github.com/goodblaster/map-cache/internal/api/v1/procedures"
+	"github.com/goodblaster/map-cache/internal/api/v1/schedules"
 	"github.com/goodblaster/map-cache/internal/api/v1/triggers"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -30,4 +33,7 @@ func SetupRoutes(e *echo.Echo) {
 	caches.SetupRoutes(v1)
 	commands.SetupRoutes(v1)
 	triggers.SetupRoutes(v1)
+	procedures.SetupRoutes(v1)
+	schedules.SetupRoutes(v1)
+	indexes.SetupRoutes(v1)
 }
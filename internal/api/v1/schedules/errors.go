@@ -0,0 +1,27 @@
+package schedules
+
+import (
+	"net/http"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// scheduleError maps schedule errors to HTTP errors, using msg for anything
+// unexpected.
+func scheduleError(err error, msg string) error {
+	switch {
+	case errors.Is(err, caches.ErrScheduleNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "schedule not found").SetInternal(err)
+	case errors.Is(err, caches.ErrScheduleExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error()).SetInternal(err)
+	case errors.Is(err, caches.ErrInvalidScheduleId),
+		errors.Is(err, caches.ErrScheduleCommandRequired),
+		errors.Is(err, caches.ErrInvalidScheduleTiming),
+		errors.Is(err, caches.ErrScheduleIntervalTooShort),
+		errors.Is(err, caches.ErrInvalidCron):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, msg).SetInternal(err)
+}
@@ -0,0 +1,69 @@
+package schedules
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+)
+
+// createScheduleRequest defines a schedule, e.g.
+// {"id": "reset", "cron": "0 0 * * *", "command": {...}}. The command can be
+// given as "command", as a "script", or as a "procedure" to call with "args".
+type createScheduleRequest struct {
+	Id         string     `json:"id"`
+	Cron       string     `json:"cron"`
+	IntervalMs int64      `json:"interval_ms"`
+	At         *time.Time `json:"at"`
+	Paused     bool       `json:"paused"`
+
+	Raw       caches.RawCommand `json:"command"`
+	Script    string            `json:"script"`
+	Procedure string            `json:"procedure"`
+	Version   int               `json:"version"`
+	Args      map[string]any    `json:"args"`
+}
+
+// command returns the scheduled command, however it was given.
+func (req createScheduleRequest) command() (caches.Command, error) {
+	switch {
+	case req.Script != "":
+		return caches.ParseScriptCommand(req.Script)
+	case req.Procedure != "":
+		return caches.CommandCall{Name: req.Procedure, Version: req.Version, Args: req.Args}, nil
+	}
+	return req.Raw.Command, nil
+}
+
+// handleCreateSchedule adds a schedule to the cache.
+func handleCreateSchedule() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		var input createScheduleRequest
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid JSON payload").SetInternal(err)
+		}
+
+		cmd, err := input.command()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+		}
+
+		cache := Cache(c)
+		schedule, err := cache.AddSchedule(ctx, caches.Schedule{
+			Id:         input.Id,
+			Cron:       input.Cron,
+			IntervalMs: input.IntervalMs,
+			At:         input.At,
+			Paused:     input.Paused,
+			Command:    cmd,
+		})
+		if err != nil {
+			return scheduleError(err, "failed to create schedule")
+		}
+
+		return c.JSON(http.StatusCreated, schedule)
+	}
+}
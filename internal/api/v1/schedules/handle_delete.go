@@ -0,0 +1,19 @@
+package schedules
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handleDeleteSchedule stops and removes a schedule.
+func handleDeleteSchedule() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cache := Cache(c)
+		if err := cache.DeleteSchedule(c.Request().Context(), c.Param("id")); err != nil {
+			return scheduleError(err, "could not delete schedule")
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
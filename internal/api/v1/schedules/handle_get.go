@@ -0,0 +1,28 @@
+package schedules
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handleListSchedules returns every schedule of the cache.
+func handleListSchedules() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cache := Cache(c)
+		return c.JSON(http.StatusOK, cache.Schedules(c.Request().Context()))
+	}
+}
+
+// handleGetSchedule returns a schedule and its run history.
+func handleGetSchedule() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cache := Cache(c)
+		schedule, err := cache.Schedule(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return scheduleError(err, "failed to get schedule")
+		}
+
+		return c.JSON(http.StatusOK, schedule)
+	}
+}
@@ -0,0 +1,33 @@
+package schedules
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handlePauseSchedule stops a schedule from running until it is resumed.
+func handlePauseSchedule() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cache := Cache(c)
+		schedule, err := cache.PauseSchedule(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return scheduleError(err, "failed to pause schedule")
+		}
+
+		return c.JSON(http.StatusOK, schedule)
+	}
+}
+
+// handleResumeSchedule restarts a paused schedule.
+func handleResumeSchedule() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cache := Cache(c)
+		schedule, err := cache.ResumeSchedule(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return scheduleError(err, "failed to resume schedule")
+		}
+
+		return c.JSON(http.StatusOK, schedule)
+	}
+}
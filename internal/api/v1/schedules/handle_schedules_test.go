@@ -0,0 +1,147 @@
+package schedules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newContext(e *echo.Echo, cache *caches.Cache, method, path string, body any, id string) (echo.Context, *httptest.ResponseRecorder) {
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	c.Set("cache", cache)
+	return c, rec
+}
+
+func TestHandleSchedules(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+	defer cache.Close()
+	ctx := context.Background()
+
+	require.NoError(t, cache.Create(ctx, map[string]any{"hits": float64(7)}))
+	_, err := cache.SaveProcedure(ctx, "reset", nil, caches.REPLACE("hits", float64(0)))
+	require.NoError(t, err)
+
+	// Create, calling a procedure
+	c, rec := newContext(e, cache, http.MethodPost, "/schedules", map[string]any{
+		"id":        "window",
+		"cron":      "*/5 * * * *",
+		"procedure": "reset",
+	}, "")
+	if assert.NoError(t, handleCreateSchedule()(c)) {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var schedule map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &schedule))
+		assert.Equal(t, "window", schedule["id"])
+		assert.Equal(t, "CALL", schedule["command"].(map[string]any)["type"])
+		assert.NotEmpty(t, schedule["next_run"])
+	}
+
+	// Duplicate
+	c, _ = newContext(e, cache, http.MethodPost, "/schedules", map[string]any{
+		"id":     "window",
+		"cron":   "@daily",
+		"script": "noop",
+	}, "")
+	var he *echo.HTTPError
+	require.ErrorAs(t, handleCreateSchedule()(c), &he)
+	assert.Equal(t, http.StatusConflict, he.Code)
+
+	// Invalid timing
+	c, _ = newContext(e, cache, http.MethodPost, "/schedules", map[string]any{
+		"cron":        "@daily",
+		"interval_ms": 1000,
+		"script":      "noop",
+	}, "")
+	require.ErrorAs(t, handleCreateSchedule()(c), &he)
+	assert.Equal(t, http.StatusBadRequest, he.Code)
+
+	// Pause
+	c, rec = newContext(e, cache, http.MethodPost, "/schedules/window/pause", nil, "window")
+	if assert.NoError(t, handlePauseSchedule()(c)) {
+		var schedule caches.RawSchedule
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &schedule))
+		assert.True(t, schedule.Paused)
+	}
+
+	// Resume
+	c, rec = newContext(e, cache, http.MethodPost, "/schedules/window/resume", nil, "window")
+	if assert.NoError(t, handleResumeSchedule()(c)) {
+		var schedule caches.RawSchedule
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &schedule))
+		assert.False(t, schedule.Paused)
+	}
+
+	// List
+	c, rec = newContext(e, cache, http.MethodGet, "/schedules", nil, "")
+	if assert.NoError(t, handleListSchedules()(c)) {
+		var schedules []map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &schedules))
+		assert.Len(t, schedules, 1)
+	}
+
+	// Delete
+	c, rec = newContext(e, cache, http.MethodDelete, "/schedules/window", nil, "window")
+	if assert.NoError(t, handleDeleteSchedule()(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	c, _ = newContext(e, cache, http.MethodGet, "/schedules/window", nil, "window")
+	require.ErrorAs(t, handleGetSchedule()(c), &he)
+	assert.Equal(t, http.StatusNotFound, he.Code)
+}
+
+func TestHandleSchedules_Runs(t *testing.T) {
+	e := echo.New()
+	cache := caches.New()
+	defer cache.Close()
+	ctx := context.Background()
+	require.NoError(t, cache.Create(ctx, map[string]any{"hits": float64(7)}))
+
+	at := time.Now().Add(10 * time.Millisecond)
+	cache.Acquire("test")
+	c, _ := newContext(e, cache, http.MethodPost, "/schedules", map[string]any{
+		"id":     "once",
+		"at":     at,
+		"script": "replace hits 0",
+	}, "")
+	require.NoError(t, handleCreateSchedule()(c))
+	cache.Release("test")
+
+	require.Eventually(t, func() bool {
+		cache.Acquire("test")
+		defer cache.Release("test")
+		c, rec := newContext(e, cache, http.MethodGet, "/schedules/once", nil, "once")
+		require.NoError(t, handleGetSchedule()(c))
+		var schedule caches.RawSchedule
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &schedule))
+		return len(schedule.History) == 1 && schedule.History[0].Success
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cache.Acquire("test")
+	hits, err := cache.Get(ctx, "hits")
+	cache.Release("test")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), hits)
+}
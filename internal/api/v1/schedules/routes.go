@@ -0,0 +1,23 @@
+package schedules
+
+import "github.com/labstack/echo/v4"
+
+func SetupRoutes(group *echo.Group) {
+	schedules := group.Group("/schedules", cacheMW)
+
+	// List schedules
+	schedules.GET("", handleListSchedules())
+
+	// Create a schedule
+	schedules.POST("", handleCreateSchedule())
+
+	// Get a schedule and its run history
+	schedules.GET("/:id", handleGetSchedule())
+
+	// Pause and resume a schedule
+	schedules.POST("/:id/pause", handlePauseSchedule())
+	schedules.POST("/:id/resume", handleResumeSchedule())
+
+	// Delete a schedule
+	schedules.DELETE("/:id", handleDeleteSchedule())
+}
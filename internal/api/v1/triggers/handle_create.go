@@ -3,6 +3,7 @@ package triggers
 import (
 	"net/http"
 
+	"github.com/goodblaster/errors"
 	"github.com/goodblaster/map-cache/pkg/caches"
 	"github.com/labstack/echo/v4"
 )
@@ -10,7 +11,10 @@ import (
 // CreateTriggerRequest is for adding a single trigger.
 type CreateTriggerRequest struct {
 	Key string            `json:"key,required"`
-	Raw caches.RawCommand `json:"command,required"`
+	Raw caches.RawCommand `json:"command"`
+
+	// Script is the command in text form, instead of Raw.
+	Script string `json:"script"`
 }
 
 // handleCreateTrigger creates a new trigger based on key and command.
@@ -23,9 +27,20 @@ func handleCreateTrigger() echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusBadRequest, "invalid JSON payload").SetInternal(err)
 		}
 
+		cmd := input.Raw.Command
+		if input.Script != "" {
+			var err error
+			if cmd, err = caches.ParseScriptCommand(input.Script); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+			}
+		}
+
 		cache := Cache(c)
-		id, err := cache.CreateTrigger(ctx, input.Key, input.Raw.Command)
+		id, err := cache.CreateTrigger(ctx, input.Key, cmd)
 		if err != nil {
+			if errors.Is(err, caches.ErrInvalidCapture) || errors.Is(err, caches.ErrDuplicateCapture) {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid trigger key").SetInternal(err)
+			}
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to add trigger").SetInternal(err)
 		}
 
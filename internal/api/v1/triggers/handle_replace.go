@@ -3,6 +3,7 @@ package triggers
 import (
 	"net/http"
 
+	"github.com/goodblaster/errors"
 	"github.com/goodblaster/map-cache/pkg/caches"
 	"github.com/labstack/echo/v4"
 )
@@ -11,7 +12,10 @@ import (
 type replaceTriggerRequest struct {
 	Id  string            `json:"id,required"`
 	Key string            `json:"key,required"`
-	Raw caches.RawCommand `json:"command,required"`
+	Raw caches.RawCommand `json:"command"`
+
+	// Script is the command in text form, instead of Raw.
+	Script string `json:"script"`
 }
 
 // handleDeleteCache deletes a trigger by id.
@@ -32,14 +36,25 @@ func handleReplaceTrigger() echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusBadRequest, "payload id must match request id")
 		}
 
+		cmd := input.Raw.Command
+		if input.Script != "" {
+			var err error
+			if cmd, err = caches.ParseScriptCommand(input.Script); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+			}
+		}
+
 		newTrigger := caches.Trigger{
 			Id:      id,
 			Key:     input.Key,
-			Command: input.Raw.Command,
+			Command: cmd,
 		}
 
 		cache := Cache(c)
 		if err := cache.ReplaceTrigger(ctx, id, newTrigger); err != nil {
+			if errors.Is(err, caches.ErrInvalidCapture) || errors.Is(err, caches.ErrDuplicateCapture) {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid trigger key").SetInternal(err)
+			}
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to replace trigger").SetInternal(err)
 		}
 
@@ -69,6 +69,42 @@ func TestHandleCreateTrigger(t *testing.T) {
 		assert.Equal(t, float64(1), total) // Should have been incremented
 	})
 
+	t.Run("create trigger from script", func(t *testing.T) {
+		cache := caches.New()
+		ctx := context.Background()
+
+		cache.Acquire("test")
+		err := cache.Create(ctx, map[string]any{
+			"counter": float64(0),
+			"total":   float64(0),
+		})
+		cache.Release("test")
+		require.NoError(t, err)
+
+		body, _ := json.Marshal(map[string]any{"key": "counter", "script": "inc total 2"})
+		req := httptest.NewRequest(http.MethodPost, "/triggers", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("cache", cache)
+
+		h := handleCreateTrigger()
+		if assert.NoError(t, h(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+
+		cache.Acquire("test")
+		err = cache.Replace(ctx, "counter", float64(5))
+		cache.Release("test")
+		require.NoError(t, err)
+
+		cache.Acquire("test")
+		total, err := cache.Get(ctx, "total")
+		cache.Release("test")
+		assert.NoError(t, err)
+		assert.Equal(t, float64(2), total)
+	})
+
 	t.Run("invalid JSON", func(t *testing.T) {
 		cache := caches.New()
 		req := httptest.NewRequest(http.MethodPost, "/triggers", bytes.NewReader([]byte("invalid")))
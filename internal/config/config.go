@@ -18,13 +18,34 @@ var (
 	ServiceName       = "map-cache"
 
 	// Command execution configuration
-	CommandLongThresholdMs = int64(500)   // 0.5 seconds default
-	CommandTimeoutMs       = int64(10000) // 10 seconds default
+	CommandLongThresholdMs   = int64(500)   // 0.5 seconds default
+	CommandTimeoutMs         = int64(10000) // 10 seconds default
+	CommandMaxLoopIterations = 10000        // per FOR range / WHILE loop
+	ExpressionCacheSize      = 1024         // compiled expressions and regexes kept for reuse
+
+	// Execution limits per command execution; 0 means unlimited
+	CommandMaxSteps       = 0 // commands run, including nested commands and triggers
+	CommandMaxKeysVisited = 0 // keys matched by wildcards
+	CommandMaxWrites      = 0 // keys written or deleted
+	CommandMaxDepth       = 0 // command nesting depth
+
+	// Background command jobs
+	JobTimeoutMs = int64(600000) // 10 minutes default, 0 for none
+	JobRetention = 1000          // finished jobs kept for polling, per cache
+	JobMaxActive = 100           // queued or running jobs per cache, 0 for no limit
+
+	// Scheduled commands
+	ScheduleHistorySize   = 20         // runs kept per schedule
+	ScheduleMinIntervalMs = int64(100) // shortest interval_ms accepted
+
+	// Idempotency keys
+	IdempotencyWindowMs = int64(86400000) // 24 hours default
 
 	// RESP (Redis Protocol) configuration
 	RESPEnabled        = false
 	RESPAddress        = ":6379"
-	RESPKeyMode        = "translate"   // "translate" (: → /) or "preserve"
+	RESPKeyMode        = "translate" // "translate" (: → /) or "preserve"
+	RESPPatternMode    = "path"      // KEYS patterns: "path" (per segment) or "redis" (whole key)
 	RESPDefaultCache   = "default"
 	RESPMaxConnections = 1000
 	RESPBackupDir      = "./backups"
@@ -69,6 +90,78 @@ func Init(l log.Logger) {
 		}
 	}
 
+	if val := os.Getenv("COMMAND_MAX_LOOP_ITERATIONS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil {
+			CommandMaxLoopIterations = parsed
+		}
+	}
+
+	if val := os.Getenv("EXPRESSION_CACHE_SIZE"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil {
+			ExpressionCacheSize = parsed
+		}
+	}
+
+	if val := os.Getenv("COMMAND_MAX_STEPS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil {
+			CommandMaxSteps = parsed
+		}
+	}
+
+	if val := os.Getenv("COMMAND_MAX_KEYS_VISITED"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil {
+			CommandMaxKeysVisited = parsed
+		}
+	}
+
+	if val := os.Getenv("COMMAND_MAX_WRITES"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil {
+			CommandMaxWrites = parsed
+		}
+	}
+
+	if val := os.Getenv("COMMAND_MAX_DEPTH"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil {
+			CommandMaxDepth = parsed
+		}
+	}
+
+	if val := os.Getenv("JOB_TIMEOUT_MS"); val != "" {
+		if parsed, err := strconv.ParseInt(val, 10, 64); err == nil {
+			JobTimeoutMs = parsed
+		}
+	}
+
+	if val := os.Getenv("JOB_RETENTION"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil {
+			JobRetention = parsed
+		}
+	}
+
+	if val := os.Getenv("JOB_MAX_ACTIVE"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil {
+			JobMaxActive = parsed
+		}
+	}
+
+	if val := os.Getenv("SCHEDULE_HISTORY_SIZE"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil {
+			ScheduleHistorySize = parsed
+		}
+	}
+
+	if val := os.Getenv("SCHEDULE_MIN_INTERVAL_MS"); val != "" {
+		if parsed, err := strconv.ParseInt(val, 10, 64); err == nil {
+			ScheduleMinIntervalMs = parsed
+		}
+	}
+
+	if val := os.Getenv("IDEMPOTENCY_WINDOW_MS"); val != "" {
+		if parsed, err := strconv.ParseInt(val, 10, 64); err == nil {
+			IdempotencyWindowMs = parsed
+		}
+	}
+
 	// RESP configuration
 	if val := os.Getenv("RESP_ENABLED"); val == "true" || val == "1" {
 		RESPEnabled = true
@@ -82,6 +175,10 @@ func Init(l log.Logger) {
 		RESPKeyMode = val
 	}
 
+	if val := os.Getenv("RESP_PATTERN_MODE"); val != "" {
+		RESPPatternMode = val
+	}
+
 	if val := os.Getenv("RESP_DEFAULT_CACHE"); val != "" {
 		RESPDefaultCache = val
 	}
@@ -103,8 +200,21 @@ func Init(l log.Logger) {
 		With("TELEMETRY_EXPORTER", TelemetryExporter).
 		With("COMMAND_LONG_THRESHOLD_MS", CommandLongThresholdMs).
 		With("COMMAND_TIMEOUT_MS", CommandTimeoutMs).
+		With("COMMAND_MAX_LOOP_ITERATIONS", CommandMaxLoopIterations).
+		With("EXPRESSION_CACHE_SIZE", ExpressionCacheSize).
+		With("COMMAND_MAX_STEPS", CommandMaxSteps).
+		With("COMMAND_MAX_KEYS_VISITED", CommandMaxKeysVisited).
+		With("COMMAND_MAX_WRITES", CommandMaxWrites).
+		With("COMMAND_MAX_DEPTH", CommandMaxDepth).
+		With("JOB_TIMEOUT_MS", JobTimeoutMs).
+		With("JOB_RETENTION", JobRetention).
+		With("JOB_MAX_ACTIVE", JobMaxActive).
+		With("SCHEDULE_HISTORY_SIZE", ScheduleHistorySize).
+		With("SCHEDULE_MIN_INTERVAL_MS", ScheduleMinIntervalMs).
+		With("IDEMPOTENCY_WINDOW_MS", IdempotencyWindowMs).
 		With("RESP_ENABLED", RESPEnabled).
 		With("RESP_ADDRESS", RESPAddress).
 		With("RESP_KEY_MODE", RESPKeyMode).
+		With("RESP_PATTERN_MODE", RESPPatternMode).
 		Info("Configuration initialized")
 }
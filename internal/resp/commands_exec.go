@@ -0,0 +1,106 @@
+package resp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	respProto "github.com/tidwall/resp"
+)
+
+func init() {
+	// Register command language commands
+	RegisterCommand("CMD.EXEC", HandleCmdExec)
+	RegisterCommand("CMD.FORMAT", HandleCmdFormat)
+}
+
+// HandleCmdExec implements CMD.EXEC commands, executing commands given either
+// as JSON (a command, an array of commands, or {"commands": [...]}) or as a
+// script.
+func HandleCmdExec(s *Session, args []respProto.Value) error {
+	if len(args) != 1 {
+		return s.WriteError("ERR wrong number of arguments for 'cmd.exec' command")
+	}
+
+	cmds, err := decodeCommands(args[0].String())
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+
+	cache, err := caches.FetchCache(s.SelectedCache())
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+
+	tag := s.Tag("CMD.EXEC")
+	cache.Acquire(tag)
+	defer cache.Release(tag)
+
+	ctx, cancel := context.WithTimeout(s.Context(), time.Duration(config.CommandTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	result := cache.Execute(ctx, cmds...)
+	if result.Error != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", result.Error.Error()))
+	}
+
+	return s.WriteValue(ConvertToRESP(result.Value))
+}
+
+// HandleCmdFormat implements CMD.FORMAT commands, returning the commands (in
+// either form) pretty-printed as a script.
+func HandleCmdFormat(s *Session, args []respProto.Value) error {
+	if len(args) != 1 {
+		return s.WriteError("ERR wrong number of arguments for 'cmd.format' command")
+	}
+
+	cmds, err := decodeCommands(args[0].String())
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+
+	return s.WriteValue(BulkString(caches.FormatScript(cmds...)))
+}
+
+// decodeCommands decodes commands as JSON if the payload is valid JSON, and
+// as a script otherwise.
+func decodeCommands(payload string) ([]caches.Command, error) {
+	trimmed := strings.TrimSpace(payload)
+	if !json.Valid([]byte(trimmed)) {
+		return caches.ParseScript(payload)
+	}
+
+	switch {
+	case strings.HasPrefix(trimmed, "["):
+		var raws []caches.RawCommand
+		if err := json.Unmarshal([]byte(trimmed), &raws); err != nil {
+			return nil, err
+		}
+		return unwrapCommands(raws), nil
+	case strings.HasPrefix(trimmed, "{"):
+		var envelope caches.CommandEnvelope
+		if err := json.Unmarshal([]byte(trimmed), &envelope); err == nil && len(envelope.Commands) > 0 {
+			return unwrapCommands(envelope.Commands), nil
+		}
+		var raw caches.RawCommand
+		if err := json.Unmarshal([]byte(trimmed), &raw); err != nil {
+			return nil, err
+		}
+		return []caches.Command{raw.Command}, nil
+	}
+
+	// A lone JSON scalar such as "noop" is a one-word script
+	return caches.ParseScript(payload)
+}
+
+func unwrapCommands(raws []caches.RawCommand) []caches.Command {
+	cmds := make([]caches.Command, len(raws))
+	for i, raw := range raws {
+		cmds[i] = raw.Command
+	}
+	return cmds
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/goodblaster/errors"
 	"github.com/goodblaster/map-cache/pkg/caches"
 	respProto "github.com/tidwall/resp"
 )
@@ -455,44 +456,17 @@ func HandleHIncrBy(s *Session, args []respProto.Value) error {
 	ctx, cancel := context.WithTimeout(s.Context(), 5*time.Second)
 	defer cancel()
 
-	// Get current value or initialize to 0
-	value, getErr := cache.Get(ctx, fullPath)
-	var currentVal int64
-	if getErr != nil {
-		// Field doesn't exist, initialize to 0
-		currentVal = 0
-		if createErr := cache.Create(ctx, map[string]any{fullPath: currentVal}); createErr != nil {
-			return s.WriteError(fmt.Sprintf("ERR %s", createErr.Error()))
-		}
-	} else {
-		// Parse current value
-		switch v := value.(type) {
-		case int64:
-			currentVal = v
-		case int:
-			currentVal = int64(v)
-		case float64:
-			currentVal = int64(v)
-		case string:
-			parsed, parseErr := strconv.ParseInt(v, 10, 64)
-			if parseErr != nil {
-				return s.WriteError("ERR hash value is not an integer")
-			}
-			currentVal = parsed
-		default:
-			return s.WriteError("ERR hash value is not an integer")
-		}
-	}
-
-	// Increment
-	newValue := currentVal + increment
-
-	// Store new value
-	if replaceErr := cache.Replace(ctx, fullPath, newValue); replaceErr != nil {
-		return s.WriteError(fmt.Sprintf("ERR %s", replaceErr.Error()))
+	newValue, err := incrementKey(ctx, cache, fullPath, increment)
+	switch {
+	case errors.Is(err, caches.ErrNotANumber):
+		return s.WriteError("ERR hash value is not an integer")
+	case errors.Is(err, caches.ErrIntegerOverflow):
+		return s.WriteError("ERR increment or decrement would overflow")
+	case err != nil:
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
 	}
 
-	return s.WriteValue(Integer(int(newValue)))
+	return s.WriteValue(ConvertToRESP(newValue))
 }
 
 // HandleHIncrByFloat implements the HINCRBYFLOAT command (increment hash field by float)
@@ -522,45 +496,15 @@ func HandleHIncrByFloat(s *Session, args []respProto.Value) error {
 	ctx, cancel := context.WithTimeout(s.Context(), 5*time.Second)
 	defer cancel()
 
-	// Get current value or initialize to 0
-	value, getErr := cache.Get(ctx, fullPath)
-	var currentVal float64
-	if getErr != nil {
-		// Field doesn't exist, initialize to 0
-		currentVal = 0.0
-		if createErr := cache.Create(ctx, map[string]any{fullPath: currentVal}); createErr != nil {
-			return s.WriteError(fmt.Sprintf("ERR %s", createErr.Error()))
-		}
-	} else {
-		// Parse current value
-		switch v := value.(type) {
-		case float64:
-			currentVal = v
-		case int:
-			currentVal = float64(v)
-		case int64:
-			currentVal = float64(v)
-		case string:
-			parsed, parseErr := strconv.ParseFloat(v, 64)
-			if parseErr != nil {
-				return s.WriteError("ERR hash value is not a valid float")
-			}
-			currentVal = parsed
-		default:
-			return s.WriteError("ERR hash value is not a valid float")
-		}
-	}
-
-	// Increment
-	newValue := currentVal + increment
-
-	// Store new value
-	if replaceErr := cache.Replace(ctx, fullPath, newValue); replaceErr != nil {
-		return s.WriteError(fmt.Sprintf("ERR %s", replaceErr.Error()))
+	newValue, err := incrementKey(ctx, cache, fullPath, floatIncrement(ctx, cache, fullPath, increment, args[2].String()))
+	switch {
+	case errors.Is(err, caches.ErrNotANumber):
+		return s.WriteError("ERR hash value is not a valid float")
+	case err != nil:
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
 	}
 
-	// Return new value as string (like Redis)
-	return s.WriteValue(BulkString(fmt.Sprintf("%.17g", newValue)))
+	return s.WriteValue(floatReply(newValue))
 }
 
 // HandleHSetNX implements the HSETNX command (set field if not exists)
@@ -0,0 +1,109 @@
+package resp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/goodblaster/map-cache/pkg/caches"
+	respProto "github.com/tidwall/resp"
+)
+
+// maxIdempotencyKeyLength matches the HTTP API's limit on idempotency keys.
+const maxIdempotencyKeyLength = 255
+
+func init() {
+	RegisterCommand("IDEMPOTENT", HandleIdempotent)
+}
+
+// HandleIdempotent implements IDEMPOTENT key command [arg ...]. The command
+// runs as usual, and its reply is kept by the selected cache for
+// IDEMPOTENCY_WINDOW_MS; repeating it with the same key gets the same reply
+// without running it again. Error replies are not kept, so a failed command
+// can be retried with the same key.
+func HandleIdempotent(s *Session, args []respProto.Value) error {
+	if len(args) < 2 {
+		return s.WriteError("ERR wrong number of arguments for 'idempotent' command")
+	}
+
+	key := args[0].String()
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return s.WriteError("ERR invalid idempotency key")
+	}
+	if strings.EqualFold(args[1].String(), "IDEMPOTENT") {
+		return s.WriteError("ERR 'idempotent' cannot be nested")
+	}
+
+	cache, err := caches.FetchCache(s.SelectedCache())
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+
+	tag := s.Tag("IDEMPOTENT")
+	cache.Acquire(tag)
+	saved, err := cache.BeginIdempotent(key, fingerprintCommand(args[1:]))
+	cache.Release(tag)
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+	if saved != nil {
+		_, err := s.conn.Write(saved.Body)
+		return err
+	}
+
+	// The command takes the cache lock itself, so run it without holding it
+	var replies []respProto.Value
+	s.capture = &replies
+	err = HandleCommand(s, respProto.ArrayValue(args[1:]))
+	s.capture = nil
+	if err != nil {
+		replies = append(replies, Error(fmt.Sprintf("ERR %s", err.Error())))
+	}
+
+	var body bytes.Buffer
+	failed := false
+	for _, reply := range replies {
+		if reply.Type() == respProto.Error {
+			failed = true
+		}
+		b, err := reply.MarshalRESP()
+		if err != nil {
+			failed = true
+			break
+		}
+		body.Write(b)
+	}
+
+	cache.Acquire(tag)
+	if failed {
+		cache.AbortIdempotent(key)
+	} else {
+		cache.FinishIdempotent(key, caches.IdempotentResponse{Body: body.Bytes()})
+	}
+	cache.Release(tag)
+
+	for _, reply := range replies {
+		if err := s.WriteValue(reply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fingerprintCommand identifies a command by its name and arguments, so that a
+// key reused for a different command can be rejected.
+func fingerprintCommand(args []respProto.Value) string {
+	h := sha256.New()
+	for i, arg := range args {
+		val := arg.String()
+		if i == 0 {
+			val = strings.ToUpper(val)
+		}
+		binary.Write(h, binary.BigEndian, uint64(len(val)))
+		h.Write([]byte(val))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
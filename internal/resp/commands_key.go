@@ -3,11 +3,14 @@ package resp
 import (
 	"context"
 	"fmt"
+	"iter"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/goodblaster/map-cache/internal/config"
 	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/goodblaster/map-cache/pkg/containers"
 	respProto "github.com/tidwall/resp"
 )
 
@@ -338,10 +341,21 @@ func HandleKeys(s *Session, args []respProto.Value) error {
 	ctx, cancel := context.WithTimeout(s.Context(), 5*time.Second)
 	defer cancel()
 
-	// Translate Redis glob pattern to map-cache wildcard pattern
-	// Redis uses * for any characters, map-cache uses * for path segments
-	// For now, translate : to / and use as-is
-	translatedPattern := strings.ReplaceAll(pattern, ":", "/")
+	// In redis pattern mode, match whole keys as Redis does, so "*" and "?"
+	// cross ":" and every value is a key
+	if config.RESPPatternMode == "redis" {
+		redisKeys := []respProto.Value{}
+		for key := range valueKeys(ctx, cache) {
+			if containers.MatchGlob(pattern, key) {
+				redisKeys = append(redisKeys, BulkString(key))
+			}
+		}
+		return s.WriteValue(Array(redisKeys))
+	}
+
+	// Otherwise translate the pattern like a key and match it segment by
+	// segment: "*", "?" and "[...]" stay within a segment, "**" spans any
+	translatedPattern := TranslateKey(pattern)
 
 	// Get matching keys
 	keys := cache.WildKeys(ctx, translatedPattern)
@@ -349,13 +363,40 @@ func HandleKeys(s *Session, args []respProto.Value) error {
 	// Convert back to Redis format (/ → :)
 	redisKeys := make([]respProto.Value, len(keys))
 	for i, key := range keys {
-		redisKey := strings.ReplaceAll(key, "/", ":")
+		redisKey := UntranslateKey(key)
 		redisKeys[i] = BulkString(redisKey)
 	}
 
 	return s.WriteValue(Array(redisKeys))
 }
 
+// valueKeys yields the Redis-style key and the value of every key in cache:
+// each hash and each value that is not a map, descending into the other
+// maps. Hashes and lists are not descended into.
+func valueKeys(ctx context.Context, cache *caches.Cache) iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		var walk func(path string, value any) bool
+		walk = func(path string, value any) bool {
+			m, ok := value.(map[string]any)
+			if !ok || isHash(m) {
+				return yield(UntranslateKey(path), value)
+			}
+			for k, v := range m {
+				if !walk(path+config.KeyDelimiter+containers.EscapeKey(k), v) {
+					return false
+				}
+			}
+			return true
+		}
+
+		for key, value := range cache.MatchKeys(ctx, "*") {
+			if !walk(key, value) {
+				return
+			}
+		}
+	}
+}
+
 // HandleExpireAt implements the EXPIREAT command (set expiration at timestamp in seconds)
 func HandleExpireAt(s *Session, args []respProto.Value) error {
 	if len(args) != 2 {
@@ -593,22 +634,7 @@ func HandleType(s *Session, args []respProto.Value) error {
 		return s.WriteValue(SimpleString("none"))
 	}
 
-	// Determine type
-	var keyType string
-	switch value.(type) {
-	case map[string]any:
-		keyType = "hash"
-	case []any:
-		keyType = "list"
-	case string:
-		keyType = "string"
-	case int, int64, float64:
-		keyType = "string" // Redis treats numbers as strings
-	default:
-		keyType = "string"
-	}
-
-	return s.WriteValue(SimpleString(keyType))
+	return s.WriteValue(SimpleString(redisType(value)))
 }
 
 // HandleCopy implements the COPY command (copy key to destination)
@@ -675,3 +701,30 @@ func HandleCopy(s *Session, args []respProto.Value) error {
 
 	return s.WriteValue(Integer(1))
 }
+
+// isHash reports whether a map is a hash rather than a level of nested keys:
+// HSET stores a hash as a map of its fields, so a map holding no maps is one.
+func isHash(m map[string]any) bool {
+	for _, v := range m {
+		if _, ok := v.(map[string]any); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// redisType returns the Redis type of a value, as TYPE reports it.
+func redisType(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "hash"
+	case []any:
+		return "list"
+	case string:
+		return "string"
+	case int, int64, float64:
+		return "string" // Redis treats numbers as strings
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,90 @@
+package resp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	respProto "github.com/tidwall/resp"
+)
+
+func init() {
+	// Register stored procedure commands
+	RegisterCommand("PROC.CALL", HandleProcCall)
+	RegisterCommand("PROC.LIST", HandleProcList)
+}
+
+// HandleProcCall implements PROC.CALL name [arg ...]. Arguments are matched to
+// the procedure's parameters by position. Each argument is decoded as JSON if
+// it is valid JSON (so 5 is a number and true a boolean), otherwise it is
+// passed as a string.
+func HandleProcCall(s *Session, args []respProto.Value) error {
+	if len(args) < 1 {
+		return s.WriteError("ERR wrong number of arguments for 'proc.call' command")
+	}
+
+	name := args[0].String()
+
+	cache, err := caches.FetchCache(s.SelectedCache())
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+
+	tag := s.Tag("PROC.CALL")
+	cache.Acquire(tag)
+	defer cache.Release(tag)
+
+	ctx, cancel := context.WithTimeout(s.Context(), time.Duration(config.CommandTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	proc, err := cache.Procedure(ctx, name, 0)
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+	if len(args)-1 != len(proc.Params) {
+		return s.WriteError(fmt.Sprintf("ERR procedure '%s' expects %d arguments, got %d", name, len(proc.Params), len(args)-1))
+	}
+
+	callArgs := make(map[string]any, len(proc.Params))
+	for i, param := range proc.Params {
+		raw := args[i+1].String()
+		var val any
+		if err := json.Unmarshal([]byte(raw), &val); err != nil {
+			val = raw
+		}
+		callArgs[param] = val
+	}
+
+	result := cache.CallProcedure(ctx, name, proc.Version, callArgs)
+	if result.Error != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", result.Error.Error()))
+	}
+
+	return s.WriteValue(ConvertToRESP(result.Value))
+}
+
+// HandleProcList implements PROC.LIST, returning the names of all procedures.
+func HandleProcList(s *Session, args []respProto.Value) error {
+	if len(args) != 0 {
+		return s.WriteError("ERR wrong number of arguments for 'proc.list' command")
+	}
+
+	cache, err := caches.FetchCache(s.SelectedCache())
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+
+	tag := s.Tag("PROC.LIST")
+	cache.Acquire(tag)
+	defer cache.Release(tag)
+
+	procs := cache.Procedures(s.Context())
+	values := make([]respProto.Value, len(procs))
+	for i, proc := range procs {
+		values[i] = BulkString(proc.Name)
+	}
+	return s.WriteValue(Array(values))
+}
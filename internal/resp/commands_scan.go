@@ -0,0 +1,241 @@
+package resp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/goodblaster/map-cache/pkg/containers"
+	respProto "github.com/tidwall/resp"
+)
+
+func init() {
+	// Register scan commands
+	RegisterCommand("SCAN", HandleScan)
+	RegisterCommand("HSCAN", HandleHScan)
+	RegisterCommand("SSCAN", HandleSScan)
+}
+
+// scanOptions holds the options that follow the cursor of a scan command.
+type scanOptions struct {
+	match    string
+	count    int
+	keyType  string
+	noValues bool
+}
+
+// parseScanOptions parses [MATCH pattern] [COUNT count], plus TYPE type and
+// NOVALUES where the command allows them.
+func parseScanOptions(args []respProto.Value, allowType, allowNoValues bool) (scanOptions, string) {
+	opts := scanOptions{match: "*", count: caches.DefaultScanCount}
+	for i := 0; i < len(args); i++ {
+		option := strings.ToUpper(args[i].String())
+		switch {
+		case option == "MATCH" && i+1 < len(args):
+			opts.match = args[i+1].String()
+			i++
+		case option == "COUNT" && i+1 < len(args):
+			count, err := strconv.Atoi(args[i+1].String())
+			if err != nil {
+				return opts, "ERR value is not an integer or out of range"
+			}
+			if count < 1 {
+				return opts, "ERR syntax error"
+			}
+			opts.count = count
+			i++
+		case option == "TYPE" && allowType && i+1 < len(args):
+			opts.keyType = strings.ToLower(args[i+1].String())
+			i++
+		case option == "NOVALUES" && allowNoValues:
+			opts.noValues = true
+		default:
+			return opts, "ERR syntax error"
+		}
+	}
+	return opts, ""
+}
+
+// parseCursor parses a scan cursor.
+func parseCursor(arg respProto.Value) (uint64, bool) {
+	cursor, err := strconv.ParseUint(arg.String(), 10, 64)
+	return cursor, err == nil
+}
+
+// scanReply builds the reply of a scan command: the next cursor and a page.
+func scanReply(cursor uint64, page []respProto.Value) respProto.Value {
+	return Array([]respProto.Value{
+		BulkString(strconv.FormatUint(cursor, 10)),
+		Array(page),
+	})
+}
+
+// HandleScan implements the SCAN command
+func HandleScan(s *Session, args []respProto.Value) error {
+	if len(args) < 1 {
+		return s.WriteError("ERR wrong number of arguments for 'scan' command")
+	}
+
+	cursor, ok := parseCursor(args[0])
+	if !ok {
+		return s.WriteError("ERR invalid cursor")
+	}
+	opts, errMsg := parseScanOptions(args[1:], true, false)
+	if errMsg != "" {
+		return s.WriteError(errMsg)
+	}
+
+	cache, err := caches.FetchCache(s.SelectedCache())
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+
+	tag := s.Tag("SCAN")
+	cache.Acquire(tag)
+	defer cache.Release(tag)
+
+	ctx, cancel := context.WithTimeout(s.Context(), 5*time.Second)
+	defer cancel()
+
+	typeMatches := func(value any) bool {
+		return opts.keyType == "" || redisType(value) == opts.keyType
+	}
+
+	var keys []string
+	var next uint64
+	if config.RESPPatternMode == "redis" {
+		// Match whole keys, as KEYS does in this mode
+		visit := func(key string, value any) (bool, bool) {
+			if m, ok := value.(map[string]any); ok && !isHash(m) {
+				return false, true
+			}
+			return containers.MatchGlob(opts.match, UntranslateKey(key)) && typeMatches(value), false
+		}
+		keys, next, err = cache.ScanTree(ctx, cursor, opts.count, visit)
+	} else {
+		filter := func(key string, value any) bool { return typeMatches(value) }
+		keys, next, err = cache.Scan(ctx, TranslateKey(opts.match), cursor, opts.count, filter)
+	}
+	if errors.Is(err, caches.ErrInvalidScanCursor) {
+		return s.WriteError("ERR invalid cursor")
+	}
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+
+	page := make([]respProto.Value, len(keys))
+	for i, key := range keys {
+		page[i] = BulkString(UntranslateKey(key))
+	}
+	return s.WriteValue(scanReply(next, page))
+}
+
+// HandleHScan implements the HSCAN command
+func HandleHScan(s *Session, args []respProto.Value) error {
+	if len(args) < 2 {
+		return s.WriteError("ERR wrong number of arguments for 'hscan' command")
+	}
+
+	key := TranslateKey(args[0].String())
+	cursor, ok := parseCursor(args[1])
+	if !ok {
+		return s.WriteError("ERR invalid cursor")
+	}
+	opts, errMsg := parseScanOptions(args[2:], false, true)
+	if errMsg != "" {
+		return s.WriteError(errMsg)
+	}
+
+	cache, err := caches.FetchCache(s.SelectedCache())
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+
+	tag := s.Tag("HSCAN")
+	cache.Acquire(tag)
+	defer cache.Release(tag)
+
+	ctx, cancel := context.WithTimeout(s.Context(), 5*time.Second)
+	defer cancel()
+
+	hash := scanHashFields(ctx, cache, key)
+	fields, next := caches.ScanKeys(matchingFields(hash, opts.match), cursor, opts.count)
+
+	page := make([]respProto.Value, 0, len(fields)*2)
+	for _, field := range fields {
+		page = append(page, BulkString(field))
+		if !opts.noValues {
+			page = append(page, ConvertToRESP(hash[field]))
+		}
+	}
+	return s.WriteValue(scanReply(next, page))
+}
+
+// HandleSScan implements the SSCAN command. Sets are stored as objects whose
+// keys are the members, so SSCAN pages through the keys of an object.
+func HandleSScan(s *Session, args []respProto.Value) error {
+	if len(args) < 2 {
+		return s.WriteError("ERR wrong number of arguments for 'sscan' command")
+	}
+
+	key := TranslateKey(args[0].String())
+	cursor, ok := parseCursor(args[1])
+	if !ok {
+		return s.WriteError("ERR invalid cursor")
+	}
+	opts, errMsg := parseScanOptions(args[2:], false, false)
+	if errMsg != "" {
+		return s.WriteError(errMsg)
+	}
+
+	cache, err := caches.FetchCache(s.SelectedCache())
+	if err != nil {
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+
+	tag := s.Tag("SSCAN")
+	cache.Acquire(tag)
+	defer cache.Release(tag)
+
+	ctx, cancel := context.WithTimeout(s.Context(), 5*time.Second)
+	defer cancel()
+
+	set := scanHashFields(ctx, cache, key)
+	members, next := caches.ScanKeys(matchingFields(set, opts.match), cursor, opts.count)
+
+	page := make([]respProto.Value, len(members))
+	for i, member := range members {
+		page[i] = BulkString(member)
+	}
+	return s.WriteValue(scanReply(next, page))
+}
+
+// scanHashFields returns the object at key, or nil if there is none, so that
+// scanning a missing key or another type returns nothing, as HGETALL does.
+func scanHashFields(ctx context.Context, cache *caches.Cache, key string) map[string]any {
+	value, err := cache.Get(ctx, key)
+	if err != nil {
+		return nil
+	}
+	hash, _ := value.(map[string]any)
+	return hash
+}
+
+// matchingFields yields the fields of hash that match a glob.
+func matchingFields(hash map[string]any, pattern string) func(yield func(string) bool) {
+	return func(yield func(string) bool) {
+		for field := range hash {
+			if !containers.MatchGlob(pattern, field) {
+				continue
+			}
+			if !yield(field) {
+				return
+			}
+		}
+	}
+}
@@ -3,10 +3,12 @@ package resp
 import (
 	"context"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/goodblaster/errors"
 	"github.com/goodblaster/map-cache/pkg/caches"
 	respProto "github.com/tidwall/resp"
 )
@@ -284,21 +286,92 @@ func handleIncrBy(s *Session, args []respProto.Value, increment int64) error {
 	ctx, cancel := context.WithTimeout(s.Context(), 5*time.Second)
 	defer cancel()
 
-	// If key doesn't exist, create it with value 0
-	_, getErr := cache.Get(ctx, key)
-	if getErr != nil {
-		// Key doesn't exist, create with value 0
-		if err := cache.Create(ctx, map[string]any{key: float64(0)}); err != nil {
-			return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	newValue, err := incrementKey(ctx, cache, key, increment)
+	switch {
+	case errors.Is(err, caches.ErrNotANumber):
+		return s.WriteError("ERR value is not an integer or out of range")
+	case errors.Is(err, caches.ErrIntegerOverflow):
+		return s.WriteError("ERR increment or decrement would overflow")
+	case err != nil:
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+	}
+
+	return s.WriteValue(ConvertToRESP(newValue))
+}
+
+// incrementKey adds increment to the number at key, as INCRBY and
+// INCRBYFLOAT do: a missing key counts as 0, and a numeric string, as stored
+// by SET, is read as a number. An integer increment needs an integer value.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func incrementKey(ctx context.Context, cache *caches.Cache, key string, increment any) (any, error) {
+	value, err := cache.Get(ctx, key)
+	if err != nil {
+		if err := cache.Create(ctx, map[string]any{key: int64(0)}); err != nil {
+			return nil, err
 		}
+		value = int64(0)
 	}
 
-	newValue, incrementErr := cache.Increment(ctx, key, increment)
-	if incrementErr != nil {
-		return s.WriteError(fmt.Sprintf("ERR %s", incrementErr.Error()))
+	str, isString := value.(string)
+	if isString {
+		if value, err = parseNumber(str); err != nil {
+			return nil, err
+		}
+	}
+
+	if caches.IsInteger(increment) && !caches.IsInteger(value) {
+		return nil, caches.ErrNotANumber
+	}
+	if !isString {
+		return cache.IncrementNumber(ctx, key, increment)
 	}
 
-	return s.WriteValue(Integer(int(newValue)))
+	// Store the sum in place of the string, only once it is known to be valid
+	sum, err := caches.AddNumbers(value, increment)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Replace(ctx, key, sum); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
+
+// parseNumber reads a number written as a string: an int64 if it is an
+// integer, otherwise a float64.
+func parseNumber(s string) (any, error) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, caches.ErrNotANumber
+	}
+	return f, nil
+}
+
+// floatIncrement returns the increment of INCRBYFLOAT and HINCRBYFLOAT for
+// the value at key: exact for a decimal value, e.g. 0.1 rather than the float
+// nearest to it, and the float otherwise.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func floatIncrement(ctx context.Context, cache *caches.Cache, key string, increment float64, text string) any {
+	if value, err := cache.Get(ctx, key); err == nil {
+		if _, ok := value.(caches.Decimal); ok {
+			if d, err := caches.ParseDecimal(text); err == nil {
+				return d
+			}
+		}
+	}
+	return increment
+}
+
+// floatReply formats the result of INCRBYFLOAT and HINCRBYFLOAT as a string,
+// like Redis.
+func floatReply(value any) respProto.Value {
+	if f, ok := value.(float64); ok {
+		return BulkString(fmt.Sprintf("%.17g", f))
+	}
+	return BulkString(fmt.Sprint(value))
 }
 
 // HandleMGet implements the MGET command
@@ -901,41 +974,13 @@ func HandleIncrByFloat(s *Session, args []respProto.Value) error {
 	ctx, cancel := context.WithTimeout(s.Context(), 5*time.Second)
 	defer cancel()
 
-	// Get current value or initialize to 0
-	value, getErr := cache.Get(ctx, key)
-	var currentVal float64
-	if getErr != nil {
-		// Key doesn't exist, create it with 0
-		currentVal = 0.0
-		if createErr := cache.Create(ctx, map[string]any{key: currentVal}); createErr != nil {
-			return s.WriteError(fmt.Sprintf("ERR %s", createErr.Error()))
-		}
-	} else {
-		// Parse current value
-		switch v := value.(type) {
-		case float64:
-			currentVal = v
-		case int:
-			currentVal = float64(v)
-		case string:
-			parsed, parseErr := strconv.ParseFloat(v, 64)
-			if parseErr != nil {
-				return s.WriteError("ERR value is not a valid float")
-			}
-			currentVal = parsed
-		default:
-			return s.WriteError("ERR value is not a valid float")
-		}
-	}
-
-	// Increment
-	newValue := currentVal + increment
-
-	// Store new value
-	if replaceErr := cache.Replace(ctx, key, newValue); replaceErr != nil {
-		return s.WriteError(fmt.Sprintf("ERR %s", replaceErr.Error()))
+	newValue, err := incrementKey(ctx, cache, key, floatIncrement(ctx, cache, key, increment, args[1].String()))
+	switch {
+	case errors.Is(err, caches.ErrNotANumber):
+		return s.WriteError("ERR value is not a valid float")
+	case err != nil:
+		return s.WriteError(fmt.Sprintf("ERR %s", err.Error()))
 	}
 
-	// Return new value as string (Redis INCRBYFLOAT returns string representation)
-	return s.WriteValue(BulkString(fmt.Sprintf("%.17g", newValue)))
+	return s.WriteValue(floatReply(newValue))
 }
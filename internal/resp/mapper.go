@@ -4,16 +4,62 @@ import (
 	"strings"
 
 	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/containers"
 )
 
 // TranslateKey converts Redis-style keys to map-cache paths.
-// If RESP_KEY_MODE is "translate", converts ":" to "/".
-// Otherwise preserves the key as-is.
+// If RESP_KEY_MODE is "translate", ":" separates path segments, and a
+// KEY_DELIMITER inside a segment is escaped so it stays part of the key;
+// "\:" is a literal colon. Otherwise preserves the key as-is, so map-cache
+// path syntax applies directly.
 func TranslateKey(redisKey string) string {
-	if config.RESPKeyMode == "translate" {
-		return strings.ReplaceAll(redisKey, ":", "/")
+	delim := config.KeyDelimiter
+	if config.RESPKeyMode != "translate" || delim == ":" {
+		return redisKey
 	}
-	return redisKey
+
+	var b strings.Builder
+	for i := 0; i < len(redisKey); i++ {
+		switch {
+		case redisKey[i] == '\\' && i+1 < len(redisKey):
+			if redisKey[i+1] != ':' {
+				b.WriteByte('\\')
+			}
+			i++
+			b.WriteByte(redisKey[i])
+		case redisKey[i] == ':':
+			b.WriteString(delim)
+		case strings.HasPrefix(redisKey[i:], delim):
+			b.WriteString(containers.EscapeKey(delim))
+			i += len(delim) - 1
+		default:
+			b.WriteByte(redisKey[i])
+		}
+	}
+	return b.String()
+}
+
+// UntranslateKey converts a map-cache path, as returned by WildKeys, back to a
+// Redis-style key: the reverse of TranslateKey.
+func UntranslateKey(path string) string {
+	if config.RESPKeyMode != "translate" || config.KeyDelimiter == ":" {
+		return path
+	}
+	segments, err := containers.ParsePath(path)
+	if err != nil {
+		return path
+	}
+	parts := make([]string, len(segments))
+	for i, seg := range segments {
+		if seg.Kind == containers.SegmentWildcard {
+			parts[i] = "*"
+			continue
+		}
+		parts[i] = strings.ReplaceAll(containers.EscapeKey(seg.Key), ":", `\:`)
+		// The delimiter needs no escape once ":" separates segments
+		parts[i] = strings.ReplaceAll(parts[i], containers.EscapeKey(config.KeyDelimiter), config.KeyDelimiter)
+	}
+	return strings.Join(parts, ":")
 }
 
 // TranslateKeys translates multiple Redis keys to map-cache paths.
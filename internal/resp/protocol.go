@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/goodblaster/map-cache/pkg/caches"
 	"github.com/tidwall/resp"
 )
 
@@ -68,6 +69,8 @@ func ConvertToRESP(value any) resp.Value {
 	case float64:
 		// Redis doesn't have native float type, return as string
 		return BulkString(strconv.FormatFloat(v, 'f', -1, 64))
+	case caches.Decimal:
+		return BulkString(v.String())
 	case bool:
 		if v {
 			return Integer(1)
@@ -23,6 +23,7 @@ type Session struct {
 	multiCmds     []resp.Value
 	ctx           context.Context
 	cancel        context.CancelFunc
+	capture       *[]resp.Value // replies held back by IDEMPOTENT, if set
 }
 
 // NewSession creates a new session for a client connection
@@ -50,6 +51,10 @@ func (s *Session) ReadCommand() (resp.Value, error) {
 
 // WriteValue writes a RESP value to the client
 func (s *Session) WriteValue(val resp.Value) error {
+	if s.capture != nil {
+		*s.capture = append(*s.capture, val)
+		return nil
+	}
 	return s.reader.WriteValue(val)
 }
 
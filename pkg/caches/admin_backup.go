@@ -16,19 +16,27 @@ type RawTrigger struct {
 }
 
 type BackupContainer struct {
-	Name           string               `json:"name"`
-	Data           map[string]any       `json:"data"`
-	KeyExpirations map[string]int64     `json:"key_expirations"`
-	Triggers       map[string][]Trigger `json:"triggers,omitempty"`
-	Expiration     *int64               `json:"expiration,omitempty"`
+	Name           string                 `json:"name"`
+	Data           map[string]any         `json:"data"`
+	KeyExpirations map[string]int64       `json:"key_expirations"`
+	Triggers       map[string][]Trigger   `json:"triggers,omitempty"`
+	Procedures     map[string][]Procedure `json:"procedures,omitempty"`
+	Schedules      []Schedule             `json:"schedules,omitempty"`
+	Indexes        []Index                `json:"indexes,omitempty"`
+	Expiration     *int64                 `json:"expiration,omitempty"`
+	Limits         *ExecutionLimits       `json:"limits,omitempty"`
 }
 
 type RestoreContainer struct {
-	Name           string                  `json:"name"`
-	Data           map[string]any          `json:"data"`
-	KeyExpirations map[string]int64        `json:"key_expirations"`
-	Triggers       map[string][]RawTrigger `json:"triggers,omitempty"`
-	Expiration     *int64                  `json:"expiration,omitempty"`
+	Name           string                    `json:"name"`
+	Data           map[string]any            `json:"data"`
+	KeyExpirations map[string]int64          `json:"key_expirations"`
+	Triggers       map[string][]RawTrigger   `json:"triggers,omitempty"`
+	Procedures     map[string][]RawProcedure `json:"procedures,omitempty"`
+	Schedules      []RawSchedule             `json:"schedules,omitempty"`
+	Indexes        []Index                   `json:"indexes,omitempty"`
+	Expiration     *int64                    `json:"expiration,omitempty"`
+	Limits         *ExecutionLimits          `json:"limits,omitempty"`
 }
 
 // Backup creates a backup of the specified cache and saves it to the given file.
@@ -64,11 +72,19 @@ func Backup(ctx context.Context, cacheName string, outFile string) error {
 		Data:           cache.cmap.Data(ctx),
 		KeyExpirations: keysTTLs,
 		Triggers:       cache.triggers,
+		Procedures:     cache.procedures,
+		Schedules:      cache.Schedules(ctx),
+	}
+	for _, info := range cache.Indexes(ctx) {
+		backup.Indexes = append(backup.Indexes, info.Index)
 	}
 
 	if cache.exp != nil {
 		backup.Expiration = &cache.exp.Expiration
 	}
+	if cache.limits != (ExecutionLimits{}) {
+		backup.Limits = &cache.limits
+	}
 
 	err = json.NewEncoder(f).Encode(backup)
 	if err != nil {
@@ -37,6 +37,12 @@ func TestBackupAndRestore(t *testing.T) {
 	triggerId, err := cache.CreateTrigger(ctx, "key1", NOOP())
 	assert.NoError(t, err, "Failed to create trigger for key1")
 
+	// Create a procedure with two versions
+	_, err = cache.SaveProcedure(ctx, "touch", []string{"key"}, NOOP())
+	assert.NoError(t, err)
+	_, err = cache.SaveProcedure(ctx, "touch", []string{"key"}, REPLACE("${{$key}}", "touched"))
+	assert.NoError(t, err)
+
 	// Backup the cache
 	err = Backup(ctx, cacheName, cacheName)
 	assert.NoError(t, err, "Failed to backup cache")
@@ -96,4 +102,18 @@ func TestBackupAndRestore(t *testing.T) {
 	for _, trigger := range triggers {
 		assert.Equal(t, triggerId, trigger.Id, "Trigger ID for key1 does not match")
 	}
+
+	// Verify every version of the procedure was restored
+	versions, err := restoredCache.ProcedureVersions(ctx, "touch")
+	if assert.NoError(t, err) && assert.Len(t, versions, 2) {
+		assert.Equal(t, 2, versions[1].Version)
+		assert.Equal(t, []string{"key"}, versions[1].Params)
+	}
+
+	res := restoredCache.CallProcedure(ctx, "touch", 0, map[string]any{"key": "key2"})
+	assert.NoError(t, res.Error)
+
+	value2, err = restoredCache.Get(ctx, "key2")
+	assert.NoError(t, err)
+	assert.Equal(t, "touched", value2)
 }
@@ -25,9 +25,10 @@ func Restore(ctx context.Context, cacheName string, inFile string) error {
 		Data:           map[string]any{},
 		KeyExpirations: map[string]int64{},
 		Triggers:       map[string][]RawTrigger{},
+		Procedures:     map[string][]RawProcedure{},
 	}
 
-	err = json.NewDecoder(f).Decode(&backup)
+	err = decodeJSON(json.NewDecoder(f), &backup)
 	if err != nil {
 		return errors.Wrapf(err, "error decoding backup file %q", inFile)
 	}
@@ -79,6 +80,47 @@ func Restore(ctx context.Context, cacheName string, inFile string) error {
 		}
 	}
 
+	// Set the procedures, keeping every version
+	for name, rawProcs := range backup.Procedures {
+		for _, rawProc := range rawProcs {
+			cache.procedures[name] = append(cache.procedures[name], Procedure{
+				Name:      name,
+				Params:    rawProc.Params,
+				Command:   rawProc.Command.Command,
+				Version:   rawProc.Version,
+				CreatedAt: rawProc.CreatedAt,
+			})
+		}
+	}
+
+	// Set the schedules, resuming them from their next run once stored
+	for _, raw := range backup.Schedules {
+		err := cache.restoreSchedule(Schedule{
+			Id:         raw.Id,
+			Cron:       raw.Cron,
+			IntervalMs: raw.IntervalMs,
+			At:         raw.At,
+			Command:    raw.Command.Command,
+			Paused:     raw.Paused,
+			NextRun:    raw.NextRun,
+			History:    raw.History,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "error restoring schedule %q", raw.Id)
+		}
+	}
+
+	// Rebuild the indexes from the restored data
+	for _, index := range backup.Indexes {
+		if _, err := cache.CreateIndex(ctx, index); err != nil {
+			return errors.Wrapf(err, "error restoring index %q", index.Name)
+		}
+	}
+
+	if backup.Limits != nil {
+		cache.limits = *backup.Limits
+	}
+
 	// Delete the existing cache if it exists, and its expirations.
 	// Log errors but don't fail - deletion is best-effort before restore
 	if err := DeleteCache(cacheName); err != nil {
@@ -86,5 +128,10 @@ func Restore(ctx context.Context, cacheName string, inFile string) error {
 	}
 
 	caches.Store(cacheName, cache)
+
+	tag := "restore-" + cacheName
+	cache.Acquire(tag)
+	cache.startSchedules()
+	cache.Release(tag)
 	return nil
 }
@@ -53,7 +53,7 @@ func TestRestore(t *testing.T) {
 
 	val, err = cache.Get(ctx, "key2")
 	assert.NoError(t, err)
-	assert.Equal(t, float64(42), val) // JSON unmarshals numbers as float64
+	assert.Equal(t, int64(42), val) // integers are restored as int64
 
 	// Cleanup
 	DeleteCache("test-restore")
@@ -183,7 +183,7 @@ func TestRestoreWithTriggers(t *testing.T) {
 	// Verify data was restored
 	val, err := cache.Get(ctx, "counter")
 	assert.NoError(t, err)
-	assert.Equal(t, float64(0), val) // JSON unmarshals numbers as float64
+	assert.Equal(t, int64(0), val) // integers are restored as int64
 
 	// Cleanup
 	DeleteCache("test-restore-triggers")
@@ -0,0 +1,110 @@
+package caches
+
+import (
+	"context"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/pkg/containers"
+)
+
+// aggregateValues collects the values an aggregate function operates on.
+func aggregateValues(ctx context.Context, cache *Cache, pattern string) ([]any, error) {
+	if !containers.IsPattern(pattern) {
+		// A missing key aggregates as an empty set
+		val, err := lookupRef(ctx, cache, pattern)
+		if errors.Is(err, containers.ErrNotFound) || errors.Is(err, ErrKeyNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if arr, ok := val.([]any); ok {
+			return arr, nil
+		}
+		return []any{val}, nil
+	}
+
+	cache, pattern, _, err := resolveCache(ctx, cache, pattern)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := cache.visitKeys(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, 0, len(keys))
+	for _, key := range keys {
+		val, err := cache.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		values = append(values, val)
+	}
+	return values, nil
+}
+
+// aggregate applies the named aggregate function to values. Numeric
+// aggregates ignore non-numeric values, and keep number types as AddNumbers
+// does: the sum of integers is an integer, failing on overflow, and a
+// Decimal makes it a Decimal. Count is an integer; min, max and avg of an
+// empty set are nil.
+func aggregate(fn string, values []any) (any, error) {
+	switch fn {
+	case "count":
+		return int64(len(values)), nil
+	case "distinct":
+		distinct := make([]any, 0, len(values))
+		for _, v := range values {
+			seen := false
+			for _, d := range distinct {
+				if jsonEqual(v, d) {
+					seen = true
+					break
+				}
+			}
+			if !seen {
+				distinct = append(distinct, v)
+			}
+		}
+		return distinct, nil
+	}
+
+	var sum any = int64(0)
+	var count int64
+	var minVal, maxVal any
+	for _, v := range values {
+		if !IsNumber(v) {
+			continue
+		}
+		if count == 0 {
+			minVal, maxVal = v, v
+		} else {
+			if cmp, err := compareNumbers(v, minVal); err == nil && cmp < 0 {
+				minVal = v
+			}
+			if cmp, err := compareNumbers(v, maxVal); err == nil && cmp > 0 {
+				maxVal = v
+			}
+		}
+		var err error
+		if sum, err = AddNumbers(sum, v); err != nil {
+			return nil, err
+		}
+		count++
+	}
+
+	switch fn {
+	case "sum":
+		return sum, nil
+	case "min":
+		return minVal, nil
+	case "max":
+		return maxVal, nil
+	case "avg":
+		if count == 0 {
+			return nil, nil
+		}
+		return divNumbers(sum, count)
+	}
+	return nil, nil
+}
@@ -0,0 +1,166 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/goodblaster/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAggregateCache(t *testing.T) *Cache {
+	ctx := context.Background()
+	cache := New()
+
+	data := `{
+		"job": {
+			"domains": {
+				"a": {"countdown": 0, "bytes": 100, "status": "done"},
+				"b": {"countdown": 2, "bytes": 50, "status": "pending"},
+				"c": {"countdown": 0, "bytes": 25, "status": "done"}
+			},
+			"tags": ["x", "y", "x"]
+		}
+	}`
+	m := map[string]any{}
+	assert.NoError(t, json.Unmarshal([]byte(data), &m))
+	assert.NoError(t, cache.Create(ctx, m))
+	return cache
+}
+
+func TestAggregates_Return(t *testing.T) {
+	ctx := context.Background()
+	cache := newAggregateCache(t)
+
+	tests := []struct {
+		expr     string
+		expected any
+	}{
+		{"sum(${{job/domains/*/bytes}})", 175.0},
+		{"count(${{job/domains/*/bytes}})", int64(3)},
+		{"min(${{job/domains/*/bytes}})", 25.0},
+		{"max(${{job/domains/*/bytes}})", 100.0},
+		{"avg(${{job/domains/*/countdown}})", 2.0 / 3.0},
+		{"count_where(${{job/domains/*/status}} == \"done\")", int64(2)},
+		{"count_where(${{job/domains/*/countdown}} > 0)", int64(1)},
+		{"sum(${{job/domains/*/bytes}}) / count(${{job/domains/*/bytes}})", 175.0 / 3.0},
+		{"count(${{job/tags}})", int64(3)},
+		{"sum(${{missing/*/bytes}})", int64(0)},
+		{"max(${{missing/*/bytes}})", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			res := RETURN_EXPR(tt.expr).Do(ctx, cache)
+			assert.NoError(t, res.Error)
+			assert.Equal(t, tt.expected, res.Value)
+		})
+	}
+}
+
+func TestAggregates_ReferenceErrors(t *testing.T) {
+	ctx := context.Background()
+	cache := newAggregateCache(t)
+
+	// A missing key is an empty set
+	res := RETURN_EXPR("count(${{job/missing}})").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, int64(0), res.Value)
+
+	// Other errors are not
+	res = RETURN_EXPR("count(${{@other:job/tags}})").Do(ctx, cache)
+	assert.True(t, errors.Is(res.Error, ErrCacheNotLinked))
+
+	res = RETURN_EXPR("sum(${{$missing}})").Do(ctx, cache)
+	assert.True(t, errors.Is(res.Error, ErrVariableNotFound))
+}
+
+func TestAggregates_Distinct(t *testing.T) {
+	ctx := context.Background()
+	cache := newAggregateCache(t)
+
+	res := RETURN_EXPR("distinct(${{job/domains/*/status}})").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.ElementsMatch(t, []any{"done", "pending"}, res.Value)
+
+	res = RETURN_EXPR("distinct(${{job/tags}})").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{"x", "y"}, res.Value)
+}
+
+func TestAggregates_IfAllDomainsDone(t *testing.T) {
+	ctx := context.Background()
+	cache := newAggregateCache(t)
+
+	condition := "count_where(${{job/domains/*/countdown}} == 0) == count(${{job/domains/*/countdown}})"
+	cmd := IF(condition, RETURN("all done"), RETURN("waiting"))
+
+	res := cmd.Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, "waiting", res.Value)
+
+	assert.NoError(t, cache.Replace(ctx, "job/domains/b/countdown", 0))
+	res = cmd.Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, "all done", res.Value)
+}
+
+func TestAggregates_ReplaceExpression(t *testing.T) {
+	ctx := context.Background()
+	cache := newAggregateCache(t)
+	assert.NoError(t, cache.Create(ctx, map[string]any{"total": 0}))
+
+	res := REPLACE_EXPR("total", "sum(${{job/domains/*/bytes}})").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+
+	val, err := cache.Get(ctx, "total")
+	assert.NoError(t, err)
+	assert.Equal(t, 175.0, val)
+}
+
+func TestAggregates_KeepNumberTypes(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{
+		"ints":  []any{int64(9007199254740993), int64(1), int64(-2)},
+		"mixed": []any{int64(1), 1.0, mustDecimal(t, "1.50"), "x"},
+		"big":   []any{int64(math.MaxInt64), int64(1)},
+	}))
+
+	tests := []struct {
+		expr     string
+		expected any
+	}{
+		{"sum(${{ints}})", int64(9007199254740992)},
+		{"max(${{ints}})", int64(9007199254740993)},
+		{"min(${{ints}})", int64(-2)},
+		{"count(${{ints}})", int64(3)},
+		{"avg(${{ints}}) > 3002399751580330", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			res := RETURN_EXPR(tt.expr).Do(ctx, cache)
+			assert.NoError(t, res.Error)
+			assert.Equal(t, tt.expected, res.Value)
+		})
+	}
+
+	res := RETURN_EXPR("sum(${{mixed}})").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	if assert.IsType(t, Decimal{}, res.Value) {
+		assert.Equal(t, "3.50", res.Value.(Decimal).String())
+	}
+
+	res = RETURN_EXPR("distinct(${{mixed}})").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	if assert.Len(t, res.Value, 3) {
+		// int64(1) and 1.0 are the same value
+		assert.Equal(t, int64(1), res.Value.([]any)[0])
+		assert.Equal(t, "x", res.Value.([]any)[2])
+	}
+
+	res = RETURN_EXPR("sum(${{big}})").Do(ctx, cache)
+	assert.True(t, errors.Is(res.Error, ErrIntegerOverflow))
+}
@@ -8,7 +8,10 @@ import (
 // ArrayAppend - Append entry to existing array.
 func (cache *Cache) ArrayAppend(ctx context.Context, key string, value any) error {
 	// Make sure the array exists.
-	path := SplitKey(key)
+	path, err := cache.splitPath(ctx, key)
+	if err != nil {
+		return err
+	}
 	val, err := cache.cmap.Get(ctx, path...)
 	if err != nil {
 		return ErrKeyNotFound.Format(path)
@@ -18,9 +21,13 @@ func (cache *Cache) ArrayAppend(ctx context.Context, key string, value any) erro
 		return ErrNotAnArray.Format(path)
 	}
 
+	if err := chargeWrites(ctx, 1); err != nil {
+		return err
+	}
 	if err := cache.cmap.ArrayAppend(ctx, value, path...); err != nil {
 		return err
 	}
+	cache.updateIndexes(ctx, path)
 
 	return nil
 }
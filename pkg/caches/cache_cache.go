@@ -3,6 +3,7 @@ package caches
 import (
 	"context"
 	"encoding/json"
+	"iter"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,19 +14,27 @@ import (
 type Cache struct {
 	cmap          containers.Map
 	mutex         *sync.Mutex
-	tag           *string              // who owns this
-	exp           *Timer               // expiration timer
-	expMillis     *int64               // TTL in milliseconds (for stats)
-	keyExps       map[string]*Timer    // key-based expiration timers
-	triggers      map[string][]Trigger // key-based triggers
-	lastAccessed  *time.Time           // last access timestamp
-	activityCount atomic.Int64         // count of operations (thread-safe)
-	opStats       *OperationStats      // long-running operation tracking
+	tag           *string                    // who owns this
+	exp           *Timer                     // expiration timer
+	expMillis     *int64                     // TTL in milliseconds (for stats)
+	keyExps       map[string]*Timer          // key-based expiration timers
+	triggers      map[string][]Trigger       // key-based triggers
+	procedures    map[string][]Procedure     // stored procedures, all versions
+	indexes       map[string]*secondaryIndex // secondary indexes by name
+	schedules     *scheduler                 // scheduled commands
+	jobs          *jobRegistry               // background jobs
+	idempotency   idempotencyStore           // responses by idempotency key
+	lastAccessed  *time.Time                 // last access timestamp
+	activityCount atomic.Int64               // count of operations (thread-safe)
+	opStats       *OperationStats            // long-running operation tracking
+	dryRun        *dryRunRecorder            // set only on dry-run views
+	limits        ExecutionLimits            // per-execution limits
+	scanCursors   map[uint64]*scanCursor     // where scans in progress resume
 
 	// Batch expiration handling to prevent goroutine storms
-	expirationChan chan string      // channel for expired keys
-	expirationStop chan struct{}    // signal to stop expiration worker
-	expirationWg   sync.WaitGroup   // wait for worker to finish
+	expirationChan chan string    // channel for expired keys
+	expirationStop chan struct{}  // signal to stop expiration worker
+	expirationWg   sync.WaitGroup // wait for worker to finish
 }
 
 func New() *Cache {
@@ -34,7 +43,11 @@ func New() *Cache {
 		mutex:          &sync.Mutex{},
 		keyExps:        map[string]*Timer{},
 		triggers:       map[string][]Trigger{},
-		opStats:        NewOperationStats(100), // Keep last 100 long operations
+		procedures:     map[string][]Procedure{},
+		indexes:        map[string]*secondaryIndex{},
+		schedules:      newScheduler(),
+		jobs:           newJobRegistry(),
+		opStats:        NewOperationStats(100),  // Keep last 100 long operations
 		expirationChan: make(chan string, 1000), // Buffer for 1000 expired keys
 		expirationStop: make(chan struct{}),
 	}
@@ -95,6 +108,13 @@ func (cache *Cache) WildKeys(ctx context.Context, pattern string) []string {
 	return cache.cmap.WildKeys(ctx, pattern)
 }
 
+// MatchKeys yields the key and value of each value matching the pattern, as
+// WildKeys does, without collecting them first.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) MatchKeys(ctx context.Context, pattern string) iter.Seq2[string, any] {
+	return cache.cmap.MatchKeys(ctx, pattern)
+}
+
 // KeyExpirations returns the map of keys with expiration timers.
 // This method is NOT thread-safe - caller must acquire the cache lock first.
 func (cache *Cache) KeyExpirations() map[string]*Timer {
@@ -143,4 +163,6 @@ func (cache *Cache) Close() {
 	if cache.exp != nil {
 		cache.exp.Stop()
 	}
+
+	cache.stopSchedules()
 }
@@ -13,8 +13,12 @@ func (cache *Cache) Create(ctx context.Context, entries map[string]any) error {
 	// Check all keys first.
 	// Keys must be a single path segment.
 	// And the keys must not already exist.
+	paths := make(map[string][]string, len(entries))
 	for key := range entries {
-		path := SplitKey(key)
+		path, err := cache.splitPath(ctx, key)
+		if err != nil {
+			return err
+		}
 		//if len(path) != 1 {
 		//	return ErrSinglePathKeyRequired.Format(key)
 		//}
@@ -22,13 +26,18 @@ func (cache *Cache) Create(ctx context.Context, entries map[string]any) error {
 		if cache.cmap.Exists(ctx, path...) {
 			return ErrKeyAlreadyExists.Format(path)
 		}
+		paths[key] = path
 	}
 
 	// Now set the entries.
+	if err := chargeWrites(ctx, len(entries)); err != nil {
+		return err
+	}
 	for key, value := range entries {
-		if err := cache.cmap.Set(ctx, value, SplitKey(key)...); err != nil {
+		if err := cache.cmap.Set(ctx, value, paths[key]...); err != nil {
 			return errors.Wrap(err, "could not set value")
 		}
+		cache.updateIndexes(ctx, paths[key])
 	}
 
 	return nil
@@ -5,40 +5,67 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/goodblaster/map-cache/internal/config"
 	"github.com/goodblaster/map-cache/internal/log"
 )
 
+// Delete removes keys from the cache. The last segment of each key is a map key
+// or an array index depending on what holds it, so numeric map keys such as
+// user ids delete as keys, and "items/[2]" or "items/2" removes an element.
 func (cache *Cache) Delete(ctx context.Context, keys ...string) error {
 	cache.recordActivity()
 
+	if err := chargeWrites(ctx, len(keys)); err != nil {
+		return err
+	}
+
 	for _, key := range keys {
-		path := SplitKey(key)
+		path, err := cache.splitPath(ctx, key)
+		if err != nil {
+			// Log but don't fail - deletion is best-effort
+			log.WithError(err).With("key", key).Warn("failed to delete key")
+			continue
+		}
 
-		// If the last value of the key is a number, assume it is an array element.
-		// We need to handle those differently.
+		// An element of an array is removed, shifting the ones after it. This
+		// goes by what holds it: a numeric key of a map is deleted as a key.
 		if len(path) > 1 {
-			lastStr := path[len(path)-1]
-			if i, err := strconv.Atoi(lastStr); err == nil {
-				if err := cache.cmap.ArrayRemove(ctx, i, path[:len(path)-1]...); err != nil {
-					// Log but don't fail - deletion is best-effort for array elements
-					log.WithError(err).With("key", key).With("index", i).Warn("failed to remove array element")
+			if parent, err := cache.cmap.Get(ctx, path[:len(path)-1]...); err == nil {
+				if _, isArray := parent.([]any); isArray {
+					i, err := strconv.Atoi(path[len(path)-1])
+					if err == nil {
+						err = cache.cmap.ArrayRemove(ctx, i, path[:len(path)-1]...)
+					}
+					if err != nil {
+						// Log but don't fail - deletion is best-effort for array elements
+						log.WithError(err).With("key", key).Warn("failed to remove array element")
+					}
+					// Later elements moved, so the whole array changed
+					cache.updateIndexes(ctx, path[:len(path)-1])
+					continue
 				}
-				continue
 			}
 		}
 
-		// Clear any TTLs that start with this key.
-		for k, timer := range cache.keyExps {
-			if strings.HasPrefix(k, key) {
-				timer.Stop()
-				delete(cache.keyExps, k)
-			}
-		}
+		// Clear any TTLs on this key or beneath it.
+		cache.clearKeyExpirations(key)
 
 		// Delete the key - log errors but don't fail (deletion is best-effort)
 		if err := cache.cmap.Delete(ctx, path...); err != nil {
 			log.WithError(err).With("key", key).Warn("failed to delete key")
 		}
+		cache.updateIndexes(ctx, path)
 	}
 	return nil
 }
+
+// clearKeyExpirations stops and removes the TTLs of key and the keys beneath it.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) clearKeyExpirations(key string) {
+	for k, timer := range cache.keyExps {
+		if k == key || strings.HasPrefix(k, key+config.KeyDelimiter) {
+			timer.Stop()
+			delete(cache.keyExps, k)
+		}
+	}
+}
@@ -35,3 +35,56 @@ func TestCache_Delete(t *testing.T) {
 	err = cache.Delete(ctx, "nonExistentKey")
 	assert.NoError(t, err)
 }
+
+func TestCache_Delete_NumericMapKey(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+
+	err := cache.Create(ctx, map[string]any{
+		"users": map[string]any{"7": "alice", "8": "bob"},
+		"items": []any{"a", "b", "c"},
+	})
+	assert.NoError(t, err)
+
+	// A numeric key of a map is deleted as a key
+	assert.NoError(t, cache.Delete(ctx, "users/7"))
+	value, err := cache.Get(ctx, "users")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"8": "bob"}, value)
+
+	// An index of an array removes the element
+	assert.NoError(t, cache.Delete(ctx, "items/[0]", "items/1"))
+	value, err = cache.Get(ctx, "items")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"b"}, value)
+}
+
+func TestCache_EscapedKeys(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+
+	err := cache.Create(ctx, map[string]any{
+		`files/report\/2024.pdf`: "r",
+		`files/\*`:               "star",
+	})
+	assert.NoError(t, err)
+
+	files, err := cache.Get(ctx, "files")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"report/2024.pdf": "r", "*": "star"}, files)
+
+	assert.NoError(t, cache.Replace(ctx, `files/report\/2024.pdf`, "updated"))
+	value, err := cache.Get(ctx, `files/report\/2024.pdf`)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", value)
+
+	// Typed segments must agree with the data
+	_, err = cache.Get(ctx, "files/[0]")
+	assert.Error(t, err)
+
+	assert.NoError(t, cache.Delete(ctx, `files/\*`))
+	_, err = cache.Get(ctx, `files/\*`)
+	assert.Error(t, err)
+	_, err = cache.Get(ctx, `files/report\/2024.pdf`)
+	assert.NoError(t, err)
+}
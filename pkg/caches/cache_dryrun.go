@@ -0,0 +1,287 @@
+package caches
+
+import (
+	"context"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/goodblaster/map-cache/pkg/containers"
+)
+
+// DryRunResult describes what executing commands would do, without doing it.
+type DryRunResult struct {
+	Value    any             `json:"value"`
+	Error    string          `json:"error,omitempty"`
+	Reads    []string        `json:"reads"`
+	Writes   []KeyWrite      `json:"writes"`
+	Triggers []*TriggerFired `json:"triggers"`
+	Trace    []*TraceNode    `json:"trace"`
+}
+
+// KeyWrite is one change made to a key during a dry run.
+type KeyWrite struct {
+	Key      string `json:"key"`
+	Op       string `json:"op"` // set, delete, append, remove or resize
+	OldValue any    `json:"old_value"`
+	NewValue any    `json:"new_value"`
+}
+
+// TriggerFired identifies a trigger that fired during a dry run.
+type TriggerFired struct {
+	Id         string `json:"id"`
+	Pattern    string `json:"pattern"`
+	Key        string `json:"key"` // the key whose change fired it
+	DurationUs int64  `json:"duration_us"`
+}
+
+// TraceNode is the timing of one executed command. Children are the commands
+// it ran, in order, so the tree mirrors the command structure; loops have one
+// child per command run.
+type TraceNode struct {
+	Type       CommandType  `json:"type"`
+	DurationUs int64        `json:"duration_us"`
+	Error      string       `json:"error,omitempty"`
+	Children   []*TraceNode `json:"children,omitempty"`
+}
+
+// DryRun executes commands against a throwaway copy-on-write view of the
+// cache and reports the keys read and written, the triggers that fired and a
+// timing trace. The cache itself is never modified, nor are other caches
+// used with @name: keys, whose keys are reported with their prefix.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) DryRun(ctx context.Context, commands ...Command) DryRunResult {
+	rec := &dryRunRecorder{readSet: map[string]bool{}}
+	view := cache.dryRunView(rec, "")
+
+	if linked := linkedCaches(ctx); linked != nil {
+		views := make(map[string]*Cache, len(linked))
+		for name, other := range linked {
+			if other == cache {
+				views[name] = view
+			} else {
+				views[name] = other.dryRunView(rec, CacheRefPrefix+name+":")
+			}
+		}
+		ctx = WithCaches(ctx, views)
+	}
+
+	res := view.Execute(ctx, commands...)
+
+	result := DryRunResult{
+		Value:    res.Value,
+		Reads:    rec.reads,
+		Writes:   rec.writes,
+		Triggers: rec.triggers,
+		Trace:    rec.root.Children,
+	}
+	if res.Error != nil {
+		result.Error = res.Error.Error()
+	}
+	if result.Reads == nil {
+		result.Reads = []string{}
+	}
+	if result.Writes == nil {
+		result.Writes = []KeyWrite{}
+	}
+	if result.Triggers == nil {
+		result.Triggers = []*TriggerFired{}
+	}
+	if result.Trace == nil {
+		result.Trace = []*TraceNode{}
+	}
+	return result
+}
+
+// dryRunView returns a copy-on-write view of the cache that records to rec,
+// reporting keys with prefix.
+func (cache *Cache) dryRunView(rec *dryRunRecorder, prefix string) *Cache {
+	return &Cache{
+		cmap:       &cowMap{base: cache.cmap, rec: rec, prefix: prefix},
+		mutex:      &sync.Mutex{},
+		keyExps:    map[string]*Timer{},
+		triggers:   cache.triggers,
+		procedures: cache.procedures,
+		indexes:    cache.cloneIndexes(),
+		limits:     cache.limits,
+		opStats:    NewOperationStats(0),
+		dryRun:     rec,
+	}
+}
+
+// dryRunRecorder collects what happens during a dry run.
+type dryRunRecorder struct {
+	reads    []string
+	readSet  map[string]bool
+	writes   []KeyWrite
+	triggers []*TriggerFired
+	root     TraceNode
+	stack    []*TraceNode
+}
+
+func (rec *dryRunRecorder) read(key string) {
+	if !rec.readSet[key] {
+		rec.readSet[key] = true
+		rec.reads = append(rec.reads, key)
+	}
+}
+
+func (rec *dryRunRecorder) write(op, key string, oldValue, newValue any) {
+	rec.writes = append(rec.writes, KeyWrite{Key: key, Op: op, OldValue: oldValue, NewValue: newValue})
+}
+
+// triggerFired records that trigger fired because key changed.
+func (rec *dryRunRecorder) triggerFired(trigger Trigger, key string) *TriggerFired {
+	fired := &TriggerFired{Id: trigger.Id, Pattern: trigger.Key, Key: key}
+	rec.triggers = append(rec.triggers, fired)
+	return fired
+}
+
+// begin adds a trace node for cmd under the command currently running.
+func (rec *dryRunRecorder) begin(cmd Command) *TraceNode {
+	parent := &rec.root
+	if len(rec.stack) > 0 {
+		parent = rec.stack[len(rec.stack)-1]
+	}
+	node := &TraceNode{Type: cmd.Type()}
+	parent.Children = append(parent.Children, node)
+	rec.stack = append(rec.stack, node)
+	return node
+}
+
+func (rec *dryRunRecorder) end(node *TraceNode, elapsed time.Duration, err error) {
+	node.DurationUs = elapsed.Microseconds()
+	if err != nil {
+		node.Error = err.Error()
+	}
+	rec.stack = rec.stack[:len(rec.stack)-1]
+}
+
+// cowMap is a copy-on-write view of another map. Reads go to the base map
+// until the first write, which copies the base data; from then on the copy is
+// used and the base map is never touched. Every access is recorded.
+type cowMap struct {
+	base   containers.Map
+	copy   containers.Map
+	rec    *dryRunRecorder
+	prefix string // @name: of another cache, for recorded keys
+}
+
+var _ containers.Map = (*cowMap)(nil)
+
+func (m *cowMap) current() containers.Map {
+	if m.copy != nil {
+		return m.copy
+	}
+	return m.base
+}
+
+func (m *cowMap) writable(ctx context.Context) (containers.Map, error) {
+	if m.copy == nil {
+		cp := containers.NewGabsMap()
+		if err := cp.Set(ctx, deepCopy(m.base.Data(ctx))); err != nil {
+			return nil, err
+		}
+		m.copy = cp
+	}
+	return m.copy, nil
+}
+
+// valueAt returns a copy of the value at path, or nil if there is none.
+func (m *cowMap) valueAt(ctx context.Context, path []string) any {
+	val, err := m.current().Get(ctx, path...)
+	if err != nil {
+		return nil
+	}
+	return deepCopy(val)
+}
+
+func joinKey(path []string) string {
+	return containers.JoinPath(path...)
+}
+
+func (m *cowMap) Get(ctx context.Context, hierarchy ...string) (containers.Data, error) {
+	m.rec.read(m.prefix + joinKey(hierarchy))
+	return m.current().Get(ctx, hierarchy...)
+}
+
+func (m *cowMap) Exists(ctx context.Context, hierarchy ...string) bool {
+	m.rec.read(m.prefix + joinKey(hierarchy))
+	return m.current().Exists(ctx, hierarchy...)
+}
+
+func (m *cowMap) Data(ctx context.Context) map[string]any {
+	return m.current().Data(ctx)
+}
+
+func (m *cowMap) WildKeys(ctx context.Context, path string) []string {
+	return m.current().WildKeys(ctx, path)
+}
+
+func (m *cowMap) MatchKeys(ctx context.Context, path string) iter.Seq2[string, any] {
+	return m.current().MatchKeys(ctx, path)
+}
+
+func (m *cowMap) Set(ctx context.Context, value any, hierarchy ...string) error {
+	return m.modify(ctx, "set", hierarchy, func(w containers.Map) error {
+		return w.Set(ctx, value, hierarchy...)
+	})
+}
+
+func (m *cowMap) Delete(ctx context.Context, hierarchy ...string) error {
+	return m.modify(ctx, "delete", hierarchy, func(w containers.Map) error {
+		return w.Delete(ctx, hierarchy...)
+	})
+}
+
+func (m *cowMap) ArrayAppend(ctx context.Context, value any, path ...string) error {
+	return m.modify(ctx, "append", path, func(w containers.Map) error {
+		return w.ArrayAppend(ctx, value, path...)
+	})
+}
+
+func (m *cowMap) ArrayRemove(ctx context.Context, index int, path ...string) error {
+	return m.modify(ctx, "remove", path, func(w containers.Map) error {
+		return w.ArrayRemove(ctx, index, path...)
+	})
+}
+
+func (m *cowMap) ArrayResize(ctx context.Context, newSize int, path ...string) error {
+	return m.modify(ctx, "resize", path, func(w containers.Map) error {
+		return w.ArrayResize(ctx, newSize, path...)
+	})
+}
+
+// modify applies a write to the copy and records the key's old and new value.
+func (m *cowMap) modify(ctx context.Context, op string, path []string, apply func(containers.Map) error) error {
+	w, err := m.writable(ctx)
+	if err != nil {
+		return err
+	}
+
+	oldValue := m.valueAt(ctx, path)
+	if err := apply(w); err != nil {
+		return err
+	}
+	m.rec.write(op, m.prefix+joinKey(path), oldValue, m.valueAt(ctx, path))
+	return nil
+}
+
+// deepCopy copies maps and arrays so the copy can be modified independently.
+func deepCopy(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		cp := make(map[string]any, len(val))
+		for k, child := range val {
+			cp[k] = deepCopy(child)
+		}
+		return cp
+	case []any:
+		cp := make([]any, len(val))
+		for i, child := range val {
+			cp[i] = deepCopy(child)
+		}
+		return cp
+	}
+	return v
+}
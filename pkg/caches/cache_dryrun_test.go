@@ -0,0 +1,113 @@
+package caches
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun_DoesNotModifyCache(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"counter": 1.0,
+		"status":  "busy",
+		"tmp":     "x",
+		"list":    []any{1.0, 2.0},
+	})
+	assert.NoError(t, err)
+
+	res := cache.DryRun(ctx,
+		INC("counter", 5),
+		REPLACE("status", "done"),
+		DELETE("tmp"),
+		DELETE("list/0"),
+		RETURN("${{counter}}"),
+	)
+	assert.Empty(t, res.Error)
+	assert.Equal(t, 6.0, res.Value.([]any)[4])
+
+	// The live cache is unchanged
+	assert.Equal(t, map[string]any{
+		"counter": 1.0,
+		"status":  "busy",
+		"tmp":     "x",
+		"list":    []any{1.0, 2.0},
+	}, cache.cmap.Data(ctx))
+
+	assert.Equal(t, []KeyWrite{
+		{Key: "counter", Op: "set", OldValue: 1.0, NewValue: 6.0},
+		{Key: "status", Op: "set", OldValue: "busy", NewValue: "done"},
+		{Key: "tmp", Op: "delete", OldValue: "x", NewValue: nil},
+		{Key: "list", Op: "remove", OldValue: []any{1.0, 2.0}, NewValue: []any{2.0}},
+	}, res.Writes)
+	assert.Contains(t, res.Reads, "counter")
+	assert.Contains(t, res.Reads, "status")
+}
+
+func TestDryRun_TriggersAndTrace(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"jobs": map[string]any{"1": map[string]any{"countdown": 1.0, "status": "busy"}},
+	})
+	assert.NoError(t, err)
+
+	id, err := cache.CreateTrigger(ctx, "jobs/{job}/countdown", IF(
+		"${{jobs/${{1}}/countdown}} == 0",
+		REPLACE("jobs/${{$job}}/status", "complete"),
+		NOOP(),
+	))
+	assert.NoError(t, err)
+
+	res := cache.DryRun(ctx, COMMANDS(INC("jobs/1/countdown", -1)))
+	assert.Empty(t, res.Error)
+
+	if assert.Len(t, res.Triggers, 1) {
+		assert.Equal(t, id, res.Triggers[0].Id)
+		assert.Equal(t, "jobs/{job}/countdown", res.Triggers[0].Pattern)
+		assert.Equal(t, "jobs/1/countdown", res.Triggers[0].Key)
+	}
+
+	// The trigger's write is part of the dry run, not the cache
+	assert.Len(t, res.Writes, 2)
+	assert.Equal(t, "jobs/1/status", res.Writes[1].Key)
+	status, err := cache.Get(ctx, "jobs/1/status")
+	assert.NoError(t, err)
+	assert.Equal(t, "busy", status)
+
+	// COMMANDS -> INC -> (trigger) IF -> REPLACE
+	if assert.Len(t, res.Trace, 1) {
+		group := res.Trace[0]
+		assert.Equal(t, CommandTypeGroup, group.Type)
+		inc := group.Children[0]
+		assert.Equal(t, CommandTypeInc, inc.Type)
+		if assert.Len(t, inc.Children, 1) {
+			assert.Equal(t, CommandTypeIf, inc.Children[0].Type)
+			assert.Equal(t, CommandTypeReplace, inc.Children[0].Children[0].Type)
+		}
+	}
+}
+
+func TestDryRun_ReportsErrors(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"a": 1.0})
+	assert.NoError(t, err)
+
+	res := cache.DryRun(ctx, REPLACE("a", 2.0), INC("missing", 1))
+	assert.Contains(t, res.Error, "key not found")
+	assert.Len(t, res.Writes, 1)
+	if assert.Len(t, res.Trace, 2) {
+		assert.Empty(t, res.Trace[0].Error)
+		assert.NotEmpty(t, res.Trace[1].Error)
+	}
+
+	val, err := cache.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, val)
+}
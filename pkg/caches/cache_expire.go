@@ -46,6 +46,7 @@ func (cache *Cache) expirationWorker() {
 			if err := cache.cmap.Delete(ctx, path...); err != nil {
 				log.WithError(err).With("key", key).Warn("failed to delete expired key")
 			}
+			cache.updateIndexes(ctx, path)
 
 			// Clean up timer reference (already stopped by FutureFunc)
 			delete(cache.keyExps, key)
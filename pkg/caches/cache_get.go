@@ -7,7 +7,11 @@ import (
 // Get - Get one specific value from the cache.
 func (cache *Cache) Get(ctx context.Context, key string) (any, error) {
 	cache.recordActivity()
-	return cache.cmap.Get(ctx, SplitKey(key)...)
+	path, err := cache.splitPath(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return cache.cmap.Get(ctx, path...)
 }
 
 // BatchGet - BatchGet values from the cache.
@@ -17,7 +21,11 @@ func (cache *Cache) BatchGet(ctx context.Context, keys ...string) ([]any, error)
 
 	for _, key := range keys {
 		// Use cmap.Get directly to avoid double-counting activity
-		val, err := cache.cmap.Get(ctx, SplitKey(key)...)
+		path, err := cache.splitPath(ctx, key)
+		if err != nil {
+			return vals, err
+		}
+		val, err := cache.cmap.Get(ctx, path...)
 		if err != nil {
 			return vals, err
 		}
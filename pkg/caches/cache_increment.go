@@ -4,28 +4,47 @@ import (
 	"context"
 )
 
-// Increment - Increment single value in the cache.
+// Increment - Increment single value in the cache. The stored value keeps its
+// type, as with IncrementNumber; the new value is returned as a float64.
 func (cache *Cache) Increment(ctx context.Context, key string, value any) (float64, error) {
-	// Check key first. Error if does not exist.
-	oldValue, err := cache.cmap.Get(ctx, SplitKey(key)...)
+	sum, err := cache.IncrementNumber(ctx, key, value)
+	if err != nil {
+		return 0, err
+	}
+	f64, _ := ToFloat64(sum)
+	return f64, nil
+}
 
+// IncrementNumber increments a single value in the cache and returns the new
+// value with its type: integers stay integers, see AddNumbers.
+func (cache *Cache) IncrementNumber(ctx context.Context, key string, value any) (any, error) {
+	path, err := cache.splitPath(ctx, key)
 	if err != nil {
-		return 0, ErrKeyNotFound.Format(key)
+		return nil, err
 	}
 
-	f64, ok := ToFloat64(oldValue)
-	if !ok {
-		return 0, ErrNotANumber
+	// Check key first. Error if does not exist.
+	oldValue, err := cache.cmap.Get(ctx, path...)
+
+	if err != nil {
+		return nil, ErrKeyNotFound.Format(key)
 	}
 
-	inc, ok := ToFloat64(value)
-	if !ok {
-		return 0, ErrIncrementValueNotNumber
+	if !IsNumber(oldValue) {
+		return nil, ErrNotANumber
+	}
+	if !IsNumber(value) {
+		return nil, ErrIncrementValueNotNumber
 	}
 
-	f64 += inc
+	sum, err := AddNumbers(oldValue, value)
+	if err != nil {
+		return nil, err
+	}
 
 	// Now set the value.
-	err = cache.Replace(ctx, key, f64)
-	return f64, err
+	if err := cache.Replace(ctx, key, sum); err != nil {
+		return nil, err
+	}
+	return sum, nil
 }
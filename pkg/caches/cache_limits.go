@@ -0,0 +1,224 @@
+package caches
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/internal/config"
+)
+
+// ExecutionLimits bounds the work a single execution may do while it holds
+// the cache lock. Zero means no limit.
+//
+// The limits that apply are the strictest of the configured defaults, the
+// cache's limits and the request's limits (see WithExecutionLimits), so a
+// cache or request can lower a limit but never raise it.
+type ExecutionLimits struct {
+	MaxSteps       int `json:"max_steps,omitempty"`        // commands run, including nested commands and triggers
+	MaxKeysVisited int `json:"max_keys_visited,omitempty"` // keys matched by wildcards
+	MaxWrites      int `json:"max_writes,omitempty"`       // keys written or deleted
+	MaxDepth       int `json:"max_depth,omitempty"`        // command nesting depth
+}
+
+// DefaultExecutionLimits returns the configured limits.
+func DefaultExecutionLimits() ExecutionLimits {
+	return ExecutionLimits{
+		MaxSteps:       config.CommandMaxSteps,
+		MaxKeysVisited: config.CommandMaxKeysVisited,
+		MaxWrites:      config.CommandMaxWrites,
+		MaxDepth:       config.CommandMaxDepth,
+	}
+}
+
+// Tighten returns the stricter of each limit in l and other.
+func (l ExecutionLimits) Tighten(other ExecutionLimits) ExecutionLimits {
+	return ExecutionLimits{
+		MaxSteps:       tighterLimit(l.MaxSteps, other.MaxSteps),
+		MaxKeysVisited: tighterLimit(l.MaxKeysVisited, other.MaxKeysVisited),
+		MaxWrites:      tighterLimit(l.MaxWrites, other.MaxWrites),
+		MaxDepth:       tighterLimit(l.MaxDepth, other.MaxDepth),
+	}
+}
+
+func tighterLimit(a, b int) int {
+	if b > 0 && (a <= 0 || b < a) {
+		return b
+	}
+	return a
+}
+
+// Validate checks that no limit is negative.
+func (l ExecutionLimits) Validate() error {
+	if l.MaxSteps < 0 || l.MaxKeysVisited < 0 || l.MaxWrites < 0 || l.MaxDepth < 0 {
+		return ErrInvalidExecutionLimits
+	}
+	return nil
+}
+
+// ErrExecutionLimit is matched (with errors.Is) by every LimitError.
+var ErrExecutionLimit = errors.New("execution limit exceeded")
+
+// LimitError reports the execution limit that stopped an execution.
+type LimitError struct {
+	Limit string // json name of the limit, e.g. "max_writes"
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("execution limit exceeded: %s is %d", e.Limit, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrExecutionLimit
+}
+
+// Limits returns the cache's execution limits.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) Limits() ExecutionLimits {
+	return cache.limits
+}
+
+// CacheLimits returns the execution limits of a cache.
+func CacheLimits(name string) (ExecutionLimits, error) {
+	cache, err := FetchCache(name)
+	if err != nil {
+		return ExecutionLimits{}, err
+	}
+
+	tag := "limits-" + name
+	cache.Acquire(tag)
+	defer cache.Release(tag)
+	return cache.limits, nil
+}
+
+// SetCacheLimits sets the execution limits of a cache.
+func SetCacheLimits(name string, limits ExecutionLimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+
+	cache, err := FetchCache(name)
+	if err != nil {
+		return err
+	}
+
+	tag := "limits-" + name
+	cache.Acquire(tag)
+	defer cache.Release(tag)
+	cache.limits = limits
+	return nil
+}
+
+type executionLimitsKey struct{}
+type executionBudgetKey struct{}
+
+var (
+	executionLimitsContextKey = executionLimitsKey{}
+	executionBudgetContextKey = executionBudgetKey{}
+)
+
+// WithExecutionLimits returns a context whose executions are also bounded by
+// limits, for limits set on a single request.
+func WithExecutionLimits(ctx context.Context, limits ExecutionLimits) context.Context {
+	if prev, ok := ctx.Value(executionLimitsContextKey).(ExecutionLimits); ok {
+		limits = prev.Tighten(limits)
+	}
+	return context.WithValue(ctx, executionLimitsContextKey, limits)
+}
+
+// executionBudget counts the work done by one execution. It is only used
+// while the cache lock is held.
+type executionBudget struct {
+	limits      ExecutionLimits
+	steps       int
+	keysVisited int
+	writes      int
+	depth       int
+}
+
+// withBudget starts counting an execution against the cache's limits, unless
+// ctx already belongs to one (e.g. commands run by a trigger).
+func (cache *Cache) withBudget(ctx context.Context) context.Context {
+	if budgetFrom(ctx) != nil {
+		return ctx
+	}
+	limits := DefaultExecutionLimits().Tighten(cache.limits)
+	if req, ok := ctx.Value(executionLimitsContextKey).(ExecutionLimits); ok {
+		limits = limits.Tighten(req)
+	}
+	return context.WithValue(ctx, executionBudgetContextKey, &executionBudget{limits: limits})
+}
+
+func budgetFrom(ctx context.Context) *executionBudget {
+	b, _ := ctx.Value(executionBudgetContextKey).(*executionBudget)
+	return b
+}
+
+// enter counts a command about to run, one level deeper than its caller.
+func (b *executionBudget) enter() error {
+	b.steps++
+	if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+		return &LimitError{Limit: "max_steps", Max: b.limits.MaxSteps}
+	}
+	if b.limits.MaxDepth > 0 && b.depth >= b.limits.MaxDepth {
+		return &LimitError{Limit: "max_depth", Max: b.limits.MaxDepth}
+	}
+	b.depth++
+	return nil
+}
+
+// leave is called when a command that entered has finished.
+func (b *executionBudget) leave() {
+	b.depth--
+}
+
+// chargeWrites counts n keys about to be written. Writes made outside of an
+// execution are not counted.
+func chargeWrites(ctx context.Context, n int) error {
+	b := budgetFrom(ctx)
+	if b == nil {
+		return nil
+	}
+	b.writes += n
+	if b.limits.MaxWrites > 0 && b.writes > b.limits.MaxWrites {
+		return &LimitError{Limit: "max_writes", Max: b.limits.MaxWrites}
+	}
+	return nil
+}
+
+// visitKeys returns the keys matching a wildcard pattern, counting them
+// against the execution's budget. It stops matching as soon as the budget is
+// spent.
+func (cache *Cache) visitKeys(ctx context.Context, pattern string) ([]string, error) {
+	b := budgetFrom(ctx)
+	var keys []string
+	for key := range cache.cmap.MatchKeys(ctx, pattern) {
+		if b != nil {
+			if err := b.visit(1); err != nil {
+				return nil, err
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// chargeKeysVisited counts keys against the execution's budget.
+func chargeKeysVisited(ctx context.Context, keys []string) ([]string, error) {
+	if b := budgetFrom(ctx); b != nil {
+		if err := b.visit(len(keys)); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// visit counts n keys visited.
+func (b *executionBudget) visit(n int) error {
+	b.keysVisited += n
+	if b.limits.MaxKeysVisited > 0 && b.keysVisited > b.limits.MaxKeysVisited {
+		return &LimitError{Limit: "max_keys_visited", Max: b.limits.MaxKeysVisited}
+	}
+	return nil
+}
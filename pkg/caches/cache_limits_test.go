@@ -0,0 +1,155 @@
+package caches
+
+import (
+	"context"
+	"iter"
+	"strconv"
+	"testing"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/containers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func limitError(t *testing.T, err error) *LimitError {
+	t.Helper()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrExecutionLimit))
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	return limitErr
+}
+
+func TestExecutionLimits_Steps(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{"n": 0.0}))
+	cache.limits = ExecutionLimits{MaxSteps: 5}
+
+	// The loop itself is a step, so only four increments run
+	res := cache.Execute(ctx, FOR_RANGE(0, 10, INC("n", 1)))
+	limitErr := limitError(t, res.Error)
+	assert.Equal(t, "max_steps", limitErr.Limit)
+	assert.Equal(t, 5, limitErr.Max)
+
+	n, _ := cache.Get(ctx, "n")
+	assert.Equal(t, 4.0, n)
+
+	// Each execution has its own budget
+	res = cache.Execute(ctx, INC("n", 1))
+	require.NoError(t, res.Error)
+}
+
+func TestExecutionLimits_KeysVisited(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"jobs": map[string]any{"a": 1.0, "b": 2.0, "c": 3.0},
+	}))
+
+	limited := WithExecutionLimits(ctx, ExecutionLimits{MaxKeysVisited: 5})
+	res := cache.Execute(limited, GET("jobs/*"))
+	require.NoError(t, res.Error)
+
+	res = cache.Execute(limited, GET("jobs/*"), RETURN_EXPR("sum(${{jobs/*}})"))
+	assert.Equal(t, "max_keys_visited", limitError(t, res.Error).Limit)
+}
+
+// countingMap counts the keys MatchKeys yields.
+type countingMap struct {
+	containers.Map
+	yielded int
+}
+
+func (m *countingMap) MatchKeys(ctx context.Context, path string) iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		for key, value := range m.Map.MatchKeys(ctx, path) {
+			m.yielded++
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+func TestExecutionLimits_KeysVisitedStopsEarly(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	items := map[string]any{}
+	for i := range 100 {
+		items[strconv.Itoa(i)] = float64(i)
+	}
+	require.NoError(t, cache.Create(ctx, map[string]any{"items": items}))
+	counting := &countingMap{Map: cache.cmap}
+	cache.cmap = counting
+
+	limited := WithExecutionLimits(ctx, ExecutionLimits{MaxKeysVisited: 5})
+	for _, cmd := range []Command{
+		GET("items/*"),
+		RETURN_EXPR("sum(${{items/*}})"),
+		QUERY(Query{Source: "items/*"}),
+	} {
+		counting.yielded = 0
+		res := cache.Execute(limited, cmd)
+		assert.Equal(t, "max_keys_visited", limitError(t, res.Error).Limit)
+		assert.Equal(t, 6, counting.yielded)
+	}
+}
+
+func TestExecutionLimits_Writes(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{"a": 0.0, "b": 0.0, "c": 0.0}))
+	cache.limits = ExecutionLimits{MaxWrites: 2}
+
+	res := cache.Execute(ctx, REPLACE("a", 1.0), REPLACE("b", 1.0), REPLACE("c", 1.0))
+	assert.Equal(t, "max_writes", limitError(t, res.Error).Limit)
+
+	c, _ := cache.Get(ctx, "c")
+	assert.Equal(t, 0.0, c)
+
+	// TRY and ignore_errors cannot swallow the limit
+	res = cache.Execute(ctx, DELETE("a"), TRY(DELETE("b"), NOOP(), nil), IGNORE_ERRORS(DELETE("c")))
+	assert.Equal(t, "max_writes", limitError(t, res.Error).Limit)
+}
+
+func TestExecutionLimits_Depth(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{"in": 0.0, "out": 0.0}))
+	cache.limits = ExecutionLimits{MaxDepth: 3}
+
+	res := cache.Execute(ctx, IF("true", IF("true", RETURN(1.0), NOOP()), NOOP()))
+	require.NoError(t, res.Error)
+
+	res = cache.Execute(ctx, IF("true", IF("true", COMMANDS(RETURN(1.0)), NOOP()), NOOP()))
+	assert.Equal(t, "max_depth", limitError(t, res.Error).Limit)
+
+	// Commands run by triggers are nested in the command that fired them
+	_, err := cache.CreateTrigger(ctx, "in", COMMANDS(REPLACE("out", 1.0)))
+	require.NoError(t, err)
+	res = cache.Execute(ctx, REPLACE("in", 1.0))
+	require.NoError(t, res.Error)
+	res = cache.Execute(ctx, COMMANDS(REPLACE("in", 2.0)))
+	assert.Equal(t, "max_depth", limitError(t, res.Error).Limit)
+}
+
+func TestExecutionLimits_Tighten(t *testing.T) {
+	defer func(steps, writes int) {
+		config.CommandMaxSteps, config.CommandMaxWrites = steps, writes
+	}(config.CommandMaxSteps, config.CommandMaxWrites)
+	config.CommandMaxSteps = 100
+	config.CommandMaxWrites = 0
+
+	cache := New()
+	cache.limits = ExecutionLimits{MaxSteps: 200, MaxWrites: 10}
+
+	// A request can lower limits, but not raise them
+	ctx := WithExecutionLimits(context.Background(), ExecutionLimits{MaxSteps: 1000, MaxWrites: 5, MaxDepth: 4})
+	budget := budgetFrom(cache.withBudget(ctx))
+	assert.Equal(t, ExecutionLimits{MaxSteps: 100, MaxWrites: 5, MaxDepth: 4}, budget.limits)
+
+	assert.True(t, errors.Is(ExecutionLimits{MaxWrites: -1}.Validate(), ErrInvalidExecutionLimits))
+}
@@ -0,0 +1,423 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/pkg/containers"
+)
+
+// JSONPatchOp is one RFC 6902 JSON Patch operation: add, remove, replace,
+// move, copy or test. Path and From are JSON pointers into the value being
+// patched, e.g. "/profile/email", or "/tags/-" to add to the end of an array.
+type JSONPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// UnmarshalJSON decodes an operation, keeping integers exact, and rejects an
+// add, replace or test without a "value" member, as RFC 6902 requires. A
+// "value" of null is allowed.
+func (op *JSONPatchOp) UnmarshalJSON(data []byte) error {
+	type Alias JSONPatchOp
+	aux := struct {
+		Value json.RawMessage `json:"value"`
+		*Alias
+	}{
+		Alias: (*Alias)(op),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if aux.Value == nil {
+		switch op.Op {
+		case "add", "replace", "test":
+			return ErrInvalidPatch.Format(strconv.Quote(op.Op) + ` operation needs a "value"`)
+		}
+		return nil
+	}
+	return DecodeJSON(aux.Value, &op.Value)
+}
+
+// JSONPatch applies RFC 6902 operations to the value at key and returns the
+// new value. The operations apply in order to a copy, which replaces the value
+// only if every one succeeds, so a failed "test" leaves the value as it was.
+// Triggers fire for every path an operation changed.
+func (cache *Cache) JSONPatch(ctx context.Context, key string, ops []JSONPatchOp) (any, error) {
+	return cache.patch(ctx, key, func(doc any) (any, [][]string, error) {
+		var touched [][]string
+		for _, op := range ops {
+			var err error
+			var changed [][]string
+			if doc, changed, err = applyJSONPatchOp(doc, op); err != nil {
+				return nil, nil, err
+			}
+			touched = append(touched, changed...)
+		}
+		return doc, touched, nil
+	})
+}
+
+// MergePatch applies an RFC 7396 merge patch to the value at key and returns
+// the new value: objects merge recursively, null removes a member, and
+// anything else replaces what was there. Triggers fire for every path the
+// patch changed.
+func (cache *Cache) MergePatch(ctx context.Context, key string, patch any) (any, error) {
+	return cache.patch(ctx, key, func(doc any) (any, [][]string, error) {
+		var touched [][]string
+		doc = mergePatch(doc, patch, nil, &touched)
+		return doc, touched, nil
+	})
+}
+
+// patch replaces the value at key with apply's result, then fires triggers
+// for the paths, relative to key, that apply reports changing. Paths it
+// removed lose their TTLs, as with Delete.
+func (cache *Cache) patch(ctx context.Context, key string, apply func(doc any) (any, [][]string, error)) (any, error) {
+	cache.recordActivity()
+	key = substituteContextVars(ctx, key)
+
+	path, err := cache.splitPath(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	oldValue, err := cache.cmap.Get(ctx, path...)
+	if err != nil {
+		return nil, ErrKeyNotFound.Format(key)
+	}
+
+	newValue, touched, err := apply(deepCopy(oldValue))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := chargeWrites(ctx, 1); err != nil {
+		return nil, err
+	}
+	if err := cache.cmap.Set(ctx, newValue, path...); err != nil {
+		return nil, errors.Wrap(err, "could not set value")
+	}
+	cache.updateIndexes(ctx, path)
+
+	for _, tokens := range touched {
+		if _, ok := pointerGet(newValue, tokens); !ok {
+			cache.clearKeyExpirations(containers.JoinPath(append(slices.Clone(path), tokens...)...))
+		}
+	}
+
+	// Fire triggers once per changed path, in the order they changed
+	seen := map[string]bool{}
+	for _, tokens := range touched {
+		changedKey := containers.JoinPath(append(slices.Clone(path), tokens...)...)
+		if seen[changedKey] {
+			continue
+		}
+		seen[changedKey] = true
+		before, _ := pointerGet(oldValue, tokens)
+		after, _ := pointerGet(newValue, tokens)
+		if err := cache.OnChange(ctx, changedKey, before, after); err != nil {
+			return nil, errors.Wrap(err, "trigger execution failed")
+		}
+	}
+
+	return newValue, nil
+}
+
+// applyJSONPatchOp applies one operation to doc, returning the new document
+// and the paths it changed.
+func applyJSONPatchOp(doc any, op JSONPatchOp) (any, [][]string, error) {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		tokens = resolveAppend(doc, tokens)
+		doc, err = pointerAdd(doc, tokens, op.Value, op.Path)
+		return doc, [][]string{tokens}, err
+
+	case "remove":
+		doc, _, err = pointerRemove(doc, tokens, op.Path)
+		return doc, [][]string{tokens}, err
+
+	case "replace":
+		if _, ok := pointerGet(doc, tokens); !ok {
+			return nil, nil, ErrPatchConflict.Format(op.Path, "path not found")
+		}
+		doc, err = pointerSet(doc, tokens, op.Value, op.Path)
+		return doc, [][]string{tokens}, err
+
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, ok := pointerGet(doc, from)
+		if !ok {
+			return nil, nil, ErrPatchConflict.Format(op.From, "path not found")
+		}
+		if op.Op == "copy" {
+			tokens = resolveAppend(doc, tokens)
+			doc, err = pointerAdd(doc, tokens, deepCopy(value), op.Path)
+			return doc, [][]string{tokens}, err
+		}
+		if len(from) < len(tokens) && slices.Equal(from, tokens[:len(from)]) {
+			return nil, nil, ErrInvalidPatch.Format(`cannot move "` + op.From + `" into itself`)
+		}
+		if doc, _, err = pointerRemove(doc, from, op.From); err != nil {
+			return nil, nil, err
+		}
+		tokens = resolveAppend(doc, tokens)
+		doc, err = pointerAdd(doc, tokens, value, op.Path)
+		return doc, [][]string{from, tokens}, err
+
+	case "test":
+		value, ok := pointerGet(doc, tokens)
+		if !ok || !jsonEqual(value, op.Value) {
+			return nil, nil, ErrPatchTestFailed.Format(op.Path)
+		}
+		return doc, nil, nil
+	}
+
+	return nil, nil, ErrInvalidPatch.Format("unknown op " + strconv.Quote(op.Op))
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into unescaped tokens. The
+// empty pointer is the whole value.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, ErrInvalidPatch.Format("invalid JSON pointer " + strconv.Quote(pointer))
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		// "~" only escapes "~" (~0) and "/" (~1)
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, ErrInvalidPatch.Format("invalid JSON pointer " + strconv.Quote(pointer))
+			}
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// resolveAppend turns a trailing "-" into the index it adds at, the length of
+// the array, so that triggers see the real path.
+func resolveAppend(doc any, tokens []string) []string {
+	if len(tokens) == 0 || tokens[len(tokens)-1] != "-" {
+		return tokens
+	}
+	parent, _ := pointerGet(doc, tokens[:len(tokens)-1])
+	if arr, ok := parent.([]any); ok {
+		tokens = slices.Clone(tokens)
+		tokens[len(tokens)-1] = strconv.Itoa(len(arr))
+	}
+	return tokens
+}
+
+// pointerGet returns the value at tokens within doc.
+func pointerGet(doc any, tokens []string) (any, bool) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]any:
+			child, ok := node[token]
+			if !ok {
+				return nil, false
+			}
+			doc = child
+		case []any:
+			i, ok := arrayIndex(token, len(node))
+			if !ok || i == len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// pointerAdd adds value at tokens: a member of an object, set whether or not
+// it exists, or an element inserted into an array.
+func pointerAdd(doc any, tokens []string, value any, pointer string) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, tokens, pointer, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			i, ok := arrayIndex(token, len(node))
+			if !ok {
+				return nil, ErrPatchConflict.Format(pointer, "invalid array index")
+			}
+			return slices.Insert(node, i, value), nil
+		}
+		return nil, ErrPatchConflict.Format(pointer, "parent is not an object or array")
+	})
+}
+
+// pointerSet replaces the existing value at tokens.
+func pointerSet(doc any, tokens []string, value any, pointer string) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, tokens, pointer, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			i, _ := arrayIndex(token, len(node))
+			node[i] = value
+			return node, nil
+		}
+		return nil, ErrPatchConflict.Format(pointer, "parent is not an object or array")
+	})
+}
+
+// pointerRemove removes the value at tokens, returning it.
+func pointerRemove(doc any, tokens []string, pointer string) (any, any, error) {
+	if len(tokens) == 0 {
+		return nil, nil, ErrPatchConflict.Format(pointer, "cannot remove the whole value")
+	}
+	removed, ok := pointerGet(doc, tokens)
+	if !ok {
+		return nil, nil, ErrPatchConflict.Format(pointer, "path not found")
+	}
+	doc, err := pointerUpdate(doc, tokens, pointer, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			delete(node, token)
+			return node, nil
+		case []any:
+			i, _ := arrayIndex(token, len(node))
+			return slices.Delete(node, i, i+1), nil
+		}
+		return nil, ErrPatchConflict.Format(pointer, "parent is not an object or array")
+	})
+	return doc, removed, err
+}
+
+// pointerUpdate calls change with the container holding the last token and
+// stores what it returns in place of the container, since changing the
+// length of an array makes a new slice.
+func pointerUpdate(doc any, tokens []string, pointer string, change func(parent any, token string) (any, error)) (any, error) {
+	if len(tokens) == 1 {
+		return change(doc, tokens[0])
+	}
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[tokens[0]]
+		if !ok {
+			return nil, ErrPatchConflict.Format(pointer, "path not found")
+		}
+		updated, err := pointerUpdate(child, tokens[1:], pointer, change)
+		if err != nil {
+			return nil, err
+		}
+		node[tokens[0]] = updated
+		return node, nil
+	case []any:
+		i, ok := arrayIndex(tokens[0], len(node))
+		if !ok || i == len(node) {
+			return nil, ErrPatchConflict.Format(pointer, "path not found")
+		}
+		updated, err := pointerUpdate(node[i], tokens[1:], pointer, change)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = updated
+		return node, nil
+	}
+	return nil, ErrPatchConflict.Format(pointer, "path not found")
+}
+
+// arrayIndex parses an array index token, allowing length itself and "-" for
+// the end of the array. Indexes have no leading zeros, as RFC 6901 requires.
+func arrayIndex(token string, length int) (int, bool) {
+	if token == "-" {
+		return length, true
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > length {
+		return 0, false
+	}
+	return i, true
+}
+
+// mergePatch merges patch into target as RFC 7396 describes, adding the
+// paths it changes, relative to target, to touched.
+func mergePatch(target, patch any, tokens []string, touched *[][]string) any {
+	members, ok := patch.(map[string]any)
+	if !ok {
+		*touched = append(*touched, tokens)
+		return patch
+	}
+	object, ok := target.(map[string]any)
+	if !ok {
+		object = map[string]any{}
+		*touched = append(*touched, tokens)
+	}
+	for _, name := range slices.Sorted(maps.Keys(members)) {
+		child := append(slices.Clone(tokens), name)
+		if members[name] == nil {
+			if _, ok := object[name]; ok {
+				delete(object, name)
+				*touched = append(*touched, child)
+			}
+			continue
+		}
+		object[name] = mergePatch(object[name], members[name], child, touched)
+	}
+	return object
+}
+
+// jsonEqual compares two JSON values as RFC 6902's test does: objects and
+// arrays member by member, and numbers by value.
+func jsonEqual(a, b any) bool {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return valuesEqual(a, b)
+}
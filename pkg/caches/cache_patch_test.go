@@ -0,0 +1,226 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/goodblaster/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPatchCache(t *testing.T) *Cache {
+	cache := New()
+	m := map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"users": {
+			"u1": {"name": "alice", "tags": ["a", "b"], "address": {"city": "Paris", "zip": "75001"}}
+		},
+		"changes": 0
+	}`), &m))
+	require.NoError(t, cache.Create(context.Background(), m))
+	return cache
+}
+
+func TestJSONPatch(t *testing.T) {
+	cache := newPatchCache(t)
+	ctx := context.Background()
+
+	value, err := cache.JSONPatch(ctx, "users/u1", []JSONPatchOp{
+		{Op: "test", Path: "/name", Value: "alice"},
+		{Op: "replace", Path: "/name", Value: "alicia"},
+		{Op: "add", Path: "/tags/-", Value: "c"},
+		{Op: "add", Path: "/tags/0", Value: "z"},
+		{Op: "remove", Path: "/tags/1"},
+		{Op: "copy", From: "/address/city", Path: "/city"},
+		{Op: "move", From: "/address/zip", Path: "/zip"},
+		{Op: "add", Path: "/a~1b", Value: true},
+	})
+	require.NoError(t, err)
+
+	expected := map[string]any{
+		"name":    "alicia",
+		"tags":    []any{"z", "b", "c"},
+		"address": map[string]any{"city": "Paris"},
+		"city":    "Paris",
+		"zip":     "75001",
+		"a/b":     true,
+	}
+	assert.Equal(t, expected, value)
+
+	stored, err := cache.Get(ctx, "users/u1")
+	require.NoError(t, err)
+	assert.Equal(t, expected, stored)
+}
+
+func TestJSONPatch_WholeValue(t *testing.T) {
+	cache := newPatchCache(t)
+	ctx := context.Background()
+
+	value, err := cache.JSONPatch(ctx, "users/u1/tags", []JSONPatchOp{
+		{Op: "replace", Path: "", Value: []any{"x"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"x"}, value)
+
+	_, err = cache.JSONPatch(ctx, "users/u1/tags", []JSONPatchOp{{Op: "remove", Path: ""}})
+	assert.True(t, errors.Is(err, ErrPatchConflict))
+}
+
+func TestJSONPatch_FailedTestRollsBack(t *testing.T) {
+	cache := newPatchCache(t)
+	ctx := context.Background()
+
+	_, err := cache.JSONPatch(ctx, "users/u1", []JSONPatchOp{
+		{Op: "replace", Path: "/name", Value: "bob"},
+		{Op: "test", Path: "/address", Value: map[string]any{"city": "Lyon", "zip": "75001"}},
+	})
+	assert.True(t, errors.Is(err, ErrPatchTestFailed))
+
+	name, err := cache.Get(ctx, "users/u1/name")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", name)
+}
+
+func TestJSONPatch_TestComparesNumbersByValue(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+	require.NoError(t, cache.Create(ctx, map[string]any{"v": map[string]any{"n": int64(1), "list": []any{int64(2)}}}))
+
+	_, err := cache.JSONPatch(ctx, "v", []JSONPatchOp{
+		{Op: "test", Path: "", Value: map[string]any{"n": 1.0, "list": []any{2.0}}},
+	})
+	assert.NoError(t, err)
+}
+
+func TestJSONPatch_Errors(t *testing.T) {
+	cache := newPatchCache(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		op   JSONPatchOp
+		err  error
+	}{
+		{"unknown op", JSONPatchOp{Op: "merge", Path: "/name"}, ErrInvalidPatch},
+		{"bad pointer", JSONPatchOp{Op: "remove", Path: "name"}, ErrInvalidPatch},
+		{"bad escape", JSONPatchOp{Op: "remove", Path: "/a~2"}, ErrInvalidPatch},
+		{"move into itself", JSONPatchOp{Op: "move", From: "/address", Path: "/address/home"}, ErrInvalidPatch},
+		{"remove missing", JSONPatchOp{Op: "remove", Path: "/missing"}, ErrPatchConflict},
+		{"replace missing", JSONPatchOp{Op: "replace", Path: "/missing", Value: 1}, ErrPatchConflict},
+		{"add under missing", JSONPatchOp{Op: "add", Path: "/missing/x", Value: 1}, ErrPatchConflict},
+		{"index out of range", JSONPatchOp{Op: "add", Path: "/tags/5", Value: 1}, ErrPatchConflict},
+		{"leading zero", JSONPatchOp{Op: "remove", Path: "/tags/01"}, ErrPatchConflict},
+		{"copy missing", JSONPatchOp{Op: "copy", From: "/missing", Path: "/x"}, ErrPatchConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cache.JSONPatch(ctx, "users/u1", []JSONPatchOp{tt.op})
+			assert.True(t, errors.Is(err, tt.err), err)
+		})
+	}
+
+	_, err := cache.JSONPatch(ctx, "users/missing", nil)
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+}
+
+func TestMergePatch(t *testing.T) {
+	cache := newPatchCache(t)
+	ctx := context.Background()
+
+	value, err := cache.MergePatch(ctx, "users/u1", map[string]any{
+		"name":    "alicia",
+		"tags":    []any{"x"},
+		"address": map[string]any{"zip": nil, "street": "Rue de Rivoli"},
+		"profile": map[string]any{"age": int64(30), "nickname": nil},
+		"missing": nil,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"name":    "alicia",
+		"tags":    []any{"x"},
+		"address": map[string]any{"city": "Paris", "street": "Rue de Rivoli"},
+		"profile": map[string]any{"age": int64(30)},
+	}, value)
+
+	// A patch that isn't an object replaces the value
+	value, err = cache.MergePatch(ctx, "users/u1/address", "gone")
+	require.NoError(t, err)
+	assert.Equal(t, "gone", value)
+}
+
+func TestPatch_FiresTriggers(t *testing.T) {
+	cache := newPatchCache(t)
+	ctx := context.Background()
+
+	_, err := cache.CreateTrigger(ctx, "users/*/name", INC("changes", 1))
+	require.NoError(t, err)
+	_, err = cache.CreateTrigger(ctx, "users/*/address/*", INC("changes", 10))
+	require.NoError(t, err)
+	_, err = cache.CreateTrigger(ctx, "users/*/tags/*", INC("changes", 100))
+	require.NoError(t, err)
+
+	_, err = cache.JSONPatch(ctx, "users/u1", []JSONPatchOp{
+		{Op: "replace", Path: "/name", Value: "alicia"},
+		{Op: "replace", Path: "/name", Value: "ali"},
+		{Op: "remove", Path: "/address/zip"},
+		{Op: "add", Path: "/tags/-", Value: "c"},
+	})
+	require.NoError(t, err)
+
+	changes, err := cache.Get(ctx, "changes")
+	require.NoError(t, err)
+	assert.EqualValues(t, 111, changes)
+
+	_, err = cache.MergePatch(ctx, "users/u1", map[string]any{
+		"name":    "bob",
+		"address": map[string]any{"city": "Lyon", "street": "Rue X"},
+	})
+	require.NoError(t, err)
+
+	changes, err = cache.Get(ctx, "changes")
+	require.NoError(t, err)
+	assert.EqualValues(t, 132, changes)
+}
+
+func TestJSONPatchOp_RequiresValue(t *testing.T) {
+	var ops []JSONPatchOp
+	for _, body := range []string{
+		`[{"op": "add", "path": "/x"}]`,
+		`[{"op": "replace", "path": "/x"}]`,
+		`[{"op": "test", "path": "/x"}]`,
+	} {
+		err := json.Unmarshal([]byte(body), &ops)
+		assert.True(t, errors.Is(err, ErrInvalidPatch), body)
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(`[{"op": "add", "path": "/x", "value": null}, {"op": "remove", "path": "/y"}]`), &ops))
+	assert.Equal(t, []JSONPatchOp{{Op: "add", Path: "/x"}, {Op: "remove", Path: "/y"}}, ops)
+
+	require.NoError(t, json.Unmarshal([]byte(`[{"op": "add", "path": "/x", "value": 9007199254740993}]`), &ops))
+	assert.Equal(t, int64(9007199254740993), ops[0].Value)
+}
+
+func TestPatch_ClearsRemovedTTLs(t *testing.T) {
+	cache := newPatchCache(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"users/u1/name", "users/u1/address/zip", "users/u1/address/city"} {
+		require.NoError(t, cache.SetKeyTTL(ctx, key, 60000))
+	}
+
+	_, err := cache.JSONPatch(ctx, "users/u1", []JSONPatchOp{
+		{Op: "remove", Path: "/name"},
+		{Op: "move", From: "/address/zip", Path: "/zip"},
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, cache.KeyExpirations(), "users/u1/name")
+	assert.NotContains(t, cache.KeyExpirations(), "users/u1/address/zip")
+	assert.Contains(t, cache.KeyExpirations(), "users/u1/address/city")
+
+	_, err = cache.MergePatch(ctx, "users/u1", map[string]any{"address": nil})
+	require.NoError(t, err)
+	assert.Empty(t, cache.KeyExpirations())
+}
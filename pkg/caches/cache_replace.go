@@ -11,17 +11,25 @@ func (cache *Cache) Replace(ctx context.Context, key string, value any) error {
 	cache.recordActivity()
 	key = substituteContextVars(ctx, key)
 
+	path, err := cache.splitPath(ctx, key)
+	if err != nil {
+		return err
+	}
+
 	// Check key first. Error if does not exist.
-	oldValue, err := cache.cmap.Get(ctx, SplitKey(key)...)
-	_ = oldValue
+	oldValue, err := cache.cmap.Get(ctx, path...)
 	if err != nil {
 		return ErrKeyNotFound.Format(key)
 	}
 
 	// Now set the value.
-	if err := cache.cmap.Set(ctx, value, SplitKey(key)...); err != nil {
+	if err := chargeWrites(ctx, 1); err != nil {
+		return err
+	}
+	if err := cache.cmap.Set(ctx, value, path...); err != nil {
 		return errors.Wrap(err, "could not set value")
 	}
+	cache.updateIndexes(ctx, path)
 
 	// Fire triggers - return error if trigger execution fails (including infinite loops)
 	if err := cache.OnChange(ctx, key, oldValue, value); err != nil {
@@ -42,17 +50,27 @@ func (cache *Cache) ReplaceBatch(ctx context.Context, values map[string]any) err
 	cache.recordActivity()
 
 	// Check all keys first. Error if any do not exist.
+	paths := make(map[string][]string, len(values))
 	for key := range values {
-		if !cache.cmap.Exists(ctx, SplitKey(key)...) {
+		path, err := cache.splitPath(ctx, key)
+		if err != nil {
+			return err
+		}
+		if !cache.cmap.Exists(ctx, path...) {
 			return ErrKeyNotFound.Format(key)
 		}
+		paths[key] = path
 	}
 
 	// Now set the values.
+	if err := chargeWrites(ctx, len(values)); err != nil {
+		return err
+	}
 	for key, value := range values {
-		if err := cache.cmap.Set(ctx, value, SplitKey(key)...); err != nil {
+		if err := cache.cmap.Set(ctx, value, paths[key]...); err != nil {
 			return errors.Wrap(err, "could not set value")
 		}
+		cache.updateIndexes(ctx, paths[key])
 	}
 
 	return nil
@@ -6,5 +6,16 @@ import (
 
 // ArrayResize - Resize an existing array in the cache.
 func (cache *Cache) ArrayResize(ctx context.Context, key string, newSize int) error {
-	return cache.cmap.ArrayResize(ctx, newSize, SplitKey(key)...)
+	path, err := cache.splitPath(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := chargeWrites(ctx, 1); err != nil {
+		return err
+	}
+	if err := cache.cmap.ArrayResize(ctx, newSize, path...); err != nil {
+		return err
+	}
+	cache.updateIndexes(ctx, path)
+	return nil
 }
@@ -0,0 +1,367 @@
+package caches
+
+import (
+	"container/heap"
+	"context"
+	"hash/fnv"
+	"iter"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/containers"
+)
+
+// A scan pages through keys with a cursor, like Redis SCAN. A cursor of 0
+// starts a scan, and a returned cursor of 0 ends it.
+//
+// Cache scans walk the cache in key order, map keys sorted and array elements
+// by index, each value before the values it holds, and a page resumes after
+// the last key of the previous one. A key present for the whole scan is
+// returned exactly once, while keys added or removed during it may or may not
+// be; so may array elements, whose indexes shift. The cache keeps only that
+// last key for each cursor, for scanCursorTTL after the page that returned it,
+// and forgets the cursors of a scan when it ends.
+//
+// ScanKeys instead visits keys in the order of a 64-bit hash of each key, and
+// its cursor is the hash to resume from, so it keeps no state.
+
+// DefaultScanCount is the page size of a scan when none is given.
+const DefaultScanCount = 10
+
+const (
+	// scanCursorTTL is how long a cursor stays valid after its page.
+	scanCursorTTL = 10 * time.Minute
+
+	// maxScanCursors caps the cursors kept for scans in progress.
+	maxScanCursors = 1024
+)
+
+// scanCursor is where a scan resumes.
+type scanCursor struct {
+	scan    uint64   // the id of the scan's first cursor, shared by all its cursors
+	after   []string // the unescaped keys of the path of the last key returned
+	expires time.Time
+}
+
+// scanVisitor reports whether a scan returns the value at path, and whether
+// it visits the values it holds. key is path as WildKeys writes it.
+type scanVisitor func(path []containers.Segment, key string, value any) (keep, descend bool)
+
+// scanEntry is a key in scan order.
+type scanEntry struct {
+	hash uint64
+	key  string
+}
+
+// ScanKeys returns the page of keys starting at cursor, and the cursor of the
+// next page. The page has count keys, or more if several share a hash, unless
+// it is the last. It suits small collections such as the fields of one value,
+// as every call walks all of keys.
+func ScanKeys(keys iter.Seq[string], cursor uint64, count int) ([]string, uint64) {
+	var entries []scanEntry
+	for key := range keys {
+		if h := scanHash(key); h >= cursor {
+			entries = append(entries, scanEntry{h, key})
+		}
+	}
+	sortScanEntries(entries)
+	return scanPage(entries, cursor, count, nil)
+}
+
+// Scan returns a page of the keys matching pattern, and the cursor of the next
+// page, skipping those whose value filter, if set, rejects.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) Scan(ctx context.Context, pattern string, cursor uint64, count int, filter func(key string, value any) bool) ([]string, uint64, error) {
+	tokens, err := containers.ParsePath(pattern)
+	if err != nil {
+		return nil, 0, nil
+	}
+	visit := func(path []containers.Segment, key string, value any) (bool, bool) {
+		match, extend := matchScanPath(tokens, path)
+		return match && (filter == nil || filter(key, value)), extend
+	}
+	return cache.scan(ctx, cursor, count, visit)
+}
+
+// ScanTree returns a page of the keys visit keeps, and the cursor of the next
+// page, the way Scan does for a pattern. visit is called with the key and
+// value of each value the scan reaches, and also reports whether the scan
+// should go on into the values it holds.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) ScanTree(ctx context.Context, cursor uint64, count int, visit func(key string, value any) (keep, descend bool)) ([]string, uint64, error) {
+	return cache.scan(ctx, cursor, count, func(_ []containers.Segment, key string, value any) (bool, bool) {
+		return visit(key, value)
+	})
+}
+
+// scan returns the page of keys visit keeps after the cursor's key.
+func (cache *Cache) scan(ctx context.Context, cursor uint64, count int, visit scanVisitor) ([]string, uint64, error) {
+	cache.recordActivity()
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+
+	now := time.Now()
+	for id, c := range cache.scanCursors {
+		if now.After(c.expires) {
+			delete(cache.scanCursors, id)
+		}
+	}
+
+	var scan uint64
+	var after []string
+	if cursor != 0 {
+		c, ok := cache.scanCursors[cursor]
+		if !ok {
+			return nil, 0, ErrInvalidScanCursor.Format(cursor)
+		}
+		scan, after = c.scan, c.after
+	}
+
+	var page []string
+	var last []string
+	more := false
+	scanWalk(ctx, cache.cmap.Data(ctx), nil, "", after, visit, func(path []containers.Segment, key string) bool {
+		if len(page) == count {
+			more = true
+			return false
+		}
+		page = append(page, key)
+		last = containers.SegmentKeys(path)
+		return true
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if !more {
+		// The scan is over, so none of its cursors is needed
+		for id, c := range cache.scanCursors {
+			if c.scan == scan {
+				delete(cache.scanCursors, id)
+			}
+		}
+		return page, 0, nil
+	}
+	return page, cache.saveScanCursor(scan, last, now), nil
+}
+
+// saveScanCursor keeps where a scan resumes, dropping the cursor closest to
+// expiring if too many are kept, and returns its id. scan is 0 for the first
+// cursor of a scan.
+func (cache *Cache) saveScanCursor(scan uint64, after []string, now time.Time) uint64 {
+	if cache.scanCursors == nil {
+		cache.scanCursors = map[uint64]*scanCursor{}
+	}
+	if len(cache.scanCursors) >= maxScanCursors {
+		var oldest uint64
+		for id, c := range cache.scanCursors {
+			if oldest == 0 || c.expires.Before(cache.scanCursors[oldest].expires) {
+				oldest = id
+			}
+		}
+		delete(cache.scanCursors, oldest)
+	}
+
+	id := rand.Uint64()
+	for _, taken := cache.scanCursors[id]; id == 0 || taken; _, taken = cache.scanCursors[id] {
+		id = rand.Uint64()
+	}
+	if scan == 0 {
+		scan = id
+	}
+	cache.scanCursors[id] = &scanCursor{scan: scan, after: after, expires: now.Add(scanCursorTTL)}
+	return id
+}
+
+// scanWalk passes the values node holds, and those they hold in turn, to
+// visit in scan order, and yields the ones it keeps. Values up to the path
+// after, the last key a scan returned, are skipped: the values before it and
+// its ancestors are neither visited nor yielded, and it is only descended
+// into. It returns false once yield asks to stop or ctx is done.
+func scanWalk(ctx context.Context, node any, path []containers.Segment, key string, after []string, visit scanVisitor, yield func(path []containers.Segment, key string) bool) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// step visits one child; rest is what remains of after below it, if the
+	// child is on the way to after
+	step := func(seg containers.Segment, escaped string, value any, keep, descend bool, onPath bool, rest []string) bool {
+		childPath := append(path, seg)
+		childKey := joinScanKey(key, escaped)
+		if keep && !onPath && !yield(childPath, childKey) {
+			return false
+		}
+		return !descend || scanWalk(ctx, value, childPath, childKey, rest, visit, yield)
+	}
+
+	switch data := node.(type) {
+	case map[string]any:
+		// Visit first, so that only the values kept or descended into are sorted
+		children := scanChildren{}
+		for name, value := range data {
+			if len(after) > 0 && name < after[0] {
+				continue
+			}
+			seg := containers.Segment{Kind: containers.SegmentKey, Key: name}
+			keep, descend := visit(append(path, seg), joinScanKey(key, containers.EscapeKey(name)), value)
+			if keep || descend {
+				children = append(children, scanChild{name: name, keep: keep, descend: descend})
+			}
+		}
+		heap.Init(&children)
+		for children.Len() > 0 {
+			c := heap.Pop(&children).(scanChild)
+			onPath := len(after) > 0 && c.name == after[0]
+			var rest []string
+			if onPath {
+				rest = after[1:]
+			}
+			seg := containers.Segment{Kind: containers.SegmentKey, Key: c.name}
+			if !step(seg, containers.EscapeKey(c.name), data[c.name], c.keep, c.descend, onPath, rest) {
+				return false
+			}
+		}
+	case []any:
+		start := 0
+		if len(after) > 0 {
+			start, _ = strconv.Atoi(after[0])
+		}
+		for i := start; i < len(data); i++ {
+			index := strconv.Itoa(i)
+			seg := containers.Segment{Kind: containers.SegmentIndex, Key: index}
+			keep, descend := visit(append(path, seg), joinScanKey(key, index), data[i])
+			onPath := len(after) > 0 && index == after[0]
+			var rest []string
+			if onPath {
+				rest = after[1:]
+			}
+			if !step(seg, index, data[i], keep, descend, onPath, rest) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// joinScanKey appends an escaped segment to a key.
+func joinScanKey(key, segment string) string {
+	if key == "" {
+		return segment
+	}
+	return key + config.KeyDelimiter + segment
+}
+
+// matchScanPath reports whether path matches pattern, and whether a longer
+// path starting with it could.
+func matchScanPath(pattern, path []containers.Segment) (match, extend bool) {
+	// reached[i] is set when path can end just before pattern[i]
+	reached := make([]bool, len(pattern)+1)
+	reached[0] = true
+	skipRecursive(pattern, reached)
+
+	for _, seg := range path {
+		next := make([]bool, len(pattern)+1)
+		alive := false
+		for i, ok := range reached[:len(pattern)] {
+			if !ok {
+				continue
+			}
+			tok := pattern[i]
+			switch {
+			case tok.Kind == containers.SegmentRecursive:
+				// "**" takes this segment, and may take more
+				next[i], next[i+1] = true, true
+				alive = true
+			case tok.Matches(seg.Key, seg.Kind == containers.SegmentIndex):
+				next[i+1] = true
+				alive = true
+			}
+		}
+		if !alive {
+			return false, false
+		}
+		skipRecursive(pattern, next)
+		reached = next
+	}
+
+	for _, ok := range reached[:len(pattern)] {
+		extend = extend || ok
+	}
+	return reached[len(pattern)], extend
+}
+
+// skipRecursive marks the positions reached by letting a "**" match no
+// segments, which only one other than the last may do.
+func skipRecursive(pattern []containers.Segment, reached []bool) {
+	for i := 0; i+1 < len(pattern); i++ {
+		if reached[i] && pattern[i].Kind == containers.SegmentRecursive {
+			reached[i+1] = true
+		}
+	}
+}
+
+// scanChild is a map key a scan returns or descends into.
+type scanChild struct {
+	name          string
+	keep, descend bool
+}
+
+// scanChildren is a heap of map keys, so that a page only sorts as many of
+// them as it returns.
+type scanChildren []scanChild
+
+func (h scanChildren) Len() int           { return len(h) }
+func (h scanChildren) Less(i, j int) bool { return h[i].name < h[j].name }
+func (h scanChildren) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *scanChildren) Push(x any)        { *h = append(*h, x.(scanChild)) }
+func (h *scanChildren) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// scanPage returns the page of entries starting at cursor, skipping those keep
+// rejects, and the cursor of the next page.
+func scanPage(entries []scanEntry, cursor uint64, count int, keep func(key string) bool) ([]string, uint64) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].hash >= cursor })
+	var page []string
+	var last uint64
+	for ; i < len(entries); i++ {
+		e := entries[i]
+		if keep != nil && !keep(e.key) {
+			continue
+		}
+		// The next page starts at the first key past a full page
+		if len(page) >= count && e.hash != last {
+			return page, e.hash
+		}
+		page = append(page, e.key)
+		last = e.hash
+	}
+	return page, 0
+}
+
+func sortScanEntries(entries []scanEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].hash != entries[j].hash {
+			return entries[i].hash < entries[j].hash
+		}
+		return entries[i].key < entries[j].key
+	})
+}
+
+// scanHash orders keys for scans.
+func scanHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
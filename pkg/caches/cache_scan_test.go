@@ -0,0 +1,210 @@
+package caches
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/goodblaster/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanKeys_Pages(t *testing.T) {
+	var all []string
+	for i := 0; i < 95; i++ {
+		all = append(all, fmt.Sprintf("key-%d", i))
+	}
+
+	var seen []string
+	cursor, pages := uint64(0), 0
+	for {
+		page, next := ScanKeys(slices.Values(all), cursor, 10)
+		assert.LessOrEqual(t, len(page), 10)
+		seen = append(seen, page...)
+		pages++
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	assert.Equal(t, 10, pages)
+	assert.ElementsMatch(t, all, seen)
+}
+
+func TestScanKeys_Empty(t *testing.T) {
+	page, next := ScanKeys(slices.Values([]string{}), 0, 10)
+	assert.Empty(t, page)
+	assert.Equal(t, uint64(0), next)
+}
+
+func TestCache_Scan_StableUnderChanges(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+
+	for i := 0; i < 50; i++ {
+		require.NoError(t, cache.Create(ctx, map[string]any{fmt.Sprintf("users/%d", i): i}))
+	}
+
+	// Delete keys returned by earlier pages and add new ones as the scan goes;
+	// every key present throughout must come back exactly once
+	seen := map[string]int{}
+	cursor := uint64(0)
+	for n := 0; ; n++ {
+		page, next, err := cache.Scan(ctx, "users/*", cursor, 7, nil)
+		require.NoError(t, err)
+		for _, key := range page {
+			seen[key]++
+		}
+		if len(page) > 0 {
+			require.NoError(t, cache.Delete(ctx, page[0]))
+		}
+		require.NoError(t, cache.Create(ctx, map[string]any{fmt.Sprintf("users/new-%d", n): n}))
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	for i := 0; i < 50; i++ {
+		assert.Equal(t, 1, seen[fmt.Sprintf("users/%d", i)], "users/%d", i)
+	}
+}
+
+func TestCache_Scan_Filter(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"a": "x",
+		"b": 1,
+		"c": map[string]any{"d": "y"},
+	}))
+
+	strings := func(key string, value any) bool { return TypeName(value) == "string" }
+	keys, next, err := cache.Scan(ctx, "**", 0, 100, strings)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "c/d"}, keys)
+	assert.Equal(t, uint64(0), next)
+}
+
+func TestCache_Scan_Order(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"b": map[string]any{"y": 1, "x": []any{"p", "q"}},
+		"a": 1,
+		"c": map[string]any{"z": map[string]any{"deep": true}},
+	}))
+
+	// One key per page, resuming after the last key each time
+	var seen []string
+	cursor := uint64(0)
+	for {
+		page, next, err := cache.Scan(ctx, "**", cursor, 1, nil)
+		require.NoError(t, err)
+		seen = append(seen, page...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, []string{"a", "b", "b/x", "b/x/0", "b/x/1", "b/y", "c", "c/z", "c/z/deep"}, seen)
+
+	// Finished scans leave no cursors behind
+	assert.Empty(t, cache.scanCursors)
+}
+
+func TestCache_Scan_Patterns(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"users": map[string]any{
+			"u1": map[string]any{"name": "a", "tags": []any{"x"}},
+			"u2": map[string]any{"name": "b"},
+		},
+		"items": []any{map[string]any{"name": "c"}},
+	}))
+
+	for pattern, expected := range map[string][]string{
+		"users/*/name":   {"users/u1/name", "users/u2/name"},
+		"**/name":        {"items/0/name", "users/u1/name", "users/u2/name"},
+		"users/**":       {"users/u1", "users/u1/name", "users/u1/tags", "users/u1/tags/0", "users/u2", "users/u2/name"},
+		"items/[0]/name": {"items/0/name"},
+		"users/u?":       {"users/u1", "users/u2"},
+		"users/u1/tags":  {"users/u1/tags"},
+		"missing/*":      nil,
+	} {
+		keys, next, err := cache.Scan(ctx, pattern, 0, 100, nil)
+		require.NoError(t, err)
+		assert.Equal(t, expected, keys, pattern)
+		assert.Equal(t, uint64(0), next)
+	}
+}
+
+func TestCache_Scan_Cursors(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		require.NoError(t, cache.Create(ctx, map[string]any{fmt.Sprintf("key-%02d", i): i}))
+	}
+
+	_, cursor, err := cache.Scan(ctx, "*", 0, 5, nil)
+	require.NoError(t, err)
+	require.NotZero(t, cursor)
+
+	// A cursor can be used again, e.g. to retry a page
+	page1, _, err := cache.Scan(ctx, "*", cursor, 5, nil)
+	require.NoError(t, err)
+	page2, _, err := cache.Scan(ctx, "*", cursor, 5, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key-05", "key-06", "key-07", "key-08", "key-09"}, page1)
+	assert.Equal(t, page1, page2)
+
+	// Unknown and expired cursors are rejected
+	_, _, err = cache.Scan(ctx, "*", 12345, 5, nil)
+	assert.True(t, errors.Is(err, ErrInvalidScanCursor))
+
+	for _, c := range cache.scanCursors {
+		c.expires = time.Now().Add(-time.Second)
+	}
+	_, _, err = cache.Scan(ctx, "*", cursor, 5, nil)
+	assert.True(t, errors.Is(err, ErrInvalidScanCursor))
+	assert.Empty(t, cache.scanCursors)
+
+	// Only so many cursors are kept
+	for i := 0; i < maxScanCursors+10; i++ {
+		_, _, err := cache.Scan(ctx, "*", 0, 5, nil)
+		require.NoError(t, err)
+	}
+	assert.Len(t, cache.scanCursors, maxScanCursors)
+}
+
+func TestCache_ScanTree(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"user": map[string]any{"1": map[string]any{"name": "alice"}, "2": "bob"},
+	}))
+
+	// Keep leaves and stop at maps holding no maps
+	visit := func(key string, value any) (bool, bool) {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return true, false
+		}
+		for _, v := range m {
+			if _, ok := v.(map[string]any); ok {
+				return false, true
+			}
+		}
+		return true, false
+	}
+	keys, next, err := cache.ScanTree(ctx, 0, 10, visit)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user/1", "user/2"}, keys)
+	assert.Equal(t, uint64(0), next)
+}
@@ -12,6 +12,7 @@ type OperationExecution struct {
 	Operation string        // Type of operation (e.g., "POST /keys", "POST /commands")
 	Success   bool          // Whether it completed successfully
 	TimedOut  bool          // Whether it exceeded timeout
+	JobId     string        // The background job, if it was one
 }
 
 // OperationStats tracks long-running operation metrics for a cache
@@ -32,17 +33,19 @@ func NewOperationStats(maxHistorySize int) *OperationStats {
 // RecordLongOperation adds a long-running operation to the history
 // This should only be called for operations exceeding the threshold
 func (os *OperationStats) RecordLongOperation(duration time.Duration, operation string, success bool, timedOut bool) {
-	os.mu.Lock()
-	defer os.mu.Unlock()
-
-	// Add to recent history
-	exec := OperationExecution{
+	os.record(OperationExecution{
 		Timestamp: time.Now(),
 		Duration:  duration,
 		Operation: operation,
 		Success:   success,
 		TimedOut:  timedOut,
-	}
+	})
+}
+
+// record adds an operation to the history.
+func (os *OperationStats) record(exec OperationExecution) {
+	os.mu.Lock()
+	defer os.mu.Unlock()
 
 	// Ring buffer behavior: remove oldest if at capacity
 	if len(os.recentHistory) >= os.maxHistorySize {
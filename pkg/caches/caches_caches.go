@@ -1,6 +1,7 @@
 package caches
 
 import (
+	"context"
 	"sync"
 
 	"github.com/goodblaster/map-cache/pkg/containers"
@@ -30,6 +31,19 @@ func AddCache(name string) error {
 	return nil
 }
 
+// cacheName returns the name cache is registered under, if it still is.
+func cacheName(cache *Cache) (string, bool) {
+	name, found := "", false
+	caches.Range(func(key, value interface{}) bool {
+		if value.(*Cache) == cache {
+			name, found = key.(string), true
+			return false
+		}
+		return true
+	})
+	return name, found
+}
+
 // FetchCache - do not automatically acquire the cache lock.
 func FetchCache(name string) (*Cache, error) {
 	val, exists := caches.Load(name)
@@ -60,6 +74,10 @@ func DeleteCache(name string) error {
 		cache.exp.Stop()
 	}
 
+	// And the schedules and jobs
+	cache.stopSchedules()
+	cache.stopJobs()
+
 	caches.Delete(name)
 	return nil
 }
@@ -89,4 +107,5 @@ func (cache *Cache) Release(tag string) {
 // Clear - Clear the cache. Must already be acquired.
 func (cache *Cache) Clear() {
 	cache.cmap = containers.NewGabsMap()
+	cache.updateIndexes(context.Background(), nil)
 }
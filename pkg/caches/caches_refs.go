@@ -0,0 +1,192 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// CacheRefPrefix starts a key in another cache, written "@name:key", e.g.
+// "@workers:pool/1/busy" or ${{@workers:pool/*/busy}}. Another cache can only
+// be used by an execution that holds its lock; see AcquireCaches and
+// WithCaches.
+const CacheRefPrefix = "@"
+
+// cacheRefPattern finds the cache names at the start of keys and references
+// in a command's JSON.
+var cacheRefPattern = regexp.MustCompile(`(?:"|\{\{\s*)@([^\s"{}$/@:]+):`)
+
+// splitCacheRef splits "@name:key" into the cache name and the key.
+func splitCacheRef(key string) (name, rest string, ok bool) {
+	if !strings.HasPrefix(key, CacheRefPrefix) {
+		return "", key, false
+	}
+	i := strings.IndexByte(key, ':')
+	if i <= len(CacheRefPrefix) {
+		return "", key, false
+	}
+	return key[len(CacheRefPrefix):i], key[i+1:], true
+}
+
+type linkedCachesKey struct{}
+
+var linkedCachesContextKey = linkedCachesKey{}
+
+// WithCaches returns a context whose executions may use the given caches by
+// name with @name: keys. The caller must hold the lock of every cache.
+func WithCaches(ctx context.Context, locked map[string]*Cache) context.Context {
+	return context.WithValue(ctx, linkedCachesContextKey, locked)
+}
+
+func linkedCaches(ctx context.Context) map[string]*Cache {
+	locked, _ := ctx.Value(linkedCachesContextKey).(map[string]*Cache)
+	return locked
+}
+
+// resolveCache returns the cache that key belongs to and the key within that
+// cache. Keys without an @name: prefix belong to cache. prefix is the @name:
+// prefix of key, if any, for reporting keys found in the other cache.
+func resolveCache(ctx context.Context, cache *Cache, key string) (target *Cache, rest, prefix string, err error) {
+	name, rest, ok := splitCacheRef(key)
+	if !ok {
+		return cache, key, "", nil
+	}
+	target, ok = linkedCaches(ctx)[name]
+	if !ok {
+		return nil, "", "", ErrCacheNotLinked.Format(name)
+	}
+	return target, rest, key[:len(key)-len(rest)], nil
+}
+
+// ReferencedCaches returns the names of the caches that commands reference
+// with @name: keys, sorted and without duplicates. Names must be literal;
+// @${{$name}}: is not recognized.
+func ReferencedCaches(commands ...Command) []string {
+	data, err := json.Marshal(rawCommands(commands))
+	if err != nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range cacheRefPattern.FindAllSubmatch(data, -1) {
+		name := string(m[1])
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReferencedCaches is the package-level ReferencedCaches, also counting the
+// references in the bodies of the procedures that commands call, directly or
+// through other procedures. Every version of a called procedure counts.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) ReferencedCaches(commands ...Command) []string {
+	all := slices.Clone(commands)
+	called := map[string]bool{}
+	for pending := commands; len(pending) > 0; {
+		var next []Command
+		for _, name := range calledProcedures(pending...) {
+			if called[name] {
+				continue
+			}
+			called[name] = true
+			for _, proc := range cache.procedures[name] {
+				next = append(next, proc.Command)
+			}
+		}
+		all = append(all, next...)
+		pending = next
+	}
+	return ReferencedCaches(all...)
+}
+
+// calledProcedures returns the names of the procedures that commands CALL.
+func calledProcedures(commands ...Command) []string {
+	data, err := json.Marshal(rawCommands(commands))
+	if err != nil {
+		return nil
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil
+	}
+
+	var names []string
+	var walk func(v any)
+	walk = func(v any) {
+		switch node := v.(type) {
+		case map[string]any:
+			if node["type"] == string(CommandTypeCall) {
+				if name, ok := node["name"].(string); ok {
+					names = append(names, name)
+				}
+			}
+			for _, child := range node {
+				walk(child)
+			}
+		case []any:
+			for _, child := range node {
+				walk(child)
+			}
+		}
+	}
+	walk(decoded)
+	return names
+}
+
+// acquireWithReferences acquires the named cache and the caches its commands
+// reference (see Cache.ReferencedCaches), in name order, and returns them by
+// name along with the names of the referenced caches. The named cache is
+// missing from the result if it doesn't exist. Release them with
+// ReleaseCaches.
+func acquireWithReferences(tag, name string, commands ...Command) (map[string]*Cache, []string) {
+	cache, err := FetchCache(name)
+	if err != nil {
+		return map[string]*Cache{}, nil
+	}
+
+	// The cache's lock guards its procedures
+	cache.Acquire(tag)
+	names := cache.ReferencedCaches(commands...)
+	if len(names) == 0 {
+		return map[string]*Cache{name: cache}, nil
+	}
+	cache.Release(tag)
+	return AcquireCaches(tag, append(names, name)...), names
+}
+
+// AcquireCaches acquires the named caches, in name order so that executions
+// locking overlapping sets of caches cannot deadlock, and returns them by
+// name. Names that are not caches are skipped; using them fails later with
+// ErrCacheNotLinked. Release them with ReleaseCaches.
+func AcquireCaches(tag string, names ...string) map[string]*Cache {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+
+	locked := map[string]*Cache{}
+	for _, name := range sorted {
+		if _, ok := locked[name]; ok {
+			continue
+		}
+		cache, err := FetchCache(name)
+		if err != nil {
+			continue
+		}
+		locked[name] = cache.Acquire(tag)
+	}
+	return locked
+}
+
+// ReleaseCaches releases caches acquired by AcquireCaches.
+func ReleaseCaches(tag string, locked map[string]*Cache) {
+	for _, cache := range locked {
+		cache.Release(tag)
+	}
+}
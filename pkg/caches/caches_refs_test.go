@@ -0,0 +1,148 @@
+package caches
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/goodblaster/errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// linkedTestCaches returns a jobs and a workers cache, linked under those names.
+func linkedTestCaches(t *testing.T) (context.Context, *Cache, *Cache) {
+	ctx := context.Background()
+	jobs := New()
+	require.NoError(t, jobs.Create(ctx, map[string]any{"pending": 2.0, "assigned": ""}))
+	workers := New()
+	require.NoError(t, workers.Create(ctx, map[string]any{
+		"pool": map[string]any{
+			"a": map[string]any{"busy": true, "load": 3.0},
+			"b": map[string]any{"busy": false, "load": 1.0},
+		},
+	}))
+	return WithCaches(ctx, map[string]*Cache{"jobs": jobs, "workers": workers}), jobs, workers
+}
+
+func TestCacheRefs_Execute(t *testing.T) {
+	ctx, jobs, workers := linkedTestCaches(t)
+
+	res := jobs.Execute(ctx,
+		FOR("${{@workers:pool/{id}/busy}}",
+			IF("${{$item}} == false", COMMANDS(
+				REPLACE("${{$key}}", true),
+				REPLACE("assigned", "${{$id}}"),
+				INC("pending", -1),
+			), NOOP()),
+		),
+		RETURN_EXPR("sum(${{@workers:pool/*/load}}) + ${{pending}}"),
+		GET("@workers:pool/*/busy"),
+	)
+	require.NoError(t, res.Error)
+
+	values := res.Value.([]any)
+	assert.Equal(t, 5.0, values[1])
+	assert.Equal(t, map[string]any{"@workers:pool/a/busy": true, "@workers:pool/b/busy": true}, values[2])
+
+	busy, err := workers.Get(ctx, "pool/b/busy")
+	require.NoError(t, err)
+	assert.Equal(t, true, busy)
+
+	assigned, err := jobs.Get(ctx, "assigned")
+	require.NoError(t, err)
+	assert.Equal(t, "b", assigned)
+
+	res = jobs.Execute(ctx, INC("@workers:pool/a/load", 1), DELETE("@workers:pool/b"), RETURN("${{@workers:pool/a/load}}"))
+	require.NoError(t, res.Error)
+	assert.Equal(t, 4.0, res.Value.([]any)[2])
+	_, err = workers.Get(ctx, "pool/b")
+	assert.Error(t, err)
+}
+
+func TestCacheRefs_Triggers(t *testing.T) {
+	ctx, jobs, workers := linkedTestCaches(t)
+
+	// A trigger in the other cache runs there, and can reach back
+	_, err := workers.CreateTrigger(ctx, "pool/*/busy", IF("${{@jobs:pending}} > 0", INC("@jobs:pending", -1), NOOP()))
+	require.NoError(t, err)
+
+	res := jobs.Execute(ctx, REPLACE("@workers:pool/b/busy", true))
+	require.NoError(t, res.Error)
+
+	pending, err := jobs.Get(ctx, "pending")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, pending)
+}
+
+func TestCacheRefs_NotLinked(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{"a": 1.0}))
+
+	for _, cmd := range []Command{
+		GET("@other:a"),
+		REPLACE("@other:a", 2.0),
+		RETURN("${{@other:a}}"),
+		IF("exists(${{@other:a}})", NOOP(), NOOP()),
+	} {
+		res := cache.Execute(ctx, cmd)
+		require.Error(t, res.Error)
+		assert.True(t, errors.Is(res.Error, ErrCacheNotLinked), res.Error.Error())
+	}
+}
+
+func TestCacheRefs_DryRun(t *testing.T) {
+	ctx, jobs, workers := linkedTestCaches(t)
+
+	report := jobs.DryRun(ctx, REPLACE("@workers:pool/b/busy", true), INC("pending", -1))
+	require.Empty(t, report.Error)
+
+	var keys []string
+	for _, w := range report.Writes {
+		keys = append(keys, w.Key)
+	}
+	assert.Equal(t, []string{"@workers:pool/b/busy", "pending"}, keys)
+
+	busy, err := workers.Get(ctx, "pool/b/busy")
+	require.NoError(t, err)
+	assert.Equal(t, false, busy)
+}
+
+func TestReferencedCaches(t *testing.T) {
+	names := ReferencedCaches(
+		GET("@workers:pool/*"),
+		IF("${{ @jobs:count }} > 0 && ${{@workers:x}}", REPLACE("note", "mail bob@example:1"), NOOP()),
+		FOR("${{@alpha:items/*}}", PRINT("${{$item}}")),
+	)
+	assert.Equal(t, []string{"alpha", "jobs", "workers"}, names)
+	assert.Empty(t, ReferencedCaches(GET("a"), RETURN("@home")))
+}
+
+func TestAcquireCaches(t *testing.T) {
+	a, b := "refs-"+uuid.NewString(), "refs-"+uuid.NewString()
+	require.NoError(t, AddCache(a))
+	require.NoError(t, AddCache(b))
+	defer DeleteCache(a)
+	defer DeleteCache(b)
+
+	locked := AcquireCaches("t1", b, a, b, "refs-missing")
+	assert.Len(t, locked, 2)
+
+	// Opposite orders of names must not deadlock
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ReleaseCaches("t2", AcquireCaches("t2", a, b))
+		}()
+		go func() {
+			defer wg.Done()
+			ReleaseCaches("t3", AcquireCaches("t3", b, a))
+		}()
+	}
+	ReleaseCaches("t1", locked)
+	wg.Wait()
+}
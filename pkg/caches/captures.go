@@ -0,0 +1,90 @@
+package caches
+
+import (
+	"strings"
+
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/containers"
+)
+
+// Named captures let trigger keys and FOR patterns name their wildcard
+// segments, e.g. "jobs/{jobId}/domains/{domain}". Each named segment matches
+// like "*" and binds the matched fragment to a variable, ${{$jobId}}. Because
+// they are variables, named captures are lexically scoped: a nested FOR binds
+// its own captures without disturbing the outer ones. Every wildcard segment,
+// named or not, is also available positionally as ${{1}}, ${{2}}, ..., as is
+// what each "**" or glob segment matched.
+
+// captureName returns the name of a "{name}" path segment.
+func captureName(segment string) (string, bool) {
+	if len(segment) < 3 || segment[0] != '{' || segment[len(segment)-1] != '}' {
+		return "", false
+	}
+	name := segment[1 : len(segment)-1]
+	if strings.ContainsAny(name, "{}*$ ") {
+		return "", false
+	}
+	return name, true
+}
+
+// parseCapturePattern rewrites named segments of pattern to "*". It returns
+// the rewritten pattern and the capture name of each wildcard segment, in
+// order; plain "*", "**" and glob segments have an empty name.
+func parseCapturePattern(pattern string) (string, []string, error) {
+	if !strings.Contains(pattern, "{") {
+		n := countWildcards(pattern)
+		if n == 0 {
+			return pattern, nil, nil
+		}
+		return pattern, make([]string, n), nil
+	}
+
+	segments := containers.SplitPath(pattern)
+	var names []string
+	for i, segment := range segments {
+		name, ok := captureName(segment)
+		if !ok {
+			if strings.ContainsAny(segment, "{}") {
+				return "", nil, ErrInvalidCapture.Format(segment, pattern)
+			}
+			names = append(names, make([]string, countWildcards(segment))...)
+			continue
+		}
+		for _, existing := range names {
+			if existing == name {
+				return "", nil, ErrDuplicateCapture.Format(name, pattern)
+			}
+		}
+		names = append(names, name)
+		segments[i] = "*"
+	}
+	return strings.Join(segments, config.KeyDelimiter), names, nil
+}
+
+// countWildcards counts the segments of a pattern that capture what they
+// match: "*", "**" and globs.
+func countWildcards(pattern string) int {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return 0
+	}
+	segments, err := containers.ParsePath(pattern)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, seg := range segments {
+		if seg.IsPattern() {
+			n++
+		}
+	}
+	return n
+}
+
+// bindCaptures defines the named captures in s.
+func bindCaptures(s *scope, names []string, values []string) {
+	for i, name := range names {
+		if name != "" && i < len(values) {
+			s.define(name, values[i])
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package caches
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCapturePattern(t *testing.T) {
+	pattern, names, err := parseCapturePattern("jobs/{jobId}/domains/*/{field}")
+	assert.NoError(t, err)
+	assert.Equal(t, "jobs/*/domains/*/*", pattern)
+	assert.Equal(t, []string{"jobId", "", "field"}, names)
+
+	pattern, names, err = parseCapturePattern("a/*/b/*")
+	assert.NoError(t, err)
+	assert.Equal(t, "a/*/b/*", pattern)
+	assert.Equal(t, []string{"", ""}, names)
+
+	pattern, names, err = parseCapturePattern("a/b")
+	assert.NoError(t, err)
+	assert.Equal(t, "a/b", pattern)
+	assert.Empty(t, names)
+
+	_, _, err = parseCapturePattern("a/{id}/b/{id}")
+	assert.ErrorIs(t, err, ErrDuplicateCapture)
+
+	_, _, err = parseCapturePattern("a/{id/b")
+	assert.ErrorIs(t, err, ErrInvalidCapture)
+
+	_, _, err = parseCapturePattern("a/x{id}")
+	assert.ErrorIs(t, err, ErrInvalidCapture)
+}
+
+func TestTrigger_NamedCaptures(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"jobs": map[string]any{
+			"42": map[string]any{
+				"domains": map[string]any{"apple": map[string]any{"countdown": 1.0}},
+				"last":    "",
+				"pos":     "",
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = cache.CreateTrigger(ctx, "jobs/{jobId}/domains/{domain}/countdown", COMMANDS(
+		REPLACE("jobs/${{$jobId}}/last", "${{$domain}}"),
+		REPLACE("jobs/${{1}}/pos", "${{2}}"),
+	))
+	assert.NoError(t, err)
+
+	res := INC("jobs/42/domains/apple/countdown", -1).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+
+	val, err := cache.Get(ctx, "jobs/42/last")
+	assert.NoError(t, err)
+	assert.Equal(t, "apple", val)
+
+	val, err = cache.Get(ctx, "jobs/42/pos")
+	assert.NoError(t, err)
+	assert.Equal(t, "apple", val)
+}
+
+func TestTrigger_InvalidCapture(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	_, err := cache.CreateTrigger(ctx, "jobs/{id}/x/{id}", NOOP())
+	assert.ErrorIs(t, err, ErrDuplicateCapture)
+}
+
+func TestFOR_NamedCaptures(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"users": map[string]any{
+			"alice": map[string]any{"role": "admin"},
+			"bob":   map[string]any{"role": "user"},
+		},
+		"roles": map[string]any{"alice": "", "bob": ""},
+	})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		FOR("${{users/{name}/role}}",
+			REPLACE("roles/${{$name}}", "${{$item}}"),
+		),
+	)
+	assert.NoError(t, res.Error)
+
+	roles, err := cache.Get(ctx, "roles")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"alice": "admin", "bob": "user"}, roles)
+}
+
+func TestFOR_NestedNamedCapturesAreScoped(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"groups": map[string]any{
+			"a": map[string]any{"x": 1.0, "y": 2.0},
+			"b": map[string]any{"z": 3.0},
+		},
+		"counts": map[string]any{"a": 0.0, "b": 0.0},
+		"last":   map[string]any{"a": "", "b": ""},
+	})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		FOR("${{groups/{g}}}",
+			// The inner loop rebinds g for its own iterations only
+			FOR("${{groups/{g}/*}}",
+				INC("counts/${{$g}}", 1),
+			),
+			REPLACE("last/${{$g}}", "${{$g}}"),
+		),
+	)
+	assert.NoError(t, res.Error)
+
+	counts, err := cache.Get(ctx, "counts")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": 4.0, "b": 2.0}, counts)
+
+	last, err := cache.Get(ctx, "last")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": "a", "b": "b"}, last)
+}
@@ -0,0 +1,69 @@
+package caches
+
+import (
+	"context"
+	"slices"
+)
+
+// MaxProcedureDepth is the maximum number of nested procedure calls, which
+// stops procedures that (directly or indirectly) call themselves forever.
+const MaxProcedureDepth = 10
+
+type procedureDepthKey struct{}
+
+var procedureDepthContextKey = procedureDepthKey{}
+
+// CommandCall runs a stored procedure. Each argument is bound to the
+// procedure parameter of the same name; string arguments are interpolated in
+// the caller's context first, so a trigger can pass ${{$jobId}} along. The
+// procedure body runs with its own variables, isolated from the caller's
+// variables and captures.
+type CommandCall struct {
+	Name    string         `json:"name,required"`
+	Args    map[string]any `json:"args,omitempty"`
+	Version int            `json:"version,omitempty"` // 0 for the latest version
+}
+
+func (CommandCall) Type() CommandType {
+	return CommandTypeCall
+}
+
+func CALL(name string, args map[string]any) Command {
+	return CommandCall{Name: name, Args: args}
+}
+
+func (p CommandCall) Do(ctx context.Context, cache *Cache) CmdResult {
+	proc, err := cache.Procedure(ctx, p.Name, p.Version)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+
+	depth, _ := ctx.Value(procedureDepthContextKey).(int)
+	if depth >= MaxProcedureDepth {
+		return CmdResult{Error: ErrProcedureRecursionLimit.Format(MaxProcedureDepth)}
+	}
+
+	for name := range p.Args {
+		if !slices.Contains(proc.Params, name) {
+			return CmdResult{Error: ErrUnknownProcedureArg.Format(p.Name, name)}
+		}
+	}
+
+	s := newScope(nil)
+	for _, param := range proc.Params {
+		arg, ok := p.Args[param]
+		if !ok {
+			return CmdResult{Error: ErrMissingProcedureArg.Format(p.Name, param)}
+		}
+		val, err := resolveValue(ctx, cache, arg)
+		if err != nil {
+			return CmdResult{Error: err}
+		}
+		s.define(param, val)
+	}
+
+	// The body sees neither the caller's variables nor its captures
+	callCtx := context.WithValue(ctx, procedureDepthContextKey, depth+1)
+	callCtx = context.WithValue(callCtx, triggerVarsContextKey, []string(nil))
+	return runCommand(withScope(callCtx, s), cache, proc.Command)
+}
@@ -12,7 +12,6 @@ type CmdResult struct {
 type Command interface {
 	Do(ctx context.Context, cache *Cache) CmdResult
 	Type() CommandType
-	MarshalJSON() ([]byte, error)
 }
 
 type CommandGroup struct {
@@ -32,6 +31,12 @@ const (
 	CommandTypeNoop    CommandType = "NOOP"
 	CommandTypeGroup   CommandType = "COMMANDS"
 	CommandTypeDelete  CommandType = "DELETE"
+	CommandTypeLet     CommandType = "LET"
+	CommandTypeSwitch  CommandType = "SWITCH"
+	CommandTypeTry     CommandType = "TRY"
+	CommandTypeWhile   CommandType = "WHILE"
+	CommandTypeCall    CommandType = "CALL"
+	CommandTypeQuery   CommandType = "QUERY"
 )
 
 func (CommandGroup) Type() CommandType {
@@ -46,13 +51,16 @@ func (p CommandGroup) Do(ctx context.Context, cache *Cache) CmdResult {
 	var res CmdResult
 	var resValues []any
 
+	// Variables bound by LET are visible to the rest of the execution.
+	ctx = ensureScope(ctx)
+
 	for _, action := range p.Actions {
 		// Check for context cancellation
 		if err := ctx.Err(); err != nil {
 			return CmdResult{Error: err}
 		}
 
-		actionRes := action.Do(ctx, cache)
+		actionRes := runCommand(ctx, cache, action)
 		if actionRes.Error != nil {
 			return actionRes
 		}
@@ -2,7 +2,8 @@ package caches
 
 import (
 	"context"
-	"strings"
+
+	"github.com/goodblaster/map-cache/pkg/containers"
 )
 
 type CommandDelete struct {
@@ -18,10 +19,23 @@ func DELETE(key string) Command {
 }
 
 func (p CommandDelete) Do(ctx context.Context, cache *Cache) CmdResult {
+	key, err := interpolateKey(ctx, p.Key)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+
+	cache, key, _, err = resolveCache(ctx, cache, key)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+
 	// Check if pattern contains wildcards
-	if strings.Contains(p.Key, "*") {
+	if containers.IsPattern(key) {
 		// Get all matching keys first (to return their values)
-		keys := cache.cmap.WildKeys(ctx, p.Key)
+		keys, err := cache.visitKeys(ctx, key)
+		if err != nil {
+			return CmdResult{Error: err}
+		}
 		values := make([]any, 0, len(keys))
 
 		for _, key := range keys {
@@ -41,14 +55,14 @@ func (p CommandDelete) Do(ctx context.Context, cache *Cache) CmdResult {
 	}
 
 	// Single key deletion
-	val, err := cache.Get(ctx, p.Key)
+	val, err := cache.Get(ctx, key)
 	if err != nil {
 		// Key doesn't exist - that's okay for delete
 		// Return nil to indicate nothing was deleted
 		return CmdResult{Value: nil}
 	}
 
-	if err := cache.Delete(ctx, p.Key); err != nil {
+	if err := cache.Delete(ctx, key); err != nil {
 		return CmdResult{Error: err}
 	}
 
@@ -1,7 +1,35 @@
 package caches
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
+// Execute runs commands, bounded by the cache's execution limits and any set
+// on ctx with WithExecutionLimits.
 func (cache *Cache) Execute(ctx context.Context, commands ...Command) CmdResult {
-	return COMMANDS(commands...).Do(ctx, cache)
+	return COMMANDS(commands...).Do(cache.withBudget(ctx), cache)
+}
+
+// runCommand runs a nested command. Commands that run other commands (groups,
+// branches, loop bodies, triggers, ...) go through here rather than calling
+// Do directly, so that per-command instrumentation and execution limits see
+// every command.
+func runCommand(ctx context.Context, cache *Cache, cmd Command) CmdResult {
+	ctx = cache.withBudget(ctx)
+	budget := budgetFrom(ctx)
+	if err := budget.enter(); err != nil {
+		return CmdResult{Error: err}
+	}
+	defer budget.leave()
+
+	if cache.dryRun == nil {
+		return cmd.Do(ctx, cache)
+	}
+
+	node := cache.dryRun.begin(cmd)
+	start := time.Now()
+	res := cmd.Do(ctx, cache)
+	cache.dryRun.end(node, time.Since(start), res.Error)
+	return res
 }
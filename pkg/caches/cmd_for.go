@@ -3,15 +3,48 @@ package caches
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"reflect"
-	"regexp"
+	"math"
 	"strings"
+
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/containers"
+)
+
+// Default names of the variables bound on each loop iteration.
+const (
+	LoopItemVariable  = "item"
+	LoopIndexVariable = "index"
+	LoopKeyVariable   = "key"
 )
 
+// CommandFor runs Commands once per iteration. It iterates over the keys
+// matching a wildcard LoopExpr such as ${{jobs/*/status}}, over the elements of
+// an array when LoopExpr references one without a wildcard (e.g. ${{queue}} or
+// ${{$list}}), or over a numeric Range when one is given.
+//
+// Each iteration binds the current element to ${{$item}} and its position to
+// ${{$index}} (renamed with As and IndexAs); wildcard loops also bind the
+// matched key to ${{$key}}, named captures such as {domain} in
+// ${{jobs/*/domains/{domain}}} to ${{$domain}}, and keep positional captures
+// ${{1}}, ${{2}}, ... Captures are read when each command runs, as in
+// triggers, so the commands are never rewritten; inside a nested wildcard
+// loop they refer to the innermost loop.
 type CommandFor struct {
-	LoopExpr string    `json:"loop_expr,required"`
-	Commands []Command `json:"commands,required"`
+	LoopExpr      string     `json:"loop_expr,omitempty"`
+	Range         *LoopRange `json:"range,omitempty"`
+	As            string     `json:"as,omitempty"`
+	IndexAs       string     `json:"index_as,omitempty"`
+	MaxIterations int        `json:"max_iterations,omitempty"`
+	Commands      []Command  `json:"commands,required"`
+}
+
+// LoopRange iterates From (inclusive) to To (exclusive) by Step. Each bound may
+// be a number or an interpolated string such as "${{jobs/count}}". Step
+// defaults to 1, or -1 when From is greater than To.
+type LoopRange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+	Step any `json:"step,omitempty"`
 }
 
 func (CommandFor) Type() CommandType {
@@ -22,9 +55,20 @@ func FOR(loopExpr string, cmds ...Command) Command {
 	return CommandFor{LoopExpr: loopExpr, Commands: cmds}
 }
 
+// FOR_RANGE iterates ${{$index}} and ${{$item}} from "from" up to, but not
+// including, "to".
+func FOR_RANGE(from, to any, cmds ...Command) Command {
+	return CommandFor{Range: &LoopRange{From: from, To: to}, Commands: cmds}
+}
+
 func (f CommandFor) Do(ctx context.Context, cache *Cache) CmdResult {
-	// Extract pattern like ${{job-1234/domains/*/countdown}} using shared regex
-	match := InterpolationPattern.FindStringSubmatch(f.LoopExpr)
+	if f.Range != nil {
+		return f.doRange(ctx, cache)
+	}
+
+	// Extract pattern like ${{job-1234/domains/{domain}/countdown}}, with
+	// captures of an enclosing loop or trigger filled in
+	match := LoopPattern.FindStringSubmatch(substituteContextVars(ctx, f.LoopExpr))
 	if len(match) < 2 {
 		return CmdResult{Error: ErrInvalidForExpression.Format(f.LoopExpr)}
 	}
@@ -35,57 +79,41 @@ func (f CommandFor) Do(ctx context.Context, cache *Cache) CmdResult {
 		keyPattern = strings.TrimSpace(keyPattern)
 	}
 
-	// Check for wildcard (avoid strings.Contains)
-	hasWildcard := false
-	for i := 0; i < len(keyPattern); i++ {
-		if keyPattern[i] == '*' {
-			hasWildcard = true
-			break
-		}
+	// Rewrite named captures ({name}) to plain wildcards
+	keyPattern, captureNames, err := parseCapturePattern(keyPattern)
+	if err != nil {
+		return CmdResult{Error: err}
 	}
-	if !hasWildcard {
-		return CmdResult{Error: ErrForExpressionNeedsWildcard.Format(keyPattern)}
+	if len(captureNames) == 0 {
+		return f.doArray(ctx, cache, keyPattern)
 	}
 
-	// Build a regex from the wildcard pattern
-	regexPattern := regexp.QuoteMeta(keyPattern)
-	starCount := strings.Count(keyPattern, "*")
-	for i := 0; i < starCount; i++ {
-		regexPattern = strings.Replace(regexPattern, "\\*", "([^/]+)", 1)
-	}
-	keyRegex := regexp.MustCompile("^" + regexPattern + "$")
-
 	// Resolve keys
-	keys := cache.cmap.WildKeys(ctx, keyPattern)
+	target, targetPattern, prefix, err := resolveCache(ctx, cache, keyPattern)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+	keys, err := target.visitKeys(ctx, targetPattern)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
 	var allResults []CmdResult
 
-	for _, key := range keys {
-		// Check for context cancellation
-		if err := ctx.Err(); err != nil {
-			return CmdResult{Error: err}
-		}
-
-		submatches := keyRegex.FindStringSubmatch(key)
-		if len(submatches) != starCount+1 {
-			// No match or incorrect group count
+	for i, key := range keys {
+		captures, ok := containers.MatchPath(targetPattern, key)
+		if !ok {
 			continue
 		}
 
-		for _, cmd := range f.Commands {
-			// Check for context cancellation
-			if err := ctx.Err(); err != nil {
-				return CmdResult{Error: err}
-			}
-
-			// Replace ${{1}}, ${{2}}, ... with the captured fragments
-			transformed := transformCommand(cmd, submatches[1:])
-
-			result := transformed.Do(ctx, cache)
-			allResults = append(allResults, result)
+		item, _ := target.Get(ctx, key)
+		key = prefix + key
+		iterCtx := f.iterationScope(ctx, item, i)
+		iterScope := scopeFrom(iterCtx)
+		iterScope.define(LoopKeyVariable, key)
+		bindCaptures(iterScope, captureNames, captures)
 
-			if result.Error != nil {
-				return result // stop on first error
-			}
+		if res := f.runBody(context.WithValue(iterCtx, triggerVarsContextKey, captures), cache, &allResults); res.Error != nil {
+			return res // stop on first error
 		}
 	}
 
@@ -94,82 +122,123 @@ func (f CommandFor) Do(ctx context.Context, cache *Cache) CmdResult {
 	}
 }
 
-func transformCommand(cmd Command, captures []string) Command {
-	if cmd == nil {
-		return nil
+// doArray iterates over the elements of the array stored at ref.
+func (f CommandFor) doArray(ctx context.Context, cache *Cache, ref string) CmdResult {
+	val, err := lookupRef(ctx, cache, ref)
+	if err != nil {
+		return CmdResult{Error: ErrForExpressionNeedsWildcard.Format(ref)}
 	}
-
-	// Convert pointer to value if needed
-	val := reflect.ValueOf(cmd)
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
-		cmd = val.Interface().(Command)
+	items, ok := val.([]any)
+	if !ok {
+		return CmdResult{Error: ErrForExpressionNeedsWildcard.Format(ref)}
 	}
 
-	switch c := cmd.(type) {
-	case CommandIf:
-		return &CommandIf{
-			Condition: substituteCaptures(c.Condition, captures),
-			IfTrue:    transformCommand(c.IfTrue, captures),
-			IfFalse:   transformCommand(c.IfFalse, captures),
-		}
-	case CommandGet:
-		return &CommandGet{
-			Key: substituteCaptures(c.Key, captures),
-		}
-	case CommandReplace:
-		return &CommandReplace{
-			Key:   substituteCaptures(c.Key, captures),
-			Value: c.Value,
-		}
-	case CommandInc:
-		return &CommandInc{
-			Key:   substituteCaptures(c.Key, captures),
-			Value: c.Value,
+	// Iterate over a snapshot, so commands modifying the array don't affect the loop.
+	items = append([]any(nil), items...)
+
+	var allResults []CmdResult
+	for i, item := range items {
+		if res := f.runBody(f.iterationScope(ctx, item, i), cache, &allResults); res.Error != nil {
+			return res
 		}
-	case CommandDelete:
-		return &CommandDelete{
-			Key: substituteCaptures(c.Key, captures),
+	}
+	return CmdResult{Value: allResults}
+}
+
+// doRange iterates over a numeric range.
+func (f CommandFor) doRange(ctx context.Context, cache *Cache) CmdResult {
+	from, err := rangeBound(ctx, cache, f.Range.From)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+	to, err := rangeBound(ctx, cache, f.Range.To)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+
+	step := 1.0
+	if from > to {
+		step = -1
+	}
+	if f.Range.Step != nil {
+		if step, err = rangeBound(ctx, cache, f.Range.Step); err != nil {
+			return CmdResult{Error: err}
 		}
-	case CommandPrint:
-		transformed := CommandPrint{Messages: make([]string, len(c.Messages))}
-		for i, msg := range c.Messages {
-			transformed.Messages[i] = substituteCaptures(msg, captures)
+		if step == 0 {
+			return CmdResult{Error: ErrInvalidLoopRange.Format(f.Range.Step)}
 		}
-		return &transformed
-	case CommandReturn:
-		if str, ok := c.Key.(string); ok {
-			return &CommandReturn{Key: substituteCaptures(str, captures)}
+	}
+
+	limit := loopIterationLimit(f.MaxIterations)
+	var allResults []CmdResult
+	for i, n := 0, from; (step > 0 && n < to) || (step < 0 && n > to); i, n = i+1, n+step {
+		if i >= limit {
+			return CmdResult{Error: ErrLoopIterationLimit.Format(limit)}
 		}
-		return &c
-	case CommandFor:
-		transformed := CommandFor{
-			LoopExpr: substituteCaptures(c.LoopExpr, captures),
-			Commands: make([]Command, len(c.Commands)),
+		if res := f.runBody(f.iterationScope(ctx, n, i), cache, &allResults); res.Error != nil {
+			return res
 		}
-		for i, cmd := range c.Commands {
-			transformed.Commands[i] = transformCommand(cmd, captures)
+	}
+	return CmdResult{Value: allResults}
+}
+
+// iterationScope returns a context with a new scope binding the loop item and index.
+func (f CommandFor) iterationScope(ctx context.Context, item any, index int) context.Context {
+	itemName, indexName := LoopItemVariable, LoopIndexVariable
+	if f.As != "" {
+		itemName = strings.TrimPrefix(f.As, VariablePrefix)
+	}
+	if f.IndexAs != "" {
+		indexName = strings.TrimPrefix(f.IndexAs, VariablePrefix)
+	}
+
+	s := newScope(scopeFrom(ctx))
+	s.define(itemName, item)
+	s.define(indexName, float64(index))
+	return withScope(ctx, s)
+}
+
+// runBody runs the loop commands once, appending their results.
+func (f CommandFor) runBody(ctx context.Context, cache *Cache, results *[]CmdResult) CmdResult {
+	for _, cmd := range f.Commands {
+		// Check for context cancellation
+		if err := ctx.Err(); err != nil {
+			return CmdResult{Error: err}
 		}
-		return &transformed
-	case CommandGroup:
-		transformed := CommandGroup{Actions: make([]Command, len(c.Actions))}
-		for i, action := range c.Actions {
-			transformed.Actions[i] = transformCommand(action, captures)
+
+		result := runCommand(ctx, cache, cmd)
+		*results = append(*results, result)
+
+		if result.Error != nil {
+			return result
 		}
-		return &transformed
-	case CommandNoop:
-		return &c
-	default:
-		return cmd
 	}
+	return CmdResult{}
 }
 
-func substituteCaptures(s string, captures []string) string {
-	for i, val := range captures {
-		placeholder := fmt.Sprintf("${{%d}}", i+1)
-		s = strings.ReplaceAll(s, placeholder, val)
+func rangeBound(ctx context.Context, cache *Cache, bound any) (float64, error) {
+	val, err := resolveValue(ctx, cache, bound)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := ToFloat64(val)
+	if !ok {
+		return 0, ErrInvalidLoopRange.Format(bound)
+	}
+	return f, nil
+}
+
+// loopIterationLimit returns the effective iteration cap for a loop. A
+// per-loop maximum can lower, but never raise, config.CommandMaxLoopIterations.
+func loopIterationLimit(max int) int {
+	limit := config.CommandMaxLoopIterations
+	if max > 0 && (limit <= 0 || max < limit) {
+		limit = max
+	}
+	if limit <= 0 {
+		limit = math.MaxInt
 	}
-	return s
+	return limit
 }
 
 func (c *CommandFor) UnmarshalJSON(data []byte) error {
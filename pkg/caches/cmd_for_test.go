@@ -266,3 +266,213 @@ func TestFOR_TransformsGET(t *testing.T) {
 	assert.Equal(t, "first", results[0].Value)
 	assert.Equal(t, "second", results[1].Value)
 }
+
+func TestFOR_ArrayValue(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"queue": []any{"a", "b", "c"},
+		"seen":  map[string]any{"a": -1.0, "b": -1.0, "c": -1.0},
+	})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		FOR("${{queue}}",
+			REPLACE("seen/${{$item}}", "${{$index}}"),
+		),
+	)
+	assert.NoError(t, res.Error)
+
+	seen, err := cache.Get(ctx, "seen")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": 0.0, "b": 1.0, "c": 2.0}, seen)
+}
+
+func TestFOR_ArrayVariableWithNames(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"total": 0.0})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		LET("prices", []any{1.5, 2.5, 4.0}),
+		CommandFor{
+			LoopExpr: "${{$prices}}",
+			As:       "price",
+			IndexAs:  "i",
+			Commands: []Command{REPLACE_EXPR("total", "${{total}} + ${{$price}}")},
+		},
+	)
+	assert.NoError(t, res.Error)
+
+	val, err := cache.Get(ctx, "total")
+	assert.NoError(t, err)
+	assert.Equal(t, 8.0, val)
+}
+
+func TestFOR_NotAnArray(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"scalar": 5.0})
+	assert.NoError(t, err)
+
+	res := FOR("${{scalar}}", RETURN("bad")).Do(ctx, cache)
+	assert.ErrorIs(t, res.Error, ErrForExpressionNeedsWildcard)
+}
+
+func TestFOR_WildcardBindsItemAndKey(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"users": map[string]any{
+			"alice": map[string]any{"age": 30.0},
+		},
+		"out": map[string]any{"item": nil, "key": nil},
+	})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		FOR("${{users/*/age}}",
+			REPLACE("out/item", "${{$item}}"),
+			REPLACE("out/key", "${{$key}}"),
+		),
+	)
+	assert.NoError(t, res.Error)
+
+	out, err := cache.Get(ctx, "out")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"item": 30.0, "key": "users/alice/age"}, out)
+}
+
+func TestFOR_Range(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"n": 4.0, "sum": 0.0, "down": []any{}})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		FOR_RANGE(0, "${{n}}", REPLACE_EXPR("sum", "${{sum}} + ${{$item}}")),
+		CommandFor{
+			Range:    &LoopRange{From: 10, To: 0, Step: -5},
+			Commands: []Command{RETURN("${{$index}}")},
+		},
+	)
+	assert.NoError(t, res.Error)
+
+	val, err := cache.Get(ctx, "sum")
+	assert.NoError(t, err)
+	assert.Equal(t, 6.0, val) // 0+1+2+3
+
+	results := res.Value.([]any)[1].([]CmdResult)
+	assert.Len(t, results, 2)
+	assert.Equal(t, 1.0, results[1].Value)
+}
+
+func TestFOR_RangeInvalid(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	res := CommandFor{Range: &LoopRange{From: 0, To: 5, Step: 0}, Commands: []Command{NOOP()}}.Do(ctx, cache)
+	assert.ErrorIs(t, res.Error, ErrInvalidLoopRange)
+
+	res = FOR_RANGE("abc", 5, NOOP()).Do(ctx, cache)
+	assert.ErrorIs(t, res.Error, ErrInvalidLoopRange)
+}
+
+func TestFOR_RangeIterationLimit(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	res := CommandFor{
+		Range:         &LoopRange{From: 0, To: 100},
+		MaxIterations: 10,
+		Commands:      []Command{NOOP()},
+	}.Do(ctx, cache)
+	assert.ErrorIs(t, res.Error, ErrLoopIterationLimit)
+}
+
+func TestFOR_RangeMarshaling(t *testing.T) {
+	data := `{"type":"FOR","range":{"from":1,"to":3},"as":"n","commands":[{"type":"RETURN","key":"${{$n}}"}]}`
+
+	var raw RawCommand
+	err := json.Unmarshal([]byte(data), &raw)
+	assert.NoError(t, err)
+
+	cmd, ok := raw.Command.(*CommandFor)
+	assert.True(t, ok)
+	assert.Equal(t, "n", cmd.As)
+	assert.Len(t, cmd.Commands, 1)
+
+	res := cmd.Do(context.Background(), New())
+	assert.NoError(t, res.Error)
+	results := res.Value.([]CmdResult)
+	assert.Len(t, results, 2)
+	assert.Equal(t, 2.0, results[1].Value)
+}
+
+func TestFOR_RecursivePattern(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"tree": map[string]any{
+			"count": int64(1),
+			"a":     map[string]any{"count": int64(2), "b": map[string]any{"count": int64(3)}},
+		},
+	})
+	assert.NoError(t, err)
+
+	// "**" may match no segments, so tree/count is included
+	res := FOR("${{tree/**/count}}", INC("${{$key}}", 10)).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+
+	for key, want := range map[string]int64{"tree/count": 11, "tree/a/count": 12, "tree/a/b/count": 13} {
+		val, err := cache.Get(ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, want, val, key)
+	}
+
+	// ${{1}} is the path "**" matched, usable in keys
+	res = FOR("${{tree/**/b/count}}", REPLACE("tree/${{1}}/b/count", "seen")).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	val, err := cache.Get(ctx, "tree/a/b/count")
+	assert.NoError(t, err)
+	assert.Equal(t, "seen", val)
+}
+
+func TestFOR_CapturesReadAtRunTime(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	err := cache.Create(ctx, map[string]any{
+		"jobs":   map[string]any{"a": "done", "b": "busy", "c": "done"},
+		"groups": map[string]any{"x": map[string]any{"m1": 0.0}, "y": map[string]any{"m2": 0.0}},
+		"done":   0.0,
+	})
+	assert.NoError(t, err)
+
+	res := FOR("${{jobs/*}}",
+		IF("${{jobs/${{1}}}} == 'done' && \"${{1}}\" != 'c'", INC("done", 1), NOOP()),
+		SWITCH("${{1}}", nil, CASE("${{1}}", INC("done", 10))),
+	).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+
+	done, _ := cache.Get(ctx, "done")
+	assert.Equal(t, 31.0, done)
+
+	// The condition is compiled as written, not once per captured value
+	_, ok := exprCache.get("${{jobs/a}} == 'done' && \"a\" != 'c'")
+	assert.False(t, ok)
+
+	// Inner loops see their own captures; the inner pattern sees the outer ones
+	res = FOR("${{groups/*}}",
+		FOR("${{groups/${{1}}/*}}", REPLACE("${{$key}}", "${{1}}")),
+	).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	m1, _ := cache.Get(ctx, "groups/x/m1")
+	assert.Equal(t, "m1", m1)
+}
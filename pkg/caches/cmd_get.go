@@ -2,7 +2,8 @@ package caches
 
 import (
 	"context"
-	"strings"
+
+	"github.com/goodblaster/map-cache/pkg/containers"
 )
 
 type CommandGet struct {
@@ -18,9 +19,17 @@ func GET(key string) Command {
 }
 
 func (p CommandGet) Do(ctx context.Context, cache *Cache) CmdResult {
-	key := p.Key
+	key, err := interpolateKey(ctx, p.Key)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
 
-	if !strings.Contains(key, "*") {
+	cache, key, prefix, err := resolveCache(ctx, cache, key)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+
+	if !containers.IsPattern(key) {
 		val, err := cache.Get(ctx, key)
 		if err != nil {
 			return CmdResult{Error: err}
@@ -29,7 +38,10 @@ func (p CommandGet) Do(ctx context.Context, cache *Cache) CmdResult {
 	}
 
 	// Wildcard path
-	matchingKeys := cache.cmap.WildKeys(ctx, key)
+	matchingKeys, err := cache.visitKeys(ctx, key)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
 	values := make(map[string]any, len(matchingKeys))
 
 	for _, k := range matchingKeys {
@@ -37,7 +49,7 @@ func (p CommandGet) Do(ctx context.Context, cache *Cache) CmdResult {
 		if err != nil {
 			return CmdResult{Error: err}
 		}
-		values[k] = val
+		values[prefix+k] = val
 	}
 
 	return CmdResult{Value: values}
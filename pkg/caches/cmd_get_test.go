@@ -62,3 +62,32 @@ func TestGET_MissingKey(t *testing.T) {
 	assert.Error(t, res.Error)
 	assert.Contains(t, res.Error.Error(), "not found")
 }
+
+func TestGET_GlobAndRecursive(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"sessions": map[string]any{
+			"web-1": map[string]any{"user": "alice", "meta": map[string]any{"user": "x"}},
+			"web-2": map[string]any{"user": "bob"},
+			"api-1": map[string]any{"user": "carol"},
+		},
+	})
+	assert.NoError(t, err)
+
+	res := GET("sessions/web-?/user").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, map[string]any{"sessions/web-1/user": "alice", "sessions/web-2/user": "bob"}, res.Value)
+
+	res = GET("sessions/web-1/**/user").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, map[string]any{"sessions/web-1/user": "alice", "sessions/web-1/meta/user": "x"}, res.Value)
+
+	// DELETE takes the same patterns
+	res = DELETE("sessions/web-*").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	sessions, err := cache.Get(ctx, "sessions")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"api-1": map[string]any{"user": "carol"}}, sessions)
+}
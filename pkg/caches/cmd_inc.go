@@ -2,39 +2,45 @@ package caches
 
 import (
 	"context"
+	"encoding/json"
 	"math"
 	"reflect"
 )
 
+// CommandInc adds Value to the number at Key. See AddNumbers for the type of
+// the result.
 type CommandInc struct {
-	Key   string  `json:"key,required"`
-	Value float64 `json:"value,required"`
+	Key   string `json:"key,required"`
+	Value any    `json:"value,required"`
 }
 
 func (CommandInc) Type() CommandType {
 	return CommandTypeInc
 }
 
-func INC(key string, value float64) Command {
+func INC(key string, value any) Command {
+	if n, ok := toNumber(value); ok && n.kind == numberInt {
+		value = n.i // 1 and int64(1) are the same increment
+	}
 	return CommandInc{Key: key, Value: value}
 }
 
 func (p CommandInc) Do(ctx context.Context, cache *Cache) CmdResult {
-	v, err := cache.Get(ctx, p.Key)
+	key, err := interpolateKey(ctx, p.Key)
 	if err != nil {
-		return CmdResult{Error: ErrKeyNotFound.Format(p.Key)}
+		return CmdResult{Error: err}
 	}
 
-	f64, ok := ToFloat64(v)
-	if !ok {
-		return CmdResult{Error: ErrNotANumber}
+	cache, key, _, err = resolveCache(ctx, cache, key)
+	if err != nil {
+		return CmdResult{Error: err}
 	}
 
-	f64 += p.Value
-	if err := cache.Replace(ctx, p.Key, f64); err != nil {
+	sum, err := cache.IncrementNumber(ctx, key, p.Value)
+	if err != nil {
 		return CmdResult{Error: err}
 	}
-	return CmdResult{Value: f64}
+	return CmdResult{Value: sum}
 }
 
 func ToFloat64(v any) (float64, bool) {
@@ -47,6 +53,11 @@ func ToFloat64(v any) (float64, bool) {
 		return float64(n), true
 	case float64:
 		return n, true
+	case Decimal:
+		return n.Float64(), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
 	default:
 		return 0, false
 	}
@@ -87,6 +98,10 @@ func ToInt64(v any) (int64, bool) {
 		if n == math.Trunc(n) && n >= math.MinInt64 && n <= math.MaxInt64 {
 			return int64(n), true
 		}
+	case Decimal:
+		return n.Int64()
+	case json.Number:
+		return ToInt64(parseJSONNumber(n))
 	}
 	return 0, false
 }
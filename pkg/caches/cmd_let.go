@@ -0,0 +1,97 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// CommandLet binds a value to a variable that later commands in the same
+// execution can reference as ${{$name}}. The bound value is, in order of
+// precedence: the result of Command, the result of evaluating Expr, or the
+// interpolated Value.
+type CommandLet struct {
+	Name    string  `json:"name,required"`
+	Value   any     `json:"value,omitempty"`
+	Expr    string  `json:"expr,omitempty"`
+	Command Command `json:"command,omitempty"`
+}
+
+func (CommandLet) Type() CommandType {
+	return CommandTypeLet
+}
+
+// LET binds value (interpolated if it is a string) to name.
+func LET(name string, value any) Command {
+	return CommandLet{Name: name, Value: value}
+}
+
+// LET_EXPR binds the result of evaluating expr to name.
+func LET_EXPR(name string, expr string) Command {
+	return CommandLet{Name: name, Expr: expr}
+}
+
+// LET_RESULT binds the result of cmd to name.
+func LET_RESULT(name string, cmd Command) Command {
+	return CommandLet{Name: name, Command: cmd}
+}
+
+func (p CommandLet) Do(ctx context.Context, cache *Cache) CmdResult {
+	name := strings.TrimPrefix(p.Name, VariablePrefix)
+	if name == "" || strings.Contains(name, "/") {
+		return CmdResult{Error: ErrInvalidVariableName.Format(p.Name)}
+	}
+
+	s := scopeFrom(ctx)
+	if s == nil {
+		return CmdResult{Error: ErrNoVariableScope}
+	}
+
+	var value any
+	switch {
+	case p.Command != nil:
+		res := runCommand(ctx, cache, p.Command)
+		if res.Error != nil {
+			return res
+		}
+		value = res.Value
+	case p.Expr != "":
+		val, err := evaluateExpression(ctx, cache, p.Expr)
+		if err != nil {
+			return CmdResult{Error: err}
+		}
+		value = val
+	default:
+		val, err := resolveValue(ctx, cache, p.Value)
+		if err != nil {
+			return CmdResult{Error: err}
+		}
+		value = val
+	}
+
+	s.set(name, value)
+	return CmdResult{Value: value}
+}
+
+func (c *CommandLet) UnmarshalJSON(data []byte) error {
+	type Alias CommandLet
+	aux := struct {
+		Command json.RawMessage `json:"command"`
+		*Alias
+	}{
+		Alias: (*Alias)(c),
+	}
+
+	if err := DecodeJSON(data, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.Command) > 0 && string(aux.Command) != "null" {
+		var raw RawCommand
+		if err := json.Unmarshal(aux.Command, &raw); err != nil {
+			return err
+		}
+		c.Command = raw.Command
+	}
+	return nil
+}
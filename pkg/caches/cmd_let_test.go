@@ -0,0 +1,141 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLET_BindAndReference(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"a": 2.0, "b": 3.0, "sum": 0.0, "msg": ""})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		LET_EXPR("total", "${{a}} + ${{b}}"),
+		REPLACE("sum", "${{$total}}"),
+		REPLACE("msg", "total is ${{$total}}"),
+		RETURN("${{$total}}"),
+	)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{5.0, 5.0, "total is 5", 5.0}, res.Value)
+
+	val, err := cache.Get(ctx, "sum")
+	assert.NoError(t, err)
+	assert.Equal(t, 5.0, val)
+}
+
+func TestLET_CommandResult(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"counter": 1.0, "last": 0.0})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		LET_RESULT("n", INC("counter", 1)),
+		IF("${{$n}} == 2", REPLACE("last", "${{$n}}"), NOOP()),
+	)
+	assert.NoError(t, res.Error)
+
+	val, err := cache.Get(ctx, "last")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, val)
+}
+
+func TestLET_KeyInterpolation(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"current": "u2",
+		"users":   map[string]any{"u1": map[string]any{"visits": 1.0}, "u2": map[string]any{"visits": 5.0}},
+	})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		LET("user", "${{current}}"),
+		INC("users/${{$user}}/visits", 1),
+		GET("users/${{$user}}"),
+	)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{"u2", 6.0, map[string]any{"visits": 6.0}}, res.Value)
+}
+
+func TestLET_NestedPathAndFallback(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"user": map[string]any{"name": "Alice", "roles": []any{"admin", "dev"}}})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		LET_RESULT("u", GET("user")),
+		RETURN("${{$u/name}} is ${{$u/roles/0}}"),
+		RETURN("${{$missing || none}}"),
+	)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{map[string]any{"name": "Alice", "roles": []any{"admin", "dev"}}, "Alice is admin", "none"}, res.Value)
+}
+
+func TestLET_Errors(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	res := LET("x", 1).Do(ctx, cache)
+	assert.ErrorIs(t, res.Error, ErrNoVariableScope)
+
+	res = cache.Execute(ctx, LET("$", 1))
+	assert.Error(t, res.Error)
+	assert.Contains(t, res.Error.Error(), "invalid variable name")
+
+	res = cache.Execute(ctx, RETURN("${{$nope}}"))
+	assert.Error(t, res.Error)
+	assert.Contains(t, res.Error.Error(), "variable not found: nope")
+}
+
+func TestLET_AssignsEnclosingVariable(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"items": map[string]any{"a": 1.0, "b": 2.0, "c": 3.0}})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		LET("total", 0),
+		FOR("${{items/*}}", LET_EXPR("total", "${{$total}} + ${{items/${{1}}}}")),
+		RETURN("${{$total}}"),
+	)
+	assert.NoError(t, res.Error)
+	values := res.Value.([]any)
+	assert.Equal(t, 6.0, values[2])
+}
+
+func TestLET_JSON(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"counter": 1.0}))
+
+	data := `{"commands": [
+		{"type": "LET", "name": "n", "command": {"type": "INC", "key": "counter", "value": 2}},
+		{"type": "RETURN", "key": "${{$n}}"}
+	]}`
+	var env CommandEnvelope
+	assert.NoError(t, json.Unmarshal([]byte(data), &env))
+
+	var cmds []Command
+	for _, raw := range env.Commands {
+		cmds = append(cmds, raw.Command)
+	}
+	res := cache.Execute(ctx, cmds...)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{3.0, 3.0}, res.Value)
+
+	out, err := json.Marshal(RawCommand{Command: cmds[0]})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "LET", "name": "n", "command": {"type": "INC", "key": "counter", "value": 2}}`, string(out))
+}
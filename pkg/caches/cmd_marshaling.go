@@ -14,160 +14,43 @@ type RawCommand struct {
 	Command Command
 }
 
+// MarshalJSON encodes the command with MarshalCommand, unless the command
+// implements json.Marshaler itself.
 func (r RawCommand) MarshalJSON() ([]byte, error) {
 	if r.Command == nil {
 		return json.Marshal(nil)
 	}
-	return json.Marshal(r.Command)
+	if m, ok := r.Command.(json.Marshaler); ok {
+		return m.MarshalJSON()
+	}
+	return MarshalCommand(r.Command)
 }
 
 func (r *RawCommand) UnmarshalJSON(data []byte) error {
 	var base struct {
-		Type CommandType `json:"type"`
+		Type         CommandType `json:"type"`
+		IgnoreErrors bool        `json:"ignore_errors"`
 	}
 	if err := json.Unmarshal(data, &base); err != nil {
 		return err
 	}
 
-	var cmd Command
-	switch base.Type {
-	case CommandTypeIf:
-		cmd = &CommandIf{}
-	case CommandTypeFor:
-		cmd = &CommandFor{}
-	case CommandTypeReplace:
-		cmd = &CommandReplace{}
-	case CommandTypeReturn:
-		cmd = &CommandReturn{}
-	case CommandTypePrint:
-		cmd = &CommandPrint{}
-	case CommandTypeGet:
-		cmd = &CommandGet{}
-	case CommandTypeInc:
-		cmd = &CommandInc{}
-	case CommandTypeNoop:
-		cmd = &CommandNoop{}
-	case CommandTypeGroup:
-		cmd = &CommandGroup{}
-	case CommandTypeDelete:
-		cmd = &CommandDelete{}
-	default:
-		return ErrUnknownCommandType.Format(base.Type)
+	cmd, err := newCommand(base.Type)
+	if err != nil {
+		return err
 	}
 
-	if err := json.Unmarshal(data, cmd); err != nil {
+	if err := DecodeJSON(data, cmd); err != nil {
 		return err
 	}
 
 	r.Command = cmd
+	if base.IgnoreErrors {
+		r.Command = CommandIgnoreErrors{Command: cmd}
+	}
 	return nil
 }
 
-// TODO: This is all very tedious. Find a better way.
-//
-///
-////
-/////
-
-func (c CommandIf) MarshalJSON() ([]byte, error) {
-	type Alias CommandIf
-	return json.Marshal(struct {
-		Type CommandType `json:"type"`
-		*Alias
-	}{
-		Type:  c.Type(),
-		Alias: (*Alias)(&c),
-	})
-}
-
-func (c CommandFor) MarshalJSON() ([]byte, error) {
-	type Alias CommandFor
-	return json.Marshal(struct {
-		Type CommandType `json:"type"`
-		*Alias
-	}{
-		Type:  c.Type(),
-		Alias: (*Alias)(&c),
-	})
-}
-
-func (c CommandReplace) MarshalJSON() ([]byte, error) {
-	type Alias CommandReplace
-	return json.Marshal(struct {
-		Type CommandType `json:"type"`
-		*Alias
-	}{
-		Type:  c.Type(),
-		Alias: (*Alias)(&c),
-	})
-}
-
-func (c CommandReturn) MarshalJSON() ([]byte, error) {
-	type Alias CommandReturn
-	return json.Marshal(struct {
-		Type CommandType `json:"type"`
-		*Alias
-	}{
-		Type:  c.Type(),
-		Alias: (*Alias)(&c),
-	})
-}
-
-func (c CommandPrint) MarshalJSON() ([]byte, error) {
-	type Alias CommandPrint
-	return json.Marshal(struct {
-		Type CommandType `json:"type"`
-		*Alias
-	}{
-		Type:  c.Type(),
-		Alias: (*Alias)(&c),
-	})
-}
-
-func (c CommandGet) MarshalJSON() ([]byte, error) {
-	type Alias CommandGet
-	return json.Marshal(struct {
-		Type CommandType `json:"type"`
-		*Alias
-	}{
-		Type:  c.Type(),
-		Alias: (*Alias)(&c),
-	})
-}
-
-func (c CommandInc) MarshalJSON() ([]byte, error) {
-	type Alias CommandInc
-	return json.Marshal(struct {
-		Type CommandType `json:"type"`
-		*Alias
-	}{
-		Type:  c.Type(),
-		Alias: (*Alias)(&c),
-	})
-}
-
-func (c CommandNoop) MarshalJSON() ([]byte, error) {
-	type Alias CommandNoop
-	return json.Marshal(struct {
-		Type CommandType `json:"type"`
-		*Alias
-	}{
-		Type:  c.Type(),
-		Alias: (*Alias)(&c),
-	})
-}
-
-func (c CommandGroup) MarshalJSON() ([]byte, error) {
-	type Alias CommandGroup
-	return json.Marshal(struct {
-		Type CommandType `json:"type"`
-		*Alias
-	}{
-		Type:  c.Type(),
-		Alias: (*Alias)(&c),
-	})
-}
-
 func (c *CommandGroup) UnmarshalJSON(data []byte) error {
 	var aux struct {
 		Commands []json.RawMessage `json:"commands"`
@@ -187,13 +70,11 @@ func (c *CommandGroup) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func (c CommandDelete) MarshalJSON() ([]byte, error) {
-	type Alias CommandDelete
-	return json.Marshal(struct {
-		Type CommandType `json:"type"`
-		*Alias
-	}{
-		Type:  c.Type(),
-		Alias: (*Alias)(&c),
-	})
+// rawCommands wraps commands, so that each is encoded with its type.
+func rawCommands(commands []Command) []RawCommand {
+	raw := make([]RawCommand, len(commands))
+	for i, cmd := range commands {
+		raw[i] = RawCommand{Command: cmd}
+	}
+	return raw
 }
@@ -39,7 +39,7 @@ func (p CommandPrint) Do(ctx context.Context, cache *Cache) CmdResult {
 		var params []any
 
 		for _, key := range keys {
-			v, err := cache.Get(ctx, key)
+			v, err := lookupRef(ctx, cache, key)
 			if err != nil {
 				return CmdResult{Error: ErrKeyNotFound.Format(key)}
 			}
@@ -0,0 +1,28 @@
+package caches
+
+import (
+	"context"
+)
+
+// CommandQuery runs a Query and returns its result as
+// {"total": n, "items": [{"key": ..., "value": ...}, ...]}, or with "groups"
+// in place of "items" when the query groups.
+type CommandQuery struct {
+	Query
+}
+
+func (CommandQuery) Type() CommandType {
+	return CommandTypeQuery
+}
+
+func QUERY(q Query) Command {
+	return CommandQuery{Query: q}
+}
+
+func (p CommandQuery) Do(ctx context.Context, cache *Cache) CmdResult {
+	result, err := cache.Query(ctx, p.Query)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+	return CmdResult{Value: result.Map()}
+}
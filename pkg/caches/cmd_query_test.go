@@ -0,0 +1,171 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newQueryCache(t *testing.T) *Cache {
+	cache := New()
+	m := map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"sessions": {
+			"s1": {"user": "alice", "status": "active", "last_seen": 30, "region": "eu"},
+			"s2": {"user": "bob", "status": "idle", "last_seen": 10, "region": "us"},
+			"s3": {"user": "carol", "status": "active", "last_seen": 20, "region": "us"},
+			"s4": {"user": "dave", "status": "active", "last_seen": 40, "region": "eu"}
+		}
+	}`), &m))
+	require.NoError(t, cache.Create(context.Background(), m))
+	return cache
+}
+
+func TestQuery_WhereSortLimit(t *testing.T) {
+	cache := newQueryCache(t)
+
+	result, err := cache.Query(context.Background(), Query{
+		Source:  "sessions/*",
+		Where:   `${{$item/status}} == "active"`,
+		Select:  []string{"user", "$key"},
+		OrderBy: []QueryOrder{{Field: "last_seen", Desc: true}},
+		Offset:  1,
+		Limit:   2,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, result.Total)
+	require.Len(t, result.Items, 2)
+	assert.Equal(t, "sessions/s1", result.Items[0].Key)
+	assert.Equal(t, map[string]any{"user": "alice", "$key": "sessions/s1"}, result.Items[0].Value)
+	assert.Equal(t, "sessions/s3", result.Items[1].Key)
+}
+
+func TestQuery_DefaultsToKeyOrder(t *testing.T) {
+	cache := newQueryCache(t)
+
+	result, err := cache.Query(context.Background(), Query{Source: "sessions/*"})
+	require.NoError(t, err)
+
+	var keys []string
+	for _, item := range result.Items {
+		keys = append(keys, item.Key)
+	}
+	assert.Equal(t, []string{"sessions/s1", "sessions/s2", "sessions/s3", "sessions/s4"}, keys)
+	assert.Equal(t, "alice", result.Items[0].Value.(map[string]any)["user"])
+}
+
+func TestQuery_NamedCaptures(t *testing.T) {
+	cache := newQueryCache(t)
+
+	result, err := cache.Query(context.Background(), Query{
+		Source: "sessions/{id}",
+		Where:  `${{$id}} != "s1" && ${{$item/region}} == "eu"`,
+		Select: []string{"$id"},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, map[string]any{"$id": "s4"}, result.Items[0].Value)
+}
+
+func TestQuery_GroupAndAggregates(t *testing.T) {
+	cache := newQueryCache(t)
+
+	result, err := cache.Query(context.Background(), Query{
+		Source:  "sessions/*",
+		Where:   `${{$item/status}} == "active"`,
+		GroupBy: "region",
+		Aggregates: map[string]QueryAggregate{
+			"latest": {Func: "max", Field: "last_seen"},
+			"users":  {Func: "distinct", Field: "user"},
+		},
+		OrderBy: []QueryOrder{{Field: "count", Desc: true}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, result.Total)
+	require.Len(t, result.Groups, 2)
+	assert.Equal(t, "eu", result.Groups[0].Group)
+	assert.Equal(t, 2, result.Groups[0].Count)
+	assert.EqualValues(t, 40, result.Groups[0].Aggregates["latest"])
+	assert.ElementsMatch(t, []any{"alice", "dave"}, result.Groups[0].Aggregates["users"])
+	assert.Equal(t, "us", result.Groups[1].Group)
+	assert.Equal(t, 1, result.Groups[1].Count)
+}
+
+func TestQuery_AggregatesWithoutGroup(t *testing.T) {
+	cache := newQueryCache(t)
+
+	result, err := cache.Query(context.Background(), Query{
+		Source:     "sessions/*",
+		Aggregates: map[string]QueryAggregate{"avg": {Func: "avg", Field: "last_seen"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Groups, 1)
+	assert.Nil(t, result.Groups[0].Group)
+	assert.Equal(t, 4, result.Groups[0].Count)
+	assert.EqualValues(t, 25, result.Groups[0].Aggregates["avg"])
+}
+
+func TestQuery_SortsMissingFieldsLast(t *testing.T) {
+	cache := newQueryCache(t)
+	require.NoError(t, cache.Create(context.Background(), map[string]any{"sessions/s5": map[string]any{"user": "erin"}}))
+
+	for _, desc := range []bool{false, true} {
+		result, err := cache.Query(context.Background(), Query{
+			Source:  "sessions/*",
+			OrderBy: []QueryOrder{{Field: "last_seen", Desc: desc}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "sessions/s5", result.Items[len(result.Items)-1].Key)
+	}
+}
+
+func TestQuery_Errors(t *testing.T) {
+	cache := newQueryCache(t)
+	ctx := context.Background()
+
+	for _, q := range []Query{
+		{},
+		{Source: "sessions/s1"},
+		{Source: "sessions/*", Limit: -1},
+		{Source: "sessions/*", Select: []string{"user"}, GroupBy: "region"},
+		{Source: "sessions/*", Aggregates: map[string]QueryAggregate{"x": {Func: "median"}}},
+	} {
+		_, err := cache.Query(ctx, q)
+		assert.ErrorIs(t, err, ErrInvalidQuery, "%+v", q)
+	}
+
+	_, err := cache.Query(ctx, Query{Source: "sessions/*", Where: `${{$item/user}}`})
+	assert.ErrorIs(t, err, ErrExpressionNotBoolean)
+}
+
+func TestQUERY_Command(t *testing.T) {
+	cache := newQueryCache(t)
+
+	var raw RawCommand
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "QUERY",
+		"source": "sessions/*",
+		"where": "${{$item/last_seen}} > 15",
+		"select": ["user"],
+		"order_by": [{"field": "user"}],
+		"limit": 1
+	}`), &raw))
+
+	res := raw.Command.Do(context.Background(), cache)
+	require.NoError(t, res.Error)
+	assert.Equal(t, map[string]any{
+		"total": 3,
+		"items": []any{map[string]any{"key": "sessions/s1", "value": map[string]any{"user": "alice"}}},
+	}, res.Value)
+
+	// Round trip
+	data, err := json.Marshal(raw)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"type":"QUERY"`)
+	assert.Contains(t, string(data), `"source":"sessions/*"`)
+}
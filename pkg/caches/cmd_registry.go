@@ -0,0 +1,258 @@
+package caches
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// CommandConstructor returns an empty command for JSON to be decoded into.
+type CommandConstructor func() Command
+
+var (
+	commandTypesMu sync.RWMutex
+	commandTypes   = map[CommandType]CommandConstructor{
+		CommandTypeIf:      func() Command { return &CommandIf{} },
+		CommandTypeFor:     func() Command { return &CommandFor{} },
+		CommandTypeReplace: func() Command { return &CommandReplace{} },
+		CommandTypeReturn:  func() Command { return &CommandReturn{} },
+		CommandTypePrint:   func() Command { return &CommandPrint{} },
+		CommandTypeGet:     func() Command { return &CommandGet{} },
+		CommandTypeInc:     func() Command { return &CommandInc{} },
+		CommandTypeNoop:    func() Command { return &CommandNoop{} },
+		CommandTypeGroup:   func() Command { return &CommandGroup{} },
+		CommandTypeDelete:  func() Command { return &CommandDelete{} },
+		CommandTypeLet:     func() Command { return &CommandLet{} },
+		CommandTypeSwitch:  func() Command { return &CommandSwitch{} },
+		CommandTypeTry:     func() Command { return &CommandTry{} },
+		CommandTypeWhile:   func() Command { return &CommandWhile{} },
+		CommandTypeCall:    func() Command { return &CommandCall{} },
+		CommandTypeQuery:   func() Command { return &CommandQuery{} },
+	}
+
+	// plainTypes caches the method-free struct types used by MarshalCommand.
+	plainTypes sync.Map // map[reflect.Type]reflect.Type
+
+	commandInterface   = reflect.TypeFor[Command]()
+	rawCommandPointer  = reflect.TypeFor[*RawCommand]()
+	marshalerInterface = reflect.TypeFor[json.Marshaler]()
+)
+
+// RegisterCommand adds a command type, so that commands of that type can be
+// decoded from JSON wherever commands are accepted: the HTTP API, triggers,
+// procedures, backups and "json" script statements.
+//
+// A custom command is a struct implementing Command; it is encoded with
+// MarshalCommand, so it needs no MarshalJSON. Fields holding nested commands
+// should be RawCommand, or the command must decode them itself. Built-in
+// types cannot be replaced.
+func RegisterCommand(cmdType CommandType, constructor CommandConstructor) error {
+	if cmdType == "" || constructor == nil {
+		return ErrInvalidCommandType.Format(cmdType)
+	}
+
+	commandTypesMu.Lock()
+	defer commandTypesMu.Unlock()
+	if _, ok := commandTypes[cmdType]; ok {
+		return ErrCommandTypeExists.Format(cmdType)
+	}
+	commandTypes[cmdType] = constructor
+	return nil
+}
+
+// newCommand returns an empty command of the given type, as a pointer.
+func newCommand(cmdType CommandType) (Command, error) {
+	commandTypesMu.RLock()
+	constructor, ok := commandTypes[cmdType]
+	commandTypesMu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownCommandType.Format(cmdType)
+	}
+
+	cmd := constructor()
+	if cmd == nil {
+		return nil, ErrInvalidCommandType.Format(cmdType)
+	}
+
+	// json.Unmarshal needs a pointer to decode into
+	if val := reflect.ValueOf(cmd); val.Kind() != reflect.Ptr {
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		cmd = ptr.Interface().(Command)
+	}
+	return cmd, nil
+}
+
+// MarshalCommand encodes a command as a JSON object of its fields, using their
+// json tags, with its type added as "type". Nested commands, in fields of type
+// Command or []Command, are encoded the same way. RawCommand uses it for
+// every command that does not implement json.Marshaler.
+func MarshalCommand(cmd Command) ([]byte, error) {
+	typ, err := json.Marshal(cmd.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	val := reflect.Indirect(reflect.ValueOf(cmd))
+	if val.Kind() != reflect.Struct {
+		return nil, ErrInvalidCommandType.Format(cmd.Type())
+	}
+
+	// Encode a copy without cmd's methods, or json would call MarshalJSON again
+	fields, err := json.Marshal(plainCopy(val).Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"type":`)
+	buf.Write(typ)
+	if len(fields) > 2 {
+		buf.WriteByte(',')
+		buf.Write(fields[1:])
+	} else {
+		buf.WriteByte('}')
+	}
+	return buf.Bytes(), nil
+}
+
+// plainCopy copies the exported fields of a struct into a struct type that
+// has the same fields and tags, but no methods.
+func plainCopy(val reflect.Value) reflect.Value {
+	t := val.Type()
+	cached, ok := plainTypes.Load(t)
+	if !ok {
+		plain, _ := plainStruct(t, map[reflect.Type]bool{t: true})
+		cached, _ = plainTypes.LoadOrStore(t, plain)
+	}
+	return plainValue(val, cached.(reflect.Type))
+}
+
+// plainStruct returns a struct type with the exported fields of t, without
+// methods, and whether any field type was changed by plainType.
+func plainStruct(t reflect.Type, seen map[reflect.Type]bool) (reflect.Type, bool) {
+	var fields []reflect.StructField
+	changed := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		ft := plainType(f.Type, seen)
+		changed = changed || ft != f.Type
+		fields = append(fields, reflect.StructField{
+			Name:      f.Name,
+			Type:      ft,
+			Tag:       f.Tag,
+			Anonymous: f.Anonymous && ft == f.Type && ft.NumMethod() == 0,
+		})
+	}
+	return reflect.StructOf(fields), changed
+}
+
+// plainType returns t with every Command replaced by *RawCommand, so that
+// nested commands are encoded with their type. Types holding no commands, and
+// structs that encode themselves, are returned unchanged.
+func plainType(t reflect.Type, seen map[reflect.Type]bool) reflect.Type {
+	switch t.Kind() {
+	case reflect.Interface:
+		if t == commandInterface {
+			return rawCommandPointer
+		}
+	case reflect.Slice:
+		if elem := plainType(t.Elem(), seen); elem != t.Elem() {
+			return reflect.SliceOf(elem)
+		}
+	case reflect.Array:
+		if elem := plainType(t.Elem(), seen); elem != t.Elem() {
+			return reflect.ArrayOf(t.Len(), elem)
+		}
+	case reflect.Pointer:
+		if elem := plainType(t.Elem(), seen); elem != t.Elem() {
+			return reflect.PointerTo(elem)
+		}
+	case reflect.Map:
+		if elem := plainType(t.Elem(), seen); elem != t.Elem() {
+			return reflect.MapOf(t.Key(), elem)
+		}
+	case reflect.Struct:
+		if seen[t] || t.Implements(marshalerInterface) || reflect.PointerTo(t).Implements(marshalerInterface) {
+			return t
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		if plain, changed := plainStruct(t, seen); changed {
+			return plain
+		}
+	}
+	return t
+}
+
+// plainValue converts val to t, a type returned by plainType for val's type.
+func plainValue(val reflect.Value, t reflect.Type) reflect.Value {
+	if val.Type() == t {
+		return val
+	}
+
+	out := reflect.New(t).Elem()
+	switch val.Kind() {
+	case reflect.Interface:
+		if !val.IsNil() {
+			out.Set(reflect.ValueOf(&RawCommand{Command: val.Interface().(Command)}))
+		}
+	case reflect.Slice:
+		if !val.IsNil() {
+			out.Set(reflect.MakeSlice(t, val.Len(), val.Len()))
+			for i := 0; i < val.Len(); i++ {
+				out.Index(i).Set(plainValue(val.Index(i), t.Elem()))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			out.Index(i).Set(plainValue(val.Index(i), t.Elem()))
+		}
+	case reflect.Pointer:
+		if !val.IsNil() {
+			ptr := reflect.New(t.Elem())
+			ptr.Elem().Set(plainValue(val.Elem(), t.Elem()))
+			out.Set(ptr)
+		}
+	case reflect.Map:
+		if !val.IsNil() {
+			out.Set(reflect.MakeMapWithSize(t, val.Len()))
+			for iter := val.MapRange(); iter.Next(); {
+				out.SetMapIndex(iter.Key(), plainValue(iter.Value(), t.Elem()))
+			}
+		}
+	case reflect.Struct:
+		j := 0
+		for i := 0; i < val.NumField(); i++ {
+			if val.Type().Field(i).IsExported() {
+				out.Field(j).Set(plainValue(val.Field(i), t.Field(j).Type))
+				j++
+			}
+		}
+	}
+	return out
+}
+
+// ResolveValue resolves a value for a custom command the way built-in
+// commands do: strings may reference keys, variables and captures, e.g.
+// "${{users/${{$id}}/name}}"; other values are returned unchanged.
+func ResolveValue(ctx context.Context, cache *Cache, value any) (any, error) {
+	return resolveValue(ctx, cache, value)
+}
+
+// ResolveKey resolves variable and capture references in a key for a custom
+// command, e.g. "users/${{$id}}/name".
+func ResolveKey(ctx context.Context, key string) (string, error) {
+	return interpolateKey(ctx, key)
+}
+
+// EvaluateExpression evaluates an expression for a custom command, the same
+// way IF conditions are evaluated.
+func EvaluateExpression(ctx context.Context, cache *Cache, expr string) (any, error) {
+	return evaluateExpression(ctx, cache, expr)
+}
@@ -0,0 +1,160 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/goodblaster/errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const commandTypeTestDouble CommandType = "TEST_DOUBLE"
+
+// commandTestDouble doubles the number at Key.
+type commandTestDouble struct {
+	Key  string `json:"key"`
+	Note string `json:"note,omitempty"`
+}
+
+func (commandTestDouble) Type() CommandType {
+	return commandTypeTestDouble
+}
+
+func (c commandTestDouble) Do(ctx context.Context, cache *Cache) CmdResult {
+	key, err := ResolveKey(ctx, c.Key)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+	val, err := ResolveValue(ctx, cache, "${{"+key+"}}")
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+	n, ok := ToFloat64(val)
+	if !ok {
+		return CmdResult{Error: errors.New("not a number")}
+	}
+	if err := cache.Replace(ctx, key, n*2); err != nil {
+		return CmdResult{Error: err}
+	}
+	return CmdResult{Value: n * 2}
+}
+
+func init() {
+	if err := RegisterCommand(commandTypeTestDouble, func() Command { return commandTestDouble{} }); err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisterCommand_Errors(t *testing.T) {
+	err := RegisterCommand(CommandTypeIf, func() Command { return &CommandNoop{} })
+	assert.True(t, errors.Is(err, ErrCommandTypeExists))
+
+	err = RegisterCommand(commandTypeTestDouble, func() Command { return commandTestDouble{} })
+	assert.True(t, errors.Is(err, ErrCommandTypeExists))
+
+	err = RegisterCommand("", func() Command { return commandTestDouble{} })
+	assert.True(t, errors.Is(err, ErrInvalidCommandType))
+
+	err = RegisterCommand("TEST_NIL", nil)
+	assert.True(t, errors.Is(err, ErrInvalidCommandType))
+
+	var raw RawCommand
+	err = json.Unmarshal([]byte(`{"type": "TEST_UNREGISTERED"}`), &raw)
+	assert.True(t, errors.Is(err, ErrUnknownCommandType))
+}
+
+func TestRegisterCommand_JSON(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{"n": 3.0}))
+
+	var envelope CommandEnvelope
+	require.NoError(t, json.Unmarshal([]byte(`{"commands": [
+		{"type": "TEST_DOUBLE", "key": "n"},
+		{"type": "IF", "condition": "${{n}} == 6",
+			"if_true": {"type": "TEST_DOUBLE", "key": "n", "ignore_errors": true},
+			"if_false": {"type": "NOOP"}}
+	]}`), &envelope))
+
+	var cmds []Command
+	for _, raw := range envelope.Commands {
+		cmds = append(cmds, raw.Command)
+	}
+	res := cache.Execute(ctx, cmds...)
+	require.NoError(t, res.Error)
+
+	n, err := cache.Get(ctx, "n")
+	require.NoError(t, err)
+	assert.Equal(t, 12.0, n)
+
+	data, err := json.Marshal(RawCommand{Command: commandTestDouble{Key: "n", Note: "x"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "TEST_DOUBLE", "key": "n", "note": "x"}`, string(data))
+}
+
+func TestMarshalCommand_NestedCommands(t *testing.T) {
+	cmd := IF("true", COMMANDS(commandTestDouble{Key: "n"}, IGNORE_ERRORS(DELETE("x"))), NOOP())
+	data, err := json.Marshal(RawCommand{Command: cmd})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "IF",
+		"condition": "true",
+		"if_true": {"type": "COMMANDS", "commands": [
+			{"type": "TEST_DOUBLE", "key": "n"},
+			{"type": "DELETE", "key": "x", "ignore_errors": true}
+		]},
+		"if_false": {"type": "NOOP"}
+	}`, string(data))
+
+	var raw RawCommand
+	require.NoError(t, json.Unmarshal(data, &raw))
+	again, err := json.Marshal(raw)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again))
+}
+
+func TestRegisterCommand_ForCaptures(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"scores": map[string]any{"a": 1.0, "b": 2.0},
+	}))
+
+	res := FOR("${{scores/*}}", commandTestDouble{Key: "scores/${{1}}"}).Do(ctx, cache)
+	require.NoError(t, res.Error)
+
+	a, _ := cache.Get(ctx, "scores/a")
+	b, _ := cache.Get(ctx, "scores/b")
+	assert.Equal(t, 2.0, a)
+	assert.Equal(t, 4.0, b)
+}
+
+func TestRegisterCommand_TriggerBackup(t *testing.T) {
+	ctx := context.Background()
+	cacheName := uuid.NewString()
+	require.NoError(t, AddCache(cacheName))
+
+	cache, err := FetchCache(cacheName)
+	require.NoError(t, err)
+	require.NoError(t, cache.Create(ctx, map[string]any{"in": 0.0, "out": 1.0}))
+
+	_, err = cache.CreateTrigger(ctx, "in", commandTestDouble{Key: "out"})
+	require.NoError(t, err)
+
+	require.NoError(t, Backup(ctx, cacheName, cacheName))
+	defer os.Remove(cacheName)
+	require.NoError(t, DeleteCache(cacheName))
+	require.NoError(t, Restore(ctx, cacheName, cacheName))
+
+	restored, err := FetchCache(cacheName)
+	require.NoError(t, err)
+	require.NoError(t, restored.Replace(ctx, "in", 1.0))
+
+	out, err := restored.Get(ctx, "out")
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, out)
+}
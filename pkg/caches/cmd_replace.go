@@ -2,13 +2,16 @@ package caches
 
 import (
 	"context"
-	"fmt"
-	"strings"
 )
 
+// CommandReplace overwrites an existing key. A string Value is interpolated,
+// so "${{other}}" copies another key's value with its type preserved. When
+// Expr is set it is evaluated like an IF condition and its result is stored
+// instead of Value, e.g. "${{a}} + ${{b}}".
 type CommandReplace struct {
 	Key   string `json:"key,required"`
-	Value any    `json:"value,required"`
+	Value any    `json:"value,omitempty"`
+	Expr  string `json:"expr,omitempty"`
 }
 
 func (CommandReplace) Type() CommandType {
@@ -19,20 +22,34 @@ func REPLACE(key string, value any) Command {
 	return CommandReplace{Key: key, Value: value}
 }
 
+// REPLACE_EXPR stores the result of evaluating expr at key.
+func REPLACE_EXPR(key string, expr string) Command {
+	return CommandReplace{Key: key, Expr: expr}
+}
+
 func (p CommandReplace) Do(ctx context.Context, cache *Cache) CmdResult {
-	// Interpolate wildcard variables in key (e.g., ${{1}} → actual wildcard match)
-	key := p.Key
-	if val := ctx.Value(triggerVarsContextKey); val != nil {
-		if vars, ok := val.([]string); ok {
-			for i, v := range vars {
-				placeholder := fmt.Sprintf("${{%d}}", i+1)
-				key = strings.ReplaceAll(key, placeholder, v)
-			}
-		}
+	// Interpolate wildcard captures and variables in key (e.g., ${{1}} → actual wildcard match)
+	key, err := interpolateKey(ctx, p.Key)
+	if err != nil {
+		return CmdResult{Error: err}
 	}
 
-	if err := cache.Replace(ctx, key, p.Value); err != nil {
+	var value any
+	if p.Expr != "" {
+		value, err = evaluateExpression(ctx, cache, p.Expr)
+	} else {
+		value, err = resolveValue(ctx, cache, p.Value)
+	}
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+
+	target, key, _, err := resolveCache(ctx, cache, key)
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+	if err := target.Replace(ctx, key, value); err != nil {
 		return CmdResult{Error: err}
 	}
-	return CmdResult{Value: p.Value}
+	return CmdResult{Value: value}
 }
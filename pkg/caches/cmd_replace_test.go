@@ -67,3 +67,78 @@ func TestREPLACE_NestedPath(t *testing.T) {
 
 	assert.EqualValues(t, "Robert", obj["name"])
 }
+
+func TestREPLACE_CopiesReferencedValue(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"source": map[string]any{"a": 1.0, "b": "two"},
+		"target": nil,
+	})
+	assert.NoError(t, err)
+
+	res := REPLACE("target", "${{source}}").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, map[string]any{"a": 1.0, "b": "two"}, res.Value)
+
+	val, err := cache.Get(ctx, "target")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": 1.0, "b": "two"}, val)
+}
+
+func TestREPLACE_TemplateValue(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"name": "Alice", "greeting": ""})
+	assert.NoError(t, err)
+
+	res := REPLACE("greeting", "Hello, ${{name}}!").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, "Hello, Alice!", res.Value)
+}
+
+func TestREPLACE_Expression(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"a": 2.0, "b": 3.0, "count": 0.0, "big": false})
+	assert.NoError(t, err)
+
+	res := REPLACE_EXPR("count", "${{a}} + ${{b}}").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, 5.0, res.Value)
+
+	res = REPLACE_EXPR("big", "${{count}} > 4").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, true, res.Value)
+
+	res = REPLACE_EXPR("count", "${{a}} +").Do(ctx, cache)
+	assert.Error(t, res.Error)
+	assert.Contains(t, res.Error.Error(), "invalid expression")
+
+	val, err := cache.Get(ctx, "count")
+	assert.NoError(t, err)
+	assert.Equal(t, 5.0, val)
+}
+
+func TestREPLACE_ExpressionJSON(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"x": 4.0, "y": 0.0})
+	assert.NoError(t, err)
+
+	var raw RawCommand
+	err = json.Unmarshal([]byte(`{"type": "REPLACE", "key": "y", "expr": "${{x}} * 2"}`), &raw)
+	assert.NoError(t, err)
+
+	res := raw.Command.Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, 8.0, res.Value)
+
+	data, err := json.Marshal(raw)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "REPLACE", "key": "y", "expr": "${{x}} * 2"}`, string(data))
+}
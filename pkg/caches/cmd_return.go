@@ -5,10 +5,16 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/goodblaster/map-cache/pkg/containers"
 )
 
+// CommandReturn returns Key, interpolating it when it is a string. When Expr
+// is set, the result of evaluating it is returned instead, which allows
+// aggregates such as "sum(${{workers/*/bytes}})".
 type CommandReturn struct {
-	Key any `json:"key,required"`
+	Key  any    `json:"key,omitempty"`
+	Expr string `json:"expr,omitempty"`
 }
 
 func (CommandReturn) Type() CommandType {
@@ -19,10 +25,23 @@ func RETURN(key any) Command {
 	return CommandReturn{Key: key}
 }
 
+// RETURN_EXPR returns the result of evaluating expr.
+func RETURN_EXPR(expr string) Command {
+	return CommandReturn{Expr: expr}
+}
+
 func (p CommandReturn) Do(ctx context.Context, cache *Cache) CmdResult {
+	if p.Expr != "" {
+		val, err := evaluateExpression(ctx, cache, p.Expr)
+		if err != nil {
+			return CmdResult{Error: err}
+		}
+		return CmdResult{Value: val}
+	}
+
 	switch str := p.Key.(type) {
 	case string:
-		resolvedVal, err := evaluateInterpolations(ctx, cache, str)
+		resolvedVal, err := resolveValue(ctx, cache, str)
 		if err != nil {
 			return CmdResult{Error: err}
 		}
@@ -74,13 +93,20 @@ func evaluateInterpolations(ctx context.Context, cache *Cache, s string) (any, e
 
 		if hasWildcard {
 			// Wildcard expression
-			keys := cache.cmap.WildKeys(ctx, keyExpr)
+			target, pattern, _, err := resolveCache(ctx, cache, keyExpr)
+			if err != nil {
+				return nil, err
+			}
+			keys, err := target.visitKeys(ctx, pattern)
+			if err != nil {
+				return nil, err
+			}
 			if len(keys) == 0 {
 				return []any{}, nil
 			}
 			results := make([]any, 0, len(keys))
 			for _, k := range keys {
-				val, err := cache.Get(ctx, k)
+				val, err := target.Get(ctx, k)
 				if err != nil {
 					return nil, ErrWildcardInterpolation.Format(k, err)
 				}
@@ -90,7 +116,7 @@ func evaluateInterpolations(ctx context.Context, cache *Cache, s string) (any, e
 		}
 
 		// Non-wildcard direct fetch
-		val, err := cache.Get(ctx, keyExpr)
+		val, err := lookupRef(ctx, cache, keyExpr)
 		if err != nil {
 			return nil, ErrInterpolation.Format(keyExpr, err)
 		}
@@ -138,7 +164,7 @@ func evaluateInterpolations(ctx context.Context, cache *Cache, s string) (any, e
 		if hasFallback {
 			val, err = evaluateWithFallback(ctx, cache, keyExpr)
 		} else {
-			val, err = cache.Get(ctx, keyExpr)
+			val, err = lookupRef(ctx, cache, keyExpr)
 		}
 
 		if err != nil {
@@ -175,12 +201,12 @@ func evaluateWithFallback(ctx context.Context, cache *Cache, expr string) (any,
 	defaultPart := strings.TrimSpace(parts[1])
 
 	// Disallow wildcards with fallback
-	if strings.Contains(keyPart, "*") {
+	if containers.IsPattern(keyPart) {
 		return nil, ErrWildcardWithFallback.Format(keyPart)
 	}
 
 	// Try to get the key from cache
-	val, err := cache.Get(ctx, keyPart)
+	val, err := lookupRef(ctx, cache, keyPart)
 	if err == nil {
 		return val, nil
 	}
@@ -0,0 +1,143 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// SwitchValueVariable is the variable holding the switched value while case
+// conditions and case commands run, referenced as ${{$value}}.
+const SwitchValueVariable = "value"
+
+// CommandSwitch evaluates Value (or Expr) once and runs the command of the
+// first matching case, or Default when no case matches. A case matches when
+// its When condition is true or, for cases without a condition, when Match
+// equals the switched value.
+type CommandSwitch struct {
+	Value   any          `json:"value,omitempty"`
+	Expr    string       `json:"expr,omitempty"`
+	Cases   []SwitchCase `json:"cases,required"`
+	Default Command      `json:"default,omitempty"`
+}
+
+// SwitchCase is a single SWITCH branch.
+type SwitchCase struct {
+	Match   any     `json:"match,omitempty"`
+	When    string  `json:"when,omitempty"`
+	Command Command `json:"command,required"`
+}
+
+func (CommandSwitch) Type() CommandType {
+	return CommandTypeSwitch
+}
+
+// SWITCH dispatches on value, which is interpolated if it is a string.
+func SWITCH(value any, defaultCmd Command, cases ...SwitchCase) Command {
+	return CommandSwitch{Value: value, Cases: cases, Default: defaultCmd}
+}
+
+// CASE matches a literal value.
+func CASE(match any, cmd Command) SwitchCase {
+	return SwitchCase{Match: match, Command: cmd}
+}
+
+// WHEN matches when condition evaluates to true.
+func WHEN(condition string, cmd Command) SwitchCase {
+	return SwitchCase{When: condition, Command: cmd}
+}
+
+func (p CommandSwitch) Do(ctx context.Context, cache *Cache) CmdResult {
+	var value any
+	var err error
+	if p.Expr != "" {
+		value, err = evaluateExpression(ctx, cache, p.Expr)
+	} else {
+		value, err = resolveValue(ctx, cache, p.Value)
+	}
+	if err != nil {
+		return CmdResult{Error: err}
+	}
+
+	s := newScope(scopeFrom(ctx))
+	s.define(SwitchValueVariable, value)
+	ctx = withScope(ctx, s)
+
+	for _, c := range p.Cases {
+		matched := false
+		if c.When != "" {
+			result, err := evaluateExpression(ctx, cache, c.When)
+			if err != nil {
+				return CmdResult{Error: err}
+			}
+			isTrue, ok := result.(bool)
+			if !ok {
+				return CmdResult{Error: ErrExpressionNotBoolean}
+			}
+			matched = isTrue
+		} else {
+			match := c.Match
+			if str, ok := match.(string); ok {
+				match = substituteContextVars(ctx, str)
+			}
+			matched = valuesEqual(match, value)
+		}
+
+		if matched {
+			if c.Command == nil {
+				return CmdResult{}
+			}
+			return runCommand(ctx, cache, c.Command)
+		}
+	}
+
+	if p.Default != nil {
+		return runCommand(ctx, cache, p.Default)
+	}
+	return CmdResult{}
+}
+
+func (c *CommandSwitch) UnmarshalJSON(data []byte) error {
+	type Alias CommandSwitch
+	aux := struct {
+		Default json.RawMessage `json:"default"`
+		*Alias
+	}{
+		Alias: (*Alias)(c),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.Default) > 0 && string(aux.Default) != "null" {
+		var raw RawCommand
+		if err := json.Unmarshal(aux.Default, &raw); err != nil {
+			return err
+		}
+		c.Default = raw.Command
+	}
+	return nil
+}
+
+func (c *SwitchCase) UnmarshalJSON(data []byte) error {
+	type Alias SwitchCase
+	aux := struct {
+		Command json.RawMessage `json:"command"`
+		*Alias
+	}{
+		Alias: (*Alias)(c),
+	}
+
+	if err := DecodeJSON(data, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.Command) > 0 && string(aux.Command) != "null" {
+		var raw RawCommand
+		if err := json.Unmarshal(aux.Command, &raw); err != nil {
+			return err
+		}
+		c.Command = raw.Command
+	}
+	return nil
+}
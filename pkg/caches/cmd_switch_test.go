@@ -0,0 +1,92 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSWITCH_LiteralMatch(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"state": "running"}))
+
+	cmd := SWITCH("${{state}}", RETURN("unknown"),
+		CASE("pending", RETURN("start it")),
+		CASE("running", RETURN("wait")),
+		CASE("done", RETURN("clean up")),
+	)
+
+	res := cmd.Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, "wait", res.Value)
+
+	assert.NoError(t, cache.Replace(ctx, "state", "exploded"))
+	res = cmd.Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, "unknown", res.Value)
+}
+
+func TestSWITCH_NumericMatch(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"level": 2.0}))
+
+	res := SWITCH("${{level}}", nil, CASE(1, RETURN("one")), CASE(2, RETURN("two"))).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, "two", res.Value)
+}
+
+func TestSWITCH_ConditionCases(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"temp": 72.0}))
+
+	cmd := SWITCH("${{temp}}", RETURN("hot"),
+		WHEN("${{$value}} < 32", RETURN("freezing")),
+		WHEN("${{$value}} < 75", RETURN("mild: ${{$value}}")),
+	)
+	res := cmd.Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, "mild: 72", res.Value)
+
+	res = SWITCH("${{temp}}", nil, WHEN("${{$value}}", RETURN("x"))).Do(ctx, cache)
+	assert.ErrorIs(t, res.Error, ErrExpressionNotBoolean)
+}
+
+func TestSWITCH_NoMatchNoDefault(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	res := SWITCH("a", nil, CASE("b", RETURN("b"))).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Nil(t, res.Value)
+}
+
+func TestSWITCH_JSON(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"order": map[string]any{"status": "paid", "next": ""}}))
+
+	data := `{
+		"type": "SWITCH",
+		"value": "${{order/status}}",
+		"cases": [
+			{"match": "new", "command": {"type": "REPLACE", "key": "order/next", "value": "pay"}},
+			{"when": "${{$value}} == \"paid\"", "command": {"type": "REPLACE", "key": "order/next", "value": "ship"}}
+		],
+		"default": {"type": "NOOP"}
+	}`
+	var raw RawCommand
+	assert.NoError(t, json.Unmarshal([]byte(data), &raw))
+
+	res := raw.Command.Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, "ship", res.Value)
+
+	out, err := json.Marshal(raw)
+	assert.NoError(t, err)
+	assert.JSONEq(t, data, string(out))
+}
@@ -0,0 +1,164 @@
+package caches
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/goodblaster/errors"
+)
+
+// TryErrorVariable is the variable holding the caught error inside a CATCH
+// branch. It is an object with "message" and "type" fields, referenced as
+// ${{$error/message}} and ${{$error/type}}.
+const TryErrorVariable = "error"
+
+// CommandTry runs Body. If Body fails, Catch runs with the error bound to
+// ${{$error}} and its result replaces Body's. Finally always runs last; its
+// result is discarded, but its error is not. Changes made by Body before it
+// failed are kept. Context cancellation, timeouts and exceeded execution
+// limits are never caught.
+type CommandTry struct {
+	Body    Command `json:"do,required"`
+	Catch   Command `json:"catch,omitempty"`
+	Finally Command `json:"finally,omitempty"`
+}
+
+func (CommandTry) Type() CommandType {
+	return CommandTypeTry
+}
+
+func TRY(body, catch, finally Command) Command {
+	return CommandTry{Body: body, Catch: catch, Finally: finally}
+}
+
+func (p CommandTry) Do(ctx context.Context, cache *Cache) CmdResult {
+	var res CmdResult
+	if p.Body != nil {
+		res = runCommand(ctx, cache, p.Body)
+	}
+
+	if res.Error != nil && catchable(ctx, res.Error) {
+		caught := res.Error
+		res = CmdResult{}
+		if p.Catch != nil {
+			s := newScope(scopeFrom(ctx))
+			s.define(TryErrorVariable, map[string]any{
+				"message": caught.Error(),
+				"type":    ErrorType(caught),
+			})
+			res = runCommand(withScope(ctx, s), cache, p.Catch)
+		}
+	}
+
+	if p.Finally != nil {
+		if fin := runCommand(ctx, cache, p.Finally); fin.Error != nil {
+			return fin
+		}
+	}
+	return res
+}
+
+// catchable reports whether err may be caught by TRY or ignore_errors.
+func catchable(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && !errors.Is(err, ErrExecutionLimit)
+}
+
+// errorTypes maps well-known errors to the type reported to CATCH branches.
+var errorTypes = []struct {
+	err  error
+	name string
+}{
+	{ErrKeyNotFound, "KEY_NOT_FOUND"},
+	{ErrKeyAlreadyExists, "KEY_ALREADY_EXISTS"},
+	{ErrNotANumber, "NOT_A_NUMBER"},
+	{ErrIntegerOverflow, "INTEGER_OVERFLOW"},
+	{ErrDivisionByZero, "DIVISION_BY_ZERO"},
+	{ErrNotAnArray, "NOT_AN_ARRAY"},
+	{ErrInvalidExpression, "INVALID_EXPRESSION"},
+	{ErrEvaluationError, "EVALUATION_ERROR"},
+	{ErrExpressionNotBoolean, "EXPRESSION_NOT_BOOLEAN"},
+	{ErrVariableNotFound, "VARIABLE_NOT_FOUND"},
+	{ErrTriggerRecursionLimit, "TRIGGER_RECURSION_LIMIT"},
+}
+
+// ErrorType classifies err for CATCH branches, e.g. "KEY_NOT_FOUND".
+// Errors without a specific classification are reported as "ERROR".
+func ErrorType(err error) string {
+	for _, et := range errorTypes {
+		if errors.Is(err, et.err) {
+			return et.name
+		}
+	}
+	return "ERROR"
+}
+
+func (c *CommandTry) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Do      json.RawMessage `json:"do"`
+		Catch   json.RawMessage `json:"catch"`
+		Finally json.RawMessage `json:"finally"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	for _, branch := range []struct {
+		data json.RawMessage
+		cmd  *Command
+	}{
+		{aux.Do, &c.Body},
+		{aux.Catch, &c.Catch},
+		{aux.Finally, &c.Finally},
+	} {
+		if len(branch.data) == 0 || string(branch.data) == "null" {
+			continue
+		}
+		var raw RawCommand
+		if err := json.Unmarshal(branch.data, &raw); err != nil {
+			return err
+		}
+		*branch.cmd = raw.Command
+	}
+	return nil
+}
+
+// CommandIgnoreErrors runs Command and discards its error, for best-effort
+// steps that should not abort the rest of a batch. It is created by setting
+// "ignore_errors": true on any command.
+type CommandIgnoreErrors struct {
+	Command Command
+}
+
+func (c CommandIgnoreErrors) Type() CommandType {
+	return c.Command.Type()
+}
+
+func IGNORE_ERRORS(cmd Command) Command {
+	return CommandIgnoreErrors{Command: cmd}
+}
+
+func (c CommandIgnoreErrors) Do(ctx context.Context, cache *Cache) CmdResult {
+	res := c.Command.Do(ctx, cache)
+	if res.Error != nil && catchable(ctx, res.Error) {
+		return CmdResult{}
+	}
+	return res
+}
+
+func (c CommandIgnoreErrors) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(RawCommand{Command: c.Command})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep numbers as written, so large integers don't lose precision
+	var fields map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	fields["ignore_errors"] = true
+	return json.Marshal(fields)
+}
@@ -0,0 +1,146 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTRY_NoError(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"n": 1.0, "log": ""}))
+
+	res := cache.Execute(ctx, TRY(INC("n", 1), RETURN("caught"), REPLACE("log", "finally")))
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{2.0}, res.Value)
+
+	val, _ := cache.Get(ctx, "log")
+	assert.Equal(t, "finally", val)
+}
+
+func TestTRY_CatchesError(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"log": "", "after": false}))
+
+	res := cache.Execute(ctx,
+		TRY(
+			REPLACE("missing", 1),
+			REPLACE("log", "${{$error/type}}: ${{$error/message}}"),
+			nil,
+		),
+		REPLACE("after", true),
+	)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{"KEY_NOT_FOUND: key not found: missing", true}, res.Value)
+}
+
+func TestTRY_ErrorTypes(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"s": "text"}))
+
+	res := cache.Execute(ctx, TRY(INC("s", 1), RETURN("${{$error/type}}"), nil))
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{"NOT_A_NUMBER"}, res.Value)
+
+	res = cache.Execute(ctx, TRY(IF("(", NOOP(), NOOP()), RETURN("${{$error/type}}"), nil))
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{"INVALID_EXPRESSION"}, res.Value)
+}
+
+func TestTRY_FinallyRunsOnError(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"cleaned": false}))
+
+	// Without catch, the error is swallowed and the result is nil.
+	res := TRY(REPLACE("missing", 1), nil, REPLACE("cleaned", true)).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Nil(t, res.Value)
+
+	val, _ := cache.Get(ctx, "cleaned")
+	assert.Equal(t, true, val)
+
+	// Errors in finally are reported.
+	res = TRY(NOOP(), nil, REPLACE("missing", 1)).Do(ctx, cache)
+	assert.Error(t, res.Error)
+}
+
+func TestTRY_CatchError(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	res := TRY(REPLACE("missing", 1), REPLACE("also-missing", 1), nil).Do(ctx, cache)
+	assert.Error(t, res.Error)
+	assert.Contains(t, res.Error.Error(), "also-missing")
+}
+
+func TestTRY_DoesNotCatchCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cache := New()
+
+	res := TRY(COMMANDS(NOOP()), RETURN("caught"), nil).Do(ctx, cache)
+	assert.ErrorIs(t, res.Error, context.Canceled)
+}
+
+func TestIgnoreErrors(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"n": 1.0}))
+
+	res := cache.Execute(ctx, IGNORE_ERRORS(INC("missing", 1)), INC("n", 1))
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{nil, 2.0}, res.Value)
+}
+
+func TestTRY_JSON(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"n": 1.0, "err": ""}))
+
+	data := `{"commands": [
+		{"type": "DELETE", "key": "tmp"},
+		{"type": "INC", "key": "missing", "value": 1, "ignore_errors": true},
+		{
+			"type": "TRY",
+			"do": {"type": "REPLACE", "key": "missing", "value": 1},
+			"catch": {"type": "REPLACE", "key": "err", "value": "${{$error/type}}"},
+			"finally": {"type": "INC", "key": "n", "value": 1}
+		}
+	]}`
+	var env CommandEnvelope
+	assert.NoError(t, json.Unmarshal([]byte(data), &env))
+
+	var cmds []Command
+	for _, raw := range env.Commands {
+		cmds = append(cmds, raw.Command)
+	}
+	res := cache.Execute(ctx, cmds...)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{nil, nil, "KEY_NOT_FOUND"}, res.Value)
+
+	n, _ := cache.Get(ctx, "n")
+	assert.Equal(t, 2.0, n)
+
+	out, err := json.Marshal(RawCommand{Command: cmds[1]})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "INC", "key": "missing", "value": 1, "ignore_errors": true}`, string(out))
+
+	out, err = json.Marshal(RawCommand{Command: IGNORE_ERRORS(REPLACE("id", int64(9007199254740993)))})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `"value":9007199254740993`)
+
+	out, err = json.Marshal(RawCommand{Command: cmds[2]})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "TRY",
+		"do": {"type": "REPLACE", "key": "missing", "value": 1},
+		"catch": {"type": "REPLACE", "key": "err", "value": "${{$error/type}}"},
+		"finally": {"type": "INC", "key": "n", "value": 1}
+	}`, string(out))
+}
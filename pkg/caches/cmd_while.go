@@ -0,0 +1,89 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// CommandWhile runs Commands for as long as Condition evaluates to true.
+// Each iteration binds its zero-based position to ${{$index}}. The loop fails
+// once it exceeds MaxIterations, or config.CommandMaxLoopIterations if that
+// is lower or MaxIterations is not set.
+type CommandWhile struct {
+	Condition     string    `json:"condition,required"`
+	Commands      []Command `json:"commands,required"`
+	MaxIterations int       `json:"max_iterations,omitempty"`
+}
+
+func (CommandWhile) Type() CommandType {
+	return CommandTypeWhile
+}
+
+func WHILE(condition string, cmds ...Command) Command {
+	return CommandWhile{Condition: condition, Commands: cmds}
+}
+
+func (p CommandWhile) Do(ctx context.Context, cache *Cache) CmdResult {
+	limit := loopIterationLimit(p.MaxIterations)
+
+	var allResults []CmdResult
+	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return CmdResult{Error: err}
+		}
+
+		val, err := evaluateExpression(ctx, cache, p.Condition)
+		if err != nil {
+			return CmdResult{Error: err}
+		}
+		ok, isBool := val.(bool)
+		if !isBool {
+			return CmdResult{Error: ErrExpressionNotBoolean}
+		}
+		if !ok {
+			break
+		}
+
+		if i >= limit {
+			return CmdResult{Error: ErrLoopIterationLimit.Format(limit)}
+		}
+
+		s := newScope(scopeFrom(ctx))
+		s.define(LoopIndexVariable, float64(i))
+		iterCtx := withScope(ctx, s)
+
+		for _, cmd := range p.Commands {
+			result := runCommand(iterCtx, cache, cmd)
+			allResults = append(allResults, result)
+			if result.Error != nil {
+				return result
+			}
+		}
+	}
+
+	return CmdResult{Value: allResults}
+}
+
+func (c *CommandWhile) UnmarshalJSON(data []byte) error {
+	type Alias CommandWhile
+	aux := struct {
+		Commands []json.RawMessage `json:"commands"`
+		*Alias
+	}{
+		Alias: (*Alias)(c),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	for _, cmdData := range aux.Commands {
+		var rc RawCommand
+		if err := json.Unmarshal(cmdData, &rc); err != nil {
+			return err
+		}
+		c.Commands = append(c.Commands, rc.Command)
+	}
+
+	return nil
+}
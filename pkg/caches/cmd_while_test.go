@@ -0,0 +1,85 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWHILE_RunsUntilFalse(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"counter": 0.0, "last": -1.0})
+	assert.NoError(t, err)
+
+	res := cache.Execute(ctx,
+		WHILE("${{counter}} < 5",
+			INC("counter", 1),
+			REPLACE("last", "${{$index}}"),
+		),
+	)
+	assert.NoError(t, res.Error)
+
+	val, err := cache.Get(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, 5.0, val)
+
+	val, err = cache.Get(ctx, "last")
+	assert.NoError(t, err)
+	assert.Equal(t, 4.0, val)
+}
+
+func TestWHILE_FalseInitially(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	res := WHILE("1 > 2", RETURN("never")).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Empty(t, res.Value)
+}
+
+func TestWHILE_IterationLimit(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{"counter": 0.0})
+	assert.NoError(t, err)
+
+	res := CommandWhile{
+		Condition:     "true",
+		Commands:      []Command{INC("counter", 1)},
+		MaxIterations: 3,
+	}.Do(ctx, cache)
+	assert.ErrorIs(t, res.Error, ErrLoopIterationLimit)
+
+	val, err := cache.Get(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, val)
+}
+
+func TestWHILE_NonBooleanCondition(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	res := WHILE("1 + 2", NOOP()).Do(ctx, cache)
+	assert.ErrorIs(t, res.Error, ErrExpressionNotBoolean)
+}
+
+func TestWHILE_Marshaling(t *testing.T) {
+	cmd := WHILE("${{n}} < 3", INC("n", 1))
+
+	data, err := json.Marshal(RawCommand{Command: cmd})
+	assert.NoError(t, err)
+
+	var raw RawCommand
+	err = json.Unmarshal(data, &raw)
+	assert.NoError(t, err)
+
+	decoded, ok := raw.Command.(*CommandWhile)
+	assert.True(t, ok)
+	assert.Equal(t, "${{n}} < 3", decoded.Condition)
+	assert.Len(t, decoded.Commands, 1)
+}
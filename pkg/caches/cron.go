@@ -0,0 +1,168 @@
+package caches
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression:
+//
+//	minute hour day-of-month month day-of-week
+//
+// Each field is *, a value, a range a-b, a step */n or a-b/n, or a
+// comma-separated list of those. Months and days of the week may also be
+// given by their first three letters (jan, mon), and Sunday is 0 or 7. As in
+// cron, when both day fields are restricted a day matching either one runs.
+//
+// @yearly, @monthly, @weekly, @daily and @hourly are shorthands.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bit n set when n matches
+	domStar, dowStar              bool
+}
+
+var cronShorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonths = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var cronDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// cronSearchLimit bounds the search for the next run, so that expressions
+// that can never match (e.g. "0 0 30 2 *") are rejected.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+func parseCron(expr string) (*cronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if full, ok := cronShorthands[strings.ToLower(spec)]; ok {
+		spec = full
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, ErrInvalidCron.Format(expr, "expected 5 fields")
+	}
+
+	var cron cronSchedule
+	var err error
+	if cron.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, ErrInvalidCron.Format(expr, err)
+	}
+	if cron.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, ErrInvalidCron.Format(expr, err)
+	}
+	if cron.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, ErrInvalidCron.Format(expr, err)
+	}
+	if cron.month, err = parseCronField(fields[3], 1, 12, cronMonths); err != nil {
+		return nil, ErrInvalidCron.Format(expr, err)
+	}
+	if cron.dow, err = parseCronField(fields[4], 0, 7, cronDays); err != nil {
+		return nil, ErrInvalidCron.Format(expr, err)
+	}
+	// Sunday is both 0 and 7
+	if cron.dow&(1<<7) != 0 {
+		cron.dow |= 1
+	}
+	cron.domStar = fields[2] == "*"
+	cron.dowStar = fields[4] == "*"
+
+	if cron.next(time.Now()).IsZero() {
+		return nil, ErrInvalidCron.Format(expr, "never runs")
+	}
+	return &cron, nil
+}
+
+// parseCronField returns the set of values matched by a field. names, if
+// given, name the values from min.
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, ErrInvalidCronField.Format(part)
+			}
+			rng, step = part[:i], n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = cronValue(bounds[0], min, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = cronValue(bounds[1], min, names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// a/n runs from a to the end of the range
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, ErrInvalidCronField.Format(part)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, min int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, ErrInvalidCronField.Format(s)
+	}
+	return v, nil
+}
+
+// next returns the first time after t that the schedule matches, or the zero
+// time if there is none within cronSearchLimit.
+func (cron *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	loc := t.Location()
+
+	for t.Before(limit) {
+		switch {
+		case cron.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !cron.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case cron.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case cron.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (cron *cronSchedule) dayMatches(t time.Time) bool {
+	dom := cron.dom&(1<<uint(t.Day())) != 0
+	dow := cron.dow&(1<<uint(t.Weekday())) != 0
+	if cron.domStar || cron.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -10,25 +10,75 @@ var ErrKeyNotFound = errors.New("key not found: %s")
 var ErrNotAnArray = errors.New("not an array: %s")
 var ErrNotANumber = errors.New("not a number")
 var ErrIncrementValueNotNumber = errors.New("increment value must be a number")
+var ErrIntegerOverflow = errors.New("integer overflow: %d %s %d")
+var ErrDivisionByZero = errors.New("division by zero")
+var ErrInvalidDecimal = errors.New("invalid decimal: %q")
 
 // Cache management errors
 var ErrCacheAlreadyExists = errors.New("cache already exists")
 var ErrCacheNotFound = errors.New("cache not found")
+var ErrCacheNotLinked = errors.New("cache %q is not available to this execution")
+
+// Schedule errors
+var ErrScheduleNotFound = errors.New("schedule not found: %s")
+var ErrScheduleExists = errors.New("schedule already exists: %s")
+var ErrInvalidScheduleId = errors.New("invalid schedule id: %q")
+var ErrScheduleCommandRequired = errors.New("schedule command is required")
+var ErrInvalidScheduleTiming = errors.New("schedule needs exactly one of cron, interval_ms (positive) and at")
+var ErrScheduleIntervalTooShort = errors.New("interval_ms must be at least %d")
+var ErrInvalidCron = errors.New("invalid cron expression %q: %v")
+var ErrInvalidCronField = errors.New("invalid field %q")
+
+// Idempotency errors
+var ErrIdempotencyKeyReused = errors.New("idempotency key %q was already used for a different request")
+var ErrIdempotencyKeyInProgress = errors.New("a request with idempotency key %q is still in progress")
+
+// Job errors
+var ErrJobNotFound = errors.New("job not found: %s")
+var ErrJobTimeout = errors.New("job timed out after %d ms")
+var ErrTooManyJobs = errors.New("cache %q already has %d queued or running jobs")
 
 // Trigger errors
 var ErrTriggerNotFound = errors.New("trigger not found")
 var ErrTriggerRecursionLimit = errors.New("trigger recursion depth limit exceeded (max: %d) - possible infinite loop detected")
 var ErrWildcardEmptySegment = errors.New("wildcard at index %d matched empty segment")
 var ErrSegmentMismatch = errors.New("segment mismatch at index %d: %s != %s")
+var ErrInvalidCapture = errors.New("invalid capture segment %q in pattern %q")
+var ErrDuplicateCapture = errors.New("duplicate capture name %q in pattern %q")
 var ErrMismatchedPathLengths = errors.New("mismatched path lengths: %v vs %v")
+var ErrPatternMismatch = errors.New("key %q does not match pattern %q")
 
 // Command and expression errors
 var ErrUnknownCommandType = errors.New("unknown command type: %s")
+var ErrInvalidCommandType = errors.New("invalid command type: %q")
+var ErrCommandTypeExists = errors.New("command type already registered: %s")
 var ErrInvalidExpression = errors.New("invalid expression: %w")
 var ErrEvaluationError = errors.New("evaluation error: %w")
+var ErrExpressionSyntax = errors.New("syntax error at position %d in %q: %s")
+var ErrExpressionOperands = errors.New("operator %s cannot be applied to %s")
+var ErrUndefinedFunction = errors.New("undefined function: %s")
 var ErrExpressionNotBoolean = errors.New("expression did not return a boolean")
 var ErrInvalidForExpression = errors.New("invalid FOR expression: %s")
-var ErrForExpressionNeedsWildcard = errors.New("FOR expression must include a wildcard: %s")
+var ErrForExpressionNeedsWildcard = errors.New("FOR expression must include a wildcard or reference an array: %s")
+var ErrInvalidLoopRange = errors.New("invalid loop range value: %v")
+var ErrLoopIterationLimit = errors.New("loop exceeded %d iterations")
+var ErrInvalidExecutionLimits = errors.New("execution limits cannot be negative")
+var ErrVariableNotFound = errors.New("variable not found: %s")
+var ErrNoVariableScope = errors.New("variables can only be bound inside a command execution")
+var ErrInvalidVariableName = errors.New("invalid variable name: %q")
+var ErrInvalidFunction = errors.New("invalid expression function: %q")
+var ErrFunctionArguments = errors.New("wrong number of arguments to %s(): %d")
+var ErrFunctionArgumentType = errors.New("%s() expects %s, got %v")
+
+// Procedure errors
+var ErrProcedureNotFound = errors.New("procedure not found: %s")
+var ErrProcedureVersionNotFound = errors.New("procedure %s has no version %d")
+var ErrInvalidProcedureName = errors.New("invalid procedure name: %q")
+var ErrProcedureCommandRequired = errors.New("procedure command is required")
+var ErrDuplicateProcedureParam = errors.New("duplicate procedure parameter: %s")
+var ErrMissingProcedureArg = errors.New("procedure %s: missing argument %s")
+var ErrUnknownProcedureArg = errors.New("procedure %s: unknown argument %s")
+var ErrProcedureRecursionLimit = errors.New("procedure call depth limit exceeded (max: %d)")
 
 // Interpolation errors
 var ErrWildcardInterpolation = errors.New("wildcard interpolation error for key %q: %w")
@@ -36,3 +86,21 @@ var ErrInterpolation = errors.New("interpolation error for key %q: %w")
 var ErrWildcardInTemplate = errors.New("wildcards not allowed in templated string: %q")
 var ErrWildcardWithFallback = errors.New("wildcards not allowed with fallback operator: %q")
 var ErrInvalidFallbackExpression = errors.New("fallback expression must have exactly 2 parts (key || default), got %d parts in: %q")
+
+// Query errors
+var ErrInvalidQuery = errors.New("invalid query: %s")
+
+// Index errors
+var ErrIndexNotFound = errors.New("index not found: %s")
+var ErrIndexExists = errors.New("index already exists: %s")
+var ErrInvalidIndexName = errors.New("invalid index name: %q")
+var ErrInvalidIndex = errors.New("invalid index %s: %s")
+var ErrNoIndex = errors.New("no index on %s")
+
+// Patch errors
+var ErrInvalidPatch = errors.New("invalid patch: %s")
+var ErrPatchConflict = errors.New("cannot apply patch at %q: %s")
+var ErrPatchTestFailed = errors.New("patch test failed at %q")
+
+// Scan errors
+var ErrInvalidScanCursor = errors.New("invalid or expired scan cursor: %d")
@@ -0,0 +1,436 @@
+package caches
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// exprNode is a node of a compiled expression.
+type exprNode interface {
+	eval(ctx context.Context, cache *Cache) (any, error)
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n *literalNode) eval(context.Context, *Cache) (any, error) {
+	return n.value, nil
+}
+
+// templateNode is a string literal containing captures or variables, e.g.
+// "job-${{$id}}".
+type templateNode struct {
+	text string
+}
+
+func (n *templateNode) eval(ctx context.Context, _ *Cache) (any, error) {
+	return interpolateKey(ctx, n.text)
+}
+
+// refNode is a ${{...}} reference to a key or variable. A reference such as
+// ${{jobs/${{$id}}/status}} has its inner references resolved first.
+type refNode struct {
+	ref     string
+	capture int  // n for a positional capture ${{n}}
+	nested  bool // ref contains ${{...}}
+}
+
+func newRefNode(ref string) *refNode {
+	n := &refNode{ref: ref, nested: strings.Contains(ref, "${{")}
+	if i, err := strconv.Atoi(ref); err == nil && i > 0 {
+		n.capture = i
+	}
+	return n
+}
+
+// key returns the referenced key or variable, with inner references resolved.
+func (n *refNode) key(ctx context.Context) (string, error) {
+	if !n.nested {
+		return n.ref, nil
+	}
+	return interpolateKey(ctx, n.ref)
+}
+
+func (n *refNode) eval(ctx context.Context, cache *Cache) (any, error) {
+	if n.capture > 0 {
+		if vars, ok := ctx.Value(triggerVarsContextKey).([]string); ok && n.capture <= len(vars) {
+			return vars[n.capture-1], nil
+		}
+	}
+
+	key, err := n.key(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Missing keys and variables are null
+	val, err := lookupRef(ctx, cache, key)
+	if err != nil {
+		return nil, nil
+	}
+	return val, nil
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n *unaryNode) eval(ctx context.Context, cache *Cache) (any, error) {
+	val, err := n.operand.eval(ctx, cache)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "!":
+		if b, ok := val.(bool); ok {
+			return !b, nil
+		}
+	case "-":
+		if IsNumber(val) {
+			return negateNumber(val)
+		}
+	}
+	return nil, ErrExpressionOperands.Format(n.op, TypeName(val))
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) eval(ctx context.Context, cache *Cache) (any, error) {
+	left, err := n.left.eval(ctx, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit operators
+	switch n.op {
+	case "&&", "||":
+		l, ok := left.(bool)
+		if !ok {
+			return nil, ErrExpressionOperands.Format(n.op, TypeName(left))
+		}
+		if l == (n.op == "||") {
+			return l, nil
+		}
+		right, err := n.right.eval(ctx, cache)
+		if err != nil {
+			return nil, err
+		}
+		r, ok := right.(bool)
+		if !ok {
+			return nil, ErrExpressionOperands.Format(n.op, TypeName(right))
+		}
+		return r, nil
+	case "??":
+		if left != nil {
+			return left, nil
+		}
+		return n.right.eval(ctx, cache)
+	}
+
+	right, err := n.right.eval(ctx, cache)
+	if err != nil {
+		return nil, err
+	}
+	return applyOperator(n.op, left, right)
+}
+
+// applyOperator applies a non-short-circuit binary operator.
+func applyOperator(op string, left, right any) (any, error) {
+	if isComparison(op) {
+		return compareValues(op, left, right)
+	}
+
+	// Numbers keep their type as AddNumbers describes: integers stay exact
+	// and a Decimal operand gives a Decimal
+	if IsNumber(left) && IsNumber(right) {
+		switch op {
+		case "+":
+			return AddNumbers(left, right)
+		case "-":
+			return subNumbers(left, right)
+		case "*":
+			return mulNumbers(left, right)
+		case "/":
+			return divNumbers(left, right)
+		case "%":
+			return modNumbers(left, right)
+		case "**":
+			l, _ := ToFloat64(left)
+			r, _ := ToFloat64(right)
+			return math.Pow(l, r), nil
+		}
+	}
+
+	// + joins strings, and anything else added to a string
+	if op == "+" {
+		_, lstr := left.(string)
+		_, rstr := right.(string)
+		if lstr || rstr {
+			return formatOperand(left) + formatOperand(right), nil
+		}
+	}
+	return nil, ErrExpressionOperands.Format(op, TypeName(left)+" and "+TypeName(right))
+}
+
+func formatOperand(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// compareValues applies a comparison operator. Numbers compare by value
+// whatever their type, strings compare lexically.
+func compareValues(op string, left, right any) (bool, error) {
+	switch op {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	case "=~", "!~":
+		s, sok := left.(string)
+		pattern, pok := right.(string)
+		if !sok || !pok {
+			return false, ErrExpressionOperands.Format(op, TypeName(left)+" and "+TypeName(right))
+		}
+		re, err := compileRegex(pattern)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(s) == (op == "=~"), nil
+	}
+
+	var cmp int
+	ls, lsok := left.(string)
+	rs, rsok := right.(string)
+	switch {
+	case IsNumber(left) && IsNumber(right):
+		var err error
+		if cmp, err = compareNumbers(left, right); err != nil {
+			return false, err
+		}
+	case lsok && rsok:
+		cmp = strings.Compare(ls, rs)
+	default:
+		return false, ErrExpressionOperands.Format(op, TypeName(left)+" and "+TypeName(right))
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return false, ErrExpressionOperands.Format(op, TypeName(left)+" and "+TypeName(right))
+}
+
+// compileRegex compiles a pattern used by =~, !~ or matches(), caching it.
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.get(pattern); ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, ErrInvalidExpression.Format(err)
+	}
+	regexCache.add(pattern, re)
+	return re, nil
+}
+
+type ternaryNode struct {
+	cond, ifTrue, ifFalse exprNode
+}
+
+func (n *ternaryNode) eval(ctx context.Context, cache *Cache) (any, error) {
+	cond, err := n.cond.eval(ctx, cache)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := cond.(bool)
+	if !ok {
+		return nil, ErrExpressionOperands.Format("?", TypeName(cond))
+	}
+	if b {
+		return n.ifTrue.eval(ctx, cache)
+	}
+	return n.ifFalse.eval(ctx, cache)
+}
+
+// callNode calls a registered function. The function must exist when the
+// expression is compiled, but is looked up again on each call so that
+// re-registering a name takes effect immediately.
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+func (n *callNode) eval(ctx context.Context, cache *Cache) (any, error) {
+	functionsMu.RLock()
+	fn, ok := functions[n.name]
+	functionsMu.RUnlock()
+	if !ok {
+		return nil, ErrUndefinedFunction.Format(n.name)
+	}
+
+	args := make([]any, len(n.args))
+	for i, arg := range n.args {
+		val, err := arg.eval(ctx, cache)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = val
+	}
+	return fn(args...)
+}
+
+// existsNode is exists(${{key}}): whether the key or variable is present,
+// even if it holds null.
+type existsNode struct {
+	ref *refNode
+}
+
+func (n *existsNode) eval(ctx context.Context, cache *Cache) (any, error) {
+	key, err := n.ref.key(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if isVariableRef(key) {
+		_, err := variableValue(ctx, key)
+		return err == nil, nil
+	}
+	cache, key, _, err = resolveCache(ctx, cache, key)
+	if err != nil {
+		return nil, err
+	}
+	path, err := cache.splitPath(ctx, key)
+	if err != nil {
+		return false, nil
+	}
+	return cache.cmap.Exists(ctx, path...), nil
+}
+
+// aggregateNode is sum/count/min/max/avg/distinct(${{pattern}}).
+type aggregateNode struct {
+	fn  string
+	ref *refNode
+}
+
+func (n *aggregateNode) eval(ctx context.Context, cache *Cache) (any, error) {
+	pattern, err := n.ref.key(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values, err := aggregateValues(ctx, cache, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return aggregate(n.fn, values)
+}
+
+// lookupNode is lookup(${{source/field}}, value): the keys of the items whose
+// field equals value, from an index.
+type lookupNode struct {
+	ref   *refNode
+	value exprNode
+}
+
+func (n *lookupNode) eval(ctx context.Context, cache *Cache) (any, error) {
+	pattern, err := n.ref.key(ctx)
+	if err != nil {
+		return nil, err
+	}
+	value, err := n.value.eval(ctx, cache)
+	if err != nil {
+		return nil, err
+	}
+	cache, pattern, prefix, err := resolveCache(ctx, cache, pattern)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := cache.Lookup(ctx, pattern, value)
+	if err != nil {
+		return nil, err
+	}
+	if keys, err = chargeKeysVisited(ctx, keys); err != nil {
+		return nil, err
+	}
+	result := make([]any, len(keys))
+	for i, key := range keys {
+		result[i] = prefix + key
+	}
+	return result, nil
+}
+
+// predicateNode is any/all/count_where(${{pattern}} op value).
+type predicateNode struct {
+	fn    string
+	ref   *refNode
+	op    string
+	right exprNode
+}
+
+func (n *predicateNode) eval(ctx context.Context, cache *Cache) (any, error) {
+	pattern, err := n.ref.key(ctx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(ctx, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.fn == "count_where" {
+		values, err := aggregateValues(ctx, cache, pattern)
+		if err != nil {
+			return nil, err
+		}
+		var count int64
+		for _, val := range values {
+			// Values that cannot be compared (e.g. null vs number) don't match.
+			if matched, err := compareValues(n.op, val, right); err == nil && matched {
+				count++
+			}
+		}
+		return count, nil
+	}
+
+	cache, pattern, _, err = resolveCache(ctx, cache, pattern)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := cache.visitKeys(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return false, nil
+	}
+	for _, key := range keys {
+		val, err := cache.Get(ctx, key)
+		if err != nil {
+			val = nil
+		}
+		matched, err := compareValues(n.op, val, right)
+		if err != nil {
+			return nil, err
+		}
+		// any stops at the first match, all at the first mismatch
+		if matched == (n.fn == "any") {
+			return matched, nil
+		}
+	}
+	return n.fn == "all", nil
+}
@@ -0,0 +1,438 @@
+package caches
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expressions are parsed once into a tree of exprNodes, and evaluated against
+// the cache as many times as needed. ${{...}} references stay in the tree as
+// nodes and are looked up at evaluation time, so an expression compiles to the
+// same tree whatever its references, captures and variables resolve to.
+//
+// Precedence, lowest first:
+//
+//	c ? a : b
+//	??
+//	||
+//	&&
+//	==  !=  <  <=  >  >=  =~  !~
+//	+  -
+//	*  /  %
+//	**            (right associative)
+//	!  -          (unary)
+
+type exprTokenKind int
+
+const (
+	exprEOF exprTokenKind = iota
+	exprNumber
+	exprString
+	exprIdent
+	exprRef
+	exprOp
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string // operator, identifier, reference or unquoted string
+	num  any    // int64 or float64
+	pos  int    // start offset in the source
+	end  int    // end offset in the source
+}
+
+// exprLexer splits an expression into tokens.
+type exprLexer struct {
+	src    string
+	pos    int
+	tokens []exprToken
+}
+
+var exprOperators = []string{
+	"**", "==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "??",
+	"+", "-", "*", "/", "%", "<", ">", "!", "(", ")", ",", "?", ":",
+}
+
+func lexExpression(src string) ([]exprToken, error) {
+	l := &exprLexer{src: src}
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tok.end = l.pos
+		l.tokens = append(l.tokens, tok)
+		if tok.kind == exprEOF {
+			return l.tokens, nil
+		}
+	}
+}
+
+func (l *exprLexer) next() (exprToken, error) {
+	for l.pos < len(l.src) && strings.ContainsRune(" \t\r\n", rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return exprToken{kind: exprEOF, pos: start}, nil
+	}
+
+	rest := l.src[l.pos:]
+	c := rest[0]
+	switch {
+	case strings.HasPrefix(rest, "${{"):
+		end := matchingRefEnd(rest)
+		if end < 0 {
+			return exprToken{}, exprSyntaxError(l.src, start, "unterminated ${{")
+		}
+		l.pos += end
+		return exprToken{kind: exprRef, text: strings.TrimSpace(rest[3 : end-2]), pos: start}, nil
+
+	case c == '"' || c == '\'':
+		var b strings.Builder
+		for i := 1; i < len(rest); i++ {
+			switch rest[i] {
+			case '\\':
+				if i+1 >= len(rest) {
+					break
+				}
+				i++
+				switch rest[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(rest[i])
+				}
+			case c:
+				l.pos += i + 1
+				return exprToken{kind: exprString, text: b.String(), pos: start}, nil
+			default:
+				b.WriteByte(rest[i])
+			}
+		}
+		return exprToken{}, exprSyntaxError(l.src, start, "unterminated string")
+
+	case c >= '0' && c <= '9' || c == '.' && len(rest) > 1 && rest[1] >= '0' && rest[1] <= '9':
+		end := 0
+		for end < len(rest) && (rest[end] >= '0' && rest[end] <= '9' || rest[end] == '.' ||
+			(rest[end] == 'e' || rest[end] == 'E') ||
+			(rest[end] == '+' || rest[end] == '-') && end > 0 && (rest[end-1] == 'e' || rest[end-1] == 'E')) {
+			end++
+		}
+		if _, err := strconv.ParseFloat(rest[:end], 64); err != nil {
+			return exprToken{}, exprSyntaxError(l.src, start, "invalid number %q", rest[:end])
+		}
+		l.pos += end
+		// Integers are int64, as they are in JSON values
+		return exprToken{kind: exprNumber, num: parseJSONNumber(json.Number(rest[:end])), pos: start}, nil
+
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		end := 0
+		for end < len(rest) && (rest[end] == '_' || rest[end] >= 'a' && rest[end] <= 'z' ||
+			rest[end] >= 'A' && rest[end] <= 'Z' || rest[end] >= '0' && rest[end] <= '9') {
+			end++
+		}
+		l.pos += end
+		return exprToken{kind: exprIdent, text: rest[:end], pos: start}, nil
+	}
+
+	for _, op := range exprOperators {
+		if strings.HasPrefix(rest, op) {
+			l.pos += len(op)
+			return exprToken{kind: exprOp, text: op, pos: start}, nil
+		}
+	}
+	return exprToken{}, exprSyntaxError(l.src, start, "unexpected %q", string(c))
+}
+
+// matchingRefEnd returns the index just past the "}}" closing the ${{ at the
+// start of s, allowing nested references such as ${{jobs/${{$id}}/status}}.
+func matchingRefEnd(s string) int {
+	depth := 0
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "${{"):
+			depth++
+			i += 3
+		case strings.HasPrefix(s[i:], "}}"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+func exprSyntaxError(src string, pos int, format string, args ...any) error {
+	return ErrInvalidExpression.Format(ErrExpressionSyntax.Format(pos+1, src, fmt.Sprintf(format, args...)))
+}
+
+// exprParser is a recursive descent parser over the lexed tokens.
+type exprParser struct {
+	src    string
+	tokens []exprToken
+	pos    int
+}
+
+// parseExpression compiles an expression into an evaluable tree.
+func parseExpression(src string) (exprNode, error) {
+	tokens, err := lexExpression(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{src: src, tokens: tokens}
+	if p.peek().kind == exprEOF {
+		return nil, exprSyntaxError(src, 0, "empty expression")
+	}
+	node, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != exprEOF {
+		return nil, p.unexpected(tok)
+	}
+	return node, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) advance() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != exprEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the operator op if it is next.
+func (p *exprParser) accept(op string) bool {
+	if tok := p.peek(); tok.kind == exprOp && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		tok := p.peek()
+		if tok.kind == exprEOF {
+			return exprSyntaxError(p.src, tok.pos, "expected %q", op)
+		}
+		return exprSyntaxError(p.src, tok.pos, "expected %q, found %q", op, p.tokenText(tok))
+	}
+	return nil
+}
+
+func (p *exprParser) unexpected(tok exprToken) error {
+	if tok.kind == exprEOF {
+		return exprSyntaxError(p.src, tok.pos, "unexpected end of expression")
+	}
+	return exprSyntaxError(p.src, tok.pos, "unexpected %q", p.tokenText(tok))
+}
+
+func (p *exprParser) tokenText(tok exprToken) string {
+	return p.src[tok.pos:tok.end]
+}
+
+func (p *exprParser) ternary() (exprNode, error) {
+	cond, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("?") {
+		return cond, nil
+	}
+	ifTrue, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	ifFalse, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	return &ternaryNode{cond: cond, ifTrue: ifTrue, ifFalse: ifFalse}, nil
+}
+
+// exprBinaryLevels lists the binary operators by increasing precedence.
+var exprBinaryLevels = [][]string{
+	{"??"},
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">=", "=~", "!~"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) binary(level int) (exprNode, error) {
+	if level == len(exprBinaryLevels) {
+		return p.power()
+	}
+
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != exprOp || !containsString(exprBinaryLevels[level], tok.text) {
+			return left, nil
+		}
+		p.advance()
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *exprParser) power() (exprNode, error) {
+	base, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("**") {
+		return base, nil
+	}
+	exp, err := p.power()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{op: "**", left: base, right: exp}, nil
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	for _, op := range []string{"!", "-"} {
+		if p.accept(op) {
+			operand, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			return &unaryNode{op: op, operand: operand}, nil
+		}
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (exprNode, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case exprNumber:
+		return &literalNode{value: tok.num}, nil
+	case exprString:
+		if strings.Contains(tok.text, "${{") {
+			return &templateNode{text: tok.text}, nil
+		}
+		return &literalNode{value: tok.text}, nil
+	case exprRef:
+		return newRefNode(tok.text), nil
+	case exprIdent:
+		if p.accept("(") {
+			return p.call(tok)
+		}
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		return nil, exprSyntaxError(p.src, tok.pos, "unknown identifier %q", tok.text)
+	case exprOp:
+		if tok.text == "(" {
+			node, err := p.ternary()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return node, nil
+		}
+	}
+	return nil, p.unexpected(tok)
+}
+
+// call parses the arguments of a function call. Calls over ${{pattern}}
+// references, such as sum(${{a/*/b}}), any(${{a/*}} == 1) and
+// lookup(${{a/*/b}}, 1), become aggregate and index nodes; everything else
+// calls a registered function.
+func (p *exprParser) call(name exprToken) (exprNode, error) {
+	var args []exprNode
+	if !p.accept(")") {
+		for {
+			arg, err := p.ternary()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(args) == 2 && name.text == "lookup" {
+		if ref, ok := args[0].(*refNode); ok {
+			return &lookupNode{ref: ref, value: args[1]}, nil
+		}
+	}
+
+	if len(args) == 1 {
+		switch name.text {
+		case "exists":
+			if ref, ok := args[0].(*refNode); ok {
+				return &existsNode{ref: ref}, nil
+			}
+		case "sum", "count", "min", "max", "avg", "distinct":
+			if ref, ok := args[0].(*refNode); ok {
+				return &aggregateNode{fn: name.text, ref: ref}, nil
+			}
+		case "any", "all", "count_where":
+			if cmp, ok := args[0].(*binaryNode); ok && isComparison(cmp.op) {
+				if ref, ok := cmp.left.(*refNode); ok {
+					return &predicateNode{fn: name.text, ref: ref, op: cmp.op, right: cmp.right}, nil
+				}
+			}
+		}
+	}
+
+	functionsMu.RLock()
+	_, ok := functions[name.text]
+	functionsMu.RUnlock()
+	if !ok {
+		return nil, ErrInvalidExpression.Format(ErrUndefinedFunction.Format(name.text))
+	}
+	return &callNode{name: name.text, args: args}, nil
+}
+
+func isComparison(op string) bool {
+	return containsString(exprBinaryLevels[3], op)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package caches
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/goodblaster/map-cache/internal/config"
+)
+
+// evaluateExpression compiles expr, or reuses its compiled form, and evaluates
+// it against the cache. The evaluated value is returned as-is (bool, float64,
+// string, ...), so callers decide what type they require.
+func evaluateExpression(ctx context.Context, cache *Cache, expr string) (any, error) {
+	node, err := compileExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := node.eval(ctx, cache)
+	if err != nil {
+		return nil, ErrEvaluationError.Format(err)
+	}
+	return result, nil
+}
+
+// compileExpression parses expr, reusing previously compiled expressions.
+// References are resolved at evaluation, so an expression is compiled once
+// however many different values its references take.
+func compileExpression(expr string) (exprNode, error) {
+	if node, ok := exprCache.get(expr); ok {
+		return node, nil
+	}
+
+	node, err := parseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	exprCache.add(expr, node)
+	return node, nil
+}
+
+// exprCache holds the most recently used compiled expressions, up to
+// config.ExpressionCacheSize of them.
+var exprCache = newCompileCache[exprNode]()
+
+// compileCache is a least recently used cache of compiled forms keyed by
+// their source text, bounded by config.ExpressionCacheSize.
+type compileCache[T any] struct {
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List // most recently used first
+}
+
+type compileCacheEntry[T any] struct {
+	source   string
+	compiled T
+}
+
+func newCompileCache[T any]() *compileCache[T] {
+	return &compileCache[T]{items: map[string]*list.Element{}, order: list.New()}
+}
+
+func (c *compileCache[T]) get(source string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[source]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*compileCacheEntry[T]).compiled, true
+}
+
+func (c *compileCache[T]) add(source string, compiled T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[source]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[source] = c.order.PushFront(&compileCacheEntry[T]{source: source, compiled: compiled})
+	for c.order.Len() > max(config.ExpressionCacheSize, 1) {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*compileCacheEntry[T]).source)
+	}
+}
+
+func (c *compileCache[T]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// resolveValue computes the value a command should store. Strings are run
+// through evaluateInterpolations, so "${{other/key}}" copies the referenced
+// value with its type intact and "id-${{n}}" builds a string. Non-string
+// values are returned unchanged.
+func resolveValue(ctx context.Context, cache *Cache, value any) (any, error) {
+	str, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+	return evaluateInterpolations(ctx, cache, substituteContextVars(ctx, str))
+}
@@ -0,0 +1,152 @@
+package caches
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpression_Operators(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"n":    4.0,
+		"name": "job-42",
+		"tags": []any{"a", "b"},
+	}))
+
+	tests := []struct {
+		expr string
+		want any
+	}{
+		{"1 + 2 * 3", int64(7)},
+		{"(1 + 2) * 3", int64(9)},
+		{"2 ** 3 ** 2", 512.0},
+		{"-${{n}} + 10 % 3", -3.0},
+		{"${{n}} >= 4 && !(${{n}} > 4)", true},
+		{"${{n}} < 4 || ${{name}} == 'job-42'", true},
+		{`${{name}} =~ "^job-\\d+$"`, true},
+		{`${{name}} !~ "^task"`, true},
+		{"${{missing}} ?? 'default'", "default"},
+		{"${{n}} ?? 'default'", 4.0},
+		{"${{n}} > 3 ? 'big' : 'small'", "big"},
+		{"${{missing}} == null", true},
+		{"'a' + 1", "a1"},
+		{"'b' > 'a'", true},
+		{"len(${{tags}}) == 2", true},
+		{"upper(${{name}})", "JOB-42"},
+		{"exists(${{n}}) && !exists(${{missing}})", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := evaluateExpression(ctx, cache, tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpression_KeysDoNotCollide(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"a":   map[string]any{"b": 1.0},
+		"a-b": 2.0,
+		"a.b": 3.0,
+		"a_b": 4.0,
+	}))
+
+	got, err := evaluateExpression(ctx, cache, "${{a/b}} * 1000 + ${{a-b}} * 100 + ${{a.b}} * 10 + ${{a_b}}")
+	require.NoError(t, err)
+	assert.Equal(t, 1234.0, got)
+}
+
+func TestExpression_CompiledOnce(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"jobs": map[string]any{"a": "done", "b": "busy"},
+	}))
+
+	const expr = "${{jobs/${{1}}}} == 'done'"
+	for job, want := range map[string]bool{"a": true, "b": false} {
+		triggerCtx := context.WithValue(ctx, triggerVarsContextKey, []string{job})
+		got, err := evaluateExpression(triggerCtx, cache, expr)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, job)
+	}
+
+	first, err := compileExpression(expr)
+	require.NoError(t, err)
+	second, err := compileExpression(expr)
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+}
+
+func TestExpression_CacheBounded(t *testing.T) {
+	defer func(size int) { config.ExpressionCacheSize = size }(config.ExpressionCacheSize)
+	config.ExpressionCacheSize = 4
+
+	for i := 0; i < 20; i++ {
+		_, err := compileExpression(fmt.Sprintf("%d + 1", i))
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, exprCache.len(), 4)
+}
+
+func TestExpression_RegexCacheBounded(t *testing.T) {
+	defer func(size int) { config.ExpressionCacheSize = size }(config.ExpressionCacheSize)
+	config.ExpressionCacheSize = 4
+
+	ctx := context.Background()
+	cache := New()
+	for i := 0; i < 20; i++ {
+		require.NoError(t, cache.Create(ctx, map[string]any{fmt.Sprintf("p%d", i): fmt.Sprintf("^id-%d$", i)}))
+		got, err := evaluateExpression(ctx, cache, fmt.Sprintf("'id-%d' =~ ${{p%d}}", i, i))
+		require.NoError(t, err)
+		assert.Equal(t, true, got)
+	}
+	assert.LessOrEqual(t, regexCache.len(), 4)
+}
+
+func TestExpression_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		msg  string
+	}{
+		{"1 +", "position 4"},
+		{"(1 + 2", `expected ")"`},
+		{"1 ? 2", `expected ":"`},
+		{"a == 1", `unknown identifier "a"`},
+		{"${{a}} == 'x", "unterminated string"},
+		{"${{a == 1", "unterminated ${{"},
+		{"1 # 2", `position 3`},
+		{"", "empty expression"},
+		{"nope(1)", "undefined function: nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := compileExpression(tt.expr)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidExpression))
+			assert.Contains(t, err.Error(), tt.msg)
+		})
+	}
+}
+
+func TestExpression_OperandErrors(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{"s": "x", "n": 1.0}))
+
+	for _, expr := range []string{"${{s}} * 2", "!${{n}}", "${{n}} && true", "${{s}} < 1"} {
+		_, err := evaluateExpression(ctx, cache, expr)
+		require.Error(t, err, expr)
+		assert.True(t, errors.Is(err, ErrEvaluationError), expr)
+	}
+}
@@ -0,0 +1,277 @@
+package caches
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExpressionFunction is a function callable from expressions, e.g. lower(${{name}}).
+// Numbers are passed and should be returned as float64.
+type ExpressionFunction func(args ...any) (any, error)
+
+var (
+	functionsMu sync.RWMutex
+	functions   = map[string]ExpressionFunction{
+		"len":      fnLen,
+		"lower":    fnLower,
+		"upper":    fnUpper,
+		"trim":     fnTrim,
+		"contains": fnContains,
+		"matches":  fnMatches,
+		"now":      fnNow,
+		"abs":      fnAbs,
+		"round":    fnRound,
+		"type":     fnType,
+		"exists":   fnExists,
+	}
+
+	// regexCache holds the most recently used patterns compiled for
+	// matches(), =~ and !~, up to config.ExpressionCacheSize of them
+	regexCache = newCompileCache[*regexp.Regexp]()
+)
+
+// RegisterFunction makes fn callable by name from IF conditions and every other
+// expression context. Registering an existing name replaces it, including the
+// built-in functions. Arrays are passed as []any and objects as
+// map[string]any; missing keys are passed as nil.
+func RegisterFunction(name string, fn ExpressionFunction) error {
+	if name == "" || fn == nil {
+		return ErrInvalidFunction.Format(name)
+	}
+
+	functionsMu.Lock()
+	defer functionsMu.Unlock()
+	functions[name] = fn
+	return nil
+}
+
+// checkArgs validates the argument count.
+func checkArgs(name string, args []any, min, max int) ([]any, error) {
+	if len(args) < min || len(args) > max {
+		return nil, ErrFunctionArguments.Format(name, len(args))
+	}
+	return args, nil
+}
+
+func stringArg(name string, v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", ErrFunctionArgumentType.Format(name, "string", v)
+	}
+	return s, nil
+}
+
+func numberArg(name string, v any) (float64, error) {
+	f, ok := ToFloat64(v)
+	if !ok {
+		return 0, ErrFunctionArgumentType.Format(name, "number", v)
+	}
+	return f, nil
+}
+
+// len(x) - length of a string, array or object; 0 for null.
+func fnLen(args ...any) (any, error) {
+	args, err := checkArgs("len", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case nil:
+		return 0.0, nil
+	case string:
+		return float64(len([]rune(v))), nil
+	}
+	rv := reflect.ValueOf(args[0])
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(rv.Len()), nil
+	}
+	return nil, ErrFunctionArgumentType.Format("len", "string, array or object", args[0])
+}
+
+func fnLower(args ...any) (any, error) {
+	args, err := checkArgs("lower", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	s, err := stringArg("lower", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToLower(s), nil
+}
+
+func fnUpper(args ...any) (any, error) {
+	args, err := checkArgs("upper", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	s, err := stringArg("upper", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToUpper(s), nil
+}
+
+func fnTrim(args ...any) (any, error) {
+	args, err := checkArgs("trim", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	s, err := stringArg("trim", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// contains(haystack, needle) - substring test for strings, membership test for arrays.
+func fnContains(args ...any) (any, error) {
+	args, err := checkArgs("contains", args, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	switch haystack := args[0].(type) {
+	case string:
+		needle, err := stringArg("contains", args[1])
+		if err != nil {
+			return nil, err
+		}
+		return strings.Contains(haystack, needle), nil
+	case []any:
+		for _, v := range haystack {
+			if valuesEqual(v, args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case nil:
+		return false, nil
+	}
+	return nil, ErrFunctionArgumentType.Format("contains", "string or array", args[0])
+}
+
+// matches(s, pattern) - regular expression match.
+func fnMatches(args ...any) (any, error) {
+	args, err := checkArgs("matches", args, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	if args[0] == nil {
+		return false, nil
+	}
+	s, err := stringArg("matches", args[0])
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := stringArg("matches", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := compileRegex(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString(s), nil
+}
+
+// now() - current Unix time in seconds, with millisecond precision.
+func fnNow(args ...any) (any, error) {
+	args, err := checkArgs("now", args, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return float64(time.Now().UnixMilli()) / 1000, nil
+}
+
+func fnAbs(args ...any) (any, error) {
+	args, err := checkArgs("abs", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	f, err := numberArg("abs", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return math.Abs(f), nil
+}
+
+// round(x) or round(x, places)
+func fnRound(args ...any) (any, error) {
+	args, err := checkArgs("round", args, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	f, err := numberArg("round", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return math.Round(f), nil
+	}
+	places, err := numberArg("round", args[1])
+	if err != nil {
+		return nil, err
+	}
+	scale := math.Pow(10, math.Trunc(places))
+	return math.Round(f*scale) / scale, nil
+}
+
+// type(x) - one of "null", "bool", "number", "string", "array" or "object".
+func fnType(args ...any) (any, error) {
+	args, err := checkArgs("type", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return TypeName(args[0]), nil
+}
+
+// exists(x) - true when x is not null. exists(${{key}}) is rewritten to check
+// the key itself, so it is also true for keys explicitly set to null.
+func fnExists(args ...any) (any, error) {
+	args, err := checkArgs("exists", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return args[0] != nil, nil
+}
+
+// TypeName returns the JSON type of a value, as type(x) does.
+func TypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case map[string]any:
+		return "object"
+	}
+	if _, ok := ToFloat64(v); ok {
+		return "number"
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// valuesEqual compares two values, treating all numeric types as equal by
+// value. Numbers compare exactly, so int64 2^53+1 does not equal 2^53.
+func valuesEqual(a, b any) bool {
+	if IsNumber(a) && IsNumber(b) {
+		cmp, err := compareNumbers(a, b)
+		return err == nil && cmp == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,125 @@
+package caches
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctions_BuiltIns(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"name":   "Alice",
+		"email":  "alice@example.com",
+		"tags":   []any{"admin", "beta"},
+		"config": map[string]any{"a": 1.0, "b": 2.0},
+		"delta":  -3.456,
+		"empty":  nil,
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		expr     string
+		expected any
+	}{
+		{`len(${{name}})`, 5.0},
+		{`len(${{tags}})`, 2.0},
+		{`len(${{config}})`, 2.0},
+		{`len(${{missing}})`, 0.0},
+		{`lower(${{name}})`, "alice"},
+		{`upper(${{name}})`, "ALICE"},
+		{`contains(${{email}}, "@example")`, true},
+		{`contains(${{tags}}, "beta")`, true},
+		{`contains(${{tags}}, "gamma")`, false},
+		{`matches(${{email}}, "^[a-z]+@example[.]com$")`, true},
+		{`matches(${{name}}, "^[0-9]+$")`, false},
+		{`abs(${{delta}})`, 3.456},
+		{`round(${{delta}})`, -3.0},
+		{`round(${{delta}}, 2)`, -3.46},
+		{`type(${{name}})`, "string"},
+		{`type(${{tags}})`, "array"},
+		{`type(${{config}})`, "object"},
+		{`type(${{delta}})`, "number"},
+		{`type(${{missing}})`, "null"},
+		{`exists(${{name}})`, true},
+		{`exists(${{empty}})`, true},
+		{`exists(${{missing}})`, false},
+		{`len(lower(${{name}})) == 5 && exists(${{email}})`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			res := RETURN_EXPR(tt.expr).Do(ctx, cache)
+			assert.NoError(t, res.Error)
+			assert.Equal(t, tt.expected, res.Value)
+		})
+	}
+}
+
+func TestFunctions_Now(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	res := RETURN_EXPR("now()").Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.InDelta(t, float64(time.Now().Unix()), res.Value, 2)
+}
+
+func TestFunctions_Errors(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"n": 1.0}))
+
+	res := RETURN_EXPR(`lower(${{n}})`).Do(ctx, cache)
+	assert.Error(t, res.Error)
+	assert.Contains(t, res.Error.Error(), "lower() expects string")
+
+	res = RETURN_EXPR(`abs(1, 2)`).Do(ctx, cache)
+	assert.Error(t, res.Error)
+	assert.Contains(t, res.Error.Error(), "wrong number of arguments to abs()")
+
+	res = RETURN_EXPR(`nope(${{n}})`).Do(ctx, cache)
+	assert.Error(t, res.Error)
+	assert.Contains(t, res.Error.Error(), "invalid expression")
+}
+
+func TestFunctions_InCondition(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"status": "DONE"}))
+
+	res := IF(`lower(${{status}}) == "done"`, RETURN("yes"), RETURN("no")).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, "yes", res.Value)
+}
+
+func TestRegisterFunction(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	assert.NoError(t, cache.Create(ctx, map[string]any{"word": "level"}))
+
+	err := RegisterFunction("test_reverse", func(args ...any) (any, error) {
+		s := args[0].(string)
+		runes := []rune(s)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	})
+	assert.NoError(t, err)
+
+	res := IF(`test_reverse(${{word}}) == ${{word}}`, RETURN("palindrome"), RETURN("no")).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, "palindrome", res.Value)
+
+	err = RegisterFunction("", func(args ...any) (any, error) { return nil, nil })
+	assert.Error(t, err)
+	err = RegisterFunction("test_nil", nil)
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "test_nil"))
+}
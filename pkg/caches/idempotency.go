@@ -0,0 +1,104 @@
+package caches
+
+import (
+	"time"
+
+	"github.com/goodblaster/map-cache/internal/config"
+)
+
+// IdempotentResponse is the response to a request made with an idempotency
+// key, kept so that a retry gets the same response without running the
+// request again.
+type IdempotentResponse struct {
+	Status      int    // e.g. the HTTP status
+	ContentType string // e.g. the HTTP content type
+	Body        []byte
+}
+
+// idempotencyEntry is a request under an idempotency key: in progress until
+// its response is saved.
+type idempotencyEntry struct {
+	key         string
+	fingerprint string
+	response    *IdempotentResponse
+	expires     time.Time
+}
+
+// idempotencyStore holds a cache's idempotency keys. Keys expire in the order
+// they were added, since they all live for config.IdempotencyWindowMs.
+type idempotencyStore struct {
+	entries map[string]*idempotencyEntry
+	order   []*idempotencyEntry
+}
+
+// BeginIdempotent starts a request under an idempotency key. fingerprint
+// identifies the request, e.g. a hash of its method, path and body.
+//
+// If the key is new (or expired), it is reserved for this request and
+// BeginIdempotent returns nil; the caller must then call FinishIdempotent or
+// AbortIdempotent. If a request with the same fingerprint already finished,
+// its response is returned. Otherwise the key is in use: ErrIdempotencyKeyReused
+// if the fingerprint differs, or ErrIdempotencyKeyInProgress if the first
+// request hasn't finished.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) BeginIdempotent(key, fingerprint string) (*IdempotentResponse, error) {
+	store := &cache.idempotency
+	now := time.Now()
+	store.expire(now)
+
+	if entry, ok := store.entries[key]; ok {
+		switch {
+		case entry.fingerprint != fingerprint:
+			return nil, ErrIdempotencyKeyReused.Format(key)
+		case entry.response == nil:
+			return nil, ErrIdempotencyKeyInProgress.Format(key)
+		}
+		return entry.response, nil
+	}
+
+	if store.entries == nil {
+		store.entries = map[string]*idempotencyEntry{}
+	}
+	entry := &idempotencyEntry{
+		key:         key,
+		fingerprint: fingerprint,
+		expires:     now.Add(time.Duration(config.IdempotencyWindowMs) * time.Millisecond),
+	}
+	store.entries[key] = entry
+	store.order = append(store.order, entry)
+	return nil, nil
+}
+
+// FinishIdempotent saves the response to a request started with
+// BeginIdempotent, for retries within config.IdempotencyWindowMs.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) FinishIdempotent(key string, response IdempotentResponse) {
+	if entry, ok := cache.idempotency.entries[key]; ok {
+		entry.response = &response
+	}
+}
+
+// AbortIdempotent releases an idempotency key without saving a response, so
+// that the request can be retried, e.g. after it failed.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) AbortIdempotent(key string) {
+	if entry, ok := cache.idempotency.entries[key]; ok && entry.response == nil {
+		delete(cache.idempotency.entries, key)
+	}
+}
+
+// expire forgets the keys whose window has passed.
+func (store *idempotencyStore) expire(now time.Time) {
+	n := 0
+	for _, entry := range store.order {
+		if now.Before(entry.expires) {
+			break
+		}
+		// Unless the key was aborted and reused since
+		if store.entries[entry.key] == entry {
+			delete(store.entries, entry.key)
+		}
+		n++
+	}
+	store.order = store.order[n:]
+}
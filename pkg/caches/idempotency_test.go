@@ -0,0 +1,66 @@
+package caches
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency(t *testing.T) {
+	cache := New()
+	defer cache.Close()
+
+	saved, err := cache.BeginIdempotent("k1", "a")
+	require.NoError(t, err)
+	assert.Nil(t, saved)
+
+	// Still in progress
+	_, err = cache.BeginIdempotent("k1", "a")
+	assert.True(t, errors.Is(err, ErrIdempotencyKeyInProgress))
+
+	// Different request
+	_, err = cache.BeginIdempotent("k1", "b")
+	assert.True(t, errors.Is(err, ErrIdempotencyKeyReused))
+
+	cache.FinishIdempotent("k1", IdempotentResponse{Status: 200, Body: []byte("ok")})
+	saved, err = cache.BeginIdempotent("k1", "a")
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, []byte("ok"), saved.Body)
+
+	// Aborted keys can be used again
+	_, err = cache.BeginIdempotent("k2", "a")
+	require.NoError(t, err)
+	cache.AbortIdempotent("k2")
+	saved, err = cache.BeginIdempotent("k2", "b")
+	require.NoError(t, err)
+	assert.Nil(t, saved)
+
+	// Aborting a finished key keeps its response
+	cache.AbortIdempotent("k1")
+	saved, err = cache.BeginIdempotent("k1", "a")
+	require.NoError(t, err)
+	assert.NotNil(t, saved)
+}
+
+func TestIdempotency_Expires(t *testing.T) {
+	defer func(ms int64) { config.IdempotencyWindowMs = ms }(config.IdempotencyWindowMs)
+	config.IdempotencyWindowMs = 10
+
+	cache := New()
+	defer cache.Close()
+
+	_, err := cache.BeginIdempotent("k", "a")
+	require.NoError(t, err)
+	cache.FinishIdempotent("k", IdempotentResponse{Status: 200})
+
+	time.Sleep(20 * time.Millisecond)
+	saved, err := cache.BeginIdempotent("k", "b")
+	require.NoError(t, err)
+	assert.Nil(t, saved)
+	assert.Len(t, cache.idempotency.order, 1)
+}
@@ -0,0 +1,304 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/containers"
+)
+
+// Index is a secondary index of the items at the keys matching Source, such as
+// "sessions/*", by the value of Field within each item, such as "user_id" or
+// "profile/email". Indexes are kept up to date on every write, expiration,
+// restore and clear, and are used by queries whose where-expression compares
+// the field for equality (${{$item/user_id}} == 42), and by lookup().
+//
+// Source may hold "*", globs and named captures, but not "**", so that every
+// item is at the same depth. Items without the field are not indexed.
+type Index struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Field  string `json:"field"`
+}
+
+// IndexInfo describes an index and its size.
+type IndexInfo struct {
+	Index
+	Items  int `json:"items"`  // indexed items
+	Values int `json:"values"` // distinct field values
+}
+
+// secondaryIndex is an Index and its entries.
+type secondaryIndex struct {
+	Index
+	pattern []string                       // raw segments of Source, escapes kept
+	parsed  []containers.Segment           // Source, parsed
+	field   []string                       // Field, as keys
+	keys    map[string]string              // item key -> value id
+	values  map[string]map[string]struct{} // value id -> item keys
+}
+
+// CreateIndex adds an index and builds it from the current data.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) CreateIndex(ctx context.Context, index Index) (IndexInfo, error) {
+	if index.Name == "" || strings.ContainsAny(index.Name, "/ ") {
+		return IndexInfo{}, ErrInvalidIndexName.Format(index.Name)
+	}
+	if _, ok := cache.indexes[index.Name]; ok {
+		return IndexInfo{}, ErrIndexExists.Format(index.Name)
+	}
+	idx, err := newSecondaryIndex(index)
+	if err != nil {
+		return IndexInfo{}, err
+	}
+
+	idx.update(ctx, cache.cmap, nil)
+	cache.indexes[index.Name] = idx
+	return idx.info(), nil
+}
+
+func newSecondaryIndex(index Index) (*secondaryIndex, error) {
+	source, _, err := parseCapturePattern(index.Source)
+	if err != nil {
+		return nil, ErrInvalidIndex.Format(index.Name, err.Error())
+	}
+	parsed, err := containers.ParsePath(source)
+	if err != nil {
+		return nil, ErrInvalidIndex.Format(index.Name, err.Error())
+	}
+	hasPattern := false
+	for _, seg := range parsed {
+		if seg.Kind == containers.SegmentRecursive {
+			return nil, ErrInvalidIndex.Format(index.Name, `source cannot hold "**"`)
+		}
+		hasPattern = hasPattern || seg.IsPattern()
+	}
+	if !hasPattern {
+		return nil, ErrInvalidIndex.Format(index.Name, "source must include a wildcard")
+	}
+
+	if index.Field == "" {
+		return nil, ErrInvalidIndex.Format(index.Name, "field is required")
+	}
+	field, err := containers.ParsePath(index.Field)
+	if err != nil {
+		return nil, ErrInvalidIndex.Format(index.Name, err.Error())
+	}
+	for _, seg := range field {
+		if seg.IsPattern() {
+			return nil, ErrInvalidIndex.Format(index.Name, "field cannot be a pattern")
+		}
+	}
+
+	index.Source = source
+	index.Field = containers.CanonicalPath(index.Field)
+	return &secondaryIndex{
+		Index:   index,
+		pattern: containers.SplitPath(source),
+		parsed:  parsed,
+		field:   containers.SegmentKeys(field),
+		keys:    map[string]string{},
+		values:  map[string]map[string]struct{}{},
+	}, nil
+}
+
+// Indexes returns every index, by name.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) Indexes(ctx context.Context) []IndexInfo {
+	infos := make([]IndexInfo, 0, len(cache.indexes))
+	for _, name := range slices.Sorted(maps.Keys(cache.indexes)) {
+		infos = append(infos, cache.indexes[name].info())
+	}
+	return infos
+}
+
+// IndexByName returns an index.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) IndexByName(ctx context.Context, name string) (IndexInfo, error) {
+	idx, ok := cache.indexes[name]
+	if !ok {
+		return IndexInfo{}, ErrIndexNotFound.Format(name)
+	}
+	return idx.info(), nil
+}
+
+// DeleteIndex removes an index.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) DeleteIndex(ctx context.Context, name string) error {
+	if _, ok := cache.indexes[name]; !ok {
+		return ErrIndexNotFound.Format(name)
+	}
+	delete(cache.indexes, name)
+	return nil
+}
+
+// Lookup returns the keys of the items whose field equals value, in order,
+// using an index. The pattern is the index's source and field joined, e.g.
+// "sessions/*/user_id" for an index on "sessions/*" by "user_id".
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) Lookup(ctx context.Context, pattern string, value any) ([]string, error) {
+	pattern = containers.CanonicalPath(pattern)
+	for _, name := range slices.Sorted(maps.Keys(cache.indexes)) {
+		idx := cache.indexes[name]
+		if containers.CanonicalPath(idx.Source)+config.KeyDelimiter+idx.Field == pattern {
+			return idx.lookup(value), nil
+		}
+	}
+	return nil, ErrNoIndex.Format(pattern)
+}
+
+// findIndex returns an index on source and field, or nil.
+func (cache *Cache) findIndex(source, field string) *secondaryIndex {
+	source = containers.CanonicalPath(source)
+	field = containers.CanonicalPath(field)
+	for _, name := range slices.Sorted(maps.Keys(cache.indexes)) {
+		idx := cache.indexes[name]
+		if containers.CanonicalPath(idx.Source) == source && idx.Field == field {
+			return idx
+		}
+	}
+	return nil
+}
+
+// updateIndexes brings every index up to date after the value at path, and
+// everything under it, changed. A nil path rebuilds them.
+func (cache *Cache) updateIndexes(ctx context.Context, path []string) {
+	for _, idx := range cache.indexes {
+		idx.update(ctx, cache.cmap, path)
+	}
+}
+
+// cloneIndexes copies the indexes, for a view whose writes must not reach them.
+func (cache *Cache) cloneIndexes() map[string]*secondaryIndex {
+	clones := make(map[string]*secondaryIndex, len(cache.indexes))
+	for name, idx := range cache.indexes {
+		clone := *idx
+		clone.keys = maps.Clone(idx.keys)
+		clone.values = make(map[string]map[string]struct{}, len(idx.values))
+		for id, keys := range idx.values {
+			clone.values[id] = maps.Clone(keys)
+		}
+		clones[name] = &clone
+	}
+	return clones
+}
+
+// update re-indexes the items affected by a change at path: the item holding
+// path, or every item under it.
+func (idx *secondaryIndex) update(ctx context.Context, m containers.Map, path []string) {
+	n := len(idx.parsed)
+	for i := 0; i < min(len(path), n); i++ {
+		if !idx.segmentMatches(i, path[i]) {
+			return
+		}
+	}
+
+	if len(path) >= n {
+		idx.reindex(ctx, m, path[:n])
+		return
+	}
+
+	// An ancestor of the items changed: drop them all and index them again
+	prefix := containers.JoinPath(path...)
+	for key := range idx.keys {
+		if len(path) == 0 || key == prefix || strings.HasPrefix(key, prefix+config.KeyDelimiter) {
+			idx.remove(key)
+		}
+	}
+	parts := idx.pattern[len(path):]
+	if len(path) > 0 {
+		parts = append([]string{prefix}, parts...)
+	}
+	for key := range m.MatchKeys(ctx, strings.Join(parts, config.KeyDelimiter)) {
+		segments, err := containers.ParsePath(key)
+		if err != nil {
+			continue
+		}
+		idx.reindex(ctx, m, containers.SegmentKeys(segments))
+	}
+}
+
+func (idx *secondaryIndex) segmentMatches(i int, name string) bool {
+	seg := idx.parsed[i]
+	if !seg.IsPattern() {
+		return seg.Key == name
+	}
+	return seg.Matches(name, false) || seg.Matches(name, true)
+}
+
+// reindex indexes the item at itemPath by its current field value, or drops
+// it if it, or its field, is gone.
+func (idx *secondaryIndex) reindex(ctx context.Context, m containers.Map, itemPath []string) {
+	key := containers.JoinPath(itemPath...)
+	idx.remove(key)
+
+	value, err := m.Get(ctx, append(slices.Clone(itemPath), idx.field...)...)
+	if err != nil {
+		return
+	}
+	id := indexValueID(value)
+	idx.keys[key] = id
+	if idx.values[id] == nil {
+		idx.values[id] = map[string]struct{}{}
+	}
+	idx.values[id][key] = struct{}{}
+}
+
+func (idx *secondaryIndex) remove(key string) {
+	id, ok := idx.keys[key]
+	if !ok {
+		return
+	}
+	delete(idx.keys, key)
+	delete(idx.values[id], key)
+	if len(idx.values[id]) == 0 {
+		delete(idx.values, id)
+	}
+}
+
+func (idx *secondaryIndex) lookup(value any) []string {
+	return slices.Sorted(maps.Keys(idx.values[indexValueID(value)]))
+}
+
+func (idx *secondaryIndex) info() IndexInfo {
+	return IndexInfo{Index: idx.Index, Items: len(idx.keys), Values: len(idx.values)}
+}
+
+// indexValueID identifies a field value, so that values == finds equal share
+// an id: numbers by their exact value whatever their type, so that int64
+// values above 2^53 don't share an id with their neighbours.
+func indexValueID(value any) string {
+	if n, ok := toNumber(value); ok {
+		d, err := n.decimal()
+		if err != nil {
+			return "n:" + strconv.FormatFloat(n.f, 'g', -1, 64) // NaN and infinities
+		}
+		return "n:" + canonicalDecimal(d)
+	}
+	if s, ok := value.(string); ok {
+		return "s:" + s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "?"
+	}
+	return "j:" + string(data)
+}
+
+// canonicalDecimal writes d without trailing zeros after the point, so that
+// 42, 42.0 and {"$decimal": "42.00"} read the same.
+func canonicalDecimal(d Decimal) string {
+	str := d.String()
+	if strings.Contains(str, ".") {
+		str = strings.TrimRight(strings.TrimRight(str, "0"), ".")
+	}
+	if str == "-0" {
+		return "0"
+	}
+	return str
+}
@@ -0,0 +1,295 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/goodblaster/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIndexedCache(t *testing.T) *Cache {
+	cache := newQueryCache(t)
+	_, err := cache.CreateIndex(context.Background(), Index{Name: "by_status", Source: "sessions/*", Field: "status"})
+	require.NoError(t, err)
+	return cache
+}
+
+func TestCreateIndex(t *testing.T) {
+	cache := newIndexedCache(t)
+	ctx := context.Background()
+
+	info, err := cache.IndexByName(ctx, "by_status")
+	require.NoError(t, err)
+	assert.Equal(t, IndexInfo{Index: Index{Name: "by_status", Source: "sessions/*", Field: "status"}, Items: 4, Values: 2}, info)
+
+	keys, err := cache.Lookup(ctx, "sessions/*/status", "active")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sessions/s1", "sessions/s3", "sessions/s4"}, keys)
+
+	_, err = cache.Lookup(ctx, "sessions/*/region", "eu")
+	assert.True(t, errors.Is(err, ErrNoIndex))
+
+	require.NoError(t, cache.DeleteIndex(ctx, "by_status"))
+	assert.Empty(t, cache.Indexes(ctx))
+	assert.True(t, errors.Is(cache.DeleteIndex(ctx, "by_status"), ErrIndexNotFound))
+}
+
+func TestCreateIndex_Invalid(t *testing.T) {
+	cache := newIndexedCache(t)
+	ctx := context.Background()
+
+	_, err := cache.CreateIndex(ctx, Index{Name: "by_status", Source: "sessions/*", Field: "user"})
+	assert.True(t, errors.Is(err, ErrIndexExists))
+
+	_, err = cache.CreateIndex(ctx, Index{Name: "a/b", Source: "sessions/*", Field: "user"})
+	assert.True(t, errors.Is(err, ErrInvalidIndexName))
+
+	for _, index := range []Index{
+		{Name: "x", Source: "sessions/s1", Field: "user"},
+		{Name: "x", Source: "**", Field: "user"},
+		{Name: "x", Source: "sessions/*", Field: ""},
+		{Name: "x", Source: "sessions/*", Field: "*"},
+	} {
+		_, err = cache.CreateIndex(ctx, index)
+		assert.True(t, errors.Is(err, ErrInvalidIndex), index)
+	}
+}
+
+func TestIndex_NumbersAndNestedFields(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"users": map[string]any{
+			"u1": map[string]any{"profile": map[string]any{"age": 42}},
+			"u2": map[string]any{"profile": map[string]any{"age": 42.0}},
+			"u3": map[string]any{"profile": map[string]any{"age": "42"}},
+			"u4": map[string]any{"name": "no profile"},
+		},
+	}))
+
+	info, err := cache.CreateIndex(ctx, Index{Name: "by_age", Source: "users/*", Field: "profile/age"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, info.Items)
+
+	keys, err := cache.Lookup(ctx, "users/*/profile/age", 42)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users/u1", "users/u2"}, keys)
+
+	keys, err = cache.Lookup(ctx, "users/*/profile/age", "42")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users/u3"}, keys)
+}
+
+func TestIndex_LargeIntegers(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"orders": map[string]any{
+			"a": map[string]any{"id": int64(9007199254740992)},
+			"b": map[string]any{"id": int64(9007199254740993)},
+			"c": map[string]any{"id": mustDecimal(t, "9007199254740993.00")},
+		},
+	}))
+	_, err := cache.CreateIndex(ctx, Index{Name: "by_id", Source: "orders/*", Field: "id"})
+	require.NoError(t, err)
+
+	keys, err := cache.Lookup(ctx, "orders/*/id", int64(9007199254740993))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders/b", "orders/c"}, keys)
+}
+
+func TestIndex_KeptUpToDate(t *testing.T) {
+	cache := newIndexedCache(t)
+	ctx := context.Background()
+
+	lookup := func(value string) []string {
+		keys, err := cache.Lookup(ctx, "sessions/*/status", value)
+		require.NoError(t, err)
+		return keys
+	}
+
+	// Change a field
+	require.NoError(t, cache.Replace(ctx, "sessions/s1/status", "idle"))
+	assert.Equal(t, []string{"sessions/s3", "sessions/s4"}, lookup("active"))
+	assert.Equal(t, []string{"sessions/s1", "sessions/s2"}, lookup("idle"))
+
+	// Replace an item
+	require.NoError(t, cache.Replace(ctx, "sessions/s2", map[string]any{"status": "active"}))
+	assert.Equal(t, []string{"sessions/s2", "sessions/s3", "sessions/s4"}, lookup("active"))
+
+	// Create an item
+	require.NoError(t, cache.Create(ctx, map[string]any{"sessions/s5": map[string]any{"status": "idle"}}))
+	assert.Equal(t, []string{"sessions/s1", "sessions/s5"}, lookup("idle"))
+
+	// Delete a field, then an item
+	require.NoError(t, cache.Delete(ctx, "sessions/s5/status"))
+	assert.Equal(t, []string{"sessions/s1"}, lookup("idle"))
+	require.NoError(t, cache.Delete(ctx, "sessions/s3"))
+	assert.Equal(t, []string{"sessions/s2", "sessions/s4"}, lookup("active"))
+
+	// Replace an ancestor
+	require.NoError(t, cache.Replace(ctx, "sessions", map[string]any{
+		"s9": map[string]any{"status": "active"},
+	}))
+	assert.Equal(t, []string{"sessions/s9"}, lookup("active"))
+	assert.Empty(t, lookup("idle"))
+
+	// Clear
+	cache.Clear()
+	assert.Empty(t, lookup("active"))
+	info, err := cache.IndexByName(ctx, "by_status")
+	require.NoError(t, err)
+	assert.Equal(t, 0, info.Items)
+}
+
+func TestIndex_ArrayItems(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"jobs": []any{
+			map[string]any{"state": "done"},
+			map[string]any{"state": "queued"},
+			map[string]any{"state": "done"},
+		},
+	}))
+	_, err := cache.CreateIndex(ctx, Index{Name: "by_state", Source: "jobs/*", Field: "state"})
+	require.NoError(t, err)
+
+	keys, err := cache.Lookup(ctx, "jobs/*/state", "done")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jobs/0", "jobs/2"}, keys)
+
+	// Removing an element shifts the ones after it
+	require.NoError(t, cache.Delete(ctx, "jobs/0"))
+	keys, err = cache.Lookup(ctx, "jobs/*/state", "done")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jobs/1"}, keys)
+
+	require.NoError(t, cache.ArrayAppend(ctx, "jobs", map[string]any{"state": "done"}))
+	keys, err = cache.Lookup(ctx, "jobs/*/state", "done")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jobs/1", "jobs/2"}, keys)
+}
+
+func TestIndex_Expiration(t *testing.T) {
+	cache := newIndexedCache(t)
+	ctx := context.Background()
+
+	require.NoError(t, cache.SetKeyTTL(ctx, "sessions/s1", 10))
+
+	assert.Eventually(t, func() bool {
+		cache.Acquire("test")
+		defer cache.Release("test")
+		keys, err := cache.Lookup(ctx, "sessions/*/status", "active")
+		return err == nil && len(keys) == 2
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestIndex_DryRunDoesNotLeak(t *testing.T) {
+	cache := newIndexedCache(t)
+	ctx := context.Background()
+
+	result := cache.DryRun(ctx, REPLACE("sessions/s2/status", "active"))
+	assert.Empty(t, result.Error)
+
+	keys, err := cache.Lookup(ctx, "sessions/*/status", "active")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sessions/s1", "sessions/s3", "sessions/s4"}, keys)
+}
+
+func TestIndex_BackupRestore(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, AddCache("index-backup"))
+	defer DeleteCache("index-backup")
+
+	cache, err := FetchCache("index-backup")
+	require.NoError(t, err)
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"sessions": map[string]any{"s1": map[string]any{"user_id": 42}},
+	}))
+	_, err = cache.CreateIndex(ctx, Index{Name: "by_user", Source: "sessions/*", Field: "user_id"})
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "backup.json")
+	require.NoError(t, Backup(ctx, "index-backup", file))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var container RestoreContainer
+	require.NoError(t, json.Unmarshal(data, &container))
+	assert.Equal(t, []Index{{Name: "by_user", Source: "sessions/*", Field: "user_id"}}, container.Indexes)
+
+	require.NoError(t, Restore(ctx, "index-restored", file))
+	defer DeleteCache("index-restored")
+
+	restored, err := FetchCache("index-restored")
+	require.NoError(t, err)
+	keys, err := restored.Lookup(ctx, "sessions/*/user_id", 42)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sessions/s1"}, keys)
+}
+
+func TestIndex_UsedByQuery(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+	sessions := map[string]any{}
+	for i := 0; i < 100; i++ {
+		sessions[strconv.Itoa(i)] = map[string]any{"user_id": float64(i % 50)}
+	}
+	require.NoError(t, cache.Create(ctx, map[string]any{"sessions": sessions}))
+	_, err := cache.CreateIndex(ctx, Index{Name: "by_user", Source: "sessions/*", Field: "user_id"})
+	require.NoError(t, err)
+
+	// Only the two matching items are visited
+	limited := WithExecutionLimits(ctx, ExecutionLimits{MaxKeysVisited: 5})
+	result, err := cache.Query(limited, Query{
+		Source: "sessions/*",
+		Where:  `${{$item/user_id}} == 7`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Total)
+	assert.Equal(t, "sessions/57", result.Items[0].Key)
+	assert.Equal(t, "sessions/7", result.Items[1].Key)
+
+	// The rest of the expression still applies
+	result, err = cache.Query(limited, Query{
+		Source: "sessions/*",
+		Where:  `${{$item/user_id}} == 7 && ${{$key}} == "sessions/7"`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Total)
+}
+
+func TestIndex_LookupExpression(t *testing.T) {
+	cache := New()
+	ctx := context.Background()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"sessions": map[string]any{
+			"a": map[string]any{"user_id": 42},
+			"b": map[string]any{"user_id": 7},
+			"c": map[string]any{"user_id": 42},
+		},
+		"user": 42,
+	}))
+
+	res := cache.Execute(ctx, RETURN_EXPR(`lookup(${{sessions/*/user_id}}, ${{user}})`))
+	assert.True(t, errors.Is(res.Error, ErrNoIndex))
+
+	_, err := cache.CreateIndex(ctx, Index{Name: "by_user", Source: "sessions/*", Field: "user_id"})
+	require.NoError(t, err)
+
+	res = cache.Execute(ctx, RETURN_EXPR(`lookup(${{sessions/*/user_id}}, ${{user}})`))
+	require.NoError(t, res.Error)
+	assert.Equal(t, []any{[]any{"sessions/a", "sessions/c"}}, res.Value)
+
+	res = cache.Execute(ctx, RETURN_EXPR(`len(lookup(${{sessions/*/user_id}}, 1))`))
+	require.NoError(t, res.Error)
+	assert.Equal(t, []any{0.0}, res.Value)
+}
@@ -7,6 +7,8 @@ var (
 	// InterpolationPattern matches ${{key}} syntax with optional whitespace
 	InterpolationPattern = regexp.MustCompile(`\${{\s*([^}]+?)\s*}}`)
 
-	// AggregationPattern matches any()/all() function calls with comparisons
-	AggregationPattern = regexp.MustCompile(`\b(any|all)\(\s*\${{\s*([^}]+?)\s*}}\s*([!<>=]=?|==)\s*([^\)]+?)\s*\)`)
+	// LoopPattern matches the ${{pattern}} of a FOR loop. Unlike
+	// InterpolationPattern it allows braces, for named captures such as
+	// ${{jobs/{id}/status}}.
+	LoopPattern = regexp.MustCompile(`\${{\s*(.+)\s*}}`)
 )
@@ -0,0 +1,361 @@
+package caches
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/google/uuid"
+)
+
+// JobStatus is the state of a background job.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"    // waiting for the cache lock
+	JobRunning   JobStatus = "running"   // executing
+	JobSucceeded JobStatus = "succeeded" // all commands completed
+	JobFailed    JobStatus = "failed"    // a command failed or the job timed out
+	JobCanceled  JobStatus = "canceled"  // canceled with CancelJob
+)
+
+// Job is a snapshot of a background command job. See SubmitJob.
+type Job struct {
+	Id     string    `json:"id"`
+	Cache  string    `json:"cache"`
+	Status JobStatus `json:"status"`
+
+	// Result holds the results of the top-level commands completed so far,
+	// so a job that fails or is canceled still reports the work it did.
+	Result []any  `json:"result"`
+	Error  string `json:"error,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job has stopped, for whatever reason.
+func (job Job) Finished() bool {
+	return job.Status != JobQueued && job.Status != JobRunning
+}
+
+// jobEntry is a submitted job and what is needed to run and cancel it.
+type jobEntry struct {
+	mu       sync.Mutex
+	jobs     *jobRegistry
+	job      Job
+	commands []Command
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func (entry *jobEntry) snapshot() Job {
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	job := entry.job
+	job.Result = append([]any{}, entry.job.Result...)
+	return job
+}
+
+// finish records the end of the job, unless it has already ended (a queued
+// job is canceled immediately). It reports whether it did.
+func (entry *jobEntry) finish(status JobStatus, err error) bool {
+	entry.mu.Lock()
+	if entry.job.Finished() {
+		entry.mu.Unlock()
+		return false
+	}
+	now := time.Now()
+	entry.job.Status = status
+	entry.job.FinishedAt = &now
+	if err != nil {
+		entry.job.Error = err.Error()
+	}
+	entry.mu.Unlock()
+
+	entry.cancel()
+	entry.jobs.retain(entry.job.Id)
+	close(entry.done)
+	return true
+}
+
+// stop cancels the job. A queued job is finished at once; a running job
+// stops before its next command.
+func (entry *jobEntry) stop() {
+	// Cancel first, so that a job starting meanwhile stops at once.
+	entry.cancel()
+	entry.mu.Lock()
+	queued := entry.job.Status == JobQueued
+	entry.mu.Unlock()
+	if queued {
+		entry.finish(JobCanceled, nil)
+	}
+}
+
+// jobRegistry holds the jobs of a cache. It has its own lock, since jobs are
+// submitted and polled without the cache lock.
+type jobRegistry struct {
+	sync.Mutex
+	byId     map[string]*jobEntry
+	finished []string // ids of finished jobs, oldest first
+	active   int      // queued or running jobs
+	stopped  bool     // set when the cache is deleted
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{byId: map[string]*jobEntry{}}
+}
+
+// add registers a new job, unless the cache is deleted or already has
+// config.JobMaxActive queued or running jobs.
+func (jobs *jobRegistry) add(cacheName string, entry *jobEntry) error {
+	jobs.Lock()
+	defer jobs.Unlock()
+	if jobs.stopped {
+		return ErrCacheNotFound
+	}
+	if config.JobMaxActive > 0 && jobs.active >= config.JobMaxActive {
+		return ErrTooManyJobs.Format(cacheName, config.JobMaxActive)
+	}
+	jobs.byId[entry.job.Id] = entry
+	jobs.active++
+	return nil
+}
+
+// retain adds a finished job to the retained jobs, forgetting the oldest
+// finished jobs beyond config.JobRetention.
+func (jobs *jobRegistry) retain(id string) {
+	jobs.Lock()
+	defer jobs.Unlock()
+	if jobs.stopped {
+		return
+	}
+	jobs.active--
+	jobs.finished = append(jobs.finished, id)
+	for len(jobs.finished) > 0 && len(jobs.finished) > config.JobRetention {
+		delete(jobs.byId, jobs.finished[0])
+		jobs.finished = jobs.finished[1:]
+	}
+}
+
+func (jobs *jobRegistry) fetch(id string) (*jobEntry, error) {
+	jobs.Lock()
+	defer jobs.Unlock()
+	entry, ok := jobs.byId[id]
+	if !ok {
+		return nil, ErrJobNotFound.Format(id)
+	}
+	return entry, nil
+}
+
+// stopJobs cancels the cache's jobs and forgets them, when the cache is
+// deleted.
+func (cache *Cache) stopJobs() {
+	jobs := cache.jobs
+	jobs.Lock()
+	entries := jobs.byId
+	jobs.byId = map[string]*jobEntry{}
+	jobs.finished = nil
+	jobs.active = 0
+	jobs.stopped = true
+	jobs.Unlock()
+
+	for _, entry := range entries {
+		entry.stop()
+	}
+}
+
+func fetchJob(cacheName, id string) (*jobEntry, error) {
+	cache, err := FetchCache(cacheName)
+	if err != nil {
+		return nil, err
+	}
+	return cache.jobs.fetch(id)
+}
+
+// SubmitJob queues commands for execution against the named cache in the
+// background and returns the queued job. Unlike a request, the job is not
+// bounded by COMMAND_TIMEOUT_MS but by JOB_TIMEOUT_MS, and by the execution
+// limits of the cache and of ctx (see WithExecutionLimits). Caches the
+// commands use with @name: keys are locked with it. A cache has at most
+// JOB_MAX_ACTIVE queued or running jobs; beyond that, SubmitJob fails with
+// ErrTooManyJobs.
+//
+// Jobs belong to their cache: poll the job with GetJob or WaitJob and stop it
+// with CancelJob, giving the same cache name. Deleting the cache cancels its
+// jobs.
+func SubmitJob(ctx context.Context, cacheName string, commands ...Command) (Job, error) {
+	cache, err := FetchCache(cacheName)
+	if err != nil {
+		return Job{}, err
+	}
+
+	// The job outlives ctx, which is typically a request's, but keeps its
+	// execution limits.
+	jobCtx := context.Background()
+	if limits, ok := ctx.Value(executionLimitsContextKey).(ExecutionLimits); ok {
+		jobCtx = WithExecutionLimits(jobCtx, limits)
+	}
+	var cancel context.CancelFunc
+	if config.JobTimeoutMs > 0 {
+		jobCtx, cancel = context.WithTimeout(jobCtx, time.Duration(config.JobTimeoutMs)*time.Millisecond)
+	} else {
+		jobCtx, cancel = context.WithCancel(jobCtx)
+	}
+
+	entry := &jobEntry{
+		jobs: cache.jobs,
+		job: Job{
+			Id:        uuid.New().String(),
+			Cache:     cacheName,
+			Status:    JobQueued,
+			Result:    []any{},
+			CreatedAt: time.Now(),
+		},
+		commands: commands,
+		ctx:      jobCtx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	if err := cache.jobs.add(cacheName, entry); err != nil {
+		cancel()
+		return Job{}, err
+	}
+
+	go entry.run()
+	return entry.snapshot(), nil
+}
+
+// GetJob returns the current state of a job of the named cache.
+func GetJob(cacheName, id string) (Job, error) {
+	entry, err := fetchJob(cacheName, id)
+	if err != nil {
+		return Job{}, err
+	}
+	return entry.snapshot(), nil
+}
+
+// WaitJob waits for a job to finish, or for ctx to be done, and returns its
+// state.
+func WaitJob(ctx context.Context, cacheName, id string) (Job, error) {
+	entry, err := fetchJob(cacheName, id)
+	if err != nil {
+		return Job{}, err
+	}
+	select {
+	case <-entry.done:
+		return entry.snapshot(), nil
+	case <-ctx.Done():
+		return entry.snapshot(), ctx.Err()
+	}
+}
+
+// CancelJob cancels a job. A queued job is canceled at once; a running job
+// stops before its next command, keeping the changes already made. Canceling
+// a finished job has no effect.
+func CancelJob(cacheName, id string) (Job, error) {
+	entry, err := fetchJob(cacheName, id)
+	if err != nil {
+		return Job{}, err
+	}
+	entry.stop()
+	return entry.snapshot(), nil
+}
+
+// run executes the job once it holds the locks of its caches.
+func (entry *jobEntry) run() {
+	tag := "job-" + entry.job.Id
+	locked, names := acquireWithReferences(tag, entry.job.Cache, entry.commands...)
+	defer ReleaseCaches(tag, locked)
+
+	cache, ok := locked[entry.job.Cache]
+	if !ok {
+		entry.finish(JobFailed, ErrCacheNotFound)
+		return
+	}
+
+	ctx := entry.ctx
+	if len(names) > 0 {
+		ctx = WithCaches(ctx, locked)
+	}
+
+	steps := make([]Command, len(entry.commands))
+	for i, cmd := range entry.commands {
+		steps[i] = jobStep{Command: cmd, entry: entry}
+	}
+
+	start := time.Now()
+	entry.mu.Lock()
+	if entry.job.Finished() || entry.ctx.Err() != nil {
+		// Canceled or timed out while queued
+		entry.mu.Unlock()
+		err := entry.ctx.Err()
+		entry.finish(jobStatus(err), jobError(err))
+		return
+	}
+	entry.job.StartedAt = &start
+	entry.job.Status = JobRunning
+	entry.mu.Unlock()
+
+	res := cache.Execute(ctx, steps...)
+	duration := time.Since(start)
+
+	status := JobSucceeded
+	err := res.Error
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		status = jobStatus(err)
+	}
+	if duration.Milliseconds() > config.CommandLongThresholdMs {
+		cache.opStats.record(OperationExecution{
+			Timestamp: start,
+			Duration:  duration,
+			Operation: "JOB",
+			Success:   status == JobSucceeded,
+			TimedOut:  errors.Is(err, context.DeadlineExceeded),
+			JobId:     entry.job.Id,
+		})
+	}
+	entry.finish(status, jobError(err))
+}
+
+func jobStatus(err error) JobStatus {
+	if errors.Is(err, context.Canceled) {
+		return JobCanceled
+	}
+	return JobFailed
+}
+
+func jobError(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrJobTimeout.Format(config.JobTimeoutMs)
+	}
+	return err
+}
+
+// jobStep runs one of a job's top-level commands and adds its result to the
+// job as soon as it completes.
+type jobStep struct {
+	Command
+	entry *jobEntry
+}
+
+func (step jobStep) Do(ctx context.Context, cache *Cache) CmdResult {
+	res := step.Command.Do(ctx, cache)
+	if res.Error == nil {
+		step.entry.mu.Lock()
+		step.entry.job.Result = append(step.entry.job.Result, res.Value)
+		step.entry.mu.Unlock()
+	}
+	return res
+}
@@ -0,0 +1,258 @@
+package caches
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addJobCache(t *testing.T, name string, data map[string]any) *Cache {
+	t.Helper()
+	require.NoError(t, AddCache(name))
+	t.Cleanup(func() { _ = DeleteCache(name) })
+	cache, err := FetchCache(name)
+	require.NoError(t, err)
+	require.NoError(t, cache.Create(context.Background(), data))
+	return cache
+}
+
+// unboundedLoops lets WHILE loops run until the job is stopped.
+func unboundedLoops(t *testing.T) {
+	t.Helper()
+	iterations := config.CommandMaxLoopIterations
+	config.CommandMaxLoopIterations = 0
+	t.Cleanup(func() { config.CommandMaxLoopIterations = iterations })
+}
+
+func waitJob(t *testing.T, cacheName, id string) Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := WaitJob(ctx, cacheName, id)
+	require.NoError(t, err)
+	return job
+}
+
+func TestJobs_Succeeds(t *testing.T) {
+	ctx := context.Background()
+	cache := addJobCache(t, "jobs-succeeds", map[string]any{"n": 1.0})
+
+	job, err := SubmitJob(ctx, "jobs-succeeds", INC("n", 1), GET("n"))
+	require.NoError(t, err)
+	assert.Equal(t, "jobs-succeeds", job.Cache)
+
+	job = waitJob(t, "jobs-succeeds", job.Id)
+	assert.Equal(t, JobSucceeded, job.Status)
+	assert.Equal(t, []any{2.0, 2.0}, job.Result)
+	assert.Empty(t, job.Error)
+	assert.NotNil(t, job.StartedAt)
+	assert.NotNil(t, job.FinishedAt)
+
+	val, err := cache.Get(ctx, "n")
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, val)
+}
+
+func TestJobs_FailureKeepsPartialResults(t *testing.T) {
+	ctx := context.Background()
+	addJobCache(t, "jobs-fails", map[string]any{"n": 1.0})
+
+	job, err := SubmitJob(ctx, "jobs-fails", INC("n", 1), INC("missing", 1), INC("n", 1))
+	require.NoError(t, err)
+
+	job = waitJob(t, "jobs-fails", job.Id)
+	assert.Equal(t, JobFailed, job.Status)
+	assert.Equal(t, []any{2.0}, job.Result)
+	assert.NotEmpty(t, job.Error)
+}
+
+func TestJobs_CancelQueued(t *testing.T) {
+	ctx := context.Background()
+	cache := addJobCache(t, "jobs-queued", map[string]any{"n": 1.0})
+
+	// Hold the lock so that the job stays queued.
+	cache.Acquire("test")
+	job, err := SubmitJob(ctx, "jobs-queued", INC("n", 1))
+	require.NoError(t, err)
+	assert.Equal(t, JobQueued, job.Status)
+
+	job, err = CancelJob("jobs-queued", job.Id)
+	require.NoError(t, err)
+	assert.Equal(t, JobCanceled, job.Status)
+	cache.Release("test")
+
+	job = waitJob(t, "jobs-queued", job.Id)
+	assert.Equal(t, JobCanceled, job.Status)
+	assert.Nil(t, job.StartedAt)
+
+	cache.Acquire("test")
+	defer cache.Release("test")
+	val, err := cache.Get(ctx, "n")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, val)
+}
+
+func TestJobs_CancelRunning(t *testing.T) {
+	ctx := context.Background()
+	unboundedLoops(t)
+	addJobCache(t, "jobs-running", map[string]any{"n": 0.0})
+
+	job, err := SubmitJob(ctx, "jobs-running", WHILE("true", INC("n", 1)))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		job, err := GetJob("jobs-running", job.Id)
+		return err == nil && job.Status == JobRunning
+	}, 5*time.Second, time.Millisecond)
+
+	_, err = CancelJob("jobs-running", job.Id)
+	require.NoError(t, err)
+
+	job = waitJob(t, "jobs-running", job.Id)
+	assert.Equal(t, JobCanceled, job.Status)
+	assert.Empty(t, job.Error)
+}
+
+func TestJobs_Timeout(t *testing.T) {
+	defer func(ms int64) { config.JobTimeoutMs = ms }(config.JobTimeoutMs)
+	config.JobTimeoutMs = 20
+	unboundedLoops(t)
+
+	addJobCache(t, "jobs-timeout", map[string]any{"n": 0.0})
+	job, err := SubmitJob(context.Background(), "jobs-timeout", WHILE("true", INC("n", 1)))
+	require.NoError(t, err)
+
+	job = waitJob(t, "jobs-timeout", job.Id)
+	assert.Equal(t, JobFailed, job.Status)
+	assert.Contains(t, job.Error, "timed out")
+}
+
+func TestJobs_Limits(t *testing.T) {
+	addJobCache(t, "jobs-limits", map[string]any{"n": 0.0})
+
+	ctx := WithExecutionLimits(context.Background(), ExecutionLimits{MaxSteps: 10})
+	job, err := SubmitJob(ctx, "jobs-limits", WHILE("true", INC("n", 1)))
+	require.NoError(t, err)
+
+	job = waitJob(t, "jobs-limits", job.Id)
+	assert.Equal(t, JobFailed, job.Status)
+	assert.Contains(t, job.Error, "max_steps")
+}
+
+func TestJobs_Retention(t *testing.T) {
+	defer func(n int) { config.JobRetention = n }(config.JobRetention)
+	config.JobRetention = 2
+
+	addJobCache(t, "jobs-retention", map[string]any{"n": 0.0})
+	var ids []string
+	for i := 0; i < 3; i++ {
+		job, err := SubmitJob(context.Background(), "jobs-retention", INC("n", 1))
+		require.NoError(t, err)
+		waitJob(t, "jobs-retention", job.Id)
+		ids = append(ids, job.Id)
+	}
+
+	_, err := GetJob("jobs-retention", ids[0])
+	assert.True(t, errors.Is(err, ErrJobNotFound))
+	for _, id := range ids[1:] {
+		_, err := GetJob("jobs-retention", id)
+		assert.NoError(t, err)
+	}
+}
+
+func TestJobs_CacheNotFound(t *testing.T) {
+	_, err := SubmitJob(context.Background(), "jobs-no-such-cache", NOOP())
+	assert.True(t, errors.Is(err, ErrCacheNotFound))
+}
+
+func TestJobs_RecordsLongOperations(t *testing.T) {
+	defer func(ms int64) { config.CommandLongThresholdMs = ms }(config.CommandLongThresholdMs)
+	config.CommandLongThresholdMs = -1
+
+	cache := addJobCache(t, "jobs-long", map[string]any{"n": 0.0})
+	job, err := SubmitJob(context.Background(), "jobs-long", INC("n", 1))
+	require.NoError(t, err)
+	waitJob(t, "jobs-long", job.Id)
+
+	history := cache.OperationStatsSnapshot().RecentHistory
+	require.Len(t, history, 1)
+	assert.Equal(t, job.Id, history[0].JobId)
+	assert.True(t, history[0].Success)
+}
+
+func TestJobs_BelongToTheirCache(t *testing.T) {
+	addJobCache(t, "jobs-owner", map[string]any{"n": 0.0})
+	addJobCache(t, "jobs-other", map[string]any{"n": 0.0})
+
+	job, err := SubmitJob(context.Background(), "jobs-owner", INC("n", 1))
+	require.NoError(t, err)
+	waitJob(t, "jobs-owner", job.Id)
+
+	_, err = GetJob("jobs-other", job.Id)
+	assert.True(t, errors.Is(err, ErrJobNotFound))
+	_, err = CancelJob("jobs-other", job.Id)
+	assert.True(t, errors.Is(err, ErrJobNotFound))
+}
+
+func TestJobs_DeleteCache(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, AddCache("jobs-deleted"))
+	cache, err := FetchCache("jobs-deleted")
+	require.NoError(t, err)
+
+	// Hold the lock so that the job stays queued.
+	cache.Acquire("test")
+	job, err := SubmitJob(ctx, "jobs-deleted", INC("n", 1))
+	require.NoError(t, err)
+	entry, err := cache.jobs.fetch(job.Id)
+	require.NoError(t, err)
+
+	require.NoError(t, DeleteCache("jobs-deleted"))
+	cache.Release("test")
+
+	<-entry.done
+	assert.Equal(t, JobCanceled, entry.snapshot().Status)
+	assert.Empty(t, cache.jobs.byId)
+
+	_, err = GetJob("jobs-deleted", job.Id)
+	assert.True(t, errors.Is(err, ErrCacheNotFound))
+
+	// A cache being deleted takes no new jobs
+	assert.True(t, errors.Is(cache.jobs.add("jobs-deleted", &jobEntry{}), ErrCacheNotFound))
+}
+
+func TestJobs_MaxActive(t *testing.T) {
+	defer func(n int) { config.JobMaxActive = n }(config.JobMaxActive)
+	config.JobMaxActive = 2
+
+	ctx := context.Background()
+	cache := addJobCache(t, "jobs-max-active", map[string]any{"n": 0.0})
+
+	// Hold the lock so that the jobs stay queued.
+	cache.Acquire("test")
+	var ids []string
+	for i := 0; i < 2; i++ {
+		job, err := SubmitJob(ctx, "jobs-max-active", INC("n", 1))
+		require.NoError(t, err)
+		ids = append(ids, job.Id)
+	}
+	_, err := SubmitJob(ctx, "jobs-max-active", INC("n", 1))
+	assert.True(t, errors.Is(err, ErrTooManyJobs))
+	assert.Contains(t, err.Error(), "2 queued or running jobs")
+
+	// Finished jobs don't count
+	_, err = CancelJob("jobs-max-active", ids[0])
+	require.NoError(t, err)
+	job, err := SubmitJob(ctx, "jobs-max-active", INC("n", 1))
+	require.NoError(t, err)
+	cache.Release("test")
+
+	for _, id := range []string{ids[1], job.Id} {
+		assert.Equal(t, JobSucceeded, waitJob(t, "jobs-max-active", id).Status)
+	}
+}
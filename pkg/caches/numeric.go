@@ -0,0 +1,643 @@
+package caches
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/goodblaster/errors"
+)
+
+// Numbers in a cache are int64 for integers, float64 for other numbers, or a
+// Decimal for exact arithmetic on values such as money. Adding two integers
+// gives an integer, failing on overflow; adding a Decimal to any number gives
+// a Decimal; anything else gives a float64.
+
+// Decimal is an arbitrary-precision decimal number. It is written in JSON as
+// {"$decimal": "19.99"}, and values of that form decode as Decimals. The zero
+// value is 0.
+type Decimal struct {
+	unscaled *big.Int // nil for 0
+	scale    int32    // digits after the decimal point
+}
+
+// ParseDecimal parses a decimal such as "19.99", "-0.5" or "1e3".
+func ParseDecimal(s string) (Decimal, error) {
+	str := s
+	exp := int64(0)
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(str[i+1:], 10, 32)
+		if err != nil || e > maxDecimalExponent || e < -maxDecimalExponent {
+			return Decimal{}, ErrInvalidDecimal.Format(s)
+		}
+		exp = e
+		str = str[:i]
+	}
+
+	digits := str
+	if digits != "" && (digits[0] == '-' || digits[0] == '+') {
+		digits = digits[1:]
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole+frac == "" || strings.ContainsFunc(whole+frac, func(r rune) bool { return r < '0' || r > '9' }) {
+		return Decimal{}, ErrInvalidDecimal.Format(s)
+	}
+
+	unscaled, _ := new(big.Int).SetString(whole+frac, 10)
+	if str[0] == '-' {
+		unscaled.Neg(unscaled)
+	}
+	scale := int64(len(frac)) - exp
+	if scale < 0 {
+		unscaled.Mul(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(-scale), nil))
+		scale = 0
+	}
+	return Decimal{unscaled: unscaled, scale: int32(scale)}, nil
+}
+
+// DecimalFromInt returns i as a Decimal.
+func DecimalFromInt(i int64) Decimal {
+	return Decimal{unscaled: big.NewInt(i)}
+}
+
+// DecimalFromFloat returns f as a Decimal, using the shortest decimal that
+// reads back as f, e.g. 0.1 rather than 0.1000000000000000055511151231257827.
+func DecimalFromFloat(f float64) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, ErrNotANumber
+	}
+	return ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func (d Decimal) bigInt() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+// rescale returns the unscaled value of d at a larger scale.
+func (d Decimal) rescale(scale int32) *big.Int {
+	n := new(big.Int).Set(d.bigInt())
+	if scale > d.scale {
+		n.Mul(n, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-d.scale)), nil))
+	}
+	return n
+}
+
+// Add returns d + o, with the larger of their scales.
+func (d Decimal) Add(o Decimal) Decimal {
+	scale := max(d.scale, o.scale)
+	sum := d.rescale(scale)
+	sum.Add(sum, o.rescale(scale))
+	return Decimal{unscaled: sum, scale: scale}
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{unscaled: new(big.Int).Neg(d.bigInt()), scale: d.scale}
+}
+
+// Sub returns d - o, with the larger of their scales.
+func (d Decimal) Sub(o Decimal) Decimal {
+	return d.Add(o.Neg())
+}
+
+// Mul returns d * o, with the sum of their scales.
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.bigInt(), o.bigInt()), scale: d.scale + o.scale}
+}
+
+// Div returns d / o, rounded half away from zero to divisionScale more digits
+// than the larger of their scales, then with trailing zeros dropped down to
+// that scale. Dividing by zero fails with ErrDivisionByZero.
+func (d Decimal) Div(o Decimal) (Decimal, error) {
+	if o.bigInt().Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+	minScale := max(d.scale, o.scale)
+	scale := minScale + divisionScale
+
+	// d/o = (a/b) * 10^(o.scale-d.scale), so at scale s the result is
+	// a * 10^(s+o.scale-d.scale) / b
+	num := new(big.Int).Mul(d.bigInt(), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale+o.scale-d.scale)), nil))
+	den := o.bigInt()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign() == den.Sign() {
+			quo.Add(quo, big.NewInt(1))
+		} else {
+			quo.Sub(quo, big.NewInt(1))
+		}
+	}
+
+	ten := big.NewInt(10)
+	for scale > minScale {
+		q, r := new(big.Int).QuoRem(quo, ten, new(big.Int))
+		if r.Sign() != 0 {
+			break
+		}
+		quo = q
+		scale--
+	}
+	return Decimal{unscaled: quo, scale: scale}, nil
+}
+
+// Cmp compares d and o, returning -1, 0 or +1.
+func (d Decimal) Cmp(o Decimal) int {
+	scale := max(d.scale, o.scale)
+	return d.rescale(scale).Cmp(o.rescale(scale))
+}
+
+// String returns d in plain notation, keeping its scale, e.g. "19.90".
+func (d Decimal) String() string {
+	n := d.bigInt()
+	digits := new(big.Int).Abs(n).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		digits = digits[:len(digits)-int(d.scale)] + "." + digits[len(digits)-int(d.scale):]
+	}
+	if n.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Float64 returns the float64 nearest to d.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// Int64 returns d as an int64, if it is an integer that fits in one.
+func (d Decimal) Int64() (int64, bool) {
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale)), nil)
+	quo, rem := new(big.Int).QuoRem(d.bigInt(), pow, new(big.Int))
+	if rem.Sign() != 0 || !quo.IsInt64() {
+		return 0, false
+	}
+	return quo.Int64(), true
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{decimalField: d.String()})
+}
+
+// UnmarshalJSON reads {"$decimal": "19.99"}, or a number or string holding
+// a decimal.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	var obj map[string]string
+	if err := json.Unmarshal(data, &obj); err == nil {
+		s, ok := obj[decimalField]
+		if !ok || len(obj) != 1 {
+			return ErrInvalidDecimal.Format(string(data))
+		}
+		return d.set(s)
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return d.set(s)
+	}
+	return d.set(string(data))
+}
+
+func (d *Decimal) set(s string) error {
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// divisionScale is how many more digits than its operands a Decimal
+// quotient keeps.
+const divisionScale = 16
+
+// decimalField names the field of a decimal's JSON object.
+const decimalField = "$decimal"
+
+// maxDecimalExponent bounds the exponent of a parsed decimal, e.g. 1e1000.
+const maxDecimalExponent = 1000
+
+// IsNumber reports whether v is a number: any Go integer or float type,
+// json.Number, or Decimal.
+func IsNumber(v any) bool {
+	_, ok := toNumber(v)
+	return ok
+}
+
+// IsInteger reports whether v is a number of an integer type, as opposed to a
+// float or Decimal that happens to be whole.
+func IsInteger(v any) bool {
+	n, ok := toNumber(v)
+	return ok && n.kind == numberInt
+}
+
+// AddNumbers adds two numbers. Integers stay integers, as int64, unless the
+// sum overflows (ErrIntegerOverflow); if either is a Decimal the sum is a
+// Decimal; otherwise it is a float64.
+func AddNumbers(a, b any) (any, error) {
+	x, y, err := toNumbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case x.kind == numberInt && y.kind == numberInt:
+		sum := x.i + y.i
+		if (x.i > 0 && y.i > 0 && sum < 0) || (x.i < 0 && y.i < 0 && sum >= 0) {
+			return nil, ErrIntegerOverflow.Format(x.i, "+", y.i)
+		}
+		return sum, nil
+	case x.kind == numberDecimal || y.kind == numberDecimal:
+		xd, yd, err := toDecimals(x, y)
+		if err != nil {
+			return nil, err
+		}
+		return xd.Add(yd), nil
+	}
+	return x.float() + y.float(), nil
+}
+
+// subNumbers subtracts b from a, with the result types of AddNumbers.
+func subNumbers(a, b any) (any, error) {
+	x, y, err := toNumbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case x.kind == numberInt && y.kind == numberInt:
+		diff := x.i - y.i
+		if (y.i > 0 && diff > x.i) || (y.i < 0 && diff < x.i) {
+			return nil, ErrIntegerOverflow.Format(x.i, "-", y.i)
+		}
+		return diff, nil
+	case x.kind == numberDecimal || y.kind == numberDecimal:
+		xd, yd, err := toDecimals(x, y)
+		if err != nil {
+			return nil, err
+		}
+		return xd.Sub(yd), nil
+	}
+	return x.float() - y.float(), nil
+}
+
+// mulNumbers multiplies two numbers, with the result types of AddNumbers.
+func mulNumbers(a, b any) (any, error) {
+	x, y, err := toNumbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case x.kind == numberInt && y.kind == numberInt:
+		product := x.i * y.i
+		if x.i != 0 && (product/x.i != y.i || (x.i == -1 && y.i == math.MinInt64)) {
+			return nil, ErrIntegerOverflow.Format(x.i, "*", y.i)
+		}
+		return product, nil
+	case x.kind == numberDecimal || y.kind == numberDecimal:
+		xd, yd, err := toDecimals(x, y)
+		if err != nil {
+			return nil, err
+		}
+		return xd.Mul(yd), nil
+	}
+	return x.float() * y.float(), nil
+}
+
+// divNumbers divides a by b. Integers that divide exactly give an integer,
+// and other integers a float64; with a Decimal the quotient is a Decimal
+// (see Decimal.Div). Dividing by zero fails with ErrDivisionByZero.
+func divNumbers(a, b any) (any, error) {
+	x, y, err := toNumbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case x.kind == numberDecimal || y.kind == numberDecimal:
+		xd, yd, err := toDecimals(x, y)
+		if err != nil {
+			return nil, err
+		}
+		return xd.Div(yd)
+	case y.float() == 0:
+		return nil, ErrDivisionByZero
+	case x.kind == numberInt && y.kind == numberInt:
+		if x.i%y.i == 0 && !(x.i == math.MinInt64 && y.i == -1) {
+			return x.i / y.i, nil
+		}
+	}
+	return x.float() / y.float(), nil
+}
+
+// modNumbers returns the remainder of a divided by b, an integer if both are
+// integers and a float64 otherwise.
+func modNumbers(a, b any) (any, error) {
+	x, y, err := toNumbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	if y.float() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	if x.kind == numberInt && y.kind == numberInt {
+		if y.i == -1 {
+			return int64(0), nil
+		}
+		return x.i % y.i, nil
+	}
+	return math.Mod(x.float(), y.float()), nil
+}
+
+// negateNumber returns -v, keeping its type.
+func negateNumber(v any) (any, error) {
+	x, ok := toNumber(v)
+	if !ok {
+		return nil, ErrNotANumber
+	}
+	switch x.kind {
+	case numberInt:
+		if x.i == math.MinInt64 {
+			return nil, ErrIntegerOverflow.Format(int64(0), "-", x.i)
+		}
+		return -x.i, nil
+	case numberDecimal:
+		return x.d.Neg(), nil
+	}
+	return -x.f, nil
+}
+
+// compareNumbers compares two numbers by value, exactly whatever their types,
+// returning -1, 0 or +1.
+func compareNumbers(a, b any) (int, error) {
+	x, y, err := toNumbers(a, b)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case x.kind == numberInt && y.kind == numberInt:
+		return cmpInts(x.i, y.i), nil
+	case x.kind == numberDecimal || y.kind == numberDecimal:
+		xd, yd, err := toDecimals(x, y)
+		if err != nil {
+			return 0, err
+		}
+		return xd.Cmp(yd), nil
+	case x.kind == numberInt:
+		return compareIntFloat(x.i, y.f), nil
+	case y.kind == numberInt:
+		return -compareIntFloat(y.i, x.f), nil
+	}
+	return compareFloats(x.f, y.f), nil
+}
+
+// compareIntFloat compares an integer with a float without rounding the
+// integer to a float, which would make 2^53+1 equal 2^53.
+func compareIntFloat(i int64, f float64) int {
+	switch {
+	case math.IsNaN(f):
+		return -1
+	case f >= math.MaxInt64:
+		return -1
+	case f < math.MinInt64:
+		return 1
+	}
+	floor := math.Floor(f)
+	if cmp := cmpInts(i, int64(floor)); cmp != 0 || floor == f {
+		return cmp
+	}
+	// f is floor(f) plus a fraction
+	return -1
+}
+
+func cmpInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toNumbers(a, b any) (number, number, error) {
+	x, ok := toNumber(a)
+	if !ok {
+		return number{}, number{}, ErrNotANumber
+	}
+	y, ok := toNumber(b)
+	if !ok {
+		return number{}, number{}, ErrNotANumber
+	}
+	return x, y, nil
+}
+
+func toDecimals(x, y number) (Decimal, Decimal, error) {
+	xd, err := x.decimal()
+	if err != nil {
+		return Decimal{}, Decimal{}, err
+	}
+	yd, err := y.decimal()
+	if err != nil {
+		return Decimal{}, Decimal{}, err
+	}
+	return xd, yd, nil
+}
+
+type numberKind int
+
+const (
+	numberInt numberKind = iota
+	numberFloat
+	numberDecimal
+)
+
+// number is a numeric value of any supported type.
+type number struct {
+	kind numberKind
+	i    int64
+	f    float64
+	d    Decimal
+}
+
+func toNumber(v any) (number, bool) {
+	switch n := v.(type) {
+	case int, int8, int16, int32, int64:
+		return number{kind: numberInt, i: reflect.ValueOf(n).Int()}, true
+	case uint, uint8, uint16, uint32, uint64:
+		u := reflect.ValueOf(n).Uint()
+		if u > math.MaxInt64 {
+			return number{kind: numberFloat, f: float64(u)}, true
+		}
+		return number{kind: numberInt, i: int64(u)}, true
+	case float32:
+		return number{kind: numberFloat, f: float64(n)}, true
+	case float64:
+		return number{kind: numberFloat, f: n}, true
+	case Decimal:
+		return number{kind: numberDecimal, d: n}, true
+	case json.Number:
+		return toNumber(parseJSONNumber(n))
+	}
+	return number{}, false
+}
+
+func (n number) float() float64 {
+	switch n.kind {
+	case numberInt:
+		return float64(n.i)
+	case numberDecimal:
+		return n.d.Float64()
+	}
+	return n.f
+}
+
+func (n number) decimal() (Decimal, error) {
+	switch n.kind {
+	case numberInt:
+		return DecimalFromInt(n.i), nil
+	case numberFloat:
+		return DecimalFromFloat(n.f)
+	}
+	return n.d, nil
+}
+
+// parseJSONNumber returns n as an int64 if it is an integer that fits, or a
+// float64.
+func parseJSONNumber(n json.Number) any {
+	if !strings.ContainsAny(n.String(), ".eE") {
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// DecodeJSON decodes JSON like json.Unmarshal, except that numbers decoded
+// into interface values are int64 if they are integers that fit in one, and
+// float64 otherwise, and {"$decimal": "..."} objects are Decimals.
+func DecodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := decodeJSON(dec, v); err != nil {
+		return err
+	}
+	// Like json.Unmarshal, reject anything after the value
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
+}
+
+// decodeJSON decodes the next value from dec as DecodeJSON does.
+func decodeJSON(dec *json.Decoder, v any) error {
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	return normalizeDecoded(reflect.ValueOf(v))
+}
+
+// normalizeDecoded replaces the json.Numbers and decimal objects in a decoded
+// value, wherever it holds interface values that can be set.
+func normalizeDecoded(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return normalizeDecoded(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		elem := v.Elem()
+		switch elem.Interface().(type) {
+		case json.Number, map[string]any, []any:
+			normal, err := normalizeValue(elem.Interface())
+			if err != nil {
+				return err
+			}
+			if v.CanSet() {
+				v.Set(reflect.ValueOf(normal))
+			}
+			return nil
+		}
+		return normalizeDecoded(elem)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Field(i); field.CanSet() {
+				if err := normalizeDecoded(field); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := normalizeDecoded(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.Interface {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			normal, err := normalizeValue(iter.Value().Interface())
+			if err != nil {
+				return err
+			}
+			if normal != nil {
+				v.SetMapIndex(iter.Key(), reflect.ValueOf(normal))
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeValue replaces the json.Numbers and decimal objects in a value
+// decoded into an interface.
+func normalizeValue(v any) (any, error) {
+	switch val := v.(type) {
+	case json.Number:
+		return parseJSONNumber(val), nil
+	case map[string]any:
+		if s, ok := val[decimalField].(string); ok && len(val) == 1 {
+			return ParseDecimal(s)
+		}
+		for k, child := range val {
+			normal, err := normalizeValue(child)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = normal
+		}
+	case []any:
+		for i, child := range val {
+			normal, err := normalizeValue(child)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = normal
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,230 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/goodblaster/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddNumbers(t *testing.T) {
+	sum, err := AddNumbers(int64(1), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), sum)
+
+	// Beyond 2^53, where float64 loses precision
+	sum, err = AddNumbers(int64(1)<<60, int64(1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1)<<60+1, sum)
+
+	sum, err = AddNumbers(int64(1), 0.5)
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, sum)
+
+	sum, err = AddNumbers(mustDecimal(t, "0.10"), 0.2)
+	require.NoError(t, err)
+	assert.Equal(t, "0.30", sum.(Decimal).String())
+
+	sum, err = AddNumbers(int64(7), mustDecimal(t, "-0.005"))
+	require.NoError(t, err)
+	assert.Equal(t, "6.995", sum.(Decimal).String())
+
+	_, err = AddNumbers(int64(math.MaxInt64), 1)
+	assert.True(t, errors.Is(err, ErrIntegerOverflow))
+	_, err = AddNumbers(int64(math.MinInt64), -1)
+	assert.True(t, errors.Is(err, ErrIntegerOverflow))
+
+	_, err = AddNumbers("1", 1)
+	assert.True(t, errors.Is(err, ErrNotANumber))
+}
+
+func TestExpression_ExactNumbers(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"a":     int64(9007199254740993),
+		"b":     int64(0),
+		"max":   int64(math.MaxInt64),
+		"price": mustDecimal(t, "19.99"),
+	}))
+
+	tests := []struct {
+		expr string
+		want any
+	}{
+		{"${{a}} + ${{b}}", int64(9007199254740993)},
+		{"${{a}} - 1", int64(9007199254740992)},
+		{"${{a}} * 1", int64(9007199254740993)},
+		{"-${{a}}", int64(-9007199254740993)},
+		{"${{a}} == 9007199254740992", false},
+		{"${{a}} == 9007199254740993", true},
+		{"${{a}} > 9007199254740992", true},
+		{"${{a}} > 9007199254740992.0", true},
+		{"6 / 3", int64(2)},
+		{"7 / 2", 3.5},
+		{"7 % 4", int64(3)},
+		{"${{price}} > 19.98", true},
+		{"${{price}} == 19.99", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := evaluateExpression(ctx, cache, tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// Decimal operands keep the result a Decimal
+	for expr, want := range map[string]string{
+		"${{price}} + 1":   "20.99",
+		"${{price}} - 0.5": "19.49",
+		"${{price}} * 3":   "59.97",
+		"${{price}} / 2":   "9.995",
+		"-${{price}}":      "-19.99",
+	} {
+		got, err := evaluateExpression(ctx, cache, expr)
+		require.NoError(t, err, expr)
+		if assert.IsType(t, Decimal{}, got, expr) {
+			assert.Equal(t, want, got.(Decimal).String(), expr)
+		}
+	}
+
+	for _, expr := range []string{"${{max}} + 1", "${{max}} * 2", "-${{max}} - 2"} {
+		_, err := evaluateExpression(ctx, cache, expr)
+		assert.True(t, errors.Is(err, ErrIntegerOverflow), expr)
+	}
+	for _, expr := range []string{"1 / 0", "1 % 0", "${{price}} / 0"} {
+		_, err := evaluateExpression(ctx, cache, expr)
+		assert.True(t, errors.Is(err, ErrDivisionByZero), expr)
+	}
+
+	// REPLACE stores the exact sum
+	res := cache.Execute(ctx, REPLACE_EXPR("b", "${{a}} + ${{b}}"))
+	require.NoError(t, res.Error)
+	b, err := cache.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), b)
+}
+
+func TestDecimal_Div(t *testing.T) {
+	q, err := mustDecimal(t, "1").Div(mustDecimal(t, "3"))
+	require.NoError(t, err)
+	assert.Equal(t, "0.3333333333333333", q.String())
+
+	q, err = mustDecimal(t, "10.00").Div(mustDecimal(t, "4"))
+	require.NoError(t, err)
+	assert.Equal(t, "2.50", q.String())
+
+	q, err = mustDecimal(t, "-2").Div(mustDecimal(t, "3"))
+	require.NoError(t, err)
+	assert.Equal(t, "-0.6666666666666667", q.String())
+}
+
+func mustDecimal(t *testing.T, s string) Decimal {
+	t.Helper()
+	d, err := ParseDecimal(s)
+	require.NoError(t, err)
+	return d
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := map[string]string{
+		"19.99":  "19.99",
+		"-0.5":   "-0.5",
+		"+.25":   "0.25",
+		"7":      "7",
+		"1e3":    "1000",
+		"1.5e-3": "0.0015",
+		"100.00": "100.00",
+	}
+	for in, want := range tests {
+		d, err := ParseDecimal(in)
+		if assert.NoError(t, err, in) {
+			assert.Equal(t, want, d.String(), in)
+		}
+	}
+
+	for _, in := range []string{"", "-", ".", "1.2.3", "abc", "1e", "1e99999"} {
+		_, err := ParseDecimal(in)
+		assert.True(t, errors.Is(err, ErrInvalidDecimal), in)
+	}
+
+	assert.Equal(t, 0, mustDecimal(t, "1.10").Cmp(mustDecimal(t, "1.1")))
+	i, ok := mustDecimal(t, "42.00").Int64()
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), i)
+	_, ok = mustDecimal(t, "42.5").Int64()
+	assert.False(t, ok)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	var v map[string]any
+	require.NoError(t, DecodeJSON([]byte(`{
+		"int": 9007199254740993,
+		"float": 1.5,
+		"whole": 2.0,
+		"huge": 100000000000000000000,
+		"price": {"$decimal": "19.99"},
+		"list": [1, {"n": 2}]
+	}`), &v))
+
+	assert.Equal(t, int64(9007199254740993), v["int"])
+	assert.Equal(t, 1.5, v["float"])
+	assert.Equal(t, 2.0, v["whole"])
+	assert.Equal(t, 1e20, v["huge"])
+	assert.Equal(t, "19.99", v["price"].(Decimal).String())
+	assert.Equal(t, []any{int64(1), map[string]any{"n": int64(2)}}, v["list"])
+
+	// Decimals marshal back to the same form
+	data, err := json.Marshal(v["price"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"$decimal": "19.99"}`, string(data))
+
+	// Structs with interface fields
+	var s struct {
+		Value any            `json:"value"`
+		Args  map[string]any `json:"args"`
+	}
+	require.NoError(t, DecodeJSON([]byte(`{"value": 5, "args": {"n": 1}}`), &s))
+	assert.Equal(t, int64(5), s.Value)
+	assert.Equal(t, int64(1), s.Args["n"])
+
+	assert.Error(t, DecodeJSON([]byte(`{} {}`), &v))
+	assert.Error(t, DecodeJSON([]byte(`{"price": {"$decimal": "x"}}`), &v))
+}
+
+func TestINC_Integers(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	var data map[string]any
+	require.NoError(t, DecodeJSON([]byte(`{"n": 9007199254740992}`), &data))
+	require.NoError(t, cache.Create(ctx, data))
+
+	var cmd RawCommand
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "INC", "key": "n", "value": 1}`), &cmd))
+	res := cache.Execute(ctx, cmd.Command)
+	require.NoError(t, res.Error)
+
+	n, err := cache.Get(ctx, "n")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), n)
+
+	require.NoError(t, cache.Replace(ctx, "n", int64(math.MaxInt64)))
+	res = INC("n", 1).Do(ctx, cache)
+	assert.True(t, errors.Is(res.Error, ErrIntegerOverflow))
+
+	require.NoError(t, cache.Create(ctx, map[string]any{"balance": mustDecimal(t, "10.00")}))
+	res = INC("balance", -0.1).Do(ctx, cache)
+	require.NoError(t, res.Error)
+	assert.Equal(t, "9.90", res.Value.(Decimal).String())
+
+	parsed, err := ParseScript(`inc n -1`)
+	require.NoError(t, err)
+	assert.Equal(t, []Command{INC("n", -1)}, parsed)
+	assert.Equal(t, "inc n -1", FormatCommand(parsed[0]))
+}
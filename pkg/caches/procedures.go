@@ -0,0 +1,138 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Procedure is a named, parameterized command script stored in a cache.
+// Saving a procedure under an existing name adds a new version; earlier
+// versions remain callable until the procedure is deleted.
+type Procedure struct {
+	Name      string   `json:"name"`
+	Params    []string `json:"params,omitempty"`
+	Command   Command  `json:"command"`
+	Version   int      `json:"version"`
+	CreatedAt int64    `json:"created_at"` // Unix seconds
+}
+
+// RawProcedure is a Procedure whose command has not been decoded yet.
+type RawProcedure struct {
+	Name      string     `json:"name"`
+	Params    []string   `json:"params,omitempty"`
+	Command   RawCommand `json:"command"`
+	Version   int        `json:"version"`
+	CreatedAt int64      `json:"created_at"`
+}
+
+// MarshalJSON encodes the procedure as a RawProcedure, so that its command
+// keeps its type.
+func (p Procedure) MarshalJSON() ([]byte, error) {
+	return json.Marshal(RawProcedure{
+		Name:      p.Name,
+		Params:    p.Params,
+		Command:   RawCommand{Command: p.Command},
+		Version:   p.Version,
+		CreatedAt: p.CreatedAt,
+	})
+}
+
+// SaveProcedure stores cmd as the next version of the named procedure. Each
+// param is bound as a variable, ${{$param}}, when the procedure is called.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) SaveProcedure(ctx context.Context, name string, params []string, cmd Command) (Procedure, error) {
+	if name == "" || strings.ContainsAny(name, "/ ") {
+		return Procedure{}, ErrInvalidProcedureName.Format(name)
+	}
+	if cmd == nil {
+		return Procedure{}, ErrProcedureCommandRequired
+	}
+	for i, param := range params {
+		if param == "" || strings.ContainsAny(param, "/$ ") {
+			return Procedure{}, ErrInvalidVariableName.Format(param)
+		}
+		if slices.Contains(params[:i], param) {
+			return Procedure{}, ErrDuplicateProcedureParam.Format(param)
+		}
+	}
+
+	versions := cache.procedures[name]
+	proc := Procedure{
+		Name:      name,
+		Params:    params,
+		Command:   cmd,
+		Version:   1,
+		CreatedAt: time.Now().Unix(),
+	}
+	if len(versions) > 0 {
+		proc.Version = versions[len(versions)-1].Version + 1
+	}
+
+	cache.procedures[name] = append(versions, proc)
+	return proc, nil
+}
+
+// Procedure returns the given version of a procedure, or the latest version
+// if version is 0.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) Procedure(ctx context.Context, name string, version int) (Procedure, error) {
+	versions := cache.procedures[name]
+	if len(versions) == 0 {
+		return Procedure{}, ErrProcedureNotFound.Format(name)
+	}
+	if version == 0 {
+		return versions[len(versions)-1], nil
+	}
+	for _, proc := range versions {
+		if proc.Version == version {
+			return proc, nil
+		}
+	}
+	return Procedure{}, ErrProcedureVersionNotFound.Format(name, version)
+}
+
+// ProcedureVersions returns every version of a procedure, oldest first.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) ProcedureVersions(ctx context.Context, name string) ([]Procedure, error) {
+	versions := cache.procedures[name]
+	if len(versions) == 0 {
+		return nil, ErrProcedureNotFound.Format(name)
+	}
+	return slices.Clone(versions), nil
+}
+
+// Procedures returns the latest version of every procedure, sorted by name.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) Procedures(ctx context.Context) []Procedure {
+	procs := make([]Procedure, 0, len(cache.procedures))
+	for _, versions := range cache.procedures {
+		if len(versions) > 0 {
+			procs = append(procs, versions[len(versions)-1])
+		}
+	}
+	sort.Slice(procs, func(i, j int) bool {
+		return procs[i].Name < procs[j].Name
+	})
+	return procs
+}
+
+// DeleteProcedure removes a procedure and all of its versions.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) DeleteProcedure(ctx context.Context, name string) error {
+	if _, ok := cache.procedures[name]; !ok {
+		return ErrProcedureNotFound.Format(name)
+	}
+	delete(cache.procedures, name)
+	return nil
+}
+
+// CallProcedure runs a procedure with the given arguments and returns its
+// result. Version 0 calls the latest version.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) CallProcedure(ctx context.Context, name string, version int, args map[string]any) CmdResult {
+	return CommandCall{Name: name, Version: version, Args: args}.Do(cache.withBudget(ctx), cache)
+}
@@ -0,0 +1,205 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcedure_SaveAndCall(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"jobs": map[string]any{
+			"42": map[string]any{
+				"domains": map[string]any{"apple": "busy"},
+				"done":    0.0,
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	proc, err := cache.SaveProcedure(ctx, "complete_domain", []string{"job", "domain"}, COMMANDS(
+		REPLACE("jobs/${{$job}}/domains/${{$domain}}", "complete"),
+		INC("jobs/${{$job}}/done", 1),
+	))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, proc.Version)
+
+	res := cache.CallProcedure(ctx, "complete_domain", 0, map[string]any{"job": "42", "domain": "apple"})
+	assert.NoError(t, res.Error)
+
+	val, err := cache.Get(ctx, "jobs/42/domains/apple")
+	assert.NoError(t, err)
+	assert.Equal(t, "complete", val)
+
+	val, err = cache.Get(ctx, "jobs/42/done")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, val)
+}
+
+func TestProcedure_Versions(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	_, err := cache.SaveProcedure(ctx, "answer", nil, RETURN(1))
+	assert.NoError(t, err)
+	proc, err := cache.SaveProcedure(ctx, "answer", nil, RETURN(2))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, proc.Version)
+
+	res := cache.CallProcedure(ctx, "answer", 0, nil)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, 2, res.Value)
+
+	res = cache.CallProcedure(ctx, "answer", 1, nil)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, 1, res.Value)
+
+	res = cache.CallProcedure(ctx, "answer", 3, nil)
+	assert.ErrorIs(t, res.Error, ErrProcedureVersionNotFound)
+
+	versions, err := cache.ProcedureVersions(ctx, "answer")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+
+	procs := cache.Procedures(ctx)
+	assert.Len(t, procs, 1)
+	assert.Equal(t, 2, procs[0].Version)
+
+	err = cache.DeleteProcedure(ctx, "answer")
+	assert.NoError(t, err)
+	res = cache.CallProcedure(ctx, "answer", 0, nil)
+	assert.ErrorIs(t, res.Error, ErrProcedureNotFound)
+}
+
+func TestProcedure_InvalidDefinitions(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	_, err := cache.SaveProcedure(ctx, "", nil, NOOP())
+	assert.ErrorIs(t, err, ErrInvalidProcedureName)
+
+	_, err = cache.SaveProcedure(ctx, "a/b", nil, NOOP())
+	assert.ErrorIs(t, err, ErrInvalidProcedureName)
+
+	_, err = cache.SaveProcedure(ctx, "p", nil, nil)
+	assert.ErrorIs(t, err, ErrProcedureCommandRequired)
+
+	_, err = cache.SaveProcedure(ctx, "p", []string{"a", "a"}, NOOP())
+	assert.ErrorIs(t, err, ErrDuplicateProcedureParam)
+}
+
+func TestProcedure_Arguments(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	_, err := cache.SaveProcedure(ctx, "echo", []string{"x"}, RETURN("${{$x}}"))
+	assert.NoError(t, err)
+
+	res := cache.CallProcedure(ctx, "echo", 0, nil)
+	assert.ErrorIs(t, res.Error, ErrMissingProcedureArg)
+
+	res = cache.CallProcedure(ctx, "echo", 0, map[string]any{"x": 1, "y": 2})
+	assert.ErrorIs(t, res.Error, ErrUnknownProcedureArg)
+
+	// Arguments are interpolated in the caller's scope; the body can't see the caller's variables
+	res = cache.Execute(ctx,
+		LET("v", "hello"),
+		CALL("echo", map[string]any{"x": "${{$v}}"}),
+	)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []any{"hello", "hello"}, res.Value)
+
+	_, err = cache.SaveProcedure(ctx, "leak", nil, RETURN("${{$v}}"))
+	assert.NoError(t, err)
+	res = cache.Execute(ctx, LET("v", "hello"), CALL("leak", nil))
+	assert.ErrorIs(t, res.Error, ErrVariableNotFound)
+}
+
+func TestProcedure_RecursionLimit(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	_, err := cache.SaveProcedure(ctx, "forever", nil, CALL("forever", nil))
+	assert.NoError(t, err)
+
+	res := cache.CallProcedure(ctx, "forever", 0, nil)
+	assert.ErrorIs(t, res.Error, ErrProcedureRecursionLimit)
+}
+
+func TestProcedure_CalledFromTrigger(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"jobs":   map[string]any{"7": map[string]any{"countdown": 1.0}},
+		"status": map[string]any{"7": "busy"},
+	})
+	assert.NoError(t, err)
+
+	_, err = cache.SaveProcedure(ctx, "finish", []string{"job"}, REPLACE("status/${{$job}}", "complete"))
+	assert.NoError(t, err)
+
+	_, err = cache.CreateTrigger(ctx, "jobs/{jobId}/countdown", CALL("finish", map[string]any{"job": "${{$jobId}}"}))
+	assert.NoError(t, err)
+
+	res := INC("jobs/7/countdown", -1).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+
+	val, err := cache.Get(ctx, "status/7")
+	assert.NoError(t, err)
+	assert.Equal(t, "complete", val)
+}
+
+func TestProcedure_IsolatedFromCaptures(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"jobs": map[string]any{"a": 0.0, "b": 0.0},
+		"1":    "global",
+		"seen": map[string]any{"a": "", "b": ""},
+	})
+	assert.NoError(t, err)
+
+	// ${{1}} in the body is the key "1", not the caller's loop capture
+	_, err = cache.SaveProcedure(ctx, "record", []string{"job"}, REPLACE("seen/${{$job}}", "${{1}}"))
+	assert.NoError(t, err)
+
+	res := FOR("${{jobs/*}}", CALL("record", map[string]any{"job": "${{1}}"})).Do(ctx, cache)
+	assert.NoError(t, res.Error)
+
+	val, err := cache.Get(ctx, "seen")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": "global", "b": "global"}, val)
+}
+
+func TestProcedure_ReferencedCaches(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	_, err := cache.SaveProcedure(ctx, "outer", nil, COMMANDS(GET("@alpha:x"), CALL("inner", nil)))
+	assert.NoError(t, err)
+	_, err = cache.SaveProcedure(ctx, "inner", nil, COMMANDS(GET("@beta:y"), CALL("outer", nil)))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"alpha", "beta", "gamma"}, cache.ReferencedCaches(GET("@gamma:z"), CALL("outer", nil)))
+	assert.Empty(t, cache.ReferencedCaches(GET("a"), CALL("missing", nil)))
+}
+
+func TestCALL_Marshaling(t *testing.T) {
+	data, err := json.Marshal(RawCommand{Command: CALL("finish", map[string]any{"job": "7"})})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"CALL","name":"finish","args":{"job":"7"}}`, string(data))
+
+	var raw RawCommand
+	err = json.Unmarshal(data, &raw)
+	assert.NoError(t, err)
+	call, ok := raw.Command.(*CommandCall)
+	assert.True(t, ok)
+	assert.Equal(t, "finish", call.Name)
+}
@@ -0,0 +1,462 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/containers"
+)
+
+// Query selects the values at the keys matching a wildcard Source, such as
+// "sessions/*" or "users/{id}/sessions/*", and filters, sorts and pages them.
+//
+// Each matched value is an item. Where is an expression evaluated once per
+// item, as IF conditions are, with the item bound to ${{$item}}, its key to
+// ${{$key}} and named captures in Source to their names, e.g.
+// ${{$item/status}} == "active" && ${{$id}} != "admin".
+//
+// Fields named by Select, OrderBy, GroupBy and Aggregates are paths within the
+// item ("status", "address/city"), or variables ("$key", "$id"). An empty
+// field is the item itself.
+//
+// With GroupBy or Aggregates, items are grouped by the value of GroupBy (one
+// group when it is empty), and each group reports its count and aggregates;
+// OrderBy then sorts groups by "group", "count" or an aggregate name. Offset
+// and Limit apply to items, or to groups when grouping.
+type Query struct {
+	Source     string                    `json:"source,omitempty"`
+	Where      string                    `json:"where,omitempty"`
+	Select     []string                  `json:"select,omitempty"`
+	OrderBy    []QueryOrder              `json:"order_by,omitempty"`
+	Offset     int                       `json:"offset,omitempty"`
+	Limit      int                       `json:"limit,omitempty"`
+	GroupBy    string                    `json:"group_by,omitempty"`
+	Aggregates map[string]QueryAggregate `json:"aggregates,omitempty"`
+}
+
+// QueryOrder sorts by a field, ascending unless Desc. Numbers sort by value
+// and strings lexically; values of different types sort numbers first, then
+// strings, booleans, arrays and objects, with nulls and missing fields last,
+// in either direction.
+type QueryOrder struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc,omitempty"`
+}
+
+// QueryAggregate applies Func (count, sum, min, max, avg or distinct) to a
+// field of the items in a group, as the aggregate expression functions do.
+// count without a field counts the items.
+type QueryAggregate struct {
+	Func  string `json:"func"`
+	Field string `json:"field,omitempty"`
+}
+
+// QueryResult holds the items or groups on the requested page, and Total, the
+// number of items or groups before Offset and Limit.
+type QueryResult struct {
+	Total  int          `json:"total"`
+	Items  []QueryItem  `json:"items,omitempty"`
+	Groups []QueryGroup `json:"groups,omitempty"`
+}
+
+// QueryItem is a matched key and its value, or the selected fields of it.
+type QueryItem struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+// QueryGroup is the items sharing a GroupBy value.
+type QueryGroup struct {
+	Group      any            `json:"group"`
+	Count      int            `json:"count"`
+	Aggregates map[string]any `json:"aggregates,omitempty"`
+}
+
+// Map returns the result as plain JSON values, as commands return them.
+func (r QueryResult) Map() map[string]any {
+	result := map[string]any{"total": r.Total}
+	if r.Groups != nil {
+		groups := make([]any, len(r.Groups))
+		for i, g := range r.Groups {
+			group := map[string]any{"group": g.Group, "count": g.Count}
+			if len(g.Aggregates) > 0 {
+				group["aggregates"] = g.Aggregates
+			}
+			groups[i] = group
+		}
+		result["groups"] = groups
+		return result
+	}
+	items := make([]any, len(r.Items))
+	for i, item := range r.Items {
+		items[i] = map[string]any{"key": item.Key, "value": item.Value}
+	}
+	result["items"] = items
+	return result
+}
+
+// queryRow is a matched item with the scope its fields are read from.
+type queryRow struct {
+	key string
+	ctx context.Context
+}
+
+// field reads a field of the row's item, or a variable; missing fields are nil.
+func (r queryRow) field(field string) any {
+	ref := VariablePrefix + LoopItemVariable
+	switch {
+	case isVariableRef(field):
+		ref = field
+	case field != "":
+		ref += config.KeyDelimiter + field
+	}
+	val, err := variableValue(r.ctx, ref)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// Query runs q against the cache.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) Query(ctx context.Context, q Query) (QueryResult, error) {
+	if err := validateQuery(q); err != nil {
+		return QueryResult{}, err
+	}
+	ctx = ensureScope(ctx)
+
+	rows, err := cache.queryRows(ctx, q)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	if q.GroupBy != "" || len(q.Aggregates) > 0 {
+		groups, err := groupRows(rows, q)
+		if err != nil {
+			return QueryResult{}, err
+		}
+		sortQueryGroups(groups, q.OrderBy)
+		total := len(groups)
+		groups = queryPage(groups, q.Offset, q.Limit)
+		if groups == nil {
+			groups = []QueryGroup{}
+		}
+		return QueryResult{Total: total, Groups: groups}, nil
+	}
+
+	sortQueryRows(rows, q.OrderBy)
+	total := len(rows)
+	rows = queryPage(rows, q.Offset, q.Limit)
+	items := make([]QueryItem, len(rows))
+	for i, row := range rows {
+		items[i] = QueryItem{Key: row.key, Value: row.field("")}
+		if len(q.Select) > 0 {
+			selected := make(map[string]any, len(q.Select))
+			for _, field := range q.Select {
+				selected[field] = row.field(field)
+			}
+			items[i].Value = selected
+		}
+	}
+	return QueryResult{Total: total, Items: items}, nil
+}
+
+func validateQuery(q Query) error {
+	switch {
+	case q.Source == "":
+		return ErrInvalidQuery.Format("source is required")
+	case q.Offset < 0 || q.Limit < 0:
+		return ErrInvalidQuery.Format("offset and limit cannot be negative")
+	case len(q.Select) > 0 && (q.GroupBy != "" || len(q.Aggregates) > 0):
+		return ErrInvalidQuery.Format("select cannot be combined with group_by or aggregates")
+	}
+	if q.Where != "" {
+		if _, err := compileExpression(q.Where); err != nil {
+			return err
+		}
+	}
+	for name, agg := range q.Aggregates {
+		switch agg.Func {
+		case "count", "sum", "min", "max", "avg", "distinct":
+		default:
+			return ErrInvalidQuery.Format(fmt.Sprintf("aggregate %q: unknown function %q", name, agg.Func))
+		}
+	}
+	return nil
+}
+
+// queryRows matches the source and keeps the items where Where holds.
+func (cache *Cache) queryRows(ctx context.Context, q Query) ([]queryRow, error) {
+	source, err := interpolateKey(ctx, q.Source)
+	if err != nil {
+		return nil, err
+	}
+	source, captureNames, err := parseCapturePattern(source)
+	if err != nil {
+		return nil, err
+	}
+	target, pattern, prefix, err := resolveCache(ctx, cache, source)
+	if err != nil {
+		return nil, err
+	}
+	if !containers.IsPattern(pattern) {
+		return nil, ErrInvalidQuery.Format("source must include a wildcard: " + q.Source)
+	}
+
+	// An index can narrow the items down to those the where-expression could keep
+	var keys []string
+	if indexed, ok := target.indexedKeys(pattern, q.Where); ok {
+		keys, err = chargeKeysVisited(ctx, indexed)
+	} else {
+		keys, err = target.visitKeys(ctx, pattern)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]queryRow, 0, len(keys))
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		captures, ok := containers.MatchPath(pattern, key)
+		if !ok {
+			continue
+		}
+		item, err := target.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+
+		rowScope := newScope(scopeFrom(ctx))
+		rowScope.define(LoopItemVariable, item)
+		rowScope.define(LoopKeyVariable, prefix+key)
+		bindCaptures(rowScope, captureNames, captures)
+		row := queryRow{key: prefix + key, ctx: withScope(ctx, rowScope)}
+
+		if q.Where != "" {
+			result, err := evaluateExpression(row.ctx, cache, q.Where)
+			if err != nil {
+				return nil, err
+			}
+			keep, ok := result.(bool)
+			if !ok {
+				return nil, ErrExpressionNotBoolean
+			}
+			if !keep {
+				continue
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// indexedKeys returns the keys of the items matching pattern whose field
+// equals a constant, if where requires that and the field is indexed, e.g.
+// ${{$item/status}} == "active" && ... with an index on pattern by status.
+func (cache *Cache) indexedKeys(pattern, where string) ([]string, bool) {
+	if where == "" || len(cache.indexes) == 0 {
+		return nil, false
+	}
+	node, err := compileExpression(where)
+	if err != nil {
+		return nil, false
+	}
+	for _, term := range equalityTerms(node) {
+		if idx := cache.findIndex(pattern, term.field); idx != nil {
+			return idx.lookup(term.value), true
+		}
+	}
+	return nil, false
+}
+
+// equalityTerm is a ${{$item/field}} == constant that an expression requires.
+type equalityTerm struct {
+	field string
+	value any
+}
+
+// equalityTerms returns the field == constant comparisons joined by && at the
+// top of an expression.
+func equalityTerms(node exprNode) []equalityTerm {
+	n, ok := node.(*binaryNode)
+	if !ok {
+		return nil
+	}
+	switch n.op {
+	case "&&":
+		return append(equalityTerms(n.left), equalityTerms(n.right)...)
+	case "==":
+		ref, lit := n.left, n.right
+		if _, isRef := ref.(*refNode); !isRef {
+			ref, lit = lit, ref
+		}
+		r, ok := ref.(*refNode)
+		if !ok || r.nested || r.capture > 0 {
+			return nil
+		}
+		field, ok := strings.CutPrefix(r.ref, VariablePrefix+LoopItemVariable+config.KeyDelimiter)
+		if !ok {
+			return nil
+		}
+		l, ok := lit.(*literalNode)
+		if !ok {
+			return nil
+		}
+		switch l.value.(type) {
+		case string, int64, float64, bool:
+			return []equalityTerm{{field: field, value: l.value}}
+		}
+	}
+	return nil
+}
+
+// groupRows groups rows by q.GroupBy and computes each group's aggregates.
+func groupRows(rows []queryRow, q Query) ([]QueryGroup, error) {
+	var groups []QueryGroup
+	members := map[string][]queryRow{}
+	var order []string
+	for _, row := range rows {
+		var value any
+		if q.GroupBy != "" {
+			value = row.field(q.GroupBy)
+		}
+		id := groupID(value)
+		if _, ok := members[id]; !ok {
+			order = append(order, id)
+			groups = append(groups, QueryGroup{Group: value})
+		}
+		members[id] = append(members[id], row)
+	}
+
+	for i, id := range order {
+		groupRows := members[id]
+		groups[i].Count = len(groupRows)
+		if len(q.Aggregates) == 0 {
+			continue
+		}
+		groups[i].Aggregates = make(map[string]any, len(q.Aggregates))
+		for name, agg := range q.Aggregates {
+			if agg.Func == "count" && agg.Field == "" {
+				groups[i].Aggregates[name] = int64(len(groupRows))
+				continue
+			}
+			values := make([]any, 0, len(groupRows))
+			for _, row := range groupRows {
+				if val := row.field(agg.Field); val != nil {
+					values = append(values, val)
+				}
+			}
+			result, err := aggregate(agg.Func, values)
+			if err != nil {
+				return nil, err
+			}
+			groups[i].Aggregates[name] = result
+		}
+	}
+	return groups, nil
+}
+
+// groupID identifies a group value, so that equal values share a group.
+func groupID(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+func sortQueryRows(rows []queryRow, orderBy []QueryOrder) {
+	slices.SortStableFunc(rows, func(a, b queryRow) int {
+		for _, order := range orderBy {
+			if cmp := compareForSort(a.field(order.Field), b.field(order.Field), order.Desc); cmp != 0 {
+				return cmp
+			}
+		}
+		return strings.Compare(a.key, b.key)
+	})
+}
+
+func sortQueryGroups(groups []QueryGroup, orderBy []QueryOrder) {
+	field := func(g QueryGroup, name string) any {
+		switch name {
+		case "group":
+			return g.Group
+		case "count":
+			return g.Count
+		}
+		return g.Aggregates[name]
+	}
+	slices.SortStableFunc(groups, func(a, b QueryGroup) int {
+		for _, order := range orderBy {
+			if cmp := compareForSort(field(a, order.Field), field(b, order.Field), order.Desc); cmp != 0 {
+				return cmp
+			}
+		}
+		return compareForSort(a.Group, b.Group, false)
+	})
+}
+
+// compareForSort orders two values as QueryOrder describes.
+func compareForSort(a, b any, desc bool) int {
+	ra, rb := sortRank(a), sortRank(b)
+	if ra != rb || a == nil {
+		return ra - rb
+	}
+
+	var cmp int
+	switch ra {
+	case 0:
+		cmp, _ = compareNumbers(a, b)
+	case 1:
+		cmp = strings.Compare(a.(string), b.(string))
+	case 2:
+		switch {
+		case a == b:
+		case a == false:
+			cmp = -1
+		default:
+			cmp = 1
+		}
+	default:
+		cmp = strings.Compare(groupID(a), groupID(b))
+	}
+	if desc {
+		return -cmp
+	}
+	return cmp
+}
+
+func sortRank(v any) int {
+	if v == nil {
+		return 5
+	}
+	switch TypeName(v) {
+	case "number":
+		return 0
+	case "string":
+		return 1
+	case "bool":
+		return 2
+	case "array":
+		return 3
+	}
+	return 4
+}
+
+// queryPage returns the items from offset, at most limit of them (all if 0).
+func queryPage[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
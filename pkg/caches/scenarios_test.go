@@ -154,7 +154,7 @@ func TestScenario_RateLimiting(t *testing.T) {
 	// Verify counter
 	count, err := cache.Get(ctx, apiKey+"/requests")
 	assert.NoError(t, err)
-	assert.Equal(t, float64(5), count)
+	assert.Equal(t, int64(5), count)
 
 	// Wait for expiration AND batch processing (100ms ticker + margin)
 	time.Sleep(200 * time.Millisecond)
@@ -540,7 +540,7 @@ func TestScenario_MetricsAggregation(t *testing.T) {
 	// Get api_server requests directly
 	requests, err := cache.Get(ctx, "metrics/api_server/requests")
 	assert.NoError(t, err)
-	assert.Equal(t, float64(10), requests)
+	assert.Equal(t, int64(10), requests)
 
 	// Check if error rate is above threshold
 	cmd := IF(
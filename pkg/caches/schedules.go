@@ -0,0 +1,370 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/google/uuid"
+)
+
+// Schedule runs a command against its cache on a cron expression (in UTC),
+// every IntervalMs milliseconds, or once at a given time. Exactly one of
+// Cron, IntervalMs and At is set. A scheduled run takes the cache lock, and
+// those of the caches its command references, and goes through Execute like
+// a request, bounded by COMMAND_TIMEOUT_MS and the cache's execution limits.
+type Schedule struct {
+	Id         string        `json:"id"`
+	Cron       string        `json:"cron,omitempty"`
+	IntervalMs int64         `json:"interval_ms,omitempty"`
+	At         *time.Time    `json:"at,omitempty"`
+	Command    Command       `json:"command"`
+	Paused     bool          `json:"paused"`
+	NextRun    *time.Time    `json:"next_run,omitempty"` // nil once a one-shot has run
+	History    []ScheduleRun `json:"history"`            // most recent last
+}
+
+// RawSchedule is a Schedule whose command has not been decoded yet.
+type RawSchedule struct {
+	Id         string        `json:"id"`
+	Cron       string        `json:"cron,omitempty"`
+	IntervalMs int64         `json:"interval_ms,omitempty"`
+	At         *time.Time    `json:"at,omitempty"`
+	Command    RawCommand    `json:"command"`
+	Paused     bool          `json:"paused"`
+	NextRun    *time.Time    `json:"next_run,omitempty"`
+	History    []ScheduleRun `json:"history"`
+}
+
+// MarshalJSON encodes the schedule as a RawSchedule, so that its command keeps
+// its type.
+func (s Schedule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(RawSchedule{
+		Id:         s.Id,
+		Cron:       s.Cron,
+		IntervalMs: s.IntervalMs,
+		At:         s.At,
+		Command:    RawCommand{Command: s.Command},
+		Paused:     s.Paused,
+		NextRun:    s.NextRun,
+		History:    s.History,
+	})
+}
+
+// ScheduleRun records one run of a schedule.
+type ScheduleRun struct {
+	Time       time.Time `json:"time"`
+	DurationMs int64     `json:"duration_ms"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// scheduleEntry is a schedule and its timer.
+type scheduleEntry struct {
+	Schedule
+	cron  *cronSchedule
+	timer *time.Timer
+	gen   int // incremented whenever the timer is replaced
+}
+
+func (entry *scheduleEntry) snapshot() Schedule {
+	s := entry.Schedule
+	s.History = slices.Clone(entry.History)
+	if s.History == nil {
+		s.History = []ScheduleRun{}
+	}
+	return s
+}
+
+// scheduler holds a cache's schedules.
+type scheduler struct {
+	entries  map[string]*scheduleEntry
+	stop     chan struct{} // closed when the cache is deleted
+	stopOnce sync.Once
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		entries: map[string]*scheduleEntry{},
+		stop:    make(chan struct{}),
+	}
+}
+
+// AddSchedule adds a schedule and starts it, unless it is paused. An empty id
+// is generated.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) AddSchedule(ctx context.Context, s Schedule) (Schedule, error) {
+	if s.Id == "" {
+		s.Id = uuid.New().String()
+	}
+	if strings.ContainsAny(s.Id, "/ ") {
+		return Schedule{}, ErrInvalidScheduleId.Format(s.Id)
+	}
+	if _, exists := cache.schedules.entries[s.Id]; exists {
+		return Schedule{}, ErrScheduleExists.Format(s.Id)
+	}
+	if s.Command == nil {
+		return Schedule{}, ErrScheduleCommandRequired
+	}
+
+	entry := &scheduleEntry{Schedule: s}
+	if err := entry.validate(); err != nil {
+		return Schedule{}, err
+	}
+	// Checked here rather than in validate, so that restoring a backup
+	// doesn't fail when the minimum has been raised since
+	if s.IntervalMs > 0 && s.IntervalMs < config.ScheduleMinIntervalMs {
+		return Schedule{}, ErrScheduleIntervalTooShort.Format(config.ScheduleMinIntervalMs)
+	}
+
+	entry.History = nil
+	entry.NextRun = entry.nextRun(time.Now(), nil)
+	cache.schedules.entries[s.Id] = entry
+	cache.armSchedule(entry)
+	return entry.snapshot(), nil
+}
+
+// validate checks the timing of a schedule and parses its cron expression.
+func (entry *scheduleEntry) validate() error {
+	timings := 0
+	if entry.Cron != "" {
+		timings++
+		cron, err := parseCron(entry.Cron)
+		if err != nil {
+			return err
+		}
+		entry.cron = cron
+	}
+	if entry.IntervalMs != 0 {
+		timings++
+		if entry.IntervalMs < 0 {
+			return ErrInvalidScheduleTiming
+		}
+	}
+	if entry.At != nil {
+		timings++
+	}
+	if timings != 1 {
+		return ErrInvalidScheduleTiming
+	}
+	return nil
+}
+
+// nextRun returns when the schedule runs next after now, given the run that
+// was due at prev (nil if none was). One-shot schedules run once.
+func (entry *scheduleEntry) nextRun(now time.Time, prev *time.Time) *time.Time {
+	var next time.Time
+	switch {
+	case entry.cron != nil:
+		next = entry.cron.next(now.UTC())
+	case entry.IntervalMs > 0:
+		interval := time.Duration(entry.IntervalMs) * time.Millisecond
+		next = now.Add(interval)
+		// Keep to the original cadence, unless runs were missed
+		if prev != nil && prev.Add(interval).After(now) {
+			next = prev.Add(interval)
+		}
+	case prev == nil:
+		next = *entry.At
+	default:
+		return nil
+	}
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// armSchedule (re)starts the timer of a schedule for its next run.
+func (cache *Cache) armSchedule(entry *scheduleEntry) {
+	if entry.timer != nil {
+		entry.timer.Stop()
+		entry.timer = nil
+	}
+	entry.gen++
+	if entry.Paused || entry.NextRun == nil {
+		return
+	}
+
+	gen := entry.gen
+	entry.timer = time.AfterFunc(time.Until(*entry.NextRun), func() {
+		cache.runSchedule(entry, gen)
+	})
+}
+
+// runSchedule runs a schedule whose timer fired, and starts the timer for the
+// next run.
+func (cache *Cache) runSchedule(entry *scheduleEntry, gen int) {
+	tag := "schedule-" + entry.Id
+	var linked map[string]*Cache
+	if name, ok := cacheName(cache); ok {
+		// Lock every cache in name order, as jobs and requests do
+		locked, names := acquireWithReferences(tag, name, entry.Command)
+		defer ReleaseCaches(tag, locked)
+		if locked[name] != cache {
+			// Deleted or replaced while waiting for the lock
+			return
+		}
+		if len(names) > 0 {
+			linked = locked
+		}
+	} else {
+		cache.Acquire(tag)
+		defer cache.Release(tag)
+	}
+
+	// Deleted, paused, or rescheduled while waiting for the lock
+	select {
+	case <-cache.schedules.stop:
+		return
+	default:
+	}
+	if cache.schedules.entries[entry.Id] != entry || entry.gen != gen {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.CommandTimeoutMs)*time.Millisecond)
+	defer cancel()
+	if linked != nil {
+		ctx = WithCaches(ctx, linked)
+	}
+
+	start := time.Now()
+	res := cache.Execute(ctx, entry.Command)
+	duration := time.Since(start)
+
+	run := ScheduleRun{
+		Time:       start,
+		DurationMs: duration.Milliseconds(),
+		Success:    res.Error == nil,
+	}
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	switch {
+	case timedOut:
+		run.Error = "command execution timed out"
+	case res.Error != nil:
+		run.Error = res.Error.Error()
+	}
+
+	entry.History = append(entry.History, run)
+	if over := len(entry.History) - config.ScheduleHistorySize; over > 0 {
+		entry.History = slices.Delete(entry.History, 0, over)
+	}
+
+	if duration.Milliseconds() > config.CommandLongThresholdMs {
+		cache.opStats.record(OperationExecution{
+			Timestamp: start,
+			Duration:  duration,
+			Operation: "SCHEDULE " + entry.Id,
+			Success:   run.Success,
+			TimedOut:  timedOut,
+		})
+	}
+
+	entry.NextRun = entry.nextRun(time.Now(), entry.NextRun)
+	cache.armSchedule(entry)
+}
+
+// Schedules returns every schedule, sorted by id.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) Schedules(ctx context.Context) []Schedule {
+	list := make([]Schedule, 0, len(cache.schedules.entries))
+	for _, entry := range cache.schedules.entries {
+		list = append(list, entry.snapshot())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Id < list[j].Id
+	})
+	return list
+}
+
+// Schedule returns a schedule and its run history.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) Schedule(ctx context.Context, id string) (Schedule, error) {
+	entry, ok := cache.schedules.entries[id]
+	if !ok {
+		return Schedule{}, ErrScheduleNotFound.Format(id)
+	}
+	return entry.snapshot(), nil
+}
+
+// PauseSchedule stops a schedule from running until it is resumed.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) PauseSchedule(ctx context.Context, id string) (Schedule, error) {
+	entry, ok := cache.schedules.entries[id]
+	if !ok {
+		return Schedule{}, ErrScheduleNotFound.Format(id)
+	}
+	entry.Paused = true
+	cache.armSchedule(entry)
+	return entry.snapshot(), nil
+}
+
+// ResumeSchedule restarts a paused schedule. Runs missed while it was paused
+// are skipped, except that a one-shot that has not run yet runs at once if
+// its time has passed.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) ResumeSchedule(ctx context.Context, id string) (Schedule, error) {
+	entry, ok := cache.schedules.entries[id]
+	if !ok {
+		return Schedule{}, ErrScheduleNotFound.Format(id)
+	}
+	if entry.Paused {
+		entry.Paused = false
+		if entry.At == nil {
+			entry.NextRun = entry.nextRun(time.Now(), nil)
+		}
+		cache.armSchedule(entry)
+	}
+	return entry.snapshot(), nil
+}
+
+// DeleteSchedule stops and removes a schedule.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) DeleteSchedule(ctx context.Context, id string) error {
+	entry, ok := cache.schedules.entries[id]
+	if !ok {
+		return ErrScheduleNotFound.Format(id)
+	}
+	entry.Paused = true
+	cache.armSchedule(entry)
+	delete(cache.schedules.entries, id)
+	return nil
+}
+
+// restoreSchedule adds a schedule from a backup, keeping its next run and
+// history. It is not started until startSchedules is called.
+func (cache *Cache) restoreSchedule(s Schedule) error {
+	if _, exists := cache.schedules.entries[s.Id]; exists {
+		return ErrScheduleExists.Format(s.Id)
+	}
+	entry := &scheduleEntry{Schedule: s}
+	if err := entry.validate(); err != nil {
+		return err
+	}
+	cache.schedules.entries[s.Id] = entry
+	return nil
+}
+
+// startSchedules starts the restored schedules, once the cache is stored, so
+// that their runs lock it and the caches it links to by name.
+// This method is NOT thread-safe - caller must acquire the cache lock first.
+func (cache *Cache) startSchedules() {
+	for _, entry := range cache.schedules.entries {
+		cache.armSchedule(entry)
+	}
+}
+
+// stopSchedules stops every schedule of a deleted cache. It is called
+// without the cache lock, so pending timers are left to fire, and do nothing.
+func (cache *Cache) stopSchedules() {
+	cache.schedules.stopOnce.Do(func() {
+		close(cache.schedules.stop)
+	})
+}
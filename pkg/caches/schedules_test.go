@@ -0,0 +1,311 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scheduleCounter returns the value of n, taking the cache lock.
+func scheduleCounter(t *testing.T, cache *Cache) float64 {
+	t.Helper()
+	cache.Acquire("test")
+	defer cache.Release("test")
+	val, err := cache.Get(context.Background(), "n")
+	require.NoError(t, err)
+	return val.(float64)
+}
+
+// addSchedule adds a schedule, taking the cache lock.
+func addSchedule(t *testing.T, cache *Cache, s Schedule) Schedule {
+	t.Helper()
+	cache.Acquire("test")
+	defer cache.Release("test")
+	s, err := cache.AddSchedule(context.Background(), s)
+	require.NoError(t, err)
+	return s
+}
+
+func TestSchedules_Interval(t *testing.T) {
+	defer func(ms int64) { config.ScheduleMinIntervalMs = ms }(config.ScheduleMinIntervalMs)
+	config.ScheduleMinIntervalMs = 1
+
+	ctx := context.Background()
+	cache := New()
+	defer cache.Close()
+	require.NoError(t, cache.Create(ctx, map[string]any{"n": 0.0}))
+
+	s := addSchedule(t, cache, Schedule{Id: "tick", IntervalMs: 10, Command: INC("n", 1)})
+	assert.NotNil(t, s.NextRun)
+
+	require.Eventually(t, func() bool {
+		return scheduleCounter(t, cache) >= 3
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cache.Acquire("test")
+	s, err := cache.Schedule(ctx, "tick")
+	cache.Release("test")
+	require.NoError(t, err)
+	require.NotEmpty(t, s.History)
+	assert.True(t, s.History[0].Success)
+}
+
+func TestSchedules_OneShot(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	defer cache.Close()
+	require.NoError(t, cache.Create(ctx, map[string]any{"n": 0.0}))
+
+	at := time.Now().Add(10 * time.Millisecond)
+	addSchedule(t, cache, Schedule{Id: "once", At: &at, Command: INC("n", 5)})
+
+	require.Eventually(t, func() bool {
+		cache.Acquire("test")
+		defer cache.Release("test")
+		s, err := cache.Schedule(ctx, "once")
+		return err == nil && len(s.History) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cache.Acquire("test")
+	s, err := cache.Schedule(ctx, "once")
+	cache.Release("test")
+	require.NoError(t, err)
+	assert.Nil(t, s.NextRun)
+	assert.Equal(t, 5.0, scheduleCounter(t, cache))
+}
+
+func TestSchedules_PauseResumeDelete(t *testing.T) {
+	defer func(ms int64) { config.ScheduleMinIntervalMs = ms }(config.ScheduleMinIntervalMs)
+	config.ScheduleMinIntervalMs = 1
+
+	ctx := context.Background()
+	cache := New()
+	defer cache.Close()
+	require.NoError(t, cache.Create(ctx, map[string]any{"n": 0.0}))
+
+	addSchedule(t, cache, Schedule{Id: "tick", IntervalMs: 10, Command: INC("n", 1), Paused: true})
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0.0, scheduleCounter(t, cache))
+
+	cache.Acquire("test")
+	s, err := cache.ResumeSchedule(ctx, "tick")
+	cache.Release("test")
+	require.NoError(t, err)
+	assert.False(t, s.Paused)
+
+	require.Eventually(t, func() bool {
+		return scheduleCounter(t, cache) >= 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cache.Acquire("test")
+	_, err = cache.PauseSchedule(ctx, "tick")
+	require.NoError(t, err)
+	paused, err := cache.Get(ctx, "n")
+	require.NoError(t, err)
+	cache.Release("test")
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, paused, scheduleCounter(t, cache))
+
+	cache.Acquire("test")
+	require.NoError(t, cache.DeleteSchedule(ctx, "tick"))
+	assert.Empty(t, cache.Schedules(ctx))
+	err = cache.DeleteSchedule(ctx, "tick")
+	cache.Release("test")
+	assert.True(t, errors.Is(err, ErrScheduleNotFound))
+}
+
+func TestSchedules_HistoryBounded(t *testing.T) {
+	defer func(n int) { config.ScheduleHistorySize = n }(config.ScheduleHistorySize)
+	config.ScheduleHistorySize = 2
+	defer func(ms int64) { config.ScheduleMinIntervalMs = ms }(config.ScheduleMinIntervalMs)
+	config.ScheduleMinIntervalMs = 1
+
+	ctx := context.Background()
+	cache := New()
+	defer cache.Close()
+
+	// Fails every time: n doesn't exist
+	addSchedule(t, cache, Schedule{Id: "tick", IntervalMs: 5, Command: INC("n", 1)})
+
+	time.Sleep(60 * time.Millisecond)
+	cache.Acquire("test")
+	s, err := cache.Schedule(ctx, "tick")
+	require.NoError(t, cache.DeleteSchedule(ctx, "tick"))
+	cache.Release("test")
+	require.NoError(t, err)
+	require.Len(t, s.History, 2)
+	assert.False(t, s.History[1].Success)
+	assert.NotEmpty(t, s.History[1].Error)
+}
+
+func TestSchedules_Invalid(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	defer cache.Close()
+	at := time.Now()
+
+	tests := []struct {
+		schedule Schedule
+		err      error
+	}{
+		{Schedule{Id: "a", Command: NOOP()}, ErrInvalidScheduleTiming},
+		{Schedule{Id: "a", Cron: "@daily", IntervalMs: 10, Command: NOOP()}, ErrInvalidScheduleTiming},
+		{Schedule{Id: "a", At: &at, IntervalMs: 10, Command: NOOP()}, ErrInvalidScheduleTiming},
+		{Schedule{Id: "a", IntervalMs: -1, Command: NOOP()}, ErrInvalidScheduleTiming},
+		{Schedule{Id: "a", IntervalMs: 1, Command: NOOP()}, ErrScheduleIntervalTooShort},
+		{Schedule{Id: "a", Cron: "bad", Command: NOOP()}, ErrInvalidCron},
+		{Schedule{Id: "a/b", Cron: "@daily", Command: NOOP()}, ErrInvalidScheduleId},
+		{Schedule{Id: "a", Cron: "@daily"}, ErrScheduleCommandRequired},
+	}
+	for _, tt := range tests {
+		_, err := cache.AddSchedule(ctx, tt.schedule)
+		assert.True(t, errors.Is(err, tt.err), "%v", err)
+	}
+
+	s, err := cache.AddSchedule(ctx, Schedule{Cron: "@daily", Command: NOOP()})
+	require.NoError(t, err)
+	assert.NotEmpty(t, s.Id)
+	_, err = cache.AddSchedule(ctx, Schedule{Id: s.Id, Cron: "@daily", Command: NOOP()})
+	assert.True(t, errors.Is(err, ErrScheduleExists))
+}
+
+func TestSchedules_BackupRestore(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, AddCache("schedules-backup"))
+	defer DeleteCache("schedules-backup")
+	cache, err := FetchCache("schedules-backup")
+	require.NoError(t, err)
+
+	cache.Acquire("test")
+	_, err = cache.AddSchedule(ctx, Schedule{Id: "nightly", Cron: "0 3 * * *", Command: INC("n", 1)})
+	require.NoError(t, err)
+	_, err = cache.AddSchedule(ctx, Schedule{Id: "tick", IntervalMs: 60000, Command: NOOP(), Paused: true})
+	require.NoError(t, err)
+	want := cache.Schedules(ctx)
+	cache.Release("test")
+
+	file := filepath.Join(t.TempDir(), "backup.json")
+	require.NoError(t, Backup(ctx, "schedules-backup", file))
+	require.NoError(t, Restore(ctx, "schedules-backup", file))
+
+	restored, err := FetchCache("schedules-backup")
+	require.NoError(t, err)
+	assert.NotSame(t, cache, restored)
+
+	restored.Acquire("test")
+	got := restored.Schedules(ctx)
+	restored.Release("test")
+	require.Len(t, got, 2)
+	for i := range want {
+		assert.Equal(t, want[i].Id, got[i].Id)
+		assert.Equal(t, want[i].Cron, got[i].Cron)
+		assert.Equal(t, want[i].Paused, got[i].Paused)
+		assert.True(t, want[i].NextRun.Equal(*got[i].NextRun))
+		assert.Equal(t, want[i].Command.Type(), got[i].Command.Type())
+	}
+}
+
+func writeScheduleBackup(t *testing.T, schedules ...RawSchedule) string {
+	t.Helper()
+	data, err := json.Marshal(RestoreContainer{
+		Data:      map[string]any{"n": 0.0},
+		Schedules: schedules,
+	})
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "backup.json")
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+	return file
+}
+
+func TestSchedules_RestoreDuplicateIds(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, AddCache("schedules-duplicate"))
+	defer DeleteCache("schedules-duplicate")
+	cache, err := FetchCache("schedules-duplicate")
+	require.NoError(t, err)
+
+	file := writeScheduleBackup(t,
+		RawSchedule{Id: "tick", IntervalMs: 60000, Command: RawCommand{Command: NOOP()}},
+		RawSchedule{Id: "tick", Cron: "0 3 * * *", Command: RawCommand{Command: NOOP()}},
+	)
+	err = Restore(ctx, "schedules-duplicate", file)
+	assert.True(t, errors.Is(err, ErrScheduleExists))
+
+	// The existing cache is kept
+	current, err := FetchCache("schedules-duplicate")
+	require.NoError(t, err)
+	assert.Same(t, cache, current)
+}
+
+func TestSchedules_RestoreStartsOnceStored(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, AddCache("schedules-restored-other"))
+	defer DeleteCache("schedules-restored-other")
+	other, err := FetchCache("schedules-restored-other")
+	require.NoError(t, err)
+	require.NoError(t, other.Create(ctx, map[string]any{"n": 0.0}))
+
+	// Due at once, and using another cache, which only a stored cache can
+	// lock when it runs
+	due := time.Now().Add(-time.Second)
+	file := writeScheduleBackup(t, RawSchedule{
+		Id:         "tick",
+		IntervalMs: 60000,
+		Command:    RawCommand{Command: INC("@schedules-restored-other:n", 1)},
+		NextRun:    &due,
+	})
+	require.NoError(t, Restore(ctx, "schedules-restored", file))
+	defer DeleteCache("schedules-restored")
+
+	assert.Eventually(t, func() bool {
+		return scheduleCounter(t, other) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	restored, err := FetchCache("schedules-restored")
+	require.NoError(t, err)
+	restored.Acquire("test")
+	s, err := restored.Schedule(ctx, "tick")
+	restored.Release("test")
+	require.NoError(t, err)
+	require.Len(t, s.History, 1)
+	assert.True(t, s.History[0].Success, s.History[0].Error)
+}
+
+func TestSchedules_OtherCaches(t *testing.T) {
+	defer func(ms int64) { config.ScheduleMinIntervalMs = ms }(config.ScheduleMinIntervalMs)
+	config.ScheduleMinIntervalMs = 1
+
+	ctx := context.Background()
+	require.NoError(t, AddCache("schedules-main"))
+	defer DeleteCache("schedules-main")
+	require.NoError(t, AddCache("schedules-other"))
+	defer DeleteCache("schedules-other")
+
+	cache, err := FetchCache("schedules-main")
+	require.NoError(t, err)
+	other, err := FetchCache("schedules-other")
+	require.NoError(t, err)
+	require.NoError(t, other.Create(ctx, map[string]any{"n": 0.0}))
+
+	addSchedule(t, cache, Schedule{Id: "tick", IntervalMs: 10, Command: INC("@schedules-other:n", 1)})
+
+	require.Eventually(t, func() bool {
+		return scheduleCounter(t, other) >= 2
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cache.Acquire("test")
+	s, err := cache.Schedule(ctx, "tick")
+	cache.Release("test")
+	require.NoError(t, err)
+	assert.True(t, s.History[0].Success, s.History[0].Error)
+}
@@ -0,0 +1,156 @@
+package caches
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VariablePrefix marks a ${{...}} reference as a variable rather than a key,
+// e.g. ${{$total}} or ${{$user/name}}.
+const VariablePrefix = "$"
+
+type scopeKey struct{}
+
+var scopeContextKey = scopeKey{}
+
+// scope holds the variables bound during one execution. Nested scopes (such
+// as loop bodies) see the variables of their enclosing scopes.
+type scope struct {
+	vars   map[string]any
+	parent *scope
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{vars: map[string]any{}, parent: parent}
+}
+
+// scopeFrom returns the current scope, or nil outside of an execution.
+func scopeFrom(ctx context.Context) *scope {
+	s, _ := ctx.Value(scopeContextKey).(*scope)
+	return s
+}
+
+// withScope returns a context carrying s.
+func withScope(ctx context.Context, s *scope) context.Context {
+	return context.WithValue(ctx, scopeContextKey, s)
+}
+
+// ensureScope returns ctx unchanged if it already carries a scope, otherwise
+// a context with a new root scope.
+func ensureScope(ctx context.Context) context.Context {
+	if scopeFrom(ctx) != nil {
+		return ctx
+	}
+	return withScope(ctx, newScope(nil))
+}
+
+// lookup finds name in this scope or any enclosing scope.
+func (s *scope) lookup(name string) (any, bool) {
+	for cur := s; cur != nil; cur = cur.parent {
+		if val, ok := cur.vars[name]; ok {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+// set assigns name in the nearest scope that already defines it, or defines
+// it in this scope. Assigning from inside a loop therefore updates a
+// variable declared before the loop.
+func (s *scope) set(name string, val any) {
+	for cur := s; cur != nil; cur = cur.parent {
+		if _, ok := cur.vars[name]; ok {
+			cur.vars[name] = val
+			return
+		}
+	}
+	s.vars[name] = val
+}
+
+// define binds name in this scope only, shadowing any enclosing binding.
+func (s *scope) define(name string, val any) {
+	s.vars[name] = val
+}
+
+// isVariableRef reports whether a ${{...}} reference names a variable.
+func isVariableRef(ref string) bool {
+	return strings.HasPrefix(ref, VariablePrefix)
+}
+
+// variableValue resolves a variable reference such as "$user/name". Path
+// segments after the variable name index into maps and arrays.
+func variableValue(ctx context.Context, ref string) (any, error) {
+	path := SplitKey(strings.TrimPrefix(ref, VariablePrefix))
+
+	s := scopeFrom(ctx)
+	if s == nil {
+		return nil, ErrVariableNotFound.Format(path[0])
+	}
+	val, ok := s.lookup(path[0])
+	if !ok {
+		return nil, ErrVariableNotFound.Format(path[0])
+	}
+
+	for _, segment := range path[1:] {
+		switch node := val.(type) {
+		case map[string]any:
+			child, ok := node[segment]
+			if !ok {
+				return nil, ErrVariableNotFound.Format(ref)
+			}
+			val = child
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, ErrVariableNotFound.Format(ref)
+			}
+			val = node[i]
+		default:
+			return nil, ErrVariableNotFound.Format(ref)
+		}
+	}
+	return val, nil
+}
+
+// lookupRef resolves a ${{...}} reference to a variable or a cache key.
+func lookupRef(ctx context.Context, cache *Cache, ref string) (any, error) {
+	if isVariableRef(ref) {
+		return variableValue(ctx, ref)
+	}
+	cache, key, _, err := resolveCache(ctx, cache, ref)
+	if err != nil {
+		return nil, err
+	}
+	return cache.Get(ctx, key)
+}
+
+// interpolateKey substitutes positional captures and ${{$var}} references in
+// a key, e.g. "users/${{$id}}/name". Key references are left alone.
+func interpolateKey(ctx context.Context, key string) (string, error) {
+	key = substituteContextVars(ctx, key)
+	if !strings.Contains(key, "${{") {
+		return key, nil
+	}
+
+	var firstErr error
+	key = InterpolationPattern.ReplaceAllStringFunc(key, func(m string) string {
+		ref := strings.TrimSpace(InterpolationPattern.FindStringSubmatch(m)[1])
+		if !isVariableRef(ref) {
+			return m
+		}
+		val, err := variableValue(ctx, ref)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return m
+		}
+		if str, ok := val.(string); ok {
+			return str
+		}
+		return fmt.Sprintf("%v", val)
+	})
+	return key, firstErr
+}
@@ -0,0 +1,940 @@
+package caches
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goodblaster/errors"
+)
+
+// Scripts are a compact text form of the command language. Every command has
+// a statement form; statements are separated by newlines or ";", and "#"
+// starts a comment:
+//
+//	for jobs/{job}/domains/* {
+//	  if ${{jobs/${{1}}/domains/${{2}}/countdown}} == 0 {
+//	    replace jobs/${{$job}}/domains/${{2}}/status "complete"
+//	  } else {
+//	    inc jobs/${{$job}}/domains/${{2}}/countdown -1
+//	  }
+//	}
+//
+// Statements:
+//
+//	noop
+//	get KEY
+//	delete KEY
+//	inc KEY [NUMBER]
+//	replace KEY VALUE              replace KEY = EXPR
+//	return VALUE                   return = EXPR
+//	print VALUE [, VALUE ...]
+//	let NAME VALUE                 let NAME = EXPR          let NAME <- STATEMENT
+//	call NAME [version N] [{"arg": VALUE, ...}]
+//	if EXPR { ... } [else { ... } | else if ...]
+//	for PATTERN [as ITEM[, INDEX]] { ... } [max N]
+//	for range FROM TO [STEP] [as ITEM[, INDEX]] { ... } [max N]
+//	while EXPR { ... } [max N]
+//	switch VALUE { case VALUE { ... } when EXPR { ... } default { ... } }
+//	switch = EXPR { ... }
+//	try { ... } [catch { ... }] [finally { ... }]
+//	ignore_errors STATEMENT
+//	json {"type": ..., ...}        any command in its JSON form
+//	{ ... }                        a group (COMMANDS)
+//
+// KEY, PATTERN and NAME are bare words such as users/${{$id}}/name or JSON
+// strings. VALUE is a JSON literal, or a bare word taken as a string. EXPR is
+// the raw expression text, up to the "{" of a block or the end of the
+// statement. A block holding several statements becomes a COMMANDS group; an
+// empty block is a NOOP.
+
+// ErrScriptSyntax is matched (with errors.Is) by every ScriptError.
+var ErrScriptSyntax = errors.New("script syntax error")
+
+// ScriptError is a script parse error at a 1-based line and column.
+type ScriptError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("script syntax error at line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+func (e *ScriptError) Unwrap() error {
+	return ErrScriptSyntax
+}
+
+// ParseScript parses a script into commands.
+func ParseScript(src string) ([]Command, error) {
+	p := &scriptParser{src: src}
+	cmds, err := p.statements(false)
+	if err != nil {
+		return nil, err
+	}
+	return cmds, nil
+}
+
+// ParseScriptCommand parses a script into a single command: the statement
+// itself if there is only one, otherwise a COMMANDS group.
+func ParseScriptCommand(src string) (Command, error) {
+	cmds, err := ParseScript(src)
+	if err != nil {
+		return nil, err
+	}
+	switch len(cmds) {
+	case 0:
+		return nil, &ScriptError{Line: 1, Column: 1, Msg: "script is empty"}
+	case 1:
+		return cmds[0], nil
+	}
+	return COMMANDS(cmds...), nil
+}
+
+type scriptParser struct {
+	src string
+	pos int
+}
+
+func (p *scriptParser) errorf(pos int, format string, args ...any) error {
+	line, col := 1, 1
+	for _, r := range p.src[:min(pos, len(p.src))] {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return &ScriptError{Line: line, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *scriptParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *scriptParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// skipSpace skips blanks and comments, and newlines too if newlines is set.
+func (p *scriptParser) skipSpace(newlines bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && newlines:
+			p.pos++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// statements parses statements up to the end of input, or up to and
+// including the closing "}" if inBlock is set.
+func (p *scriptParser) statements(inBlock bool) ([]Command, error) {
+	var cmds []Command
+	for {
+		p.skipSpace(true)
+		for p.peek() == ';' {
+			p.pos++
+			p.skipSpace(true)
+		}
+		if p.eof() {
+			if inBlock {
+				return nil, p.errorf(p.pos, "missing }")
+			}
+			return cmds, nil
+		}
+		if p.peek() == '}' {
+			if !inBlock {
+				return nil, p.errorf(p.pos, "unexpected }")
+			}
+			p.pos++
+			return cmds, nil
+		}
+
+		cmd, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+
+		// A statement ends at a newline, ";", "}" or the end of input
+		p.skipSpace(false)
+		if !p.eof() && !strings.ContainsRune("\n;}", rune(p.peek())) {
+			return nil, p.errorf(p.pos, "unexpected %q after statement", p.peekToken())
+		}
+	}
+}
+
+// block parses "{ statements }".
+func (p *scriptParser) block() ([]Command, error) {
+	p.skipSpace(false)
+	if p.peek() != '{' {
+		return nil, p.errorf(p.pos, "expected {, found %q", p.peekToken())
+	}
+	p.pos++
+	return p.statements(true)
+}
+
+// blockCommand parses a block as a single command.
+func (p *scriptParser) blockCommand() (Command, error) {
+	cmds, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	switch len(cmds) {
+	case 0:
+		return NOOP(), nil
+	case 1:
+		return cmds[0], nil
+	}
+	return COMMANDS(cmds...), nil
+}
+
+// keyword consumes the keyword kw if it is next, possibly after newlines
+// when newlines is set.
+func (p *scriptParser) keyword(kw string, newlines bool) bool {
+	start := p.pos
+	p.skipSpace(newlines)
+	end := p.pos + len(kw)
+	if end <= len(p.src) && strings.EqualFold(p.src[p.pos:end], kw) && (end == len(p.src) || !isWordByte(p.src[end])) {
+		p.pos = end
+		return true
+	}
+	p.pos = start
+	return false
+}
+
+func (p *scriptParser) statement() (Command, error) {
+	if p.peek() == '{' {
+		cmds, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return COMMANDS(cmds...), nil
+	}
+
+	start := p.pos
+	kw := strings.ToLower(p.bareWord())
+	if kw == "" {
+		return nil, p.errorf(start, "expected a statement, found %q", p.peekToken())
+	}
+
+	switch kw {
+	case "noop":
+		return NOOP(), nil
+	case "get":
+		key, err := p.key()
+		return GET(key), err
+	case "delete":
+		key, err := p.key()
+		return DELETE(key), err
+	case "inc":
+		return p.inc()
+	case "replace":
+		return p.replace()
+	case "return":
+		return p.returnStatement()
+	case "print":
+		return p.print()
+	case "let":
+		return p.let()
+	case "call":
+		return p.call()
+	case "query":
+		return p.query()
+	case "if":
+		return p.ifStatement()
+	case "for":
+		return p.forStatement()
+	case "while":
+		return p.while()
+	case "switch":
+		return p.switchStatement()
+	case "try":
+		return p.try()
+	case "ignore_errors":
+		p.skipSpace(false)
+		cmd, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		return IGNORE_ERRORS(cmd), nil
+	case "json":
+		return p.jsonCommand()
+	}
+	return nil, p.errorf(start, "unknown statement %q", kw)
+}
+
+func (p *scriptParser) inc() (Command, error) {
+	key, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace(false)
+	if p.atStatementEnd() {
+		return INC(key, 1), nil
+	}
+	start := p.pos
+	val, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if !IsNumber(val) {
+		return nil, p.errorf(start, "inc expects a number, found %q", p.src[start:p.pos])
+	}
+	return INC(key, val), nil
+}
+
+func (p *scriptParser) replace() (Command, error) {
+	key, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	if p.equals() {
+		expr, err := p.expr(false)
+		if err != nil {
+			return nil, err
+		}
+		return REPLACE_EXPR(key, expr), nil
+	}
+	val, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return REPLACE(key, val), nil
+}
+
+func (p *scriptParser) returnStatement() (Command, error) {
+	if p.equals() {
+		expr, err := p.expr(false)
+		if err != nil {
+			return nil, err
+		}
+		return RETURN_EXPR(expr), nil
+	}
+	val, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return RETURN(val), nil
+}
+
+func (p *scriptParser) print() (Command, error) {
+	var msgs []string
+	for {
+		start := p.pos
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		msg, ok := val.(string)
+		if !ok {
+			return nil, p.errorf(start, "print expects strings")
+		}
+		msgs = append(msgs, msg)
+
+		p.skipSpace(false)
+		if p.peek() != ',' {
+			return PRINT(msgs...), nil
+		}
+		p.pos++
+	}
+}
+
+func (p *scriptParser) let() (Command, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace(false)
+	if strings.HasPrefix(p.src[p.pos:], "<-") {
+		p.pos += 2
+		p.skipSpace(false)
+		cmd, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		return LET_RESULT(name, cmd), nil
+	}
+	if p.equals() {
+		expr, err := p.expr(false)
+		if err != nil {
+			return nil, err
+		}
+		return LET_EXPR(name, expr), nil
+	}
+	val, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return LET(name, val), nil
+}
+
+func (p *scriptParser) call() (Command, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	call := CommandCall{Name: name}
+
+	if p.keyword("version", false) {
+		n, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		call.Version = n
+	}
+
+	p.skipSpace(false)
+	if p.peek() == '{' {
+		start := p.pos
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		args, ok := val.(map[string]any)
+		if !ok {
+			return nil, p.errorf(start, "call arguments must be an object")
+		}
+		call.Args = args
+	}
+	return call, nil
+}
+
+func (p *scriptParser) query() (Command, error) {
+	source, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	var q Query
+
+	p.skipSpace(false)
+	if p.peek() == '{' {
+		start := p.pos
+		raw, err := p.rawJSON()
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(raw), &q); err != nil {
+			return nil, p.errorf(start, "invalid query options: %v", err)
+		}
+	}
+	q.Source = source
+	return QUERY(q), nil
+}
+
+func (p *scriptParser) ifStatement() (Command, error) {
+	cond, err := p.expr(true)
+	if err != nil {
+		return nil, err
+	}
+	ifTrue, err := p.blockCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	var ifFalse Command = NOOP()
+	if p.keyword("else", true) {
+		if p.keyword("if", false) {
+			ifFalse, err = p.ifStatement()
+		} else {
+			ifFalse, err = p.blockCommand()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return IF(cond, ifTrue, ifFalse), nil
+}
+
+func (p *scriptParser) forStatement() (Command, error) {
+	var f CommandFor
+	if p.keyword("range", false) {
+		var bounds []any
+		for len(bounds) < 3 {
+			p.skipSpace(false)
+			if p.peek() == '{' || p.peekKeyword("as") {
+				break
+			}
+			val, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			bounds = append(bounds, val)
+		}
+		if len(bounds) < 2 {
+			return nil, p.errorf(p.pos, "for range expects FROM and TO")
+		}
+		f.Range = &LoopRange{From: bounds[0], To: bounds[1]}
+		if len(bounds) == 3 {
+			f.Range.Step = bounds[2]
+		}
+	} else {
+		p.skipSpace(false)
+		start := p.pos
+		pattern, quoted, err := p.keyOrString()
+		if err != nil {
+			return nil, err
+		}
+		if pattern == "" {
+			return nil, p.errorf(start, "for expects a pattern")
+		}
+		f.LoopExpr = loopExprFromPattern(pattern, quoted)
+	}
+
+	if p.keyword("as", false) {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		f.As = name
+		p.skipSpace(false)
+		if p.peek() == ',' {
+			p.pos++
+			if f.IndexAs, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	cmds, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	f.Commands = cmds
+
+	if p.keyword("max", false) {
+		if f.MaxIterations, err = p.integer(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// loopExprFromPattern turns a for pattern into a LoopExpr. Bare patterns are
+// wrapped in ${{...}}; quoted ones are used as is if they hold a reference.
+func loopExprFromPattern(pattern string, quoted bool) string {
+	if quoted && strings.Contains(pattern, "${{") {
+		return pattern
+	}
+	if !quoted && strings.HasPrefix(pattern, "${{") && strings.HasSuffix(pattern, "}}") && strings.Count(pattern, "${{") == 1 {
+		return pattern
+	}
+	return "${{" + pattern + "}}"
+}
+
+func (p *scriptParser) while() (Command, error) {
+	cond, err := p.expr(true)
+	if err != nil {
+		return nil, err
+	}
+	cmds, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	w := CommandWhile{Condition: cond, Commands: cmds}
+	if p.keyword("max", false) {
+		if w.MaxIterations, err = p.integer(); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (p *scriptParser) switchStatement() (Command, error) {
+	var sw CommandSwitch
+	if p.equals() {
+		expr, err := p.expr(true)
+		if err != nil {
+			return nil, err
+		}
+		sw.Expr = expr
+	} else {
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		sw.Value = val
+	}
+
+	p.skipSpace(false)
+	if p.peek() != '{' {
+		return nil, p.errorf(p.pos, "expected {, found %q", p.peekToken())
+	}
+	p.pos++
+
+	for {
+		p.skipSpace(true)
+		for p.peek() == ';' {
+			p.pos++
+			p.skipSpace(true)
+		}
+		if p.eof() {
+			return nil, p.errorf(p.pos, "missing }")
+		}
+		if p.peek() == '}' {
+			p.pos++
+			return sw, nil
+		}
+
+		start := p.pos
+		switch kw := strings.ToLower(p.bareWord()); kw {
+		case "case":
+			match, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			cmd, err := p.blockCommand()
+			if err != nil {
+				return nil, err
+			}
+			sw.Cases = append(sw.Cases, CASE(match, cmd))
+		case "when":
+			cond, err := p.expr(true)
+			if err != nil {
+				return nil, err
+			}
+			cmd, err := p.blockCommand()
+			if err != nil {
+				return nil, err
+			}
+			sw.Cases = append(sw.Cases, WHEN(cond, cmd))
+		case "default":
+			if sw.Default != nil {
+				return nil, p.errorf(start, "duplicate default")
+			}
+			cmd, err := p.blockCommand()
+			if err != nil {
+				return nil, err
+			}
+			sw.Default = cmd
+		default:
+			return nil, p.errorf(start, "expected case, when or default, found %q", kw)
+		}
+	}
+}
+
+func (p *scriptParser) try() (Command, error) {
+	body, err := p.blockCommand()
+	if err != nil {
+		return nil, err
+	}
+	t := CommandTry{Body: body}
+	if p.keyword("catch", true) {
+		if t.Catch, err = p.blockCommand(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("finally", true) {
+		if t.Finally, err = p.blockCommand(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (p *scriptParser) jsonCommand() (Command, error) {
+	p.skipSpace(false)
+	start := p.pos
+	raw, err := p.rawJSON()
+	if err != nil {
+		return nil, err
+	}
+	var rc RawCommand
+	if err := json.Unmarshal([]byte(raw), &rc); err != nil {
+		return nil, p.errorf(start, "invalid JSON command: %v", err)
+	}
+	if rc.Command == nil {
+		return nil, p.errorf(start, "invalid JSON command")
+	}
+	return rc.Command, nil
+}
+
+// equals consumes a "=" if it is next.
+func (p *scriptParser) equals() bool {
+	p.skipSpace(false)
+	if p.peek() == '=' {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *scriptParser) peekKeyword(kw string) bool {
+	start := p.pos
+	ok := p.keyword(kw, false)
+	p.pos = start
+	return ok
+}
+
+func (p *scriptParser) atStatementEnd() bool {
+	return p.eof() || strings.ContainsRune("\n;}#", rune(p.peek()))
+}
+
+// peekToken returns the upcoming text, for error messages.
+func (p *scriptParser) peekToken() string {
+	if p.eof() {
+		return "end of script"
+	}
+	end := p.pos
+	for end < len(p.src) && end-p.pos < 20 && !unicode.IsSpace(rune(p.src[end])) {
+		end++
+	}
+	if end == p.pos {
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		return string(r)
+	}
+	return p.src[p.pos:end]
+}
+
+// isWordByte reports whether c can appear in a bare word.
+func isWordByte(c byte) bool {
+	return c > ' ' && !strings.ContainsRune(`"';,=<>(){}[]#`, rune(c))
+}
+
+// bareWord reads a bare word: a key or pattern such as jobs/{id}/*/name,
+// users/user-[ab]* or users/${{$id}}. Braces are allowed in ${{...}}
+// references and in "/{name}" capture segments, and brackets in balanced
+// [...] groups.
+func (p *scriptParser) bareWord() string {
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "${{"):
+			end := strings.Index(p.src[p.pos:], "}}")
+			if end < 0 {
+				return p.src[start:p.pos]
+			}
+			p.pos += end + 2
+		case c == '{' && p.pos > start && p.src[p.pos-1] == '/':
+			end := strings.IndexByte(p.src[p.pos:], '}')
+			if end < 0 || strings.ContainsAny(p.src[p.pos+1:p.pos+end], " \t\n{") {
+				return p.src[start:p.pos]
+			}
+			p.pos += end + 1
+		case c == '[':
+			end := strings.IndexByte(p.src[p.pos:], ']')
+			if end < 0 || strings.ContainsAny(p.src[p.pos+1:p.pos+end], " \t\n[") {
+				return p.src[start:p.pos]
+			}
+			p.pos += end + 1
+		case isWordByte(c):
+			p.pos++
+		default:
+			return p.src[start:p.pos]
+		}
+	}
+	return p.src[start:p.pos]
+}
+
+// keyOrString reads a bare word or a JSON string.
+func (p *scriptParser) keyOrString() (string, bool, error) {
+	p.skipSpace(false)
+	if p.peek() == '"' {
+		start := p.pos
+		raw, err := p.rawString()
+		if err != nil {
+			return "", false, err
+		}
+		var s string
+		if err := json.Unmarshal([]byte(raw), &s); err != nil {
+			return "", false, p.errorf(start, "invalid string: %v", err)
+		}
+		return s, true, nil
+	}
+	return p.bareWord(), false, nil
+}
+
+func (p *scriptParser) key() (string, error) {
+	start := p.pos
+	key, _, err := p.keyOrString()
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", p.errorf(start, "expected a key, found %q", p.peekToken())
+	}
+	return key, nil
+}
+
+func (p *scriptParser) name() (string, error) {
+	p.skipSpace(false)
+	start := p.pos
+	name, _, err := p.keyOrString()
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", p.errorf(start, "expected a name, found %q", p.peekToken())
+	}
+	return name, nil
+}
+
+func (p *scriptParser) integer() (int, error) {
+	p.skipSpace(false)
+	start := p.pos
+	word := p.bareWord()
+	n, err := strconv.Atoi(word)
+	if err != nil {
+		return 0, p.errorf(start, "expected an integer, found %q", word)
+	}
+	return n, nil
+}
+
+// value reads a JSON literal, or a bare word as a string.
+func (p *scriptParser) value() (any, error) {
+	p.skipSpace(false)
+	start := p.pos
+	var raw string
+	switch c := p.peek(); {
+	case c == '"' || c == '[' || c == '{':
+		var err error
+		if raw, err = p.rawJSON(); err != nil {
+			return nil, err
+		}
+	default:
+		word := p.bareWord()
+		if word == "" {
+			return nil, p.errorf(start, "expected a value, found %q", p.peekToken())
+		}
+		switch {
+		case word == "true" || word == "false" || word == "null":
+			raw = word
+		case (word[0] >= '0' && word[0] <= '9') || (len(word) > 1 && (word[0] == '-' || word[0] == '.')):
+			if !json.Valid([]byte(word)) {
+				return word, nil
+			}
+			raw = word
+		default:
+			return word, nil
+		}
+	}
+
+	var val any
+	if err := DecodeJSON([]byte(raw), &val); err != nil {
+		return nil, p.errorf(start, "invalid value: %v", err)
+	}
+	return val, nil
+}
+
+// rawString reads a JSON string literal, including its quotes.
+func (p *scriptParser) rawString() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	for !p.eof() {
+		switch p.peek() {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			return p.src[start:p.pos], nil
+		case '\n':
+			return "", p.errorf(start, "unterminated string")
+		default:
+			p.pos++
+		}
+	}
+	return "", p.errorf(start, "unterminated string")
+}
+
+// rawJSON reads a JSON string, array or object literal.
+func (p *scriptParser) rawJSON() (string, error) {
+	start := p.pos
+	if p.peek() == '"' {
+		return p.rawString()
+	}
+	if p.peek() != '[' && p.peek() != '{' {
+		return "", p.errorf(start, "expected JSON, found %q", p.peekToken())
+	}
+
+	depth := 0
+	for !p.eof() {
+		switch p.peek() {
+		case '"':
+			if _, err := p.rawString(); err != nil {
+				return "", err
+			}
+			continue
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return p.src[start:p.pos], nil
+			}
+		}
+		p.pos++
+	}
+	return "", p.errorf(start, "unterminated %c", p.src[start])
+}
+
+// expr reads raw expression text. With untilBlock it ends at the "{" that
+// opens a block; otherwise at the end of the statement.
+func (p *scriptParser) expr(untilBlock bool) (string, error) {
+	p.skipSpace(false)
+	start := p.pos
+	depth := 0 // parentheses and brackets
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "${{"):
+			end := strings.Index(p.src[p.pos:], "}}")
+			if end < 0 {
+				return "", p.errorf(p.pos, "unterminated ${{")
+			}
+			p.pos += end + 2
+			continue
+		case c == '"' || c == '\'':
+			quote := c
+			p.pos++
+			for !p.eof() && p.peek() != quote {
+				if p.peek() == '\\' {
+					p.pos++
+				}
+				p.pos++
+			}
+			if p.eof() {
+				return "", p.errorf(start, "unterminated string in expression")
+			}
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case depth == 0 && untilBlock && c == '{':
+			return p.exprText(start)
+		case depth == 0 && !untilBlock && (c == '\n' || c == ';' || c == '}'):
+			return p.exprText(start)
+		}
+		p.pos++
+	}
+	if untilBlock {
+		return "", p.errorf(p.pos, "expected {, found end of script")
+	}
+	return p.exprText(start)
+}
+
+func (p *scriptParser) exprText(start int) (string, error) {
+	expr := strings.TrimSpace(p.src[start:p.pos])
+	if expr == "" {
+		return "", p.errorf(start, "expected an expression")
+	}
+	return expr, nil
+}
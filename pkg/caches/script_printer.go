@@ -0,0 +1,268 @@
+package caches
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FormatScript pretty-prints commands as a script that ParseScript reads back.
+func FormatScript(cmds ...Command) string {
+	var b strings.Builder
+	for _, cmd := range cmds {
+		writeStatement(&b, cmd, 0)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// FormatCommand pretty-prints a single command as a script statement.
+func FormatCommand(cmd Command) string {
+	var b strings.Builder
+	writeStatement(&b, cmd, 0)
+	return b.String()
+}
+
+func writeIndent(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+}
+
+// commandValue dereferences commands decoded from JSON, which are pointers.
+func commandValue(cmd Command) Command {
+	if v := reflect.ValueOf(cmd); v.Kind() == reflect.Ptr && !v.IsNil() {
+		if c, ok := v.Elem().Interface().(Command); ok {
+			return c
+		}
+	}
+	return cmd
+}
+
+func writeStatement(b *strings.Builder, cmd Command, depth int) {
+	switch c := commandValue(cmd).(type) {
+	case nil:
+		b.WriteString("noop")
+	case CommandNoop:
+		b.WriteString("noop")
+	case CommandGet:
+		b.WriteString("get " + formatKey(c.Key))
+	case CommandDelete:
+		b.WriteString("delete " + formatKey(c.Key))
+	case CommandInc:
+		b.WriteString("inc " + formatKey(c.Key))
+		if c.Value != int64(1) {
+			b.WriteString(" " + formatValue(c.Value))
+		}
+	case CommandReplace:
+		b.WriteString("replace " + formatKey(c.Key))
+		if c.Expr != "" {
+			b.WriteString(" = " + c.Expr)
+		} else {
+			b.WriteString(" " + formatValue(c.Value))
+		}
+	case CommandReturn:
+		if c.Expr != "" {
+			b.WriteString("return = " + c.Expr)
+		} else {
+			b.WriteString("return " + formatValue(c.Key))
+		}
+	case CommandPrint:
+		b.WriteString("print")
+		for i, msg := range c.Messages {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(" " + formatValue(msg))
+		}
+		if len(c.Messages) == 0 {
+			b.WriteString(` ""`)
+		}
+	case CommandLet:
+		b.WriteString("let " + formatKey(c.Name))
+		switch {
+		case c.Command != nil:
+			b.WriteString(" <- ")
+			writeStatement(b, c.Command, depth)
+		case c.Expr != "":
+			b.WriteString(" = " + c.Expr)
+		default:
+			b.WriteString(" " + formatValue(c.Value))
+		}
+	case CommandCall:
+		b.WriteString("call " + formatKey(c.Name))
+		if c.Version != 0 {
+			b.WriteString(" version " + strconv.Itoa(c.Version))
+		}
+		if len(c.Args) > 0 {
+			b.WriteString(" " + formatValue(c.Args))
+		}
+	case CommandQuery:
+		b.WriteString("query " + formatKey(c.Source))
+		options := c.Query
+		options.Source = ""
+		if data := formatValue(options); data != "{}" {
+			b.WriteString(" " + data)
+		}
+	case CommandGroup:
+		writeBlock(b, c.Actions, depth)
+	case CommandIf:
+		b.WriteString("if " + c.Condition + " ")
+		writeBlockCommand(b, c.IfTrue, depth)
+		ifFalse := commandValue(c.IfFalse)
+		if _, noop := ifFalse.(CommandNoop); ifFalse != nil && !noop {
+			b.WriteString(" else ")
+			if elseIf, ok := ifFalse.(CommandIf); ok {
+				writeStatement(b, elseIf, depth)
+			} else {
+				writeBlockCommand(b, ifFalse, depth)
+			}
+		}
+	case CommandFor:
+		b.WriteString("for ")
+		if c.Range != nil {
+			b.WriteString("range " + formatValue(c.Range.From) + " " + formatValue(c.Range.To))
+			if c.Range.Step != nil {
+				b.WriteString(" " + formatValue(c.Range.Step))
+			}
+		} else {
+			b.WriteString(formatPattern(c.LoopExpr))
+		}
+		if c.As != "" || c.IndexAs != "" {
+			b.WriteString(" as " + formatKey(orDefault(c.As, LoopItemVariable)))
+			if c.IndexAs != "" {
+				b.WriteString(", " + formatKey(c.IndexAs))
+			}
+		}
+		b.WriteByte(' ')
+		writeBlock(b, c.Commands, depth)
+		if c.MaxIterations > 0 {
+			b.WriteString(" max " + strconv.Itoa(c.MaxIterations))
+		}
+	case CommandWhile:
+		b.WriteString("while " + c.Condition + " ")
+		writeBlock(b, c.Commands, depth)
+		if c.MaxIterations > 0 {
+			b.WriteString(" max " + strconv.Itoa(c.MaxIterations))
+		}
+	case CommandSwitch:
+		b.WriteString("switch ")
+		if c.Expr != "" {
+			b.WriteString("= " + c.Expr)
+		} else {
+			b.WriteString(formatValue(c.Value))
+		}
+		b.WriteString(" {\n")
+		for _, sc := range c.Cases {
+			writeIndent(b, depth+1)
+			if sc.When != "" {
+				b.WriteString("when " + sc.When + " ")
+			} else {
+				b.WriteString("case " + formatValue(sc.Match) + " ")
+			}
+			writeBlockCommand(b, sc.Command, depth+1)
+			b.WriteByte('\n')
+		}
+		if c.Default != nil {
+			writeIndent(b, depth+1)
+			b.WriteString("default ")
+			writeBlockCommand(b, c.Default, depth+1)
+			b.WriteByte('\n')
+		}
+		writeIndent(b, depth)
+		b.WriteByte('}')
+	case CommandTry:
+		b.WriteString("try ")
+		writeBlockCommand(b, c.Body, depth)
+		if c.Catch != nil {
+			b.WriteString(" catch ")
+			writeBlockCommand(b, c.Catch, depth)
+		}
+		if c.Finally != nil {
+			b.WriteString(" finally ")
+			writeBlockCommand(b, c.Finally, depth)
+		}
+	case CommandIgnoreErrors:
+		b.WriteString("ignore_errors ")
+		writeStatement(b, c.Command, depth)
+	default:
+		data, err := json.Marshal(RawCommand{Command: cmd})
+		if err != nil {
+			data = []byte(`{"type":"NOOP"}`)
+		}
+		b.WriteString("json " + string(data))
+	}
+}
+
+// writeBlockCommand writes a command as a block, unwrapping groups.
+func writeBlockCommand(b *strings.Builder, cmd Command, depth int) {
+	switch c := commandValue(cmd).(type) {
+	case nil, CommandNoop:
+		writeBlock(b, nil, depth)
+	case CommandGroup:
+		writeBlock(b, c.Actions, depth)
+	default:
+		writeBlock(b, []Command{cmd}, depth)
+	}
+}
+
+func writeBlock(b *strings.Builder, cmds []Command, depth int) {
+	if len(cmds) == 0 {
+		b.WriteString("{}")
+		return
+	}
+	b.WriteString("{\n")
+	for _, cmd := range cmds {
+		writeIndent(b, depth+1)
+		writeStatement(b, cmd, depth+1)
+		b.WriteByte('\n')
+	}
+	writeIndent(b, depth)
+	b.WriteByte('}')
+}
+
+// formatKey writes a key or name bare when it reads back as the same word.
+func formatKey(key string) string {
+	p := &scriptParser{src: key}
+	if key != "" && p.bareWord() == key && !isScriptKeyword(key) {
+		return key
+	}
+	return formatValue(key)
+}
+
+// formatPattern writes a LoopExpr as a for pattern.
+func formatPattern(expr string) string {
+	if strings.HasPrefix(expr, "${{") && strings.HasSuffix(expr, "}}") && strings.Count(expr, "${{") == 1 {
+		inner := strings.TrimSpace(expr[3 : len(expr)-2])
+		if formatted := formatKey(inner); formatted == inner {
+			return inner
+		}
+	}
+	return formatValue(expr)
+}
+
+func isScriptKeyword(word string) bool {
+	switch strings.ToLower(word) {
+	case "true", "false", "null", "as", "max", "version", "range":
+		return true
+	}
+	return false
+}
+
+// formatValue writes a value as JSON, without escaping HTML characters.
+func formatValue(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "null"
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
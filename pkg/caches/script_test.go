@@ -0,0 +1,246 @@
+package caches
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/goodblaster/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScript_Statements(t *testing.T) {
+	cmds, err := ParseScript(`
+		# simple statements
+		noop
+		get users/1/name
+		delete "odd key"
+		inc counter; inc counter -2.5
+		replace status "done"
+		replace total = ${{a}} + ${{b}}
+		replace name bob
+		return {"ok": true}
+		return = ${{a}} * 2
+		print "hello", "world"
+		let x 5
+		let y = ${{x}} + 1
+		let z <- get users/1/name
+		call archive version 2 {"job": "${{$id}}"}
+		query sessions/* {"where": "${{$item/active}}", "limit": 5}
+		ignore_errors delete missing
+	`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Command{
+		NOOP(),
+		GET("users/1/name"),
+		DELETE("odd key"),
+		INC("counter", 1),
+		INC("counter", -2.5),
+		REPLACE("status", "done"),
+		REPLACE_EXPR("total", "${{a}} + ${{b}}"),
+		REPLACE("name", "bob"),
+		RETURN(map[string]any{"ok": true}),
+		RETURN_EXPR("${{a}} * 2"),
+		PRINT("hello", "world"),
+		LET("x", int64(5)),
+		LET_EXPR("y", "${{x}} + 1"),
+		LET_RESULT("z", GET("users/1/name")),
+		CommandCall{Name: "archive", Version: 2, Args: map[string]any{"job": "${{$id}}"}},
+		QUERY(Query{Source: "sessions/*", Where: "${{$item/active}}", Limit: 5}),
+		IGNORE_ERRORS(DELETE("missing")),
+	}, cmds)
+}
+
+func TestParseScript_BracketKeys(t *testing.T) {
+	cmds, err := ParseScript(`
+		get users/user-[ab]*
+		get items/[0]
+		delete [abc]?/x
+		replace list/[1] [1, 2]
+		for users/user-[!a]* as u { print ${{u}} }
+	`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Command{
+		GET("users/user-[ab]*"),
+		GET("items/[0]"),
+		DELETE("[abc]?/x"),
+		REPLACE("list/[1]", []any{int64(1), int64(2)}),
+		CommandFor{LoopExpr: "${{users/user-[!a]*}}", As: "u", Commands: []Command{PRINT("${{u}}")}},
+	}, cmds)
+
+	// An unbalanced bracket ends the word
+	_, err = ParseScript(`get items/[0`)
+	assert.Error(t, err)
+
+	// Keys with brackets are written bare and read back the same
+	for _, key := range []string{"users/user-[ab]*", "items/[0]"} {
+		text := FormatCommand(GET(key))
+		assert.Equal(t, "get "+key, text)
+		parsed, err := ParseScript(text)
+		require.NoError(t, err)
+		assert.Equal(t, []Command{GET(key)}, parsed)
+	}
+}
+
+func TestParseScript_ControlFlow(t *testing.T) {
+	cmds, err := ParseScript(`
+		if ${{n}} > 1 {
+			replace a 1
+			replace b 2
+		} else if ${{n}} == 1 {
+			replace a 1
+		}
+
+		for jobs/{job}/* as d, i { inc count } max 10
+		for range 0 ${{n}} 2 {}
+		while ${{count}} < 3 { inc count }
+
+		switch ${{state}} {
+			case "pending" { return 1 }
+			when ${{n}} > 2 { return 2 }
+			default { return 3 }
+		}
+
+		try { delete x } catch { print "failed" }
+		finally { noop }
+	`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Command{
+		IF("${{n}} > 1",
+			COMMANDS(REPLACE("a", int64(1)), REPLACE("b", int64(2))),
+			IF("${{n}} == 1", REPLACE("a", int64(1)), NOOP()),
+		),
+		CommandFor{LoopExpr: "${{jobs/{job}/*}}", As: "d", IndexAs: "i", MaxIterations: 10, Commands: []Command{INC("count", 1)}},
+		CommandFor{Range: &LoopRange{From: int64(0), To: "${{n}}", Step: int64(2)}},
+		WHILE("${{count}} < 3", INC("count", 1)),
+		SWITCH("${{state}}", RETURN(int64(3)),
+			CASE("pending", RETURN(int64(1))),
+			WHEN("${{n}} > 2", RETURN(int64(2))),
+		),
+		TRY(DELETE("x"), PRINT("failed"), NOOP()),
+	}, cmds)
+}
+
+func TestParseScript_JSONFallback(t *testing.T) {
+	cmds, err := ParseScript(`json {"type": "REPLACE", "key": "a", "value": [1, "}"]}`)
+	require.NoError(t, err)
+	require.Len(t, cmds, 1)
+	assert.Equal(t, &CommandReplace{Key: "a", Value: []any{int64(1), "}"}}, cmds[0])
+}
+
+func TestParseScript_Errors(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int
+		col  int
+	}{
+		{"jump a", 1, 1},
+		{"noop\n  get", 2, 6},
+		{"if ${{a}} {\n  noop\n", 3, 1},
+		{"replace a \"unterminated", 1, 11},
+		{"inc a b", 1, 7},
+		{"noop }", 1, 6},
+		{"switch 1 {\n  other {}\n}", 2, 3},
+		{"for range 1 { noop }", 1, 13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := ParseScript(tt.src)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrScriptSyntax))
+
+			var scriptErr *ScriptError
+			require.ErrorAs(t, err, &scriptErr)
+			assert.Equal(t, tt.line, scriptErr.Line, err.Error())
+			assert.Equal(t, tt.col, scriptErr.Column, err.Error())
+		})
+	}
+}
+
+func TestParseScript_Execute(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+	require.NoError(t, cache.Create(ctx, map[string]any{
+		"jobs": map[string]any{
+			"a": map[string]any{"countdown": 0.0, "status": "busy"},
+			"b": map[string]any{"countdown": 2.0, "status": "busy"},
+		},
+	}))
+
+	cmds, err := ParseScript(`
+		for jobs/{job}/countdown {
+			if ${{$item}} == 0 {
+				replace jobs/${{$job}}/status "complete"
+			} else {
+				inc jobs/${{$job}}/countdown -1
+			}
+		}
+	`)
+	require.NoError(t, err)
+	require.NoError(t, cache.Execute(ctx, cmds...).Error)
+
+	status, err := cache.Get(ctx, "jobs/a/status")
+	require.NoError(t, err)
+	assert.Equal(t, "complete", status)
+
+	countdown, err := cache.Get(ctx, "jobs/b/countdown")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, countdown)
+}
+
+func TestFormatScript_RoundTrip(t *testing.T) {
+	cmds := []Command{
+		NOOP(),
+		GET("a/b"),
+		DELETE("odd key"),
+		INC("n", 1),
+		INC("n", -0.5),
+		REPLACE("s", "<done>"),
+		REPLACE_EXPR("t", "${{a}} + 1"),
+		RETURN("${{a}}"),
+		RETURN_EXPR("len(${{list}})"),
+		PRINT("a", "b"),
+		LET("x", []any{1.0, "two"}),
+		LET_EXPR("y", "${{x}} * 2"),
+		LET_RESULT("z", GET("a")),
+		CommandCall{Name: "proc", Version: 3, Args: map[string]any{"a": 1.0}},
+		QUERY(Query{Source: "s/*"}),
+		QUERY(Query{Source: "s/{id}", Where: `${{$item/a}} > 1 && ${{$id}} != "x"`, OrderBy: []QueryOrder{{Field: "a", Desc: true}}, GroupBy: "b"}),
+		IF("${{a}} > 1", COMMANDS(INC("a", 1), INC("b", 1)), IF("${{a}} == 1", RETURN(1.0), RETURN(2.0))),
+		CommandFor{LoopExpr: "${{jobs/{id}/*}}", As: "v", IndexAs: "i", MaxIterations: 5, Commands: []Command{PRINT("x")}},
+		CommandFor{LoopExpr: "${{range}}", Commands: []Command{NOOP()}},
+		CommandFor{Range: &LoopRange{From: 1.0, To: 10.0}, Commands: []Command{NOOP()}},
+		CommandWhile{Condition: "${{a}} < 3", MaxIterations: 4, Commands: []Command{INC("a", 1)}},
+		SWITCH("${{s}}", RETURN("other"), CASE("a", RETURN(1.0)), WHEN("${{n}} > 1", COMMANDS(NOOP(), NOOP()))),
+		CommandSwitch{Expr: "${{n}} % 2", Cases: []SwitchCase{CASE(0.0, NOOP())}},
+		CommandTry{Body: DELETE("x"), Catch: PRINT("failed"), Finally: NOOP()},
+		IGNORE_ERRORS(DELETE("x")),
+		COMMANDS(NOOP(), GET("a")),
+	}
+
+	text := FormatScript(cmds...)
+	parsed, err := ParseScript(text)
+	require.NoError(t, err, text)
+
+	want, err := json.Marshal(rawCommands(cmds))
+	require.NoError(t, err)
+	got, err := json.Marshal(rawCommands(parsed))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(got), text)
+}
+
+func TestFormatCommand_DecodedJSON(t *testing.T) {
+	var raw RawCommand
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "IF",
+		"condition": "${{a}} == 1",
+		"if_true": {"type": "REPLACE", "key": "b", "value": 2},
+		"if_false": {"type": "DELETE", "key": "b"}
+	}`), &raw))
+
+	assert.Equal(t, "if ${{a}} == 1 {\n  replace b 2\n} else {\n  delete b\n}", FormatCommand(raw.Command))
+}
@@ -1,11 +1,40 @@
 package caches
 
 import (
+	"context"
 	"strings"
 
 	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/containers"
 )
 
+// SplitKey splits a key into the map keys and array indexes of its path,
+// removing escapes (see containers.ParsePath). A key that is not a valid path
+// is split on the delimiter as it stands.
 func SplitKey(key string) []string {
-	return strings.Split(key, config.KeyDelimiter)
+	if !strings.ContainsAny(key, `\[`) {
+		return strings.Split(key, config.KeyDelimiter)
+	}
+	segments, err := containers.ParsePath(key)
+	if err != nil {
+		return strings.Split(key, config.KeyDelimiter)
+	}
+	return containers.SegmentKeys(segments)
+}
+
+// splitPath splits a key like SplitKey, but fails on invalid paths and checks
+// typed segments against the data: "[0]" must index an array, and an escaped
+// segment must name a map key.
+func (cache *Cache) splitPath(ctx context.Context, key string) ([]string, error) {
+	if !strings.ContainsAny(key, `\[`) {
+		return strings.Split(key, config.KeyDelimiter), nil
+	}
+	segments, err := containers.ParsePath(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := containers.CheckPath(ctx, cache.cmap, key, segments); err != nil {
+		return nil, err
+	}
+	return containers.SegmentKeys(segments), nil
 }
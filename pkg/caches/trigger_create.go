@@ -7,6 +7,10 @@ import (
 )
 
 func (cache *Cache) CreateTrigger(ctx context.Context, key string, command Command) (string, error) {
+	if _, _, err := parseCapturePattern(key); err != nil {
+		return "", err
+	}
+
 	trigger := Trigger{
 		Id:      uuid.New().String(),
 		Key:     key,
@@ -5,6 +5,10 @@ import (
 )
 
 func (cache *Cache) ReplaceTrigger(ctx context.Context, id string, newTrigger Trigger) error {
+	if _, _, err := parseCapturePattern(newTrigger.Key); err != nil {
+		return err
+	}
+
 	for k, v := range cache.triggers {
 		for i, t := range v {
 			if t.Id == id {
@@ -2,9 +2,13 @@ package caches
 
 import (
 	"context"
+	"encoding/json"
 	"strings"
+	"time"
 
 	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/pkg/containers"
 )
 
 // Trigger recursion limits
@@ -28,6 +32,8 @@ var (
 )
 
 // Trigger - A trigger is a command that is executed when a specified key is modified.
+// Key may contain wildcards, either "*" (captured as ${{1}}, ${{2}}, ...) or
+// named captures such as "jobs/{jobId}/status" (captured as ${{$jobId}}).
 // For now, this command is only called on-change. Future versions may support
 // additional commands like on-delete.
 type Trigger struct {
@@ -36,6 +42,12 @@ type Trigger struct {
 	Command Command `json:"command"`
 }
 
+// MarshalJSON encodes the trigger as a RawTrigger, so that its command keeps
+// its type.
+func (t Trigger) MarshalJSON() ([]byte, error) {
+	return json.Marshal(RawTrigger{Id: t.Id, Key: t.Key, Command: RawCommand{Command: t.Command}})
+}
+
 // OnChange gets called whenever there was a successful data replacement.
 // All trigger keys are checked, and for each match, the trigger command is called.
 //
@@ -53,11 +65,16 @@ func (cache *Cache) OnChange(ctx context.Context, key string, oldValue any, newV
 	// Increment depth for nested trigger executions
 	ctx = context.WithValue(ctx, triggerDepthContextKey, depth+1)
 	for triggerKey, triggers := range cache.triggers {
-		matchingKeys := cache.KeysMatch(ctx, triggerKey, key)
+		// Named captures ({jobId}) match like "*"
+		wildKey, captureNames, err := parseCapturePattern(triggerKey)
+		if err != nil {
+			return err
+		}
+		matchingKeys := cache.KeysMatch(ctx, wildKey, key)
 
 		// Full list might be used later. For now, accept any match.
 		if len(matchingKeys) > 0 {
-			vars, err := ExtractWildcardMatches(key, triggerKey)
+			vars, err := ExtractWildcardMatches(key, wildKey)
 			if err != nil {
 				return errors.Wrapf(err, "failed to extract wildcard matches for key %s", key)
 			}
@@ -66,7 +83,21 @@ func (cache *Cache) OnChange(ctx context.Context, key string, oldValue any, newV
 				cmdCtx := context.WithValue(ctx, triggerVarsContextKey, vars)
 				cmdCtx = context.WithValue(cmdCtx, triggerOldValueContextKey, oldValue)
 				cmdCtx = context.WithValue(cmdCtx, triggerNewValueContextKey, newValue)
-				if res := trigger.Command.Do(cmdCtx, cache); res.Error != nil {
+				// Each trigger runs with its own variables, isolated from the caller's.
+				// Named captures are its initial variables.
+				s := newScope(nil)
+				bindCaptures(s, captureNames, vars)
+				cmdCtx = withScope(cmdCtx, s)
+				var fired *TriggerFired
+				if cache.dryRun != nil {
+					fired = cache.dryRun.triggerFired(trigger, key)
+				}
+				start := time.Now()
+				res := runCommand(cmdCtx, cache, trigger.Command)
+				if fired != nil {
+					fired.DurationUs = time.Since(start).Microseconds()
+				}
+				if res.Error != nil {
 					return errors.Wrap(res.Error, "trigger failed")
 				}
 			}
@@ -76,52 +107,86 @@ func (cache *Cache) OnChange(ctx context.Context, key string, oldValue any, newV
 	return nil
 }
 
+// KeysMatch returns dataKey if it matches triggerKey, which may contain "*",
+// "**" and glob segments. Keys are compared in canonical form, so
+// "users/\42" matches "users/42" and "items/[0]" matches "items/0".
 func (cache *Cache) KeysMatch(ctx context.Context, triggerKey, dataKey string) []string {
-	if !strings.Contains(triggerKey, "*") {
-		if triggerKey == dataKey {
+	dataKey = containers.CanonicalPath(dataKey)
+	if !containers.IsPattern(triggerKey) {
+		if containers.CanonicalPath(triggerKey) == dataKey {
 			return []string{dataKey}
 		}
 		return nil
 	}
 
-	var keys []string
-	wildKeys := cache.cmap.WildKeys(ctx, triggerKey)
-	for _, wildKey := range wildKeys {
-		if wildKey == dataKey {
-			keys = append(keys, wildKey)
-		}
+	if _, ok := containers.MatchPath(triggerKey, dataKey); ok {
+		return []string{dataKey}
 	}
-
-	return keys
+	return nil
 }
 
 // ExtractWildcardMatches returns values that match wildcards in triggerKey.
+// Each value is the matched segment as it would be written in a path, escaped
+// if needed, so that it can be substituted into other keys; for "**" it is the
+// matched segments joined as a path.
 func ExtractWildcardMatches(key, triggerKey string) ([]string, error) {
-	// Normalize by trimming any leading/trailing slashes
-	key = strings.Trim(key, "/")
-	triggerKey = strings.Trim(triggerKey, "/")
+	// Normalize by trimming any leading/trailing delimiters
+	key = trimDelimiters(key)
+	triggerKey = trimDelimiters(triggerKey)
 
-	keyParts := strings.Split(key, "/")
-	triggerParts := strings.Split(triggerKey, "/")
+	keyParts, err := containers.ParsePath(key)
+	if err != nil {
+		return nil, err
+	}
+	triggerParts, err := containers.ParsePath(triggerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, part := range triggerParts {
+		if part.Kind == containers.SegmentRecursive {
+			matches, ok := containers.MatchSegments(triggerParts, keyParts)
+			if !ok {
+				return nil, ErrPatternMismatch.Format(key, triggerKey)
+			}
+			return matches, nil
+		}
+	}
 
 	if len(keyParts) != len(triggerParts) {
-		return nil, ErrMismatchedPathLengths.Format(keyParts, triggerParts)
+		return nil, ErrMismatchedPathLengths.Format(containers.SegmentKeys(keyParts), containers.SegmentKeys(triggerParts))
 	}
 
 	var matches []string
 	for i := range keyParts {
-		if triggerParts[i] == "*" {
-			if keyParts[i] == "" {
+		if triggerParts[i].IsPattern() {
+			if keyParts[i].Key == "" {
 				return nil, ErrWildcardEmptySegment.Format(i)
 			}
-			matches = append(matches, keyParts[i])
-		} else if triggerParts[i] != keyParts[i] {
-			return nil, ErrSegmentMismatch.Format(i, triggerParts[i], keyParts[i])
+			if !triggerParts[i].Matches(keyParts[i].Key, false) {
+				return nil, ErrSegmentMismatch.Format(i, triggerParts[i].Pattern, keyParts[i].Key)
+			}
+			matches = append(matches, containers.EscapeKey(keyParts[i].Key))
+		} else if triggerParts[i].Key != keyParts[i].Key {
+			return nil, ErrSegmentMismatch.Format(i, triggerParts[i].Key, keyParts[i].Key)
 		}
 	}
 	return matches, nil
 }
 
+// trimDelimiters removes leading and trailing delimiters from a path, leaving
+// an escaped delimiter at the end in place.
+func trimDelimiters(path string) string {
+	delim := config.KeyDelimiter
+	for strings.HasPrefix(path, delim) {
+		path = path[len(delim):]
+	}
+	for strings.HasSuffix(path, delim) && !strings.HasSuffix(path, `\`+delim) {
+		path = path[:len(path)-len(delim)]
+	}
+	return path
+}
+
 // getTriggerDepth retrieves the current trigger recursion depth from context.
 // Returns 0 if not set (first trigger execution).
 func getTriggerDepth(ctx context.Context) int {
@@ -94,7 +94,7 @@ func TestTrigger_MaxDepth_Allowed(t *testing.T) {
 	// Verify the cascade worked: key9 should have been incremented
 	val, err := cache.Get(ctx, "key9")
 	require.NoError(t, err)
-	assert.Equal(t, float64(1), val) // INC uses float64
+	assert.Equal(t, int64(1), val) // integers stay integers
 }
 
 // TestTrigger_MaxDepth_Exceeded tests that exceeding MaxTriggerDepth returns an error
@@ -149,7 +149,7 @@ func TestTrigger_NormalOperation_StillWorks(t *testing.T) {
 	// Verify trigger fired (total should be 1 now)
 	val, err := cache.Get(ctx, "total")
 	require.NoError(t, err)
-	assert.Equal(t, float64(1), val)
+	assert.Equal(t, int64(1), val)
 }
 
 // TestTrigger_WildcardLoop tests infinite loop with wildcard patterns
@@ -254,6 +254,6 @@ func TestTrigger_MultipleIndependentTriggers(t *testing.T) {
 	for _, key := range []string{"dest1", "dest2", "dest3"} {
 		val, err := cache.Get(ctx, key)
 		require.NoError(t, err)
-		assert.Equal(t, float64(1), val)
+		assert.Equal(t, int64(1), val)
 	}
 }
@@ -262,6 +262,24 @@ func TestExtractWildcardMatches(t *testing.T) {
 			want:    nil,
 			wantErr: assert.NoError,
 		},
+		{
+			name: "wildcard matches an escaped segment",
+			args: args{
+				key:        `files/a\/b.txt/size`,
+				triggerKey: "files/*/size",
+			},
+			want:    []string{`a\/b.txt`},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "escaped star is literal",
+			args: args{
+				key:        "a/x",
+				triggerKey: `a/\*`,
+			},
+			want:    nil,
+			wantErr: assert.Error,
+		},
 		{
 			name: "wildcard mismatch in literal",
 			args: args{
@@ -300,3 +318,32 @@ func TestExtractWildcardMatches(t *testing.T) {
 		})
 	}
 }
+
+func TestTrigger_GlobAndRecursiveKeys(t *testing.T) {
+	ctx := context.Background()
+	cache := New()
+
+	err := cache.Create(ctx, map[string]any{
+		"jobs": map[string]any{
+			"job-1": map[string]any{"steps": map[string]any{"fetch": map[string]any{"status": "busy"}}},
+			"tmp-1": map[string]any{"status": "busy"},
+		},
+		"last": "",
+	})
+	assert.NoError(t, err)
+
+	_, err = cache.CreateTrigger(ctx, "jobs/job-*/**/status", REPLACE("last", "${{1}}:${{2}}"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.Replace(ctx, "jobs/job-1/steps/fetch/status", "done"))
+	val, err := cache.Get(ctx, "last")
+	assert.NoError(t, err)
+	assert.Equal(t, "job-1:steps/fetch", val)
+
+	// tmp-1 does not match job-*
+	assert.NoError(t, cache.Replace(ctx, "last", ""))
+	assert.NoError(t, cache.Replace(ctx, "jobs/tmp-1/status", "done"))
+	val, err = cache.Get(ctx, "last")
+	assert.NoError(t, err)
+	assert.Equal(t, "", val)
+}
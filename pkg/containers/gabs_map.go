@@ -3,11 +3,13 @@ package containers
 import (
 	"context"
 	"fmt"
+	"iter"
 	"reflect"
 	"strconv"
 	"strings"
 
 	"github.com/Jeffail/gabs/v2"
+	"github.com/goodblaster/map-cache/internal/config"
 )
 
 // GabsMap wraps a Gabs container for JSON-like nested data structures.
@@ -103,55 +105,111 @@ func (gMap *GabsMap) Data(ctx context.Context) map[string]any {
 	return gMap.container.Data().(map[string]any)
 }
 
+// WildKeys returns the paths of the values matching a path pattern, in which
+// "*" segments match every key of a map and every index of an array, globs
+// match the keys or indexes they fit, and "**" matches any number of segments
+// (see ParsePath). The paths are escaped as needed, with array indexes written
+// as plain integers.
 func (gMap *GabsMap) WildKeys(ctx context.Context, path string) []string {
 	var results []string
-	tokens := strings.Split(path, "/")
+	for key := range gMap.MatchKeys(ctx, path) {
+		results = append(results, key)
+	}
+	return results
+}
 
-	var walk func(node *gabs.Container, idx int, currentPath []string)
-	walk = func(node *gabs.Container, idx int, currentPath []string) {
-		if node == nil || node.Data() == nil {
+// MatchKeys yields the path and value of each value matching a path pattern,
+// as WildKeys does, without collecting them first.
+func (gMap *GabsMap) MatchKeys(ctx context.Context, path string) iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		tokens, err := ParsePath(path)
+		if err != nil {
 			return
 		}
 
-		if idx >= len(tokens) {
-			results = append(results, strings.Join(currentPath, "/"))
-			return
+		// With "**" the same path can be reached more than one way
+		var seen map[string]bool
+		for _, token := range tokens {
+			if token.Kind == SegmentRecursive {
+				seen = map[string]bool{}
+			}
 		}
 
-		token := tokens[idx]
+		// deep is set once the "**" at idx has matched a segment. walk returns
+		// false once yield asks to stop.
+		var walk func(node *gabs.Container, idx int, currentPath []string, deep bool) bool
+		walk = func(node *gabs.Container, idx int, currentPath []string, deep bool) bool {
+			if node == nil || node.Data() == nil {
+				return true
+			}
 
-		if token == "*" {
+			if idx >= len(tokens) {
+				key := strings.Join(currentPath, config.KeyDelimiter)
+				if seen != nil {
+					if seen[key] {
+						return true
+					}
+					seen[key] = true
+				}
+				return yield(key, node.Data())
+			}
+
+			token := tokens[idx]
 			switch data := node.Data().(type) {
 			case map[string]interface{}:
-				for key := range data {
-					child := node.Path(key)
-					walk(child, idx+1, append(currentPath, key))
+				switch token.Kind {
+				case SegmentRecursive:
+					if (idx+1 < len(tokens) || deep) && !walk(node, idx+1, currentPath, false) {
+						return false
+					}
+					for key := range data {
+						if !walk(node.Search(key), idx, append(currentPath, EscapeKey(key)), true) {
+							return false
+						}
+					}
+				case SegmentWildcard, SegmentGlob:
+					for key := range data {
+						if token.Matches(key, false) && !walk(node.Search(key), idx+1, append(currentPath, EscapeKey(key)), false) {
+							return false
+						}
+					}
+				case SegmentIndex:
+				default:
+					return walk(node.Search(token.Key), idx+1, append(currentPath, EscapeKey(token.Key)), false)
 				}
 			case []interface{}:
-				for i := range data {
-					child := node.Index(i)
-					// Use strconv.Itoa instead of fmt.Sprintf for better performance
-					walk(child, idx+1, append(currentPath, strconv.Itoa(i)))
+				switch token.Kind {
+				case SegmentRecursive:
+					if (idx+1 < len(tokens) || deep) && !walk(node, idx+1, currentPath, false) {
+						return false
+					}
+					for i := range data {
+						if !walk(node.Index(i), idx, append(currentPath, strconv.Itoa(i)), true) {
+							return false
+						}
+					}
+				case SegmentWildcard, SegmentGlob:
+					for i := range data {
+						// Use strconv.Itoa instead of fmt.Sprintf for better performance
+						index := strconv.Itoa(i)
+						if token.Matches(index, true) && !walk(node.Index(i), idx+1, append(currentPath, index), false) {
+							return false
+						}
+					}
+				case SegmentKey:
+				default:
+					if i, err := strconv.Atoi(token.Key); err == nil {
+						return walk(node.Index(i), idx+1, append(currentPath, strconv.Itoa(i)), false)
+					}
 				}
-			}
-		} else {
-			// Try map lookup
-			child := node.Path(token)
-			if child != nil && child.Data() != nil {
-				walk(child, idx+1, append(currentPath, token))
-				return
-			}
-
-			// Try array index
-			if i, err := strconv.Atoi(token); err == nil {
-				child := node.Index(i)
-				if child != nil && child.Data() != nil {
-					walk(child, idx+1, append(currentPath, token))
+			default:
+				if token.Kind == SegmentRecursive && deep {
+					return walk(node, idx+1, currentPath, false)
 				}
 			}
+			return true
 		}
-	}
 
-	walk(gMap.container, 0, []string{})
-	return results
+		walk(gMap.container, 0, []string{}, false)
+	}
 }
@@ -0,0 +1,115 @@
+package containers
+
+import "unicode/utf8"
+
+// MatchGlob reports whether name matches a glob pattern, as Redis matches
+// keys: "*" matches any run of characters, "?" any one character, "[abc]",
+// "[a-z]" and "[^abc]" one character from (or not from) a set, and a backslash
+// makes the next character literal. An unclosed "[" is literal.
+func MatchGlob(pattern, name string) bool {
+	px, nx := 0, 0
+	// Where to resume after a mismatch: the last "*", one character further on
+	starPx, starNx := -1, 0
+	for px < len(pattern) || nx < len(name) {
+		if px < len(pattern) {
+			switch pattern[px] {
+			case '*':
+				starPx, starNx = px, nx+runeWidth(name, nx)
+				px++
+				continue
+			case '?':
+				if nx < len(name) {
+					px++
+					nx += runeWidth(name, nx)
+					continue
+				}
+			case '[':
+				if nx < len(name) {
+					r, size := utf8.DecodeRuneInString(name[nx:])
+					matched, width, ok := matchClass(pattern[px:], r)
+					if !ok && name[nx] == '[' {
+						px++
+						nx++
+						continue
+					}
+					if ok && matched {
+						px += width
+						nx += size
+						continue
+					}
+				}
+			case '\\':
+				if px+1 < len(pattern) {
+					_, size := utf8.DecodeRuneInString(pattern[px+1:])
+					lit := pattern[px+1 : px+1+size]
+					if len(name)-nx >= size && name[nx:nx+size] == lit {
+						px += 1 + size
+						nx += size
+						continue
+					}
+					break
+				}
+				fallthrough
+			default:
+				if nx < len(name) && name[nx] == pattern[px] {
+					px++
+					nx++
+					continue
+				}
+			}
+		}
+		if starPx >= 0 && starNx <= len(name) {
+			px, nx = starPx, starNx
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// matchClass matches r against the "[...]" class at the start of pattern. It
+// returns whether r is in the class, the width of the class in pattern, and
+// false if the class is not closed.
+func matchClass(pattern string, r rune) (matched bool, width int, ok bool) {
+	i := 1
+	negate := i < len(pattern) && (pattern[i] == '^' || pattern[i] == '!')
+	if negate {
+		i++
+	}
+	for i < len(pattern) && pattern[i] != ']' {
+		lo, size := classRune(pattern, i)
+		i += size
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			hi, size = classRune(pattern, i+1)
+			i += 1 + size
+		}
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+	if i >= len(pattern) {
+		return false, 0, false
+	}
+	return matched != negate, i + 1, true
+}
+
+// classRune reads one, possibly escaped, character of a class.
+func classRune(pattern string, i int) (rune, int) {
+	if pattern[i] == '\\' && i+1 < len(pattern) {
+		r, size := utf8.DecodeRuneInString(pattern[i+1:])
+		return r, 1 + size
+	}
+	return utf8.DecodeRuneInString(pattern[i:])
+}
+
+func runeWidth(s string, i int) int {
+	if i >= len(s) {
+		return 1
+	}
+	_, size := utf8.DecodeRuneInString(s[i:])
+	return size
+}
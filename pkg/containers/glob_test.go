@@ -0,0 +1,39 @@
+package containers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"user-*", "user-42", true},
+		{"user-*", "admin-42", false},
+		{"user:1*", "user:10:name", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"*.txt", "a.b.txt", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{`\*`, "*", true},
+		{`\*`, "x", false},
+		{`a\?`, "a?", true},
+		{"a[b", "a[b", true},
+		{"?", "é", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchGlob(tt.pattern, tt.name), "%q ~ %q", tt.pattern, tt.name)
+	}
+}
@@ -1,6 +1,9 @@
 package containers
 
-import "context"
+import (
+	"context"
+	"iter"
+)
 
 type Data any
 type Map interface {
@@ -13,4 +16,5 @@ type Map interface {
 	Exists(ctx context.Context, hierarchy ...string) bool
 	Data(ctx context.Context) map[string]any
 	WildKeys(ctx context.Context, path string) []string
+	MatchKeys(ctx context.Context, path string) iter.Seq2[string, any]
 }
@@ -0,0 +1,355 @@
+package containers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goodblaster/errors"
+	"github.com/goodblaster/map-cache/internal/config"
+)
+
+// Paths name values nested in maps and arrays, one segment per level,
+// separated by KEY_DELIMITER ("/" by default):
+//
+//	users/42/name      key "42" of a map, or index 42 of an array
+//	users/\42/name     key "42", never an array index
+//	items/[0]/name     index 0, never a map key
+//	files/a\/b.txt     key "a/b.txt"
+//	users/*/name       any key or index, in patterns
+//	users/user-?/name  keys matching a glob (see MatchGlob), in patterns
+//	users/**/name      any number of segments, in patterns
+//
+// A backslash makes the next character literal, so keys can hold the
+// delimiter, "*", "?", "[" or "\" itself, and marks the segment as a map key.
+// A segment starting with "[" is always an array index, and an error unless
+// it is "[N]"; a glob can use a character class anywhere but at its start, so
+// "items/[12]" is index 12 while "items/x[12]" matches "x1" and "x2".
+// A "**" ending a pattern matches one or more segments, so "users/**" is
+// everything under users but not users itself.
+
+// SegmentKind says how a path segment addresses its parent.
+type SegmentKind int
+
+const (
+	SegmentAny       SegmentKind = iota // a map key, or an array index if it is an integer
+	SegmentKey                          // a map key only
+	SegmentIndex                        // an array index only
+	SegmentWildcard                     // every key or index, in patterns
+	SegmentGlob                         // keys or indexes matching Pattern, in patterns
+	SegmentRecursive                    // any number of segments, in patterns
+)
+
+// Segment is one level of a parsed path.
+type Segment struct {
+	Kind    SegmentKind
+	Key     string // unescaped; the index in decimal for SegmentIndex
+	Pattern string // the glob, escapes included, for SegmentGlob
+}
+
+// IsPattern reports whether a segment can match more than one key.
+func (seg Segment) IsPattern() bool {
+	return seg.Kind == SegmentWildcard || seg.Kind == SegmentGlob || seg.Kind == SegmentRecursive
+}
+
+// Matches reports whether a segment other than "**" matches a key of a map,
+// or an index of an array if inArray.
+func (seg Segment) Matches(name string, inArray bool) bool {
+	switch seg.Kind {
+	case SegmentWildcard:
+		return true
+	case SegmentGlob:
+		return MatchGlob(seg.Pattern, name)
+	case SegmentIndex:
+		return inArray && name == seg.Key
+	case SegmentKey:
+		return !inArray && name == seg.Key
+	case SegmentRecursive:
+		return false
+	}
+	return name == seg.Key
+}
+
+var (
+	ErrInvalidPath = errors.New("invalid path %q: %s")
+	ErrPathType    = errors.New("path %q: %s")
+)
+
+// ParsePath splits a path into segments, removing escapes.
+func ParsePath(path string) ([]Segment, error) {
+	delim := config.KeyDelimiter
+	var segments []Segment
+	var key strings.Builder
+	escaped := false
+
+	end := func(raw string) error {
+		seg := Segment{Key: key.String()}
+		switch {
+		case raw == "*":
+			seg.Kind = SegmentWildcard
+		case raw == "**":
+			seg.Kind = SegmentRecursive
+		case strings.HasPrefix(raw, "["):
+			// Always an index: a glob can't start with a character class
+			index, err := strconv.Atoi(strings.TrimSuffix(raw[1:], "]"))
+			if !isIndex(raw) || err != nil {
+				return ErrInvalidPath.Format(path, "bad array index "+raw)
+			}
+			seg = Segment{Kind: SegmentIndex, Key: strconv.Itoa(index)}
+		case hasGlob(raw):
+			seg.Kind = SegmentGlob
+			seg.Pattern = raw
+		case escaped:
+			seg.Kind = SegmentKey
+		}
+		segments = append(segments, seg)
+		key.Reset()
+		escaped = false
+		return nil
+	}
+
+	start := 0
+	for i := 0; i < len(path); {
+		switch {
+		case path[i] == '\\':
+			if i+1 == len(path) {
+				return nil, ErrInvalidPath.Format(path, "trailing backslash")
+			}
+			_, size := utf8.DecodeRuneInString(path[i+1:])
+			key.WriteString(path[i+1 : i+1+size])
+			escaped = true
+			i += 1 + size
+		case delim != "" && strings.HasPrefix(path[i:], delim):
+			if err := end(path[start:i]); err != nil {
+				return nil, err
+			}
+			i += len(delim)
+			start = i
+		default:
+			key.WriteByte(path[i])
+			i++
+		}
+	}
+	if err := end(path[start:]); err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+// isIndex reports whether a raw segment is an array index, "[N]".
+func isIndex(raw string) bool {
+	if len(raw) < 3 || raw[0] != '[' || raw[len(raw)-1] != ']' {
+		return false
+	}
+	for _, c := range raw[1 : len(raw)-1] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// hasGlob reports whether a raw segment has unescaped glob characters.
+func hasGlob(raw string) bool {
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// IsPattern reports whether path has segments that can match more than one
+// key: "*", "**" or globs. Invalid paths are not patterns.
+func IsPattern(path string) bool {
+	if !strings.ContainsAny(path, "*?[") {
+		return false
+	}
+	segments, err := ParsePath(path)
+	if err != nil {
+		return false
+	}
+	for _, seg := range segments {
+		if seg.IsPattern() {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitPath splits a path at its delimiters, leaving escapes in place, so that
+// a pattern can be rewritten segment by segment and joined again.
+func SplitPath(path string) []string {
+	delim := config.KeyDelimiter
+	var parts []string
+	start := 0
+	for i := 0; i < len(path); {
+		switch {
+		case path[i] == '\\':
+			i += 2
+		case delim != "" && strings.HasPrefix(path[i:], delim):
+			parts = append(parts, path[start:i])
+			i += len(delim)
+			start = i
+		default:
+			i++
+		}
+	}
+	return append(parts, path[min(start, len(path)):])
+}
+
+// SegmentKeys returns the keys of segments, for use as a Map hierarchy.
+func SegmentKeys(segments []Segment) []string {
+	keys := make([]string, len(segments))
+	for i, seg := range segments {
+		keys[i] = seg.Key
+	}
+	return keys
+}
+
+// EscapeKey escapes a map key for use as one path segment.
+func EscapeKey(key string) string {
+	if !strings.ContainsAny(key, `\*?[`) && !strings.Contains(key, config.KeyDelimiter) {
+		return key
+	}
+	var b strings.Builder
+	for i := 0; i < len(key); {
+		if strings.HasPrefix(key[i:], config.KeyDelimiter) {
+			for _, r := range config.KeyDelimiter {
+				b.WriteByte('\\')
+				b.WriteRune(r)
+			}
+			i += len(config.KeyDelimiter)
+			continue
+		}
+		switch key[i] {
+		case '\\', '*', '?', '[':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(key[i])
+		i++
+	}
+	return b.String()
+}
+
+// JoinPath builds a path from unescaped keys.
+func JoinPath(keys ...string) string {
+	escaped := make([]string, len(keys))
+	for i, key := range keys {
+		escaped[i] = EscapeKey(key)
+	}
+	return strings.Join(escaped, config.KeyDelimiter)
+}
+
+// CanonicalPath rewrites path in the form WildKeys returns, so that two ways of
+// writing the same path compare equal. Invalid paths are returned unchanged.
+func CanonicalPath(path string) string {
+	segments, err := ParsePath(path)
+	if err != nil {
+		return path
+	}
+	parts := make([]string, len(segments))
+	for i, seg := range segments {
+		switch seg.Kind {
+		case SegmentWildcard:
+			parts[i] = "*"
+		case SegmentRecursive:
+			parts[i] = "**"
+		case SegmentGlob:
+			parts[i] = seg.Pattern
+		default:
+			parts[i] = EscapeKey(seg.Key)
+		}
+	}
+	return strings.Join(parts, config.KeyDelimiter)
+}
+
+// CheckPath checks the typed segments of path against the values in m: an
+// index segment needs an array, and an escaped key segment needs a map or
+// nothing yet. Plain segments are not checked.
+func CheckPath(ctx context.Context, m Map, path string, segments []Segment) error {
+	keys := SegmentKeys(segments)
+	for i, seg := range segments {
+		if seg.Kind != SegmentKey && seg.Kind != SegmentIndex {
+			continue
+		}
+		parent := any(m.Data(ctx))
+		if i > 0 {
+			data, err := m.Get(ctx, keys[:i]...)
+			if err != nil {
+				if seg.Kind == SegmentIndex {
+					return ErrNotFound
+				}
+				return nil
+			}
+			parent = data
+		}
+		_, isArray := parent.([]any)
+		if seg.Kind == SegmentIndex && !isArray {
+			return ErrPathType.Format(path, "["+seg.Key+"] indexes a value that is not an array")
+		}
+		if seg.Kind == SegmentKey && isArray {
+			return ErrPathType.Format(path, "key "+strconv.Quote(seg.Key)+" of an array")
+		}
+	}
+	return nil
+}
+
+// MatchPath reports whether path matches pattern, and returns what each
+// pattern segment ("*", "**" or a glob) matched, escaped as in a path.
+func MatchPath(pattern, path string) ([]string, bool) {
+	patternSegs, err := ParsePath(pattern)
+	if err != nil {
+		return nil, false
+	}
+	pathSegs, err := ParsePath(path)
+	if err != nil {
+		return nil, false
+	}
+	return MatchSegments(patternSegs, pathSegs)
+}
+
+// MatchSegments is MatchPath for parsed paths.
+func MatchSegments(pattern, path []Segment) ([]string, bool) {
+	if len(pattern) == 0 {
+		return nil, len(path) == 0
+	}
+
+	seg := pattern[0]
+	if seg.Kind == SegmentRecursive {
+		// Try the shortest match first; at the end, "**" needs one segment
+		least := 0
+		if len(pattern) == 1 {
+			least = 1
+		}
+		for n := least; n <= len(path); n++ {
+			if rest, ok := MatchSegments(pattern[1:], path[n:]); ok {
+				return append([]string{JoinPath(SegmentKeys(path[:n])...)}, rest...), true
+			}
+		}
+		return nil, false
+	}
+
+	if len(path) == 0 {
+		return nil, false
+	}
+	if seg.IsPattern() {
+		if !seg.Matches(path[0].Key, false) && !seg.Matches(path[0].Key, true) {
+			return nil, false
+		}
+	} else if seg.Key != path[0].Key {
+		return nil, false
+	}
+	rest, ok := MatchSegments(pattern[1:], path[1:])
+	if !ok {
+		return nil, false
+	}
+	if seg.IsPattern() {
+		return append([]string{EscapeKey(path[0].Key)}, rest...), true
+	}
+	return rest, true
+}
@@ -0,0 +1,160 @@
+package containers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []Segment
+	}{
+		{"users/42/name", []Segment{{Kind: SegmentAny, Key: "users"}, {Kind: SegmentAny, Key: "42"}, {Kind: SegmentAny, Key: "name"}}},
+		{`users/\42`, []Segment{{Kind: SegmentAny, Key: "users"}, {Kind: SegmentKey, Key: "42"}}},
+		{"items/[0]", []Segment{{Kind: SegmentAny, Key: "items"}, {Kind: SegmentIndex, Key: "0"}}},
+		{`files/a\/b.txt`, []Segment{{Kind: SegmentAny, Key: "files"}, {Kind: SegmentKey, Key: "a/b.txt"}}},
+		{`a/\*/\\`, []Segment{{Kind: SegmentAny, Key: "a"}, {Kind: SegmentKey, Key: "*"}, {Kind: SegmentKey, Key: `\`}}},
+		{`a/\[0]`, []Segment{{Kind: SegmentAny, Key: "a"}, {Kind: SegmentKey, Key: "[0]"}}},
+		{"users/*/name", []Segment{{Kind: SegmentAny, Key: "users"}, {Kind: SegmentWildcard, Key: "*"}, {Kind: SegmentAny, Key: "name"}}},
+		{"**/user-?", []Segment{{Kind: SegmentRecursive, Key: "**"}, {Kind: SegmentGlob, Key: "user-?", Pattern: "user-?"}}},
+		{`a\*[bc]`, []Segment{{Kind: SegmentGlob, Key: "a*[bc]", Pattern: `a\*[bc]`}}},
+		{"items/[12]", []Segment{{Kind: SegmentAny, Key: "items"}, {Kind: SegmentIndex, Key: "12"}}},
+		{"items/x[12]", []Segment{{Kind: SegmentAny, Key: "items"}, {Kind: SegmentGlob, Key: "x[12]", Pattern: "x[12]"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := ParsePath(tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, bad := range []string{`a\`, "a/[x]", "a/[-1]", "a/[1", "a/[12]x", "a/[ab]*"} {
+		_, err := ParsePath(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestEscapeKey_RoundTrip(t *testing.T) {
+	for _, key := range []string{"plain", "a/b", "*", "what?", `back\slash`, "[0]", "42", ""} {
+		segments, err := ParsePath(EscapeKey(key))
+		require.NoError(t, err)
+		require.Len(t, segments, 1)
+		assert.Equal(t, key, segments[0].Key)
+	}
+
+	assert.Equal(t, `files/a\/b/\*`, JoinPath("files", "a/b", "*"))
+	assert.Equal(t, "items/0/x", CanonicalPath(`items/[0]/\x`))
+}
+
+func TestSplitPath(t *testing.T) {
+	assert.Equal(t, []string{"a", `b\/c`, "{id}"}, SplitPath(`a/b\/c/{id}`))
+}
+
+func TestCheckPath(t *testing.T) {
+	ctx := context.Background()
+	gMap := NewGabsMap()
+	require.NoError(t, gMap.Set(ctx, map[string]any{
+		"users": map[string]any{"42": "alice"},
+		"items": []any{"a", "b"},
+	}))
+
+	check := func(path string) error {
+		segments, err := ParsePath(path)
+		require.NoError(t, err)
+		return CheckPath(ctx, gMap, path, segments)
+	}
+
+	assert.NoError(t, check(`users/\42`))
+	assert.NoError(t, check("items/[1]"))
+	assert.NoError(t, check(`new/\key`))
+	assert.Error(t, check("users/[0]"))
+	assert.Error(t, check(`items/\0`))
+	assert.Error(t, check("missing/[0]"))
+}
+
+func TestGabsMap_WildKeys_EscapedKeys(t *testing.T) {
+	ctx := context.Background()
+	gMap := NewGabsMap()
+	require.NoError(t, gMap.Set(ctx, map[string]any{
+		"files": map[string]any{
+			"a/b.txt": map[string]any{"size": 1},
+			"c.d":     map[string]any{"size": 2},
+			"*":       map[string]any{"size": 3},
+		},
+	}))
+
+	keys := gMap.WildKeys(ctx, "files/*/size")
+	assert.ElementsMatch(t, []string{`files/a\/b.txt/size`, "files/c.d/size", `files/\*/size`}, keys)
+
+	// An escaped "*" matches only the key "*"
+	assert.Equal(t, []string{`files/\*/size`}, gMap.WildKeys(ctx, `files/\*/size`))
+	assert.Equal(t, []string{`files/a\/b.txt/size`}, gMap.WildKeys(ctx, `files/a\/b.txt/size`))
+}
+
+func TestMatchPath(t *testing.T) {
+	captures, ok := MatchPath("users/*/name", "users/42/name")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"42"}, captures)
+
+	captures, ok = MatchPath("logs/**/error-?", "logs/2024/05/error-1")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"2024/05", "error-1"}, captures)
+
+	// "**" in the middle may match nothing; at the end it needs a segment
+	_, ok = MatchPath("a/**/b", "a/b")
+	assert.True(t, ok)
+	_, ok = MatchPath("a/**", "a")
+	assert.False(t, ok)
+
+	captures, ok = MatchPath("files/*", `files/a\/b`)
+	assert.True(t, ok)
+	assert.Equal(t, []string{`a\/b`}, captures)
+
+	_, ok = MatchPath("users/user-*", "users/admin-1")
+	assert.False(t, ok)
+}
+
+func TestGabsMap_WildKeys_Globs(t *testing.T) {
+	ctx := context.Background()
+	gMap := NewGabsMap()
+	require.NoError(t, gMap.Set(ctx, map[string]any{
+		"users": map[string]any{
+			"user-1":  map[string]any{"name": "a"},
+			"user-2":  map[string]any{"name": "b"},
+			"user-10": map[string]any{"name": "c"},
+			"admin":   map[string]any{"name": "d"},
+		},
+		"items": []any{"x", "y"},
+	}))
+
+	assert.ElementsMatch(t, []string{"users/user-1", "users/user-2", "users/user-10"}, gMap.WildKeys(ctx, "users/user-*"))
+	assert.ElementsMatch(t, []string{"users/user-1/name", "users/user-2/name"}, gMap.WildKeys(ctx, "users/user-?/name"))
+	assert.ElementsMatch(t, []string{"users/user-1", "users/user-2"}, gMap.WildKeys(ctx, "users/user-[12]"))
+	assert.ElementsMatch(t, []string{"items/1"}, gMap.WildKeys(ctx, "items/*[1-9]"))
+}
+
+func TestGabsMap_WildKeys_Recursive(t *testing.T) {
+	ctx := context.Background()
+	gMap := NewGabsMap()
+	require.NoError(t, gMap.Set(ctx, map[string]any{
+		"a": map[string]any{
+			"name": 1,
+			"b": map[string]any{
+				"name": 2,
+				"c":    []any{map[string]any{"name": 3}},
+			},
+		},
+	}))
+
+	assert.ElementsMatch(t, []string{"a/name", "a/b/name", "a/b/c/0/name"}, gMap.WildKeys(ctx, "**/name"))
+	assert.ElementsMatch(t, []string{"a/b/name", "a/b/c/0/name"}, gMap.WildKeys(ctx, "a/b/**/name"))
+	assert.ElementsMatch(t, []string{"a/b/name", "a/b/c", "a/b/c/0", "a/b/c/0/name"}, gMap.WildKeys(ctx, "a/b/**"))
+
+	// Each path is listed once however many ways it matches
+	assert.ElementsMatch(t, []string{"a/name", "a/b/name", "a/b/c/0/name"}, gMap.WildKeys(ctx, "**/**/name"))
+}
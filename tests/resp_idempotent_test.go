@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRESP_IDEMPOTENT(t *testing.T) {
+	client := setupRESPClient(t)
+	defer client.Close()
+	ctx := context.Background()
+	defer cleanupKeys(client)
+
+	client.Set(ctx, "counter", "0", 0)
+
+	// First call runs INCR
+	val, err := client.Do(ctx, "IDEMPOTENT", "incr-1", "INCR", "counter").Int64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+
+	// Retry replays the reply without running it again
+	val, err = client.Do(ctx, "IDEMPOTENT", "incr-1", "INCR", "counter").Int64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+
+	got, err := client.Get(ctx, "counter").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "1", got)
+
+	// Same key, different command
+	err = client.Do(ctx, "IDEMPOTENT", "incr-1", "INCRBY", "counter", "5").Err()
+	assert.Error(t, err)
+}
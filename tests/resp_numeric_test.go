@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRESP_Numeric_IntegersStayExact(t *testing.T) {
+	client := setupRESPClient(t)
+	defer client.Close()
+	ctx := context.Background()
+	defer cleanupKeys(client)
+
+	// Beyond 2^53, where a float64 would round
+	client.Set(ctx, "big", "9007199254740992", 0)
+	val, err := client.Incr(ctx, "big").Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), val)
+
+	client.Set(ctx, "max", "9223372036854775807", 0)
+	err = client.Incr(ctx, "max").Err()
+	assert.ErrorContains(t, err, "overflow")
+
+	// INCRBYFLOAT turns an integer into a float
+	f, err := client.IncrByFloat(ctx, "big", 0.5).Result()
+	require.NoError(t, err)
+	assert.InDelta(t, 9007199254740993.5, f, 2)
+
+	err = client.Incr(ctx, "big").Err()
+	assert.ErrorContains(t, err, "not an integer")
+}
+
+func TestRESP_Numeric_FailedIncrChangesNothing(t *testing.T) {
+	client := setupRESPClient(t)
+	defer client.Close()
+	ctx := context.Background()
+	defer cleanupKeys(client)
+
+	client.Set(ctx, "price", "3.5", 0)
+	err := client.Incr(ctx, "price").Err()
+	assert.ErrorContains(t, err, "not an integer")
+
+	val, err := client.Get(ctx, "price").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "3.5", val)
+
+	client.Set(ctx, "max", "9223372036854775807", 0)
+	err = client.Incr(ctx, "max").Err()
+	assert.ErrorContains(t, err, "overflow")
+
+	val, err = client.Get(ctx, "max").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "9223372036854775807", val)
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRESP_Paths_EscapedKeys(t *testing.T) {
+	client := setupRESPClient(t)
+	defer client.Close()
+	ctx := context.Background()
+	defer cleanupKeys(client)
+
+	// "/" is part of the key, and "\:" is a literal colon
+	require.NoError(t, client.Set(ctx, "files:report/2024.pdf", "r", 0).Err())
+	require.NoError(t, client.Set(ctx, `files:12\:30`, "t", 0).Err())
+
+	val, err := client.Get(ctx, "files:report/2024.pdf").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "r", val)
+
+	keys, err := client.Keys(ctx, "files:*").Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"files:report/2024.pdf", `files:12\:30`}, keys)
+
+	// Numeric segments under a map are keys, so deleting one leaves the rest
+	require.NoError(t, client.Set(ctx, "users:7", "alice", 0).Err())
+	require.NoError(t, client.Set(ctx, "users:8", "bob", 0).Err())
+	require.NoError(t, client.Del(ctx, "users:7").Err())
+	val, err = client.Get(ctx, "users:8").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "bob", val)
+}
+
+func TestRESP_Paths_KeysPatterns(t *testing.T) {
+	client := setupRESPClient(t)
+	defer client.Close()
+	ctx := context.Background()
+	defer cleanupKeys(client)
+
+	for _, key := range []string{"user:1:name", "user:15:name", "user:2:name", "user:1:address:city"} {
+		require.NoError(t, client.Set(ctx, key, "x", 0).Err())
+	}
+
+	// Globs stay within a segment
+	keys, err := client.Keys(ctx, "user:1*").Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user:1", "user:15"}, keys)
+
+	keys, err = client.Keys(ctx, "user:?:name").Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user:1:name", "user:2:name"}, keys)
+
+	// "**" spans segments
+	keys, err = client.Keys(ctx, "user:**:city").Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user:1:address:city"}, keys)
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/goodblaster/map-cache/internal/config"
+	"github.com/goodblaster/map-cache/internal/resp"
+	"github.com/goodblaster/map-cache/pkg/caches"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupLocalRESPClient starts a RESP server in the test process, with its own
+// cache and the given RESP_PATTERN_MODE, and returns a client for it.
+func setupLocalRESPClient(t *testing.T, patternMode string) *redis.Client {
+	// Find a free port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	cacheName := uuid.NewString()
+	require.NoError(t, caches.AddCache(cacheName))
+
+	oldAddress, oldCache, oldMode := config.RESPAddress, config.RESPDefaultCache, config.RESPPatternMode
+	config.RESPAddress, config.RESPDefaultCache, config.RESPPatternMode = addr, cacheName, patternMode
+
+	srv := resp.NewServer()
+	require.NoError(t, srv.Start())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+		_ = caches.DeleteCache(cacheName)
+		config.RESPAddress, config.RESPDefaultCache, config.RESPPatternMode = oldAddress, oldCache, oldMode
+	})
+
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: 1})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRESP_RedisPatternMode_Keys(t *testing.T) {
+	client := setupLocalRESPClient(t, "redis")
+	ctx := context.Background()
+
+	require.NoError(t, client.HSet(ctx, "user:1", "name", "alice", "age", "30").Err())
+	require.NoError(t, client.Set(ctx, "user:2", "bob", 0).Err())
+	require.NoError(t, client.Set(ctx, "counter", "5", 0).Err())
+	require.NoError(t, client.RPush(ctx, "queue", "a", "b").Err())
+	require.NoError(t, client.Set(ctx, "app:name", "demo", 0).Err())
+	require.NoError(t, client.Set(ctx, "app:db:host", "localhost", 0).Err())
+	require.NoError(t, client.Set(ctx, "app:db:port", "5432", 0).Err())
+
+	keys, err := client.Keys(ctx, "*").Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user:1", "user:2", "counter", "queue", "app:name", "app:db"}, keys)
+
+	// Hashes are keys, not their fields
+	keys, err = client.Keys(ctx, "user:*").Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user:1", "user:2"}, keys)
+
+	// "*" and "?" cross ":"
+	keys, err = client.Keys(ctx, "a*e").Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"app:name"}, keys)
+
+	keys, err = client.Keys(ctx, "user:?").Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user:1", "user:2"}, keys)
+
+	// Every key listed agrees with TYPE and EXISTS
+	types := map[string]string{}
+	for _, key := range []string{"user:1", "user:2", "counter", "queue", "app:name", "app:db"} {
+		typ, err := client.Type(ctx, key).Result()
+		require.NoError(t, err)
+		types[key] = typ
+
+		n, err := client.Exists(ctx, key).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n, key)
+	}
+	assert.Equal(t, map[string]string{
+		"user:1":   "hash",
+		"user:2":   "string",
+		"counter":  "string",
+		"queue":    "list",
+		"app:name": "string",
+		"app:db":   "hash",
+	}, types)
+}
+
+func TestRESP_ScanTypeHash(t *testing.T) {
+	for _, mode := range []string{"path", "redis"} {
+		t.Run(mode, func(t *testing.T) {
+			client := setupLocalRESPClient(t, mode)
+			ctx := context.Background()
+
+			require.NoError(t, client.HSet(ctx, "profile", "name", "alice").Err())
+			require.NoError(t, client.HSet(ctx, "user:1", "name", "bob").Err())
+			require.NoError(t, client.Set(ctx, "user:2", "carol", 0).Err())
+			require.NoError(t, client.Set(ctx, "plain", "x", 0).Err())
+
+			keys, _, err := client.ScanType(ctx, 0, "*", 100, "hash").Result()
+			require.NoError(t, err)
+			if mode == "redis" {
+				assert.ElementsMatch(t, []string{"profile", "user:1"}, keys)
+			} else {
+				// Patterns match one segment, and "user" is a map
+				assert.ElementsMatch(t, []string{"profile", "user"}, keys)
+			}
+
+			keys, _, err = client.ScanType(ctx, 0, "user:*", 100, "hash").Result()
+			require.NoError(t, err)
+			assert.Equal(t, []string{"user:1"}, keys)
+
+			keys, _, err = client.ScanType(ctx, 0, "user:*", 100, "string").Result()
+			require.NoError(t, err)
+			assert.Equal(t, []string{"user:2"}, keys)
+		})
+	}
+}
+
+func TestRESP_ScanPaging(t *testing.T) {
+	for _, mode := range []string{"path", "redis"} {
+		t.Run(mode, func(t *testing.T) {
+			client := setupLocalRESPClient(t, mode)
+			ctx := context.Background()
+
+			var want []string
+			for i := range 30 {
+				key := fmt.Sprintf("key%02d", i)
+				require.NoError(t, client.Set(ctx, key, "v", 0).Err())
+				want = append(want, key)
+			}
+
+			var got []string
+			var cursor uint64
+			for {
+				keys, next, err := client.Scan(ctx, cursor, "key*", 7).Result()
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(keys), 7)
+				got = append(got, keys...)
+				if cursor = next; cursor == 0 {
+					break
+				}
+			}
+			assert.Equal(t, want, got)
+
+			err := client.Scan(ctx, 12345, "*", 10).Err()
+			assert.ErrorContains(t, err, "invalid cursor")
+		})
+	}
+}
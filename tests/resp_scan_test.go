@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRESP_Scan(t *testing.T) {
+	client := setupRESPClient(t)
+	defer client.Close()
+	ctx := context.Background()
+	defer cleanupKeys(client)
+
+	for i := 0; i < 30; i++ {
+		require.NoError(t, client.Set(ctx, fmt.Sprintf("scan:%d", i), i, 0).Err())
+	}
+	require.NoError(t, client.RPush(ctx, "scanlist", "a").Err())
+
+	var seen []string
+	cursor := uint64(0)
+	for {
+		keys, next, err := client.Scan(ctx, cursor, "scan:*", 7).Result()
+		require.NoError(t, err)
+		seen = append(seen, keys...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	assert.Len(t, seen, 30)
+	assert.Contains(t, seen, "scan:0")
+
+	keys, _, err := client.ScanType(ctx, 0, "*", 100, "list").Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"scanlist"}, keys)
+}
+
+func TestRESP_HScan(t *testing.T) {
+	client := setupRESPClient(t)
+	defer client.Close()
+	ctx := context.Background()
+	defer cleanupKeys(client)
+
+	require.NoError(t, client.HSet(ctx, "hscan", "f1", "a", "f2", "b", "g1", "c").Err())
+
+	fields, next, err := client.HScan(ctx, "hscan", 0, "f*", 100).Result()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), next)
+	assert.Len(t, fields, 4)
+	assert.Contains(t, fields, "f1")
+	assert.NotContains(t, fields, "g1")
+
+	// Missing keys scan as empty
+	fields, next, err = client.HScan(ctx, "nohash", 0, "", 10).Result()
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+	assert.Equal(t, uint64(0), next)
+}
+
+func TestRESP_SScan(t *testing.T) {
+	client := setupRESPClient(t)
+	defer client.Close()
+	ctx := context.Background()
+	defer cleanupKeys(client)
+
+	// Sets are objects whose keys are the members
+	require.NoError(t, client.HSet(ctx, "tags", "red", "true", "blue", "true").Err())
+
+	members, next, err := client.SScan(ctx, "tags", 0, "", 10).Result()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), next)
+	assert.ElementsMatch(t, []string{"red", "blue"}, members)
+}